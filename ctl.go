@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	client "github.com/mpoegel/mahogany/pkg/client"
+)
+
+const ctlUsage = `usage: mahogany ctl [-server URL] [-token TOKEN] [-o table|json] <command> [args]
+
+commands:
+  ps                          list containers
+  logs [-f] [-n LINES] <id>   print the logs of a container
+  start|stop|restart <id>     start, stop or restart a container
+  devices                     list devices
+  push-release <pkg> [tag]    install a github release of a package, the latest if no tag is given
+  registry ls [repository]    list repositories, or the tags of a repository
+  registry rm <repo>:<tag>    delete an image from the registry
+
+The server and token default to MAHOGANY_URL and MAHOGANY_TOKEN.`
+
+// ctl holds what every ctl command needs
+type ctl struct {
+	client *client.Client
+	output string
+}
+
+func ctlCommand(args []string) {
+	fs := flag.NewFlagSet("ctl", flag.ExitOnError)
+	fs.Usage = func() { fmt.Fprintln(os.Stderr, ctlUsage) }
+	server := fs.String("server", envOr("MAHOGANY_URL", "http://localhost:9090"), "address of the mahogany server")
+	token := fs.String("token", os.Getenv("MAHOGANY_TOKEN"), "API token from the settings page")
+	output := fs.String("o", "table", "output format: table or json")
+	if err := fs.Parse(args); err != nil {
+		ctlExit(err)
+	}
+	if fs.NArg() < 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if *output != "table" && *output != "json" {
+		ctlExit(fmt.Errorf("unknown output format %q, expected table or json", *output))
+	}
+	if len(*token) == 0 {
+		ctlExit(errors.New("missing API token, set -token or MAHOGANY_TOKEN"))
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	c := &ctl{
+		client: client.NewClient(*server, *token),
+		output: *output,
+	}
+
+	cmd, cmdArgs := fs.Arg(0), fs.Args()[1:]
+	var err error
+	switch cmd {
+	case "ps":
+		err = c.ps(ctx)
+	case "logs":
+		err = c.logs(ctx, cmdArgs)
+	case "start", "stop", "restart":
+		err = c.containerAction(ctx, cmd, cmdArgs)
+	case "devices":
+		err = c.devices(ctx)
+	case "push-release":
+		err = c.pushRelease(ctx, cmdArgs)
+	case "registry":
+		err = c.registry(ctx, cmdArgs)
+	default:
+		fs.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		ctlExit(err)
+	}
+}
+
+func (c *ctl) ps(ctx context.Context) error {
+	containers, err := c.client.ListContainers(ctx)
+	if err != nil {
+		return err
+	}
+	if c.output == "json" {
+		return printJSON(containers)
+	}
+	rows := make([][]string, len(containers))
+	for i, ctr := range containers {
+		name := ""
+		if len(ctr.Names) > 0 {
+			name = strings.TrimPrefix(ctr.Names[0], "/")
+		}
+		rows[i] = []string{shortID(ctr.ID), name, ctr.Image, ctr.State, ctr.Status}
+	}
+	return printTable([]string{"CONTAINER ID", "NAME", "IMAGE", "STATE", "STATUS"}, rows)
+}
+
+func (c *ctl) logs(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("ctl logs", flag.ExitOnError)
+	follow := fs.Bool("f", false, "keep printing new lines")
+	tail := fs.Int("n", 0, "only print the last lines")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: mahogany ctl logs [-f] [-n LINES] <container>")
+	}
+	logs, err := c.client.ContainerLogs(ctx, fs.Arg(0), *follow, *tail)
+	if err != nil {
+		return err
+	}
+	defer logs.Close()
+	if _, err = io.Copy(os.Stdout, logs); err != nil && ctx.Err() == nil {
+		return err
+	}
+	return nil
+}
+
+func (c *ctl) containerAction(ctx context.Context, action string, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: mahogany ctl %s <container>", action)
+	}
+	result, err := c.client.ContainerAction(ctx, args[0], action)
+	if err != nil {
+		return err
+	}
+	if c.output == "json" {
+		return printJSON(result)
+	}
+	fmt.Println(args[0])
+	return nil
+}
+
+func (c *ctl) devices(ctx context.Context) error {
+	devices, err := c.client.ListDevices(ctx)
+	if err != nil {
+		return err
+	}
+	if c.output == "json" {
+		return printJSON(devices)
+	}
+	rows := make([][]string, len(devices))
+	for i, device := range devices {
+		rows[i] = []string{device.Id, device.Hostname, strings.Join(device.Addresses, ","), device.OS, formatLastSeen(device.LastSeen)}
+	}
+	return printTable([]string{"ID", "HOSTNAME", "ADDRESSES", "OS", "LAST SEEN"}, rows)
+}
+
+func (c *ctl) pushRelease(ctx context.Context, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return errors.New("usage: mahogany ctl push-release <package> [tag]")
+	}
+	tag := ""
+	if len(args) == 2 {
+		tag = args[1]
+	}
+	result, err := c.client.PushRelease(ctx, args[0], tag)
+	if err != nil {
+		return err
+	}
+	if c.output == "json" {
+		return printJSON(result)
+	}
+	fmt.Println(result.Message)
+	return nil
+}
+
+func (c *ctl) registry(ctx context.Context, args []string) error {
+	if len(args) < 1 {
+		return errors.New("usage: mahogany ctl registry ls [repository] | rm <repository>:<tag>")
+	}
+	switch args[0] {
+	case "ls":
+		if len(args) == 1 {
+			catalog, err := c.client.ListRepositories(ctx)
+			if err != nil {
+				return err
+			}
+			if c.output == "json" {
+				return printJSON(catalog)
+			}
+			rows := make([][]string, len(catalog.Repositories))
+			for i, repository := range catalog.Repositories {
+				rows[i] = []string{repository}
+			}
+			return printTable([]string{"REPOSITORY"}, rows)
+		}
+		tags, err := c.client.ListTags(ctx, args[1])
+		if err != nil {
+			return err
+		}
+		if c.output == "json" {
+			return printJSON(tags)
+		}
+		rows := make([][]string, len(tags.Tags))
+		for i, tag := range tags.Tags {
+			rows[i] = []string{tags.Name, tag}
+		}
+		return printTable([]string{"REPOSITORY", "TAG"}, rows)
+	case "rm":
+		if len(args) != 2 {
+			return errors.New("usage: mahogany ctl registry rm <repository>:<tag>")
+		}
+		repository, tag, ok := strings.Cut(args[1], ":")
+		if !ok || len(repository) == 0 || len(tag) == 0 {
+			return fmt.Errorf("invalid image %q, expected <repository>:<tag>", args[1])
+		}
+		if err := c.client.DeleteImage(ctx, repository, tag); err != nil {
+			return err
+		}
+		if c.output == "json" {
+			return printJSON(client.Result{OK: true})
+		}
+		fmt.Println(args[1])
+		return nil
+	}
+	return fmt.Errorf("unknown registry command %q, expected ls or rm", args[0])
+}
+
+func printJSON(v any) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func printTable(header []string, rows [][]string) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
+
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+func formatLastSeen(lastSeen string) string {
+	t, err := time.Parse(time.RFC3339, lastSeen)
+	if err != nil {
+		return lastSeen
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+func envOr(name, fallback string) string {
+	if value, ok := os.LookupEnv(name); ok {
+		return value
+	}
+	return fallback
+}
+
+func ctlExit(err error) {
+	fmt.Fprintln(os.Stderr, "error:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,26 @@
+// Package dbtest opens databases for tests
+package dbtest
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	db "github.com/mpoegel/mahogany/internal/db"
+	_ "modernc.org/sqlite"
+)
+
+// New opens a migrated database in a temporary directory, which is closed when the test ends
+func New(t testing.TB) *sql.DB {
+	t.Helper()
+	dbConn, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "mahogany.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { dbConn.Close() })
+	if err = db.Migrate(context.Background(), dbConn); err != nil {
+		t.Fatal(err)
+	}
+	return dbConn
+}
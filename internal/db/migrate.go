@@ -0,0 +1,61 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"sort"
+)
+
+// migrations are applied in the order of their file names, and the number of those applied is kept in the
+// user_version of the database. Databases from before migrations are at version 0 and already have the tables of
+// the first one, which only creates what is missing.
+//
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// Migrate applies the migrations that the database does not have yet, each one in its own transaction
+func Migrate(ctx context.Context, dbConn *sql.DB) error {
+	names, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	var version int
+	if err = dbConn.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+	if version > len(names) {
+		return fmt.Errorf("database is at version %d, which is newer than the %d migrations known", version, len(names))
+	}
+	for i, name := range names[version:] {
+		ddl, err := migrations.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		if err = migrate(ctx, dbConn, string(ddl), version+i+1); err != nil {
+			return fmt.Errorf("migration %s failed: %w", name, err)
+		}
+		slog.Info("applied database migration", "name", name)
+	}
+	return nil
+}
+
+func migrate(ctx context.Context, dbConn *sql.DB, ddl string, version int) error {
+	tx, err := dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err = tx.ExecContext(ctx, ddl); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", version)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
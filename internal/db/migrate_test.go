@@ -0,0 +1,135 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dbConn, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "mahogany.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { dbConn.Close() })
+	return dbConn
+}
+
+func userVersion(t *testing.T, dbConn *sql.DB) int {
+	t.Helper()
+	var version int
+	if err := dbConn.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		t.Fatal(err)
+	}
+	return version
+}
+
+// settingValues returns the value of every setting and fails if one is there more than once
+func settingValues(t *testing.T, query *Queries) map[string]string {
+	t.Helper()
+	settings, err := query.ListSettings(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := map[string]string{}
+	for _, setting := range settings {
+		if _, ok := values[setting.Name]; ok {
+			t.Errorf("setting %s is there more than once", setting.Name)
+		}
+		values[setting.Name] = setting.Value
+	}
+	return values
+}
+
+func TestMigrate(t *testing.T) {
+	names, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbConn := openTestDB(t)
+	ctx := context.Background()
+
+	for range 2 {
+		if err = Migrate(ctx, dbConn); err != nil {
+			t.Fatal(err)
+		}
+		if got := userVersion(t, dbConn); got != len(names) {
+			t.Errorf("got version %d, want %d", got, len(names))
+		}
+	}
+	values := settingValues(t, New(dbConn))
+	for name, want := range map[string]string{"RegistryAddr": "localhost:5000", "GithubWebhookSecret": "", "NotifyRelease": ""} {
+		if got, ok := values[name]; !ok || got != want {
+			t.Errorf("got setting %s=%q, want %q", name, got, want)
+		}
+	}
+
+	if _, err = dbConn.Exec("PRAGMA user_version = 1000"); err != nil {
+		t.Fatal(err)
+	}
+	if err = Migrate(ctx, dbConn); err == nil || !strings.Contains(err.Error(), "newer") {
+		t.Errorf("got error %v migrating a newer database", err)
+	}
+}
+
+func TestMigrateExistingDatabase(t *testing.T) {
+	dbConn := openTestDB(t)
+	ctx := context.Background()
+
+	// a database from before migrations, whose settings were added twice by applying the schema again
+	baseline, err := migrations.ReadFile("migrations/0001_baseline.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = dbConn.Exec(string(baseline)); err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		_, err = dbConn.Exec(`INSERT INTO settings (name, value) VALUES ("RegistryAddr", "localhost:5000"), ("TailnetName", "")`)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err = dbConn.Exec(`
+UPDATE settings SET value = "registry:5000" WHERE name = "RegistryAddr";
+INSERT INTO devices (hostname) VALUES ("host");
+INSERT INTO packages (name, install_cmd, update_cmd) VALUES ("tool", "true", "true");
+INSERT INTO assets (device_id, package_id, source_url, version) VALUES (1, 1, "https://example.com/tool", "v1");
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = Migrate(ctx, dbConn); err != nil {
+		t.Fatal(err)
+	}
+	query := New(dbConn)
+	values := settingValues(t, query)
+	if values["RegistryAddr"] != "registry:5000" {
+		t.Errorf("existing setting was overwritten with %q", values["RegistryAddr"])
+	}
+	if _, ok := values["NotifyRelease"]; !ok {
+		t.Error("new setting was not added")
+	}
+
+	assets, err := query.ListAssets(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(assets) != 1 || assets[0].Version.String != "v1" || assets[0].Name != "" || assets[0].IsInstalled || assets[0].InstalledAt != 0 {
+		t.Errorf("got migrated assets %+v", assets)
+	}
+	asset, err := query.AddAsset(ctx, AddAssetParams{DeviceID: 1, PackageID: 1, Name: "tool.deb", IsInstalled: true, DurationMs: 5, InstalledAt: 10, IsRollback: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.Name != "tool.deb" || !asset.IsInstalled || asset.DurationMs != 5 || asset.InstalledAt != 10 || !asset.IsRollback {
+		t.Errorf("got added asset %+v", asset)
+	}
+}
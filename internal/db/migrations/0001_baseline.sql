@@ -1,4 +1,5 @@
-CREATE TABLE devices (
+-- the tables as the first release created them, which databases from before migrations already have
+CREATE TABLE IF NOT EXISTS devices (
     id       INTEGER PRIMARY KEY,
     hostname text NOT NULL UNIQUE,
 
@@ -6,7 +7,7 @@ CREATE TABLE devices (
     agent_last_seen     INTEGER
 );
 
-CREATE TABLE packages (
+CREATE TABLE IF NOT EXISTS packages (
     id          INTEGER PRIMARY KEY,
     name        text NOT NULL UNIQUE,
     install_cmd text NOT NULL,
@@ -14,7 +15,7 @@ CREATE TABLE packages (
     remove_cmd  text
 );
 
-CREATE TABLE assets (
+CREATE TABLE IF NOT EXISTS assets (
     id         INTEGER PRIMARY KEY,
     device_id  INTEGER NOT NULL,
     package_id INTEGER NOT NULL,
@@ -25,27 +26,18 @@ CREATE TABLE assets (
     FOREIGN KEY(package_id) REFERENCES packages(id)
 );
 
-CREATE TABLE settings (
+CREATE TABLE IF NOT EXISTS settings (
     id    INTEGER PRIMARY KEY,
     name  text NOT NULL,
     value text NOT NULL
 );
 
-INSERT INTO settings (name, value)
-VALUES ("WatchtowerAddr", "localhost:8080"),
-       ("WatchtowerToken", ""),
-       ("WatchtowerTimeout", "3s"),
-       ("RegistryAddr", "localhost:5000"),
-       ("RegistryTimeout", "3s"),
-       ("TailscaleApiKey", ""),
-       ("TailnetName", "");
-
-CREATE TABLE watched_services (
+CREATE TABLE IF NOT EXISTS watched_services (
     id      INTEGER PRIMARY KEY,
     name    text NOT NULL UNIQUE
 );
 
-CREATE TABLE tracked_services (
+CREATE TABLE IF NOT EXISTS tracked_services (
     id              INTEGER PRIMARY KEY,
     device_id       INTEGER NOT NULL,
     name            text    NOT NULL,
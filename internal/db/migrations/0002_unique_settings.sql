@@ -0,0 +1,14 @@
+-- applying the old schema more than once added every setting again, keep the first of each
+DELETE FROM settings
+WHERE id NOT IN (SELECT MIN(id) FROM settings GROUP BY name);
+
+CREATE UNIQUE INDEX settings_name ON settings(name);
+
+INSERT OR IGNORE INTO settings (name, value)
+VALUES ('WatchtowerAddr', 'localhost:8080'),
+       ('WatchtowerToken', ''),
+       ('WatchtowerTimeout', '3s'),
+       ('RegistryAddr', 'localhost:5000'),
+       ('RegistryTimeout', '3s'),
+       ('TailscaleApiKey', ''),
+       ('TailnetName', '');
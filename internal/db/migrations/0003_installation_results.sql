@@ -0,0 +1,5 @@
+ALTER TABLE assets ADD COLUMN name         text    NOT NULL DEFAULT '';
+ALTER TABLE assets ADD COLUMN is_installed boolean NOT NULL DEFAULT false;
+ALTER TABLE assets ADD COLUMN output       text;
+ALTER TABLE assets ADD COLUMN duration_ms  INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE assets ADD COLUMN installed_at INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,9 @@
+CREATE TABLE github_deliveries (
+    id          INTEGER PRIMARY KEY,
+    delivery_id text    NOT NULL UNIQUE,
+    event       text    NOT NULL,
+    received_at INTEGER NOT NULL
+);
+
+INSERT OR IGNORE INTO settings (name, value)
+VALUES ('GithubWebhookSecret', '');
@@ -0,0 +1 @@
+ALTER TABLE assets ADD COLUMN is_rollback boolean NOT NULL DEFAULT false;
@@ -0,0 +1,12 @@
+CREATE TABLE host_metrics (
+    id          INTEGER PRIMARY KEY,
+    device_id   INTEGER NOT NULL,
+    recorded_at INTEGER NOT NULL,
+    cpu_usage   REAL    NOT NULL,
+    mem_usage   REAL    NOT NULL,
+    disk_usage  REAL    NOT NULL,
+
+    FOREIGN KEY(device_id) REFERENCES devices(id)
+);
+
+CREATE INDEX host_metrics_device_recorded_at ON host_metrics(device_id, recorded_at);
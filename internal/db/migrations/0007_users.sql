@@ -0,0 +1,18 @@
+CREATE TABLE users (
+    id            INTEGER PRIMARY KEY,
+    username      text    NOT NULL UNIQUE,
+    password_hash text    NOT NULL,
+    role          text    NOT NULL,
+    created_at    INTEGER NOT NULL
+);
+
+CREATE TABLE sessions (
+    id         INTEGER PRIMARY KEY,
+    token_hash text    NOT NULL UNIQUE,
+    user_id    INTEGER NOT NULL,
+    csrf_token text    NOT NULL,
+    created_at INTEGER NOT NULL,
+    expires_at INTEGER NOT NULL,
+
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
@@ -0,0 +1,17 @@
+CREATE TABLE join_tokens (
+    id         INTEGER PRIMARY KEY,
+    token_hash text    NOT NULL UNIQUE,
+    hostname   text    NOT NULL,
+    created_at INTEGER NOT NULL,
+    expires_at INTEGER NOT NULL,
+    used_at    INTEGER
+);
+
+CREATE TABLE agent_certificates (
+    id         INTEGER PRIMARY KEY,
+    serial     text    NOT NULL UNIQUE,
+    hostname   text    NOT NULL,
+    issued_at  INTEGER NOT NULL,
+    expires_at INTEGER NOT NULL,
+    revoked_at INTEGER
+);
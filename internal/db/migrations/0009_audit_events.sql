@@ -0,0 +1,13 @@
+CREATE TABLE audit_events (
+    id         INTEGER PRIMARY KEY,
+    created_at INTEGER NOT NULL,
+    actor      text    NOT NULL,
+    action     text    NOT NULL,
+    target     text    NOT NULL,
+    params     text    NOT NULL,
+    is_success BOOLEAN NOT NULL,
+    error      text
+);
+
+CREATE INDEX audit_events_actor ON audit_events(actor);
+CREATE INDEX audit_events_action ON audit_events(action);
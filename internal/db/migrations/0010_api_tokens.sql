@@ -0,0 +1,13 @@
+CREATE TABLE api_tokens (
+    id           INTEGER PRIMARY KEY,
+    name         text    NOT NULL,
+    token_hash   text    NOT NULL UNIQUE,
+    user_id      INTEGER NOT NULL,
+    scope        text    NOT NULL,
+    created_at   INTEGER NOT NULL,
+    expires_at   INTEGER,
+    last_used_at INTEGER,
+    revoked_at   INTEGER,
+
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
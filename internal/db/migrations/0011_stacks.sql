@@ -0,0 +1,9 @@
+CREATE TABLE stacks (
+    id               INTEGER PRIMARY KEY,
+    name             text    NOT NULL UNIQUE,
+    compose          text    NOT NULL,
+    deployed_compose text,
+    created_at       INTEGER NOT NULL,
+    updated_at       INTEGER NOT NULL,
+    deployed_at      INTEGER
+);
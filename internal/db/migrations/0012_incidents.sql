@@ -0,0 +1,16 @@
+CREATE TABLE incidents (
+    id             INTEGER PRIMARY KEY,
+    kind           text    NOT NULL,
+    container_id   text    NOT NULL,
+    container_name text    NOT NULL,
+    image          text    NOT NULL,
+    message        text    NOT NULL,
+    occurrences    INTEGER NOT NULL,
+    started_at     INTEGER NOT NULL,
+    last_seen_at   INTEGER NOT NULL,
+    resolved_at    INTEGER,
+    resolved_by    text
+);
+
+-- a container has at most one open incident of each kind, which later occurrences are added to
+CREATE UNIQUE INDEX incidents_open ON incidents(container_name, kind) WHERE resolved_at IS NULL;
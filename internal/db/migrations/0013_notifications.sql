@@ -0,0 +1,15 @@
+INSERT OR IGNORE INTO settings (name, value)
+VALUES ('NotifyWebhookURL', ''),
+       ('NotifyNtfyURL', ''),
+       ('NotifyNtfyToken', ''),
+       ('NotifyGotifyURL', ''),
+       ('NotifyGotifyToken', ''),
+       ('NotifySlackURL', ''),
+       ('NotifySMTPAddr', ''),
+       ('NotifySMTPUsername', ''),
+       ('NotifySMTPPassword', ''),
+       ('NotifySMTPFrom', ''),
+       ('NotifySMTPTo', ''),
+       ('NotifyIncident', ''),
+       ('NotifyAgentDisconnect', ''),
+       ('NotifyRelease', '');
@@ -0,0 +1,19 @@
+-- a reading the agent failed to take is stored as NULL instead of 0
+CREATE TABLE host_metrics_new (
+    id          INTEGER PRIMARY KEY,
+    device_id   INTEGER NOT NULL,
+    recorded_at INTEGER NOT NULL,
+    cpu_usage   REAL,
+    mem_usage   REAL,
+    disk_usage  REAL,
+
+    FOREIGN KEY(device_id) REFERENCES devices(id)
+);
+
+INSERT INTO host_metrics_new (id, device_id, recorded_at, cpu_usage, mem_usage, disk_usage)
+SELECT id, device_id, recorded_at, cpu_usage, mem_usage, disk_usage FROM host_metrics;
+
+DROP TABLE host_metrics;
+ALTER TABLE host_metrics_new RENAME TO host_metrics;
+
+CREATE INDEX host_metrics_device_recorded_at ON host_metrics(device_id, recorded_at);
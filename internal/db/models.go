@@ -8,12 +8,50 @@ import (
 	"database/sql"
 )
 
+type AgentCertificate struct {
+	ID        int64
+	Serial    string
+	Hostname  string
+	IssuedAt  int64
+	ExpiresAt int64
+	RevokedAt sql.NullInt64
+}
+
+type ApiToken struct {
+	ID         int64
+	Name       string
+	TokenHash  string
+	UserID     int64
+	Scope      string
+	CreatedAt  int64
+	ExpiresAt  sql.NullInt64
+	LastUsedAt sql.NullInt64
+	RevokedAt  sql.NullInt64
+}
+
 type Asset struct {
+	ID          int64
+	DeviceID    int64
+	PackageID   int64
+	SourceUrl   sql.NullString
+	Version     sql.NullString
+	Name        string
+	IsInstalled bool
+	Output      sql.NullString
+	DurationMs  int64
+	InstalledAt int64
+	IsRollback  bool
+}
+
+type AuditEvent struct {
 	ID        int64
-	DeviceID  int64
-	PackageID int64
-	SourceUrl sql.NullString
-	Version   sql.NullString
+	CreatedAt int64
+	Actor     string
+	Action    string
+	Target    string
+	Params    string
+	IsSuccess bool
+	Error     sql.NullString
 }
 
 type Device struct {
@@ -23,6 +61,45 @@ type Device struct {
 	AgentLastSeen     sql.NullInt64
 }
 
+type GithubDelivery struct {
+	ID         int64
+	DeliveryID string
+	Event      string
+	ReceivedAt int64
+}
+
+type HostMetric struct {
+	ID         int64
+	DeviceID   int64
+	RecordedAt int64
+	CpuUsage   sql.NullFloat64
+	MemUsage   sql.NullFloat64
+	DiskUsage  sql.NullFloat64
+}
+
+type Incident struct {
+	ID            int64
+	Kind          string
+	ContainerID   string
+	ContainerName string
+	Image         string
+	Message       string
+	Occurrences   int64
+	StartedAt     int64
+	LastSeenAt    int64
+	ResolvedAt    sql.NullInt64
+	ResolvedBy    sql.NullString
+}
+
+type JoinToken struct {
+	ID        int64
+	TokenHash string
+	Hostname  string
+	CreatedAt int64
+	ExpiresAt int64
+	UsedAt    sql.NullInt64
+}
+
 type Package struct {
 	ID         int64
 	Name       string
@@ -37,6 +114,25 @@ type Setting struct {
 	Value string
 }
 
+type Session struct {
+	ID        int64
+	TokenHash string
+	UserID    int64
+	CsrfToken string
+	CreatedAt int64
+	ExpiresAt int64
+}
+
+type Stack struct {
+	ID              int64
+	Name            string
+	Compose         string
+	DeployedCompose sql.NullString
+	CreatedAt       int64
+	UpdatedAt       int64
+	DeployedAt      sql.NullInt64
+}
+
 type TrackedService struct {
 	ID             int64
 	DeviceID       int64
@@ -47,6 +143,14 @@ type TrackedService struct {
 	ContainerImage sql.NullString
 }
 
+type User struct {
+	ID           int64
+	Username     string
+	PasswordHash string
+	Role         string
+	CreatedAt    int64
+}
+
 type WatchedService struct {
 	ID   int64
 	Name string
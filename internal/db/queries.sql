@@ -14,6 +14,10 @@ RETURNING *;
 SELECT * FROM devices
 WHERE hostname = ?;
 
+-- name: GetDeviceByID :one
+SELECT * FROM devices
+WHERE id = ?;
+
 -- name: UpdateDevice :exec
 UPDATE devices
 SET tailscale_last_seen = ?,
@@ -39,6 +43,10 @@ INSERT INTO packages (
 )
 RETURNING *;
 
+-- name: GetPackageByName :one
+SELECT * FROM packages
+WHERE name = ?;
+
 -- name: UpdatePackage :exec
 UPDATE packages
 set name = ?,
@@ -65,11 +73,37 @@ SELECT * FROM assets
 WHERE package_id = ?
 ORDER BY id;
 
+-- name: ListInstalledAssetsOnDevice :many
+-- the assets of one installation report share its version and time, and a release is only installed if all of them are
+SELECT packages.name AS package_name, assets.version, assets.installed_at
+FROM assets
+JOIN packages ON packages.id = assets.package_id
+WHERE assets.id IN (
+  SELECT MAX(latest.id) FROM assets AS latest
+  WHERE latest.device_id = ? AND NOT EXISTS (
+    SELECT 1 FROM assets AS failed
+    WHERE failed.device_id = latest.device_id AND failed.package_id = latest.package_id
+      AND failed.version IS latest.version AND failed.installed_at = latest.installed_at
+      AND failed.is_rollback = latest.is_rollback AND failed.is_installed = false
+  )
+  GROUP BY latest.package_id
+)
+ORDER BY packages.name;
+
+-- name: ListAssetHistoryOnDevice :many
+SELECT packages.name AS package_name, assets.name, assets.version, assets.is_installed, assets.is_rollback,
+       assets.output, assets.duration_ms, assets.installed_at
+FROM assets
+JOIN packages ON packages.id = assets.package_id
+WHERE assets.device_id = ?
+ORDER BY assets.id DESC
+LIMIT 50;
+
 -- name: AddAsset :one
 INSERT INTO assets (
-  device_id, package_id, source_url, version
+  device_id, package_id, name, source_url, version, is_installed, output, duration_ms, installed_at, is_rollback
 ) VALUES (
-  ?, ?, ?, ?
+  ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
 )
 RETURNING *;
 
@@ -97,7 +131,15 @@ INSERT INTO watched_services (name) VALUES (?);
 DELETE FROM watched_services WHERE name = ?;
 
 -- name: ListTrackedServices :many
-SELECT * FROM tracked_services ORDER BY (device_id, name);
+SELECT tracked_services.*, devices.hostname FROM tracked_services
+JOIN devices ON devices.id = tracked_services.device_id
+ORDER BY devices.hostname, tracked_services.name;
+
+-- name: ListTrackedServicesOnDevice :many
+SELECT * FROM tracked_services WHERE device_id = ? ORDER BY name;
+
+-- name: GetTrackedService :one
+SELECT * FROM tracked_services WHERE id = ?;
 
 -- name: GetTrackedServiceID :one
 SELECT id FROM tracked_services WHERE name=? and device_id=?;
@@ -116,5 +158,239 @@ DELETE FROM tracked_services WHERE id = ?;
 -- name: UpdateTrackedService :exec
 UPDATE tracked_services
 set status = ?,
-    last_updated = ?
+    last_updated = ?,
+    container_id = ?,
+    container_image = ?
 WHERE id = ?;
+
+-- name: AddGithubDelivery :execrows
+INSERT INTO github_deliveries (
+  delivery_id, event, received_at
+) VALUES (
+  ?, ?, ?
+)
+ON CONFLICT (delivery_id) DO NOTHING;
+
+-- name: AddHostMetrics :exec
+INSERT INTO host_metrics (
+  device_id, recorded_at, cpu_usage, mem_usage, disk_usage
+) VALUES (
+  ?, ?, ?, ?, ?
+);
+
+-- name: ListHostMetricsOnDevice :many
+SELECT * FROM host_metrics
+WHERE device_id = ? AND recorded_at >= ?
+ORDER BY recorded_at;
+
+-- name: ListHostMetricsSince :many
+SELECT devices.hostname, host_metrics.recorded_at, host_metrics.cpu_usage, host_metrics.mem_usage, host_metrics.disk_usage
+FROM host_metrics
+JOIN devices ON devices.id = host_metrics.device_id
+WHERE host_metrics.recorded_at >= ?
+ORDER BY devices.hostname, host_metrics.recorded_at;
+
+-- name: DeleteHostMetricsBefore :exec
+DELETE FROM host_metrics WHERE recorded_at < ?;
+
+-- name: AddUser :one
+INSERT INTO users (
+  username, password_hash, role, created_at
+) VALUES (
+  ?, ?, ?, ?
+)
+RETURNING *;
+
+-- name: GetUserByUsername :one
+SELECT * FROM users WHERE username = ?;
+
+-- name: CountUsers :one
+SELECT COUNT(*) FROM users;
+
+-- name: AddSession :exec
+INSERT INTO sessions (
+  token_hash, user_id, csrf_token, created_at, expires_at
+) VALUES (
+  ?, ?, ?, ?, ?
+);
+
+-- name: GetSession :one
+SELECT sessions.user_id, sessions.csrf_token, sessions.expires_at, users.username, users.role
+FROM sessions
+JOIN users ON users.id = sessions.user_id
+WHERE sessions.token_hash = ?;
+
+-- name: DeleteSession :exec
+DELETE FROM sessions WHERE token_hash = ?;
+
+-- name: DeleteExpiredSessions :exec
+DELETE FROM sessions WHERE expires_at < ?;
+
+-- name: AddApiToken :one
+INSERT INTO api_tokens (
+  name, token_hash, user_id, scope, created_at, expires_at
+) VALUES (
+  ?, ?, ?, ?, ?, ?
+)
+RETURNING id;
+
+-- name: GetApiToken :one
+SELECT api_tokens.id, api_tokens.name, api_tokens.user_id, api_tokens.scope, api_tokens.expires_at,
+  api_tokens.revoked_at, users.username, users.role
+FROM api_tokens
+JOIN users ON users.id = api_tokens.user_id
+WHERE api_tokens.token_hash = ?;
+
+-- name: ListApiTokens :many
+SELECT api_tokens.id, api_tokens.name, api_tokens.scope, api_tokens.created_at, api_tokens.expires_at,
+  api_tokens.last_used_at, api_tokens.revoked_at, users.username
+FROM api_tokens
+JOIN users ON users.id = api_tokens.user_id
+ORDER BY api_tokens.created_at DESC;
+
+-- name: ListApiTokensForUser :many
+SELECT api_tokens.id, api_tokens.name, api_tokens.scope, api_tokens.created_at, api_tokens.expires_at,
+  api_tokens.last_used_at, api_tokens.revoked_at, users.username
+FROM api_tokens
+JOIN users ON users.id = api_tokens.user_id
+WHERE api_tokens.user_id = ?
+ORDER BY api_tokens.created_at DESC;
+
+-- name: UpdateApiTokenLastUsed :exec
+UPDATE api_tokens
+set last_used_at = ?
+WHERE id = ?;
+
+-- name: RevokeApiToken :execrows
+UPDATE api_tokens
+set revoked_at = ?
+WHERE id = ? AND revoked_at IS NULL;
+
+-- name: RevokeUserApiToken :execrows
+UPDATE api_tokens
+set revoked_at = ?
+WHERE id = ? AND user_id = ? AND revoked_at IS NULL;
+
+-- name: AddJoinToken :exec
+INSERT INTO join_tokens (
+  token_hash, hostname, created_at, expires_at
+) VALUES (
+  ?, ?, ?, ?
+);
+
+-- name: UseJoinToken :one
+UPDATE join_tokens
+set used_at = ?
+WHERE token_hash = ? AND used_at IS NULL AND expires_at > ?
+RETURNING hostname;
+
+-- name: AddAgentCertificate :exec
+INSERT INTO agent_certificates (
+  serial, hostname, issued_at, expires_at
+) VALUES (
+  ?, ?, ?, ?
+);
+
+-- name: GetAgentCertificate :one
+SELECT * FROM agent_certificates WHERE serial = ?;
+
+-- name: ListAgentCertificatesForHost :many
+SELECT * FROM agent_certificates WHERE hostname = ? ORDER BY issued_at DESC;
+
+-- name: RevokeAgentCertificate :execrows
+UPDATE agent_certificates
+set revoked_at = ?
+WHERE serial = ? AND revoked_at IS NULL;
+
+-- name: AddAuditEvent :exec
+INSERT INTO audit_events (
+  created_at, actor, action, target, params, is_success, error
+) VALUES (
+  ?, ?, ?, ?, ?, ?, ?
+);
+
+-- name: ListAuditEvents :many
+SELECT * FROM audit_events
+WHERE (sqlc.arg(actor) = '' OR actor = sqlc.arg(actor))
+  AND (sqlc.arg(action) = '' OR action = sqlc.arg(action))
+  AND target LIKE '%' || sqlc.arg(target) || '%'
+  AND (NOT sqlc.arg(failed_only) OR NOT is_success)
+ORDER BY id DESC
+LIMIT sqlc.arg(limit) OFFSET sqlc.arg(offset);
+
+-- name: CountAuditEvents :one
+SELECT COUNT(*) FROM audit_events
+WHERE (sqlc.arg(actor) = '' OR actor = sqlc.arg(actor))
+  AND (sqlc.arg(action) = '' OR action = sqlc.arg(action))
+  AND target LIKE '%' || sqlc.arg(target) || '%'
+  AND (NOT sqlc.arg(failed_only) OR NOT is_success);
+
+-- name: ListAuditActors :many
+SELECT DISTINCT actor FROM audit_events ORDER BY actor;
+
+-- name: ListAuditActions :many
+SELECT DISTINCT action FROM audit_events ORDER BY action;
+
+-- name: ListAllAuditEvents :many
+SELECT * FROM audit_events ORDER BY id;
+
+-- name: ListStacks :many
+SELECT * FROM stacks ORDER BY name;
+
+-- name: GetStack :one
+SELECT * FROM stacks
+WHERE name = ?;
+
+-- name: SaveStack :exec
+INSERT INTO stacks (
+  name, compose, created_at, updated_at
+) VALUES (
+  ?, ?, ?, ?
+)
+ON CONFLICT (name) DO UPDATE SET compose = excluded.compose, updated_at = excluded.updated_at;
+
+-- name: SetStackDeployed :exec
+UPDATE stacks
+set deployed_compose = ?,
+    deployed_at = ?
+WHERE name = ?;
+
+-- name: DeleteStack :execrows
+DELETE FROM stacks
+WHERE name = ?;
+
+-- name: RecordIncident :one
+INSERT INTO incidents (
+  kind, container_id, container_name, image, message, occurrences, started_at, last_seen_at
+) VALUES (
+  sqlc.arg(kind), sqlc.arg(container_id), sqlc.arg(container_name), sqlc.arg(image), sqlc.arg(message), 1,
+  sqlc.arg(seen_at), sqlc.arg(seen_at)
+)
+ON CONFLICT (container_name, kind) WHERE resolved_at IS NULL DO UPDATE
+SET container_id = excluded.container_id,
+    image = excluded.image,
+    message = excluded.message,
+    occurrences = occurrences + 1,
+    last_seen_at = excluded.last_seen_at
+RETURNING *;
+
+-- name: ResolveIncident :execrows
+UPDATE incidents
+set resolved_at = ?,
+    resolved_by = ?
+WHERE id = ? AND resolved_at IS NULL;
+
+-- name: ResolveContainerIncidents :execrows
+UPDATE incidents
+set resolved_at = ?,
+    resolved_by = ?
+WHERE container_name = ? AND kind = ? AND resolved_at IS NULL;
+
+-- name: ListIncidents :many
+SELECT * FROM incidents
+WHERE resolved_at IS NULL OR resolved_at >= ?
+ORDER BY resolved_at IS NOT NULL, last_seen_at DESC;
+
+-- name: CountOpenIncidents :one
+SELECT COUNT(*) FROM incidents
+WHERE resolved_at IS NULL;
@@ -10,28 +10,97 @@ import (
 	"database/sql"
 )
 
+const addAgentCertificate = `-- name: AddAgentCertificate :exec
+INSERT INTO agent_certificates (
+  serial, hostname, issued_at, expires_at
+) VALUES (
+  ?, ?, ?, ?
+)
+`
+
+type AddAgentCertificateParams struct {
+	Serial    string
+	Hostname  string
+	IssuedAt  int64
+	ExpiresAt int64
+}
+
+func (q *Queries) AddAgentCertificate(ctx context.Context, arg AddAgentCertificateParams) error {
+	_, err := q.db.ExecContext(ctx, addAgentCertificate,
+		arg.Serial,
+		arg.Hostname,
+		arg.IssuedAt,
+		arg.ExpiresAt,
+	)
+	return err
+}
+
+const addApiToken = `-- name: AddApiToken :one
+INSERT INTO api_tokens (
+  name, token_hash, user_id, scope, created_at, expires_at
+) VALUES (
+  ?, ?, ?, ?, ?, ?
+)
+RETURNING id
+`
+
+type AddApiTokenParams struct {
+	Name      string
+	TokenHash string
+	UserID    int64
+	Scope     string
+	CreatedAt int64
+	ExpiresAt sql.NullInt64
+}
+
+func (q *Queries) AddApiToken(ctx context.Context, arg AddApiTokenParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, addApiToken,
+		arg.Name,
+		arg.TokenHash,
+		arg.UserID,
+		arg.Scope,
+		arg.CreatedAt,
+		arg.ExpiresAt,
+	)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
 const addAsset = `-- name: AddAsset :one
 INSERT INTO assets (
-  device_id, package_id, source_url, version
+  device_id, package_id, name, source_url, version, is_installed, output, duration_ms, installed_at, is_rollback
 ) VALUES (
-  ?, ?, ?, ?
+  ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
 )
-RETURNING id, device_id, package_id, source_url, version
+RETURNING id, device_id, package_id, source_url, version, name, is_installed, output, duration_ms, installed_at, is_rollback
 `
 
 type AddAssetParams struct {
-	DeviceID  int64
-	PackageID int64
-	SourceUrl sql.NullString
-	Version   sql.NullString
+	DeviceID    int64
+	PackageID   int64
+	Name        string
+	SourceUrl   sql.NullString
+	Version     sql.NullString
+	IsInstalled bool
+	Output      sql.NullString
+	DurationMs  int64
+	InstalledAt int64
+	IsRollback  bool
 }
 
 func (q *Queries) AddAsset(ctx context.Context, arg AddAssetParams) (Asset, error) {
 	row := q.db.QueryRowContext(ctx, addAsset,
 		arg.DeviceID,
 		arg.PackageID,
+		arg.Name,
 		arg.SourceUrl,
 		arg.Version,
+		arg.IsInstalled,
+		arg.Output,
+		arg.DurationMs,
+		arg.InstalledAt,
+		arg.IsRollback,
 	)
 	var i Asset
 	err := row.Scan(
@@ -40,10 +109,47 @@ func (q *Queries) AddAsset(ctx context.Context, arg AddAssetParams) (Asset, erro
 		&i.PackageID,
 		&i.SourceUrl,
 		&i.Version,
+		&i.Name,
+		&i.IsInstalled,
+		&i.Output,
+		&i.DurationMs,
+		&i.InstalledAt,
+		&i.IsRollback,
 	)
 	return i, err
 }
 
+const addAuditEvent = `-- name: AddAuditEvent :exec
+INSERT INTO audit_events (
+  created_at, actor, action, target, params, is_success, error
+) VALUES (
+  ?, ?, ?, ?, ?, ?, ?
+)
+`
+
+type AddAuditEventParams struct {
+	CreatedAt int64
+	Actor     string
+	Action    string
+	Target    string
+	Params    string
+	IsSuccess bool
+	Error     sql.NullString
+}
+
+func (q *Queries) AddAuditEvent(ctx context.Context, arg AddAuditEventParams) error {
+	_, err := q.db.ExecContext(ctx, addAuditEvent,
+		arg.CreatedAt,
+		arg.Actor,
+		arg.Action,
+		arg.Target,
+		arg.Params,
+		arg.IsSuccess,
+		arg.Error,
+	)
+	return err
+}
+
 const addDevice = `-- name: AddDevice :one
 INSERT INTO devices (
   hostname
@@ -65,6 +171,81 @@ func (q *Queries) AddDevice(ctx context.Context, hostname string) (Device, error
 	return i, err
 }
 
+const addGithubDelivery = `-- name: AddGithubDelivery :execrows
+INSERT INTO github_deliveries (
+  delivery_id, event, received_at
+) VALUES (
+  ?, ?, ?
+)
+ON CONFLICT (delivery_id) DO NOTHING
+`
+
+type AddGithubDeliveryParams struct {
+	DeliveryID string
+	Event      string
+	ReceivedAt int64
+}
+
+func (q *Queries) AddGithubDelivery(ctx context.Context, arg AddGithubDeliveryParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, addGithubDelivery, arg.DeliveryID, arg.Event, arg.ReceivedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const addHostMetrics = `-- name: AddHostMetrics :exec
+INSERT INTO host_metrics (
+  device_id, recorded_at, cpu_usage, mem_usage, disk_usage
+) VALUES (
+  ?, ?, ?, ?, ?
+)
+`
+
+type AddHostMetricsParams struct {
+	DeviceID   int64
+	RecordedAt int64
+	CpuUsage   sql.NullFloat64
+	MemUsage   sql.NullFloat64
+	DiskUsage  sql.NullFloat64
+}
+
+func (q *Queries) AddHostMetrics(ctx context.Context, arg AddHostMetricsParams) error {
+	_, err := q.db.ExecContext(ctx, addHostMetrics,
+		arg.DeviceID,
+		arg.RecordedAt,
+		arg.CpuUsage,
+		arg.MemUsage,
+		arg.DiskUsage,
+	)
+	return err
+}
+
+const addJoinToken = `-- name: AddJoinToken :exec
+INSERT INTO join_tokens (
+  token_hash, hostname, created_at, expires_at
+) VALUES (
+  ?, ?, ?, ?
+)
+`
+
+type AddJoinTokenParams struct {
+	TokenHash string
+	Hostname  string
+	CreatedAt int64
+	ExpiresAt int64
+}
+
+func (q *Queries) AddJoinToken(ctx context.Context, arg AddJoinTokenParams) error {
+	_, err := q.db.ExecContext(ctx, addJoinToken,
+		arg.TokenHash,
+		arg.Hostname,
+		arg.CreatedAt,
+		arg.ExpiresAt,
+	)
+	return err
+}
+
 const addPackage = `-- name: AddPackage :one
 INSERT INTO packages (
   name, install_cmd, update_cmd, remove_cmd
@@ -99,6 +280,33 @@ func (q *Queries) AddPackage(ctx context.Context, arg AddPackageParams) (Package
 	return i, err
 }
 
+const addSession = `-- name: AddSession :exec
+INSERT INTO sessions (
+  token_hash, user_id, csrf_token, created_at, expires_at
+) VALUES (
+  ?, ?, ?, ?, ?
+)
+`
+
+type AddSessionParams struct {
+	TokenHash string
+	UserID    int64
+	CsrfToken string
+	CreatedAt int64
+	ExpiresAt int64
+}
+
+func (q *Queries) AddSession(ctx context.Context, arg AddSessionParams) error {
+	_, err := q.db.ExecContext(ctx, addSession,
+		arg.TokenHash,
+		arg.UserID,
+		arg.CsrfToken,
+		arg.CreatedAt,
+		arg.ExpiresAt,
+	)
+	return err
+}
+
 const addTrackedService = `-- name: AddTrackedService :one
 INSERT INTO tracked_services (
   device_id, name, status, last_updated, container_id, container_image
@@ -139,6 +347,40 @@ func (q *Queries) AddTrackedService(ctx context.Context, arg AddTrackedServicePa
 	return i, err
 }
 
+const addUser = `-- name: AddUser :one
+INSERT INTO users (
+  username, password_hash, role, created_at
+) VALUES (
+  ?, ?, ?, ?
+)
+RETURNING id, username, password_hash, role, created_at
+`
+
+type AddUserParams struct {
+	Username     string
+	PasswordHash string
+	Role         string
+	CreatedAt    int64
+}
+
+func (q *Queries) AddUser(ctx context.Context, arg AddUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, addUser,
+		arg.Username,
+		arg.PasswordHash,
+		arg.Role,
+		arg.CreatedAt,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.PasswordHash,
+		&i.Role,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
 const addWatchedService = `-- name: AddWatchedService :exec
 INSERT INTO watched_services (name) VALUES (?)
 `
@@ -148,6 +390,33 @@ func (q *Queries) AddWatchedService(ctx context.Context, name string) error {
 	return err
 }
 
+const countAuditEvents = `-- name: CountAuditEvents :one
+SELECT COUNT(*) FROM audit_events
+WHERE (?1 = '' OR actor = ?1)
+  AND (?2 = '' OR action = ?2)
+  AND target LIKE '%' || ?3 || '%'
+  AND (NOT ?4 OR NOT is_success)
+`
+
+type CountAuditEventsParams struct {
+	Actor      string
+	Action     string
+	Target     string
+	FailedOnly bool
+}
+
+func (q *Queries) CountAuditEvents(ctx context.Context, arg CountAuditEventsParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countAuditEvents,
+		arg.Actor,
+		arg.Action,
+		arg.Target,
+		arg.FailedOnly,
+	)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const countDevices = `-- name: CountDevices :one
 SELECT COUNT(*) FROM devices
 `
@@ -159,6 +428,29 @@ func (q *Queries) CountDevices(ctx context.Context) (int64, error) {
 	return count, err
 }
 
+const countOpenIncidents = `-- name: CountOpenIncidents :one
+SELECT COUNT(*) FROM incidents
+WHERE resolved_at IS NULL
+`
+
+func (q *Queries) CountOpenIncidents(ctx context.Context) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countOpenIncidents)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countUsers = `-- name: CountUsers :one
+SELECT COUNT(*) FROM users
+`
+
+func (q *Queries) CountUsers(ctx context.Context) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countUsers)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const deleteDevice = `-- name: DeleteDevice :exec
 DELETE FROM devices
 WHERE id = ?
@@ -169,6 +461,24 @@ func (q *Queries) DeleteDevice(ctx context.Context, id int64) error {
 	return err
 }
 
+const deleteExpiredSessions = `-- name: DeleteExpiredSessions :exec
+DELETE FROM sessions WHERE expires_at < ?
+`
+
+func (q *Queries) DeleteExpiredSessions(ctx context.Context, expiresAt int64) error {
+	_, err := q.db.ExecContext(ctx, deleteExpiredSessions, expiresAt)
+	return err
+}
+
+const deleteHostMetricsBefore = `-- name: DeleteHostMetricsBefore :exec
+DELETE FROM host_metrics WHERE recorded_at < ?
+`
+
+func (q *Queries) DeleteHostMetricsBefore(ctx context.Context, recordedAt int64) error {
+	_, err := q.db.ExecContext(ctx, deleteHostMetricsBefore, recordedAt)
+	return err
+}
+
 const deletePackage = `-- name: DeletePackage :exec
 DELETE FROM packages
 WHERE id = ?
@@ -179,6 +489,28 @@ func (q *Queries) DeletePackage(ctx context.Context, id int64) error {
 	return err
 }
 
+const deleteSession = `-- name: DeleteSession :exec
+DELETE FROM sessions WHERE token_hash = ?
+`
+
+func (q *Queries) DeleteSession(ctx context.Context, tokenHash string) error {
+	_, err := q.db.ExecContext(ctx, deleteSession, tokenHash)
+	return err
+}
+
+const deleteStack = `-- name: DeleteStack :execrows
+DELETE FROM stacks
+WHERE name = ?
+`
+
+func (q *Queries) DeleteStack(ctx context.Context, name string) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteStack, name)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const deleteTrackedService = `-- name: DeleteTrackedService :exec
 DELETE FROM tracked_services WHERE id = ?
 `
@@ -197,6 +529,59 @@ func (q *Queries) DeleteWatchedService(ctx context.Context, name string) error {
 	return err
 }
 
+const getAgentCertificate = `-- name: GetAgentCertificate :one
+SELECT id, serial, hostname, issued_at, expires_at, revoked_at FROM agent_certificates WHERE serial = ?
+`
+
+func (q *Queries) GetAgentCertificate(ctx context.Context, serial string) (AgentCertificate, error) {
+	row := q.db.QueryRowContext(ctx, getAgentCertificate, serial)
+	var i AgentCertificate
+	err := row.Scan(
+		&i.ID,
+		&i.Serial,
+		&i.Hostname,
+		&i.IssuedAt,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+	)
+	return i, err
+}
+
+const getApiToken = `-- name: GetApiToken :one
+SELECT api_tokens.id, api_tokens.name, api_tokens.user_id, api_tokens.scope, api_tokens.expires_at,
+  api_tokens.revoked_at, users.username, users.role
+FROM api_tokens
+JOIN users ON users.id = api_tokens.user_id
+WHERE api_tokens.token_hash = ?
+`
+
+type GetApiTokenRow struct {
+	ID        int64
+	Name      string
+	UserID    int64
+	Scope     string
+	ExpiresAt sql.NullInt64
+	RevokedAt sql.NullInt64
+	Username  string
+	Role      string
+}
+
+func (q *Queries) GetApiToken(ctx context.Context, tokenHash string) (GetApiTokenRow, error) {
+	row := q.db.QueryRowContext(ctx, getApiToken, tokenHash)
+	var i GetApiTokenRow
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.UserID,
+		&i.Scope,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+		&i.Username,
+		&i.Role,
+	)
+	return i, err
+}
+
 const getDevice = `-- name: GetDevice :one
 SELECT id, hostname, tailscale_last_seen, agent_last_seen FROM devices
 WHERE hostname = ?
@@ -214,6 +599,69 @@ func (q *Queries) GetDevice(ctx context.Context, hostname string) (Device, error
 	return i, err
 }
 
+const getDeviceByID = `-- name: GetDeviceByID :one
+SELECT id, hostname, tailscale_last_seen, agent_last_seen FROM devices
+WHERE id = ?
+`
+
+func (q *Queries) GetDeviceByID(ctx context.Context, id int64) (Device, error) {
+	row := q.db.QueryRowContext(ctx, getDeviceByID, id)
+	var i Device
+	err := row.Scan(
+		&i.ID,
+		&i.Hostname,
+		&i.TailscaleLastSeen,
+		&i.AgentLastSeen,
+	)
+	return i, err
+}
+
+const getPackageByName = `-- name: GetPackageByName :one
+SELECT id, name, install_cmd, update_cmd, remove_cmd FROM packages
+WHERE name = ?
+`
+
+func (q *Queries) GetPackageByName(ctx context.Context, name string) (Package, error) {
+	row := q.db.QueryRowContext(ctx, getPackageByName, name)
+	var i Package
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.InstallCmd,
+		&i.UpdateCmd,
+		&i.RemoveCmd,
+	)
+	return i, err
+}
+
+const getSession = `-- name: GetSession :one
+SELECT sessions.user_id, sessions.csrf_token, sessions.expires_at, users.username, users.role
+FROM sessions
+JOIN users ON users.id = sessions.user_id
+WHERE sessions.token_hash = ?
+`
+
+type GetSessionRow struct {
+	UserID    int64
+	CsrfToken string
+	ExpiresAt int64
+	Username  string
+	Role      string
+}
+
+func (q *Queries) GetSession(ctx context.Context, tokenHash string) (GetSessionRow, error) {
+	row := q.db.QueryRowContext(ctx, getSession, tokenHash)
+	var i GetSessionRow
+	err := row.Scan(
+		&i.UserID,
+		&i.CsrfToken,
+		&i.ExpiresAt,
+		&i.Username,
+		&i.Role,
+	)
+	return i, err
+}
+
 const getSetting = `-- name: GetSetting :one
 SELECT name, value FROM settings
 WHERE name = ?
@@ -232,6 +680,45 @@ func (q *Queries) GetSetting(ctx context.Context, name string) (GetSettingRow, e
 	return i, err
 }
 
+const getStack = `-- name: GetStack :one
+SELECT id, name, compose, deployed_compose, created_at, updated_at, deployed_at FROM stacks
+WHERE name = ?
+`
+
+func (q *Queries) GetStack(ctx context.Context, name string) (Stack, error) {
+	row := q.db.QueryRowContext(ctx, getStack, name)
+	var i Stack
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Compose,
+		&i.DeployedCompose,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeployedAt,
+	)
+	return i, err
+}
+
+const getTrackedService = `-- name: GetTrackedService :one
+SELECT id, device_id, name, status, last_updated, container_id, container_image FROM tracked_services WHERE id = ?
+`
+
+func (q *Queries) GetTrackedService(ctx context.Context, id int64) (TrackedService, error) {
+	row := q.db.QueryRowContext(ctx, getTrackedService, id)
+	var i TrackedService
+	err := row.Scan(
+		&i.ID,
+		&i.DeviceID,
+		&i.Name,
+		&i.Status,
+		&i.LastUpdated,
+		&i.ContainerID,
+		&i.ContainerImage,
+	)
+	return i, err
+}
+
 const getTrackedServiceID = `-- name: GetTrackedServiceID :one
 SELECT id FROM tracked_services WHERE name=? and device_id=?
 `
@@ -248,26 +735,502 @@ func (q *Queries) GetTrackedServiceID(ctx context.Context, arg GetTrackedService
 	return id, err
 }
 
-const listAssets = `-- name: ListAssets :many
-SELECT id, device_id, package_id, source_url, version FROM assets
-ORDER BY id
+const getUserByUsername = `-- name: GetUserByUsername :one
+SELECT id, username, password_hash, role, created_at FROM users WHERE username = ?
+`
+
+func (q *Queries) GetUserByUsername(ctx context.Context, username string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByUsername, username)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.PasswordHash,
+		&i.Role,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const listAgentCertificatesForHost = `-- name: ListAgentCertificatesForHost :many
+SELECT id, serial, hostname, issued_at, expires_at, revoked_at FROM agent_certificates WHERE hostname = ? ORDER BY issued_at DESC
+`
+
+func (q *Queries) ListAgentCertificatesForHost(ctx context.Context, hostname string) ([]AgentCertificate, error) {
+	rows, err := q.db.QueryContext(ctx, listAgentCertificatesForHost, hostname)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []AgentCertificate
+	for rows.Next() {
+		var i AgentCertificate
+		if err := rows.Scan(
+			&i.ID,
+			&i.Serial,
+			&i.Hostname,
+			&i.IssuedAt,
+			&i.ExpiresAt,
+			&i.RevokedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAllAuditEvents = `-- name: ListAllAuditEvents :many
+SELECT id, created_at, actor, action, target, params, is_success, error FROM audit_events ORDER BY id
+`
+
+func (q *Queries) ListAllAuditEvents(ctx context.Context) ([]AuditEvent, error) {
+	rows, err := q.db.QueryContext(ctx, listAllAuditEvents)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []AuditEvent
+	for rows.Next() {
+		var i AuditEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.Actor,
+			&i.Action,
+			&i.Target,
+			&i.Params,
+			&i.IsSuccess,
+			&i.Error,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listApiTokens = `-- name: ListApiTokens :many
+SELECT api_tokens.id, api_tokens.name, api_tokens.scope, api_tokens.created_at, api_tokens.expires_at,
+  api_tokens.last_used_at, api_tokens.revoked_at, users.username
+FROM api_tokens
+JOIN users ON users.id = api_tokens.user_id
+ORDER BY api_tokens.created_at DESC
+`
+
+type ListApiTokensRow struct {
+	ID         int64
+	Name       string
+	Scope      string
+	CreatedAt  int64
+	ExpiresAt  sql.NullInt64
+	LastUsedAt sql.NullInt64
+	RevokedAt  sql.NullInt64
+	Username   string
+}
+
+func (q *Queries) ListApiTokens(ctx context.Context) ([]ListApiTokensRow, error) {
+	rows, err := q.db.QueryContext(ctx, listApiTokens)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListApiTokensRow
+	for rows.Next() {
+		var i ListApiTokensRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Scope,
+			&i.CreatedAt,
+			&i.ExpiresAt,
+			&i.LastUsedAt,
+			&i.RevokedAt,
+			&i.Username,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listApiTokensForUser = `-- name: ListApiTokensForUser :many
+SELECT api_tokens.id, api_tokens.name, api_tokens.scope, api_tokens.created_at, api_tokens.expires_at,
+  api_tokens.last_used_at, api_tokens.revoked_at, users.username
+FROM api_tokens
+JOIN users ON users.id = api_tokens.user_id
+WHERE api_tokens.user_id = ?
+ORDER BY api_tokens.created_at DESC
+`
+
+type ListApiTokensForUserRow struct {
+	ID         int64
+	Name       string
+	Scope      string
+	CreatedAt  int64
+	ExpiresAt  sql.NullInt64
+	LastUsedAt sql.NullInt64
+	RevokedAt  sql.NullInt64
+	Username   string
+}
+
+func (q *Queries) ListApiTokensForUser(ctx context.Context, userID int64) ([]ListApiTokensForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, listApiTokensForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListApiTokensForUserRow
+	for rows.Next() {
+		var i ListApiTokensForUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Scope,
+			&i.CreatedAt,
+			&i.ExpiresAt,
+			&i.LastUsedAt,
+			&i.RevokedAt,
+			&i.Username,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAssetHistoryOnDevice = `-- name: ListAssetHistoryOnDevice :many
+SELECT packages.name AS package_name, assets.name, assets.version, assets.is_installed, assets.is_rollback,
+       assets.output, assets.duration_ms, assets.installed_at
+FROM assets
+JOIN packages ON packages.id = assets.package_id
+WHERE assets.device_id = ?
+ORDER BY assets.id DESC
+LIMIT 50
+`
+
+type ListAssetHistoryOnDeviceRow struct {
+	PackageName string
+	Name        string
+	Version     sql.NullString
+	IsInstalled bool
+	IsRollback  bool
+	Output      sql.NullString
+	DurationMs  int64
+	InstalledAt int64
+}
+
+func (q *Queries) ListAssetHistoryOnDevice(ctx context.Context, deviceID int64) ([]ListAssetHistoryOnDeviceRow, error) {
+	rows, err := q.db.QueryContext(ctx, listAssetHistoryOnDevice, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListAssetHistoryOnDeviceRow
+	for rows.Next() {
+		var i ListAssetHistoryOnDeviceRow
+		if err := rows.Scan(
+			&i.PackageName,
+			&i.Name,
+			&i.Version,
+			&i.IsInstalled,
+			&i.IsRollback,
+			&i.Output,
+			&i.DurationMs,
+			&i.InstalledAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAssets = `-- name: ListAssets :many
+SELECT id, device_id, package_id, source_url, version, name, is_installed, output, duration_ms, installed_at, is_rollback FROM assets
+ORDER BY id
+`
+
+func (q *Queries) ListAssets(ctx context.Context) ([]Asset, error) {
+	rows, err := q.db.QueryContext(ctx, listAssets)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Asset
+	for rows.Next() {
+		var i Asset
+		if err := rows.Scan(
+			&i.ID,
+			&i.DeviceID,
+			&i.PackageID,
+			&i.SourceUrl,
+			&i.Version,
+			&i.Name,
+			&i.IsInstalled,
+			&i.Output,
+			&i.DurationMs,
+			&i.InstalledAt,
+			&i.IsRollback,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAssetsForPackage = `-- name: ListAssetsForPackage :many
+SELECT id, device_id, package_id, source_url, version, name, is_installed, output, duration_ms, installed_at, is_rollback FROM assets
+WHERE package_id = ?
+ORDER BY id
+`
+
+func (q *Queries) ListAssetsForPackage(ctx context.Context, packageID int64) ([]Asset, error) {
+	rows, err := q.db.QueryContext(ctx, listAssetsForPackage, packageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Asset
+	for rows.Next() {
+		var i Asset
+		if err := rows.Scan(
+			&i.ID,
+			&i.DeviceID,
+			&i.PackageID,
+			&i.SourceUrl,
+			&i.Version,
+			&i.Name,
+			&i.IsInstalled,
+			&i.Output,
+			&i.DurationMs,
+			&i.InstalledAt,
+			&i.IsRollback,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAssetsOnDevice = `-- name: ListAssetsOnDevice :many
+SELECT id, device_id, package_id, source_url, version, name, is_installed, output, duration_ms, installed_at, is_rollback FROM assets
+WHERE device_id = ?
+ORDER BY id
+`
+
+func (q *Queries) ListAssetsOnDevice(ctx context.Context, deviceID int64) ([]Asset, error) {
+	rows, err := q.db.QueryContext(ctx, listAssetsOnDevice, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Asset
+	for rows.Next() {
+		var i Asset
+		if err := rows.Scan(
+			&i.ID,
+			&i.DeviceID,
+			&i.PackageID,
+			&i.SourceUrl,
+			&i.Version,
+			&i.Name,
+			&i.IsInstalled,
+			&i.Output,
+			&i.DurationMs,
+			&i.InstalledAt,
+			&i.IsRollback,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAuditActions = `-- name: ListAuditActions :many
+SELECT DISTINCT action FROM audit_events ORDER BY action
+`
+
+func (q *Queries) ListAuditActions(ctx context.Context) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, listAuditActions)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var action string
+		if err := rows.Scan(&action); err != nil {
+			return nil, err
+		}
+		items = append(items, action)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAuditActors = `-- name: ListAuditActors :many
+SELECT DISTINCT actor FROM audit_events ORDER BY actor
+`
+
+func (q *Queries) ListAuditActors(ctx context.Context) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, listAuditActors)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var actor string
+		if err := rows.Scan(&actor); err != nil {
+			return nil, err
+		}
+		items = append(items, actor)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAuditEvents = `-- name: ListAuditEvents :many
+SELECT id, created_at, actor, action, target, params, is_success, error FROM audit_events
+WHERE (?1 = '' OR actor = ?1)
+  AND (?2 = '' OR action = ?2)
+  AND target LIKE '%' || ?3 || '%'
+  AND (NOT ?4 OR NOT is_success)
+ORDER BY id DESC
+LIMIT ?5 OFFSET ?6
+`
+
+type ListAuditEventsParams struct {
+	Actor      string
+	Action     string
+	Target     string
+	FailedOnly bool
+	Limit      int64
+	Offset     int64
+}
+
+func (q *Queries) ListAuditEvents(ctx context.Context, arg ListAuditEventsParams) ([]AuditEvent, error) {
+	rows, err := q.db.QueryContext(ctx, listAuditEvents,
+		arg.Actor,
+		arg.Action,
+		arg.Target,
+		arg.FailedOnly,
+		arg.Limit,
+		arg.Offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []AuditEvent
+	for rows.Next() {
+		var i AuditEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.Actor,
+			&i.Action,
+			&i.Target,
+			&i.Params,
+			&i.IsSuccess,
+			&i.Error,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listDevices = `-- name: ListDevices :many
+SELECT id, hostname, tailscale_last_seen, agent_last_seen FROM devices
+ORDER BY hostname
 `
 
-func (q *Queries) ListAssets(ctx context.Context) ([]Asset, error) {
-	rows, err := q.db.QueryContext(ctx, listAssets)
+func (q *Queries) ListDevices(ctx context.Context) ([]Device, error) {
+	rows, err := q.db.QueryContext(ctx, listDevices)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Asset
+	var items []Device
 	for rows.Next() {
-		var i Asset
+		var i Device
 		if err := rows.Scan(
 			&i.ID,
-			&i.DeviceID,
-			&i.PackageID,
-			&i.SourceUrl,
-			&i.Version,
+			&i.Hostname,
+			&i.TailscaleLastSeen,
+			&i.AgentLastSeen,
 		); err != nil {
 			return nil, err
 		}
@@ -282,27 +1245,33 @@ func (q *Queries) ListAssets(ctx context.Context) ([]Asset, error) {
 	return items, nil
 }
 
-const listAssetsForPackage = `-- name: ListAssetsForPackage :many
-SELECT id, device_id, package_id, source_url, version FROM assets
-WHERE package_id = ?
-ORDER BY id
+const listHostMetricsOnDevice = `-- name: ListHostMetricsOnDevice :many
+SELECT id, device_id, recorded_at, cpu_usage, mem_usage, disk_usage FROM host_metrics
+WHERE device_id = ? AND recorded_at >= ?
+ORDER BY recorded_at
 `
 
-func (q *Queries) ListAssetsForPackage(ctx context.Context, packageID int64) ([]Asset, error) {
-	rows, err := q.db.QueryContext(ctx, listAssetsForPackage, packageID)
+type ListHostMetricsOnDeviceParams struct {
+	DeviceID   int64
+	RecordedAt int64
+}
+
+func (q *Queries) ListHostMetricsOnDevice(ctx context.Context, arg ListHostMetricsOnDeviceParams) ([]HostMetric, error) {
+	rows, err := q.db.QueryContext(ctx, listHostMetricsOnDevice, arg.DeviceID, arg.RecordedAt)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Asset
+	var items []HostMetric
 	for rows.Next() {
-		var i Asset
+		var i HostMetric
 		if err := rows.Scan(
 			&i.ID,
 			&i.DeviceID,
-			&i.PackageID,
-			&i.SourceUrl,
-			&i.Version,
+			&i.RecordedAt,
+			&i.CpuUsage,
+			&i.MemUsage,
+			&i.DiskUsage,
 		); err != nil {
 			return nil, err
 		}
@@ -317,27 +1286,37 @@ func (q *Queries) ListAssetsForPackage(ctx context.Context, packageID int64) ([]
 	return items, nil
 }
 
-const listAssetsOnDevice = `-- name: ListAssetsOnDevice :many
-SELECT id, device_id, package_id, source_url, version FROM assets
-WHERE device_id = ?
-ORDER BY id
+const listHostMetricsSince = `-- name: ListHostMetricsSince :many
+SELECT devices.hostname, host_metrics.recorded_at, host_metrics.cpu_usage, host_metrics.mem_usage, host_metrics.disk_usage
+FROM host_metrics
+JOIN devices ON devices.id = host_metrics.device_id
+WHERE host_metrics.recorded_at >= ?
+ORDER BY devices.hostname, host_metrics.recorded_at
 `
 
-func (q *Queries) ListAssetsOnDevice(ctx context.Context, deviceID int64) ([]Asset, error) {
-	rows, err := q.db.QueryContext(ctx, listAssetsOnDevice, deviceID)
+type ListHostMetricsSinceRow struct {
+	Hostname   string
+	RecordedAt int64
+	CpuUsage   sql.NullFloat64
+	MemUsage   sql.NullFloat64
+	DiskUsage  sql.NullFloat64
+}
+
+func (q *Queries) ListHostMetricsSince(ctx context.Context, recordedAt int64) ([]ListHostMetricsSinceRow, error) {
+	rows, err := q.db.QueryContext(ctx, listHostMetricsSince, recordedAt)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Asset
+	var items []ListHostMetricsSinceRow
 	for rows.Next() {
-		var i Asset
+		var i ListHostMetricsSinceRow
 		if err := rows.Scan(
-			&i.ID,
-			&i.DeviceID,
-			&i.PackageID,
-			&i.SourceUrl,
-			&i.Version,
+			&i.Hostname,
+			&i.RecordedAt,
+			&i.CpuUsage,
+			&i.MemUsage,
+			&i.DiskUsage,
 		); err != nil {
 			return nil, err
 		}
@@ -352,25 +1331,33 @@ func (q *Queries) ListAssetsOnDevice(ctx context.Context, deviceID int64) ([]Ass
 	return items, nil
 }
 
-const listDevices = `-- name: ListDevices :many
-SELECT id, hostname, tailscale_last_seen, agent_last_seen FROM devices
-ORDER BY hostname
+const listIncidents = `-- name: ListIncidents :many
+SELECT id, kind, container_id, container_name, image, message, occurrences, started_at, last_seen_at, resolved_at, resolved_by FROM incidents
+WHERE resolved_at IS NULL OR resolved_at >= ?
+ORDER BY resolved_at IS NOT NULL, last_seen_at DESC
 `
 
-func (q *Queries) ListDevices(ctx context.Context) ([]Device, error) {
-	rows, err := q.db.QueryContext(ctx, listDevices)
+func (q *Queries) ListIncidents(ctx context.Context, resolvedAt sql.NullInt64) ([]Incident, error) {
+	rows, err := q.db.QueryContext(ctx, listIncidents, resolvedAt)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Device
+	var items []Incident
 	for rows.Next() {
-		var i Device
+		var i Incident
 		if err := rows.Scan(
 			&i.ID,
-			&i.Hostname,
-			&i.TailscaleLastSeen,
-			&i.AgentLastSeen,
+			&i.Kind,
+			&i.ContainerID,
+			&i.ContainerName,
+			&i.Image,
+			&i.Message,
+			&i.Occurrences,
+			&i.StartedAt,
+			&i.LastSeenAt,
+			&i.ResolvedAt,
+			&i.ResolvedBy,
 		); err != nil {
 			return nil, err
 		}
@@ -385,6 +1372,53 @@ func (q *Queries) ListDevices(ctx context.Context) ([]Device, error) {
 	return items, nil
 }
 
+const listInstalledAssetsOnDevice = `-- name: ListInstalledAssetsOnDevice :many
+SELECT packages.name AS package_name, assets.version, assets.installed_at
+FROM assets
+JOIN packages ON packages.id = assets.package_id
+WHERE assets.id IN (
+  SELECT MAX(latest.id) FROM assets AS latest
+  WHERE latest.device_id = ? AND NOT EXISTS (
+    SELECT 1 FROM assets AS failed
+    WHERE failed.device_id = latest.device_id AND failed.package_id = latest.package_id
+      AND failed.version IS latest.version AND failed.installed_at = latest.installed_at
+      AND failed.is_rollback = latest.is_rollback AND failed.is_installed = false
+  )
+  GROUP BY latest.package_id
+)
+ORDER BY packages.name
+`
+
+type ListInstalledAssetsOnDeviceRow struct {
+	PackageName string
+	Version     sql.NullString
+	InstalledAt int64
+}
+
+// the assets of one installation report share its version and time, and a release is only installed if all of them are
+func (q *Queries) ListInstalledAssetsOnDevice(ctx context.Context, deviceID int64) ([]ListInstalledAssetsOnDeviceRow, error) {
+	rows, err := q.db.QueryContext(ctx, listInstalledAssetsOnDevice, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListInstalledAssetsOnDeviceRow
+	for rows.Next() {
+		var i ListInstalledAssetsOnDeviceRow
+		if err := rows.Scan(&i.PackageName, &i.Version, &i.InstalledAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listPackages = `-- name: ListPackages :many
 SELECT id, name, install_cmd, update_cmd, remove_cmd FROM packages
 ORDER BY name
@@ -447,16 +1481,100 @@ func (q *Queries) ListSettings(ctx context.Context) ([]Setting, error) {
 	return items, nil
 }
 
+const listStacks = `-- name: ListStacks :many
+SELECT id, name, compose, deployed_compose, created_at, updated_at, deployed_at FROM stacks ORDER BY name
+`
+
+func (q *Queries) ListStacks(ctx context.Context) ([]Stack, error) {
+	rows, err := q.db.QueryContext(ctx, listStacks)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Stack
+	for rows.Next() {
+		var i Stack
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Compose,
+			&i.DeployedCompose,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.DeployedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listTrackedServices = `-- name: ListTrackedServices :many
-SELECT id, device_id, name, status, last_updated, container_id, container_image FROM tracked_services ORDER BY (device_id, name)
+SELECT tracked_services.id, tracked_services.device_id, tracked_services.name, tracked_services.status, tracked_services.last_updated, tracked_services.container_id, tracked_services.container_image, devices.hostname FROM tracked_services
+JOIN devices ON devices.id = tracked_services.device_id
+ORDER BY devices.hostname, tracked_services.name
 `
 
-func (q *Queries) ListTrackedServices(ctx context.Context) ([]TrackedService, error) {
+type ListTrackedServicesRow struct {
+	ID             int64
+	DeviceID       int64
+	Name           string
+	Status         string
+	LastUpdated    int64
+	ContainerID    sql.NullString
+	ContainerImage sql.NullString
+	Hostname       string
+}
+
+func (q *Queries) ListTrackedServices(ctx context.Context) ([]ListTrackedServicesRow, error) {
 	rows, err := q.db.QueryContext(ctx, listTrackedServices)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
+	var items []ListTrackedServicesRow
+	for rows.Next() {
+		var i ListTrackedServicesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.DeviceID,
+			&i.Name,
+			&i.Status,
+			&i.LastUpdated,
+			&i.ContainerID,
+			&i.ContainerImage,
+			&i.Hostname,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTrackedServicesOnDevice = `-- name: ListTrackedServicesOnDevice :many
+SELECT id, device_id, name, status, last_updated, container_id, container_image FROM tracked_services WHERE device_id = ? ORDER BY name
+`
+
+func (q *Queries) ListTrackedServicesOnDevice(ctx context.Context, deviceID int64) ([]TrackedService, error) {
+	rows, err := q.db.QueryContext(ctx, listTrackedServicesOnDevice, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 	var items []TrackedService
 	for rows.Next() {
 		var i TrackedService
@@ -509,6 +1627,223 @@ func (q *Queries) ListWatchedServices(ctx context.Context) ([]string, error) {
 	return items, nil
 }
 
+const recordIncident = `-- name: RecordIncident :one
+INSERT INTO incidents (
+  kind, container_id, container_name, image, message, occurrences, started_at, last_seen_at
+) VALUES (
+  ?1, ?2, ?3, ?4, ?5, 1,
+  ?6, ?6
+)
+ON CONFLICT (container_name, kind) WHERE resolved_at IS NULL DO UPDATE
+SET container_id = excluded.container_id,
+    image = excluded.image,
+    message = excluded.message,
+    occurrences = occurrences + 1,
+    last_seen_at = excluded.last_seen_at
+RETURNING id, kind, container_id, container_name, image, message, occurrences, started_at, last_seen_at, resolved_at, resolved_by
+`
+
+type RecordIncidentParams struct {
+	Kind          string
+	ContainerID   string
+	ContainerName string
+	Image         string
+	Message       string
+	SeenAt        int64
+}
+
+func (q *Queries) RecordIncident(ctx context.Context, arg RecordIncidentParams) (Incident, error) {
+	row := q.db.QueryRowContext(ctx, recordIncident,
+		arg.Kind,
+		arg.ContainerID,
+		arg.ContainerName,
+		arg.Image,
+		arg.Message,
+		arg.SeenAt,
+	)
+	var i Incident
+	err := row.Scan(
+		&i.ID,
+		&i.Kind,
+		&i.ContainerID,
+		&i.ContainerName,
+		&i.Image,
+		&i.Message,
+		&i.Occurrences,
+		&i.StartedAt,
+		&i.LastSeenAt,
+		&i.ResolvedAt,
+		&i.ResolvedBy,
+	)
+	return i, err
+}
+
+const resolveContainerIncidents = `-- name: ResolveContainerIncidents :execrows
+UPDATE incidents
+set resolved_at = ?,
+    resolved_by = ?
+WHERE container_name = ? AND kind = ? AND resolved_at IS NULL
+`
+
+type ResolveContainerIncidentsParams struct {
+	ResolvedAt    sql.NullInt64
+	ResolvedBy    sql.NullString
+	ContainerName string
+	Kind          string
+}
+
+func (q *Queries) ResolveContainerIncidents(ctx context.Context, arg ResolveContainerIncidentsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, resolveContainerIncidents,
+		arg.ResolvedAt,
+		arg.ResolvedBy,
+		arg.ContainerName,
+		arg.Kind,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const resolveIncident = `-- name: ResolveIncident :execrows
+UPDATE incidents
+set resolved_at = ?,
+    resolved_by = ?
+WHERE id = ? AND resolved_at IS NULL
+`
+
+type ResolveIncidentParams struct {
+	ResolvedAt sql.NullInt64
+	ResolvedBy sql.NullString
+	ID         int64
+}
+
+func (q *Queries) ResolveIncident(ctx context.Context, arg ResolveIncidentParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, resolveIncident, arg.ResolvedAt, arg.ResolvedBy, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const revokeAgentCertificate = `-- name: RevokeAgentCertificate :execrows
+UPDATE agent_certificates
+set revoked_at = ?
+WHERE serial = ? AND revoked_at IS NULL
+`
+
+type RevokeAgentCertificateParams struct {
+	RevokedAt sql.NullInt64
+	Serial    string
+}
+
+func (q *Queries) RevokeAgentCertificate(ctx context.Context, arg RevokeAgentCertificateParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeAgentCertificate, arg.RevokedAt, arg.Serial)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const revokeApiToken = `-- name: RevokeApiToken :execrows
+UPDATE api_tokens
+set revoked_at = ?
+WHERE id = ? AND revoked_at IS NULL
+`
+
+type RevokeApiTokenParams struct {
+	RevokedAt sql.NullInt64
+	ID        int64
+}
+
+func (q *Queries) RevokeApiToken(ctx context.Context, arg RevokeApiTokenParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeApiToken, arg.RevokedAt, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const revokeUserApiToken = `-- name: RevokeUserApiToken :execrows
+UPDATE api_tokens
+set revoked_at = ?
+WHERE id = ? AND user_id = ? AND revoked_at IS NULL
+`
+
+type RevokeUserApiTokenParams struct {
+	RevokedAt sql.NullInt64
+	ID        int64
+	UserID    int64
+}
+
+func (q *Queries) RevokeUserApiToken(ctx context.Context, arg RevokeUserApiTokenParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeUserApiToken, arg.RevokedAt, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const saveStack = `-- name: SaveStack :exec
+INSERT INTO stacks (
+  name, compose, created_at, updated_at
+) VALUES (
+  ?, ?, ?, ?
+)
+ON CONFLICT (name) DO UPDATE SET compose = excluded.compose, updated_at = excluded.updated_at
+`
+
+type SaveStackParams struct {
+	Name      string
+	Compose   string
+	CreatedAt int64
+	UpdatedAt int64
+}
+
+func (q *Queries) SaveStack(ctx context.Context, arg SaveStackParams) error {
+	_, err := q.db.ExecContext(ctx, saveStack,
+		arg.Name,
+		arg.Compose,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+	)
+	return err
+}
+
+const setStackDeployed = `-- name: SetStackDeployed :exec
+UPDATE stacks
+set deployed_compose = ?,
+    deployed_at = ?
+WHERE name = ?
+`
+
+type SetStackDeployedParams struct {
+	DeployedCompose sql.NullString
+	DeployedAt      sql.NullInt64
+	Name            string
+}
+
+func (q *Queries) SetStackDeployed(ctx context.Context, arg SetStackDeployedParams) error {
+	_, err := q.db.ExecContext(ctx, setStackDeployed, arg.DeployedCompose, arg.DeployedAt, arg.Name)
+	return err
+}
+
+const updateApiTokenLastUsed = `-- name: UpdateApiTokenLastUsed :exec
+UPDATE api_tokens
+set last_used_at = ?
+WHERE id = ?
+`
+
+type UpdateApiTokenLastUsedParams struct {
+	LastUsedAt sql.NullInt64
+	ID         int64
+}
+
+func (q *Queries) UpdateApiTokenLastUsed(ctx context.Context, arg UpdateApiTokenLastUsedParams) error {
+	_, err := q.db.ExecContext(ctx, updateApiTokenLastUsed, arg.LastUsedAt, arg.ID)
+	return err
+}
+
 const updateDevice = `-- name: UpdateDevice :exec
 UPDATE devices
 SET tailscale_last_seen = ?,
@@ -574,17 +1909,47 @@ func (q *Queries) UpdateSetting(ctx context.Context, arg UpdateSettingParams) er
 const updateTrackedService = `-- name: UpdateTrackedService :exec
 UPDATE tracked_services
 set status = ?,
-    last_updated = ?
+    last_updated = ?,
+    container_id = ?,
+    container_image = ?
 WHERE id = ?
 `
 
 type UpdateTrackedServiceParams struct {
-	Status      string
-	LastUpdated int64
-	ID          int64
+	Status         string
+	LastUpdated    int64
+	ContainerID    sql.NullString
+	ContainerImage sql.NullString
+	ID             int64
 }
 
 func (q *Queries) UpdateTrackedService(ctx context.Context, arg UpdateTrackedServiceParams) error {
-	_, err := q.db.ExecContext(ctx, updateTrackedService, arg.Status, arg.LastUpdated, arg.ID)
+	_, err := q.db.ExecContext(ctx, updateTrackedService,
+		arg.Status,
+		arg.LastUpdated,
+		arg.ContainerID,
+		arg.ContainerImage,
+		arg.ID,
+	)
 	return err
 }
+
+const useJoinToken = `-- name: UseJoinToken :one
+UPDATE join_tokens
+set used_at = ?
+WHERE token_hash = ? AND used_at IS NULL AND expires_at > ?
+RETURNING hostname
+`
+
+type UseJoinTokenParams struct {
+	UsedAt    sql.NullInt64
+	TokenHash string
+	ExpiresAt int64
+}
+
+func (q *Queries) UseJoinToken(ctx context.Context, arg UseJoinTokenParams) (string, error) {
+	row := q.db.QueryRowContext(ctx, useJoinToken, arg.UsedAt, arg.TokenHash, arg.ExpiresAt)
+	var hostname string
+	err := row.Scan(&hostname)
+	return hostname, err
+}
@@ -4,18 +4,23 @@ package main
 //go:generate sqlc generate
 
 import (
+	"bufio"
 	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
 	"flag"
+	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"os/signal"
+	"strings"
 	"time"
 
 	db "github.com/mpoegel/mahogany/internal/db"
 	mahogany "github.com/mpoegel/mahogany/pkg/mahogany"
+	term "golang.org/x/term"
 	_ "modernc.org/sqlite"
 )
 
@@ -105,7 +110,7 @@ func exportData(args []string) {
 		return
 	}
 
-	dbConn, err := sql.Open("sqlite", *dbFile)
+	dbConn, err := openDatabase(*dbFile)
 	if err != nil {
 		slog.Error("failed to open database file", "err", err)
 		return
@@ -131,6 +136,12 @@ func exportData(args []string) {
 		return
 	}
 
+	data.AuditEvents, err = query.ListAllAuditEvents(context.Background())
+	if err != nil {
+		slog.Error("failed to list audit events", "err", err)
+		return
+	}
+
 	encoder := json.NewEncoder(fp)
 	if err := encoder.Encode(data); err != nil {
 		slog.Error("failed to encode app data", "err", err)
@@ -155,7 +166,7 @@ func importData(args []string) {
 		return
 	}
 
-	dbConn, err := sql.Open("sqlite", *dbFile)
+	dbConn, err := openDatabase(*dbFile)
 	if err != nil {
 		slog.Error("failed to open database file", "err", err)
 		return
@@ -198,10 +209,132 @@ func importData(args []string) {
 	slog.Info("import complete")
 }
 
+func userCommand(args []string) {
+	if len(args) < 1 || args[0] != "add" {
+		slog.Error("missing argument [add]")
+		return
+	}
+	fs := flag.NewFlagSet("user add", flag.ExitOnError)
+	dbFile := fs.String("db", "mahogany.db", "database file")
+	username := fs.String("username", "admin", "name of the user")
+	roleName := fs.String("role", "admin", "role of the user: viewer, operator or admin")
+
+	if err := fs.Parse(args[1:]); err != nil {
+		slog.Error("failed to parse user args", "err", err)
+		return
+	}
+	role, err := mahogany.ParseRole(*roleName)
+	if err != nil {
+		slog.Error("invalid role", "err", err)
+		return
+	}
+
+	password, err := readPassword()
+	if err != nil {
+		slog.Error("failed to read password", "err", err)
+		return
+	}
+
+	dbConn, err := openDatabase(*dbFile)
+	if err != nil {
+		slog.Error("failed to open database file", "err", err)
+		return
+	}
+	query := db.New(dbConn)
+	if err = mahogany.AddUser(context.Background(), query, *username, password, role); err != nil {
+		slog.Error("failed to add user", "err", err)
+		return
+	}
+	slog.Info("user added", "username", *username, "role", role)
+}
+
+func migrateDatabase(args []string) {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dbFile := fs.String("db", "mahogany.db", "database file")
+
+	if err := fs.Parse(args); err != nil {
+		slog.Error("failed to parse migrate args", "err", err)
+		return
+	}
+
+	dbConn, err := openDatabase(*dbFile)
+	if err != nil {
+		slog.Error("failed to migrate database", "err", err)
+		return
+	}
+	dbConn.Close()
+	slog.Info("database is up to date")
+}
+
+// openDatabase opens the database file, creating it if needed, and applies the migrations it does not have yet
+func openDatabase(dbFile string) (*sql.DB, error) {
+	dbConn, err := sql.Open("sqlite", dbFile)
+	if err != nil {
+		return nil, err
+	}
+	if err = db.Migrate(context.Background(), dbConn); err != nil {
+		dbConn.Close()
+		return nil, err
+	}
+	return dbConn, nil
+}
+
+func enrollAgent(args []string) {
+	config := mahogany.LoadAgentConfig()
+	fs := flag.NewFlagSet("enroll", flag.ExitOnError)
+	token := fs.String("token", "", "join token from the devices page")
+	fs.StringVar(&config.ServerAddr, "server", config.ServerAddr, "address of the update server")
+	fs.StringVar(&config.CertDir, "dir", config.CertDir, "directory to store the agent certificate in")
+
+	if err := fs.Parse(args); err != nil {
+		slog.Error("failed to parse enroll args", "err", err)
+		return
+	}
+	if len(*token) == 0 {
+		slog.Error("missing -token")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := mahogany.Enroll(ctx, config, *token); err != nil {
+		slog.Error("failed to enroll agent", "err", err)
+	}
+}
+
+// readPassword prompts for a password on a terminal, or reads the first line of stdin when it is piped in
+func readPassword() (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return "", err
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	fmt.Fprint(os.Stderr, "Password: ")
+	password, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprint(os.Stderr, "Confirm password: ")
+	confirm, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	if string(password) != string(confirm) {
+		return "", errors.New("passwords do not match")
+	}
+	return string(password), nil
+}
+
 func main() {
 	args := os.Args
 	if len(args) < 2 {
-		slog.Error("missing argument [server, agent]")
+		slog.Error("missing argument [server, agent, enroll, migrate, export, import, user, ctl]")
 		return
 	}
 
@@ -210,10 +343,18 @@ func main() {
 		RunServer()
 	case "agent":
 		RunAgent()
+	case "enroll":
+		enrollAgent(args[2:])
+	case "migrate":
+		migrateDatabase(args[2:])
 	case "export":
 		exportData(args[2:])
 	case "import":
 		importData(args[2:])
+	case "user":
+		userCommand(args[2:])
+	case "ctl":
+		ctlCommand(args[2:])
 	default:
 		slog.Error("invalid argument")
 	}
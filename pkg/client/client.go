@@ -0,0 +1,171 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	types "github.com/docker/docker/api/types"
+	sources "github.com/mpoegel/mahogany/pkg/mahogany/sources"
+	vpn "github.com/mpoegel/mahogany/pkg/vpn"
+)
+
+const apiPrefix = "/api/v1"
+
+// Error is an error returned by the mahogany API
+type Error struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (%d)", e.Message, e.Status)
+}
+
+// Result is the response to an API request that takes an action
+type Result struct {
+	OK      bool   `json:"ok"`
+	Message string `json:"message,omitempty"`
+}
+
+// Client calls the JSON API of a mahogany server with an API token
+type Client struct {
+	BaseURL string
+	Token   string
+
+	http *http.Client
+}
+
+func NewClient(baseURL, token string) *Client {
+	return &Client{
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+		Token:   token,
+		http:    &http.Client{},
+	}
+}
+
+func (c *Client) newRequest(ctx context.Context, method, path string, body any) (*http.Request, error) {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+apiPrefix+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", c.Token))
+	req.Header.Add("Accept", "application/json")
+	if body != nil {
+		req.Header.Add("Content-Type", "application/json")
+	}
+	return req, nil
+}
+
+// send makes the request and returns the response if it succeeded, or the API error if it did not
+func (c *Client) send(req *http.Request) (*http.Response, error) {
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		defer resp.Body.Close()
+		apiErr := struct {
+			Error *Error `json:"error"`
+		}{}
+		if err = json.NewDecoder(resp.Body).Decode(&apiErr); err != nil || apiErr.Error == nil {
+			return nil, &Error{Status: resp.StatusCode, Message: resp.Status}
+		}
+		return nil, apiErr.Error
+	}
+	return resp, nil
+}
+
+// do makes the request and decodes the response into out, unless out is nil
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	req, err := c.newRequest(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	resp, err := c.send(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (c *Client) ListContainers(ctx context.Context) ([]types.Container, error) {
+	var containers []types.Container
+	err := c.do(ctx, http.MethodGet, "/containers", nil, &containers)
+	return containers, err
+}
+
+// ContainerAction starts, stops or restarts the container
+func (c *Client) ContainerAction(ctx context.Context, containerID, action string) (*Result, error) {
+	var result Result
+	err := c.do(ctx, http.MethodPost, fmt.Sprintf("/containers/%s/%s", url.PathEscape(containerID), action), nil, &result)
+	return &result, err
+}
+
+// ContainerLogs returns the logs of the container as text. With follow, the logs keep coming until the context is
+// cancelled. A tail of zero returns all of the logs.
+func (c *Client) ContainerLogs(ctx context.Context, containerID string, follow bool, tail int) (io.ReadCloser, error) {
+	query := url.Values{}
+	query.Set("follow", strconv.FormatBool(follow))
+	if tail > 0 {
+		query.Set("tail", strconv.Itoa(tail))
+	}
+	req, err := c.newRequest(ctx, http.MethodGet, fmt.Sprintf("/containers/%s/logs?%s", url.PathEscape(containerID), query.Encode()), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.send(req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (c *Client) ListDevices(ctx context.Context) ([]vpn.Device, error) {
+	var devices []vpn.Device
+	err := c.do(ctx, http.MethodGet, "/devices", nil, &devices)
+	return devices, err
+}
+
+// PushRelease installs a github release of the package on the hosts that have it, or the latest release if the tag
+// is empty
+func (c *Client) PushRelease(ctx context.Context, packageName, tag string) (*Result, error) {
+	body := map[string]string{"package": packageName, "tag": tag}
+	var result Result
+	err := c.do(ctx, http.MethodPost, "/releases", body, &result)
+	return &result, err
+}
+
+func (c *Client) ListRepositories(ctx context.Context) (*sources.RegistryCatalog, error) {
+	var catalog sources.RegistryCatalog
+	err := c.do(ctx, http.MethodGet, "/registry/repositories", nil, &catalog)
+	return &catalog, err
+}
+
+func (c *Client) ListTags(ctx context.Context, repository string) (*sources.RegistryTags, error) {
+	var tags sources.RegistryTags
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/registry/repositories/%s/tags", url.PathEscape(repository)), nil, &tags)
+	return &tags, err
+}
+
+func (c *Client) DeleteImage(ctx context.Context, repository, tag string) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/registry/repositories/%s/tags/%s", url.PathEscape(repository), url.PathEscape(tag)), nil, nil)
+}
@@ -2,45 +2,70 @@ package mahogany
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"os"
-	"os/exec"
 	"path"
 	"slices"
-	"strings"
+	"sync"
 	"time"
 
 	dbus "github.com/coreos/go-systemd/v22/dbus"
+	types "github.com/docker/docker/api/types"
 	container "github.com/docker/docker/api/types/container"
 	sources "github.com/mpoegel/mahogany/pkg/mahogany/sources"
 	schema "github.com/mpoegel/mahogany/pkg/schema"
 	grpc "google.golang.org/grpc"
-	insecure "google.golang.org/grpc/credentials/insecure"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
 	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type Agent struct {
-	config AgentConfig
+	config   AgentConfig
+	topology *schema.Topology
+	metrics  *Metrics
 
 	conn   *grpc.ClientConn
 	client schema.UpdateServiceClient
 
 	registration *schema.RegisterManifestResponse
+
+	// containers last reported to the server, the only ones it may act on
+	containersMu      sync.Mutex
+	trackedContainers map[string]bool
 }
 
 func NewAgent(config AgentConfig) (*Agent, error) {
-	conn, err := grpc.NewClient(config.ServerAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	creds, err := agentCredentials(config)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := grpc.NewClient(config.ServerAddr, grpc.WithTransportCredentials(creds))
 	if err != nil {
 		return nil, err
 	}
 
 	a := &Agent{
-		config: config,
-		conn:   conn,
-		client: schema.NewUpdateServiceClient(conn),
+		config:   config,
+		topology: &schema.Topology{},
+		conn:     conn,
+		client:   schema.NewUpdateServiceClient(conn),
+	}
+
+	if len(config.TopologyFile) > 0 {
+		a.topology, err = schema.ReadTopology(config.TopologyFile)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+	} else {
+		slog.Warn("agent has no topology file, so the signatures of release assets are not checked")
 	}
 
 	return a, nil
@@ -53,11 +78,12 @@ func (a *Agent) Run(ctx context.Context) error {
 		return err
 	}
 
-	metrics, err := NewMetrics(10*time.Second, map[string]string{"hostname": a.config.HostName})
+	var err error
+	a.metrics, err = NewMetrics(10*time.Second, map[string]string{"hostname": a.config.HostName})
 	if err != nil {
 		return err
 	}
-	go metrics.Collect(ctx)
+	go a.metrics.Collect(ctx)
 	go a.reportServices(ctx)
 
 	req := &schema.ReleaseStreamRequest{
@@ -78,8 +104,7 @@ func (a *Agent) Run(ctx context.Context) error {
 			return err
 		}
 		slog.Info("got release notification", "resp", resp)
-		// TODO reply with installation status
-		a.installRelease(resp.Release)
+		a.handleRelease(ctx, resp)
 	}
 }
 
@@ -98,54 +123,274 @@ func (a *Agent) register(ctx context.Context) error {
 	return nil
 }
 
-func (a *Agent) installRelease(release *schema.Release) bool {
-	downloadDir := path.Join(a.config.DownloadDir, release.Name)
-	if err := os.MkdirAll(downloadDir, 0777); err != nil {
-		slog.Error("could not create download directory", "dir", downloadDir, "err", err)
-		return false
+func (a *Agent) handleRelease(ctx context.Context, resp *schema.ReleaseStreamResponse) {
+	packageDir := path.Join(a.config.DownloadDir, resp.Release.Name)
+	if err := os.MkdirAll(packageDir, 0777); err != nil {
+		slog.Error("could not create download directory", "dir", packageDir, "err", err)
+		report := a.newReport(resp.Release, resp.Action)
+		report.Results = append(report.Results, &schema.AssetInstallResult{Error: err.Error(), Duration: durationpb.New(0)})
+		a.reportInstallation(ctx, report)
+		return
+	}
+	state, err := loadInstallState(packageDir)
+	if err != nil {
+		slog.Warn("could not load install state", "dir", packageDir, "err", err)
+		state = &installState{dir: packageDir}
+	}
+
+	if resp.Action == schema.ReleaseAction_RELEASE_ACTION_ROLLBACK {
+		if state.Previous == nil {
+			slog.Warn("no previous release to roll back to", "name", resp.Release.Name)
+			report := a.newReport(resp.Release, resp.Action)
+			report.Results = append(report.Results, &schema.AssetInstallResult{
+				Error:    "no previous release to roll back to",
+				Duration: durationpb.New(0),
+			})
+			a.reportInstallation(ctx, report)
+			return
+		}
+		report, ok := a.reinstallRelease(ctx, state.Previous, packageDir)
+		a.reportInstallation(ctx, report)
+		if ok {
+			if err := state.revert(); err != nil {
+				slog.Warn("could not save install state", "dir", packageDir, "err", err)
+			}
+		}
+		return
+	}
+
+	report, ok := a.installRelease(ctx, resp.Release, packageDir)
+	a.reportInstallation(ctx, report)
+	if ok {
+		if err := state.promote(resp.Release); err != nil {
+			slog.Warn("could not save install state", "dir", packageDir, "err", err)
+		}
+		return
+	}
+	// a failed install of the current version again rolls back to the artifacts it was installed from before
+	if state.Current == nil {
+		slog.Warn("no known-good release to roll back to", "name", resp.Release.Name)
+		return
+	}
+	slog.Warn("install failed, rolling back", "name", resp.Release.Name, "failed", resp.Release.Version, "version", state.Current.Version)
+	report, _ = a.reinstallRelease(ctx, state.Current, packageDir)
+	a.reportInstallation(ctx, report)
+}
+
+func (a *Agent) newReport(release *schema.Release, action schema.ReleaseAction) *schema.ReportInstallationRequest {
+	return &schema.ReportInstallationRequest{
+		Hostname:  a.config.HostName,
+		Timestamp: timestamppb.Now(),
+		Name:      release.Name,
+		Version:   release.Version,
+		Action:    action,
+		Results:   make([]*schema.AssetInstallResult, 0, len(release.Assets)),
+	}
+}
+
+// installRelease downloads, verifies and installs every asset of the release, stopping at the first failure
+func (a *Agent) installRelease(ctx context.Context, release *schema.Release, packageDir string) (*schema.ReportInstallationRequest, bool) {
+	report := a.newReport(release, schema.ReleaseAction_RELEASE_ACTION_INSTALL)
+	defer func() { report.Timestamp = timestamppb.Now() }()
+
+	// the assets are staged until they install, since the version directory may hold the known-good artifacts of
+	// the same version that a failed install rolls back to
+	stagingDir, err := os.MkdirTemp(packageDir, stagingDirPrefix)
+	if err != nil {
+		slog.Error("could not create download directory", "dir", packageDir, "err", err)
+		report.Results = append(report.Results, &schema.AssetInstallResult{Error: err.Error(), Duration: durationpb.New(0)})
+		return report, false
 	}
+	defer os.RemoveAll(stagingDir)
+	runner := NewCommandRunner(release.InstallTimeout.AsDuration(), release.WorkingDir, release.Environment)
 	for _, asset := range release.Assets {
-		resp, err := http.Get(asset.SourceUrl)
-		if err != nil {
-			slog.Error("could not download asset", "asset", asset.Name, "source", asset.SourceUrl)
-			return false
+		result := a.installAsset(ctx, runner, release, asset, stagingDir)
+		report.Results = append(report.Results, result)
+		if !result.IsInstalled {
+			return report, false
 		}
-		if resp.StatusCode != http.StatusOK {
-			slog.Error("got bad response downloading asset", "asset", asset.Name, "status", resp.Status)
-			return false
+	}
+	if len(report.Results) == 0 {
+		return report, false
+	}
+
+	downloadDir := path.Join(packageDir, versionDir(release.Version))
+	if err = os.RemoveAll(downloadDir); err == nil {
+		err = os.Rename(stagingDir, downloadDir)
+	}
+	if err != nil {
+		slog.Error("could not keep the installed release to roll back to", "dir", downloadDir, "err", err)
+	}
+	return report, true
+}
+
+// reinstallRelease re-runs the install command of a previously installed release against its kept artifacts
+func (a *Agent) reinstallRelease(ctx context.Context, release *schema.Release, packageDir string) (*schema.ReportInstallationRequest, bool) {
+	report := a.newReport(release, schema.ReleaseAction_RELEASE_ACTION_ROLLBACK)
+	defer func() { report.Timestamp = timestamppb.Now() }()
+
+	downloadDir := path.Join(packageDir, versionDir(release.Version))
+	runner := NewCommandRunner(release.InstallTimeout.AsDuration(), release.WorkingDir, release.Environment)
+	for _, asset := range release.Assets {
+		start := time.Now()
+		result := &schema.AssetInstallResult{
+			Asset: asset,
 		}
 		filename := path.Join(downloadDir, asset.Name)
-		fp, err := os.Create(filename)
-		if err != nil {
-			slog.Error("could not create asset file", "file", filename, "err", err)
-			return false
+		// the kept artifact is verified again, since it may have been changed on disk since it was installed
+		digest, err := fileDigest(filename)
+		if err == nil {
+			err = a.verifyAsset(release, asset, filename, digest)
 		}
-		defer fp.Close()
-		_, err = io.Copy(fp, resp.Body)
 		if err != nil {
-			slog.Error("could not write asset to file", "asset", asset.Name, "err", err)
-			return false
+			slog.Error("previous asset is missing or failed verification", "file", filename, "err", err)
+			result.Error = err.Error()
+		} else {
+			result.IsDownloaded = true
+			a.runInstall(ctx, runner, release, result, filename, downloadDir)
 		}
-		slog.Info("asset downloaded", "file", filename)
-
-		rawInstallCmd := strings.ReplaceAll(release.InstallCommand, "{}", filename)
-		// TODO add command timeout
-		cmds := strings.Split(rawInstallCmd, ";")
-		for _, rawCmd := range cmds {
-			cmdParts := strings.Split(rawCmd, " ")
-			cmd := exec.Command(cmdParts[0], cmdParts[1:]...)
-			out := &strings.Builder{}
-			cmd.Stdout = out
-			cmd.Stderr = out
-			if err = cmd.Run(); err != nil {
-				slog.Error("install failed", "asset", asset.Name, "err", err, "output", out.String())
-				return false
-			}
+		result.Duration = durationpb.New(time.Since(start))
+		report.Results = append(report.Results, result)
+		if !result.IsInstalled {
+			return report, false
+		}
+	}
+
+	return report, len(report.Results) > 0
+}
+
+func (a *Agent) installAsset(ctx context.Context, runner *CommandRunner, release *schema.Release, asset *schema.Asset, downloadDir string) *schema.AssetInstallResult {
+	start := time.Now()
+	result := &schema.AssetInstallResult{
+		Asset: asset,
+	}
+	defer func() { result.Duration = durationpb.New(time.Since(start)) }()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, asset.SourceUrl, nil)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		slog.Error("could not download asset", "asset", asset.Name, "source", asset.SourceUrl)
+		result.Error = err.Error()
+		return result
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		slog.Error("got bad response downloading asset", "asset", asset.Name, "status", resp.Status)
+		result.Error = fmt.Sprintf("bad download response: %s", resp.Status)
+		return result
+	}
+	filename := path.Join(downloadDir, asset.Name)
+	fp, err := os.Create(filename)
+	if err != nil {
+		slog.Error("could not create asset file", "file", filename, "err", err)
+		result.Error = err.Error()
+		return result
+	}
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(fp, hash), resp.Body)
+	fp.Close()
+	if err != nil {
+		slog.Error("could not write asset to file", "asset", asset.Name, "err", err)
+		result.Error = err.Error()
+		return result
+	}
+	result.IsDownloaded = true
+	slog.Info("asset downloaded", "file", filename)
+
+	if err = a.verifyAsset(release, asset, filename, hash.Sum(nil)); err != nil {
+		slog.Error("asset failed verification", "asset", asset.Name, "err", err)
+		result.Error = err.Error()
+		if err := os.Remove(filename); err != nil {
+			slog.Warn("could not remove unverified asset", "file", filename, "err", err)
+		}
+		return result
+	}
+
+	a.runInstall(ctx, runner, release, result, filename, downloadDir)
+	return result
+}
+
+func (a *Agent) runInstall(ctx context.Context, runner *CommandRunner, release *schema.Release, result *schema.AssetInstallResult, filename, downloadDir string) {
+	vars := CommandVars{
+		Name:        release.Name,
+		Version:     release.Version,
+		Asset:       filename,
+		DownloadDir: downloadDir,
+	}
+	installCmd, err := runner.Render(release.InstallCommand, vars)
+	if err != nil {
+		slog.Error("invalid install command", "asset", result.Asset.Name, "err", err)
+		result.Error = err.Error()
+		return
+	}
+	result.Output, err = runner.Run(ctx, installCmd, vars)
+	if err != nil {
+		slog.Error("install failed", "asset", result.Asset.Name, "err", err, "output", result.Output)
+		result.Error = err.Error()
+		return
+	}
+	result.IsInstalled = true
+	slog.Info("install completed", "asset", result.Asset.Name, "version", release.Version)
+}
+
+// verifyAsset checks the downloaded asset against its published digest and, if the package declares any public
+// keys in the topology, requires a valid signature from one of them
+func (a *Agent) verifyAsset(release *schema.Release, asset *schema.Asset, filename string, digest []byte) error {
+	if len(asset.Sha256) == 0 {
+		return errors.New("asset has no checksum")
+	}
+	expected, err := hex.DecodeString(asset.Sha256)
+	if err != nil {
+		return fmt.Errorf("invalid checksum: %w", err)
+	}
+	if subtle.ConstantTimeCompare(expected, digest) != 1 {
+		return fmt.Errorf("checksum mismatch: expected %s, got %x", asset.Sha256, digest)
+	}
+
+	// the keys come from the topology file of the agent, so that a compromised server cannot vouch for its own assets
+	pack := a.topology.FindPackage(a.config.HostName, release.Name)
+	if pack == nil || len(pack.Keys) == 0 {
+		if len(asset.Signature) > 0 {
+			slog.Warn("asset is signed but its package has no public keys in the topology of this agent, the signature is not checked",
+				"asset", asset.Name, "package", release.Name, "topology", a.config.TopologyFile)
 		}
-		slog.Info("install completed", "asset", asset.Name)
+		return nil
+	}
+	if len(asset.Signature) == 0 {
+		return errors.New("asset is not signed")
 	}
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	return verifySignature(pack.Keys, content, asset.Signature)
+}
 
-	return true
+func fileDigest(filename string) ([]byte, error) {
+	fp, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+	hash := sha256.New()
+	if _, err = io.Copy(hash, fp); err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
+
+func (a *Agent) reportInstallation(ctx context.Context, report *schema.ReportInstallationRequest) {
+	tctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if _, err := a.client.ReportInstallation(tctx, report); err != nil {
+		slog.Warn("failed to report installation", "err", err, "name", report.Name, "version", report.Version)
+		return
+	}
+	slog.Info("reported installation", "name", report.Name, "version", report.Version)
 }
 
 func (a *Agent) reportServices(ctx context.Context) {
@@ -176,13 +421,18 @@ func (a *Agent) reportServices(ctx context.Context) {
 		defer dbusConn.Close()
 	}
 
+	// the stream allows only one sender at a time
+	sendMu := &sync.Mutex{}
+	go a.handleServiceActions(ctx, stream, sendMu, dockerClient, dbusConn)
+
 	frequency := 30 * time.Second
 	ticker := time.NewTicker(frequency)
 	for {
 		subCtx, cancel := context.WithTimeout(ctx, frequency)
 		req := &schema.ServicesStreamRequest{
-			Hostname:  a.config.HostName,
-			Timestamp: timestamppb.Now(),
+			Hostname:    a.config.HostName,
+			Timestamp:   timestamppb.Now(),
+			HostMetrics: a.metrics.Latest(),
 		}
 
 		if a.registration.SubscribeToDocker {
@@ -190,6 +440,7 @@ func (a *Agent) reportServices(ctx context.Context) {
 			if err != nil {
 				slog.Warn("failed to list containers", "err", err)
 			} else {
+				a.trackContainers(containers)
 				for _, container := range containers {
 					req.Services = append(req.Services, &schema.ServiceStatus{
 						Name: container.Names[0],
@@ -240,7 +491,10 @@ func (a *Agent) reportServices(ctx context.Context) {
 			}
 		}
 
-		if err := stream.Send(req); err != nil {
+		sendMu.Lock()
+		err := stream.Send(req)
+		sendMu.Unlock()
+		if err != nil {
 			slog.Warn("failed to send services stream", "err", err, "msg", req)
 		}
 
@@ -254,6 +508,107 @@ func (a *Agent) reportServices(ctx context.Context) {
 	}
 }
 
+func (a *Agent) handleServiceActions(ctx context.Context, stream grpc.BidiStreamingClient[schema.ServicesStreamRequest, schema.ServicesStreamResponse], sendMu *sync.Mutex, dockerClient sources.DockerI, dbusConn *dbus.Conn) {
+	for {
+		action, err := stream.Recv()
+		if err != nil {
+			if !errors.Is(err, io.EOF) && ctx.Err() == nil {
+				slog.Warn("failed to receive service action", "err", err)
+			}
+			return
+		}
+		slog.Info("got service action", "service", action.ServiceName, "action", action.ServiceAction, "id", action.ActionId)
+		result := &schema.ServiceActionResult{
+			ActionId: action.ActionId,
+		}
+		if err := a.runServiceAction(ctx, action, dockerClient, dbusConn); err != nil {
+			slog.Warn("service action failed", "service", action.ServiceName, "action", action.ServiceAction, "err", err)
+			result.Error = err.Error()
+		} else {
+			result.IsSuccess = true
+		}
+
+		sendMu.Lock()
+		err = stream.Send(&schema.ServicesStreamRequest{
+			Hostname:     a.config.HostName,
+			Timestamp:    timestamppb.Now(),
+			ActionResult: result,
+		})
+		sendMu.Unlock()
+		if err != nil {
+			slog.Warn("failed to acknowledge service action", "err", err, "id", action.ActionId)
+		}
+	}
+}
+
+func (a *Agent) runServiceAction(ctx context.Context, action *schema.ServicesStreamResponse, dockerClient sources.DockerI, dbusConn *dbus.Conn) error {
+	if len(action.ContainerId) > 0 {
+		if dockerClient == nil {
+			return errors.New("docker is not enabled on this host")
+		}
+		if !a.isTrackedContainer(action.ContainerId) {
+			return fmt.Errorf("container %s is not watched", action.ContainerId)
+		}
+		switch action.ServiceAction {
+		case schema.ServiceAction_SERVICE_ACTION_START:
+			return dockerClient.ContainerStart(ctx, action.ContainerId, container.StartOptions{})
+		case schema.ServiceAction_SERVICE_ACTION_STOP:
+			return dockerClient.ContainerStop(ctx, action.ContainerId, container.StopOptions{})
+		case schema.ServiceAction_SERVICE_ACTION_RESTART:
+			return dockerClient.ContainerRestart(ctx, action.ContainerId, container.StopOptions{})
+		}
+		return fmt.Errorf("unknown service action %s", action.ServiceAction)
+	}
+
+	if dbusConn == nil {
+		return errors.New("systemd is not enabled on this host")
+	}
+	if !slices.Contains(a.registration.SubscribeToServices, action.ServiceName) {
+		return fmt.Errorf("unit %s is not watched", action.ServiceName)
+	}
+	resultC := make(chan string, 1)
+	var err error
+	switch action.ServiceAction {
+	case schema.ServiceAction_SERVICE_ACTION_START:
+		_, err = dbusConn.StartUnitContext(ctx, action.ServiceName, "replace", resultC)
+	case schema.ServiceAction_SERVICE_ACTION_STOP:
+		_, err = dbusConn.StopUnitContext(ctx, action.ServiceName, "replace", resultC)
+	case schema.ServiceAction_SERVICE_ACTION_RESTART:
+		_, err = dbusConn.RestartUnitContext(ctx, action.ServiceName, "replace", resultC)
+	default:
+		err = fmt.Errorf("unknown service action %s", action.ServiceAction)
+	}
+	if err != nil {
+		return err
+	}
+	select {
+	case result := <-resultC:
+		if result != "done" {
+			return fmt.Errorf("unit job %s", result)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// trackContainers replaces the containers that service actions may run on with those just listed
+func (a *Agent) trackContainers(containers []types.Container) {
+	tracked := make(map[string]bool, len(containers))
+	for _, c := range containers {
+		tracked[c.ID] = true
+	}
+	a.containersMu.Lock()
+	defer a.containersMu.Unlock()
+	a.trackedContainers = tracked
+}
+
+func (a *Agent) isTrackedContainer(containerID string) bool {
+	a.containersMu.Lock()
+	defer a.containersMu.Unlock()
+	return a.trackedContainers[containerID]
+}
+
 func (a *Agent) Close() {
 	if a.conn != nil {
 		a.conn.Close()
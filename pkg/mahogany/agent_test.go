@@ -0,0 +1,64 @@
+package mahogany
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	types "github.com/docker/docker/api/types"
+	container "github.com/docker/docker/api/types/container"
+	sources "github.com/mpoegel/mahogany/pkg/mahogany/sources"
+	schema "github.com/mpoegel/mahogany/pkg/schema"
+)
+
+// fakeActionDocker records the containers that were restarted
+type fakeActionDocker struct {
+	sources.DockerI
+	restarted []string
+}
+
+func (d *fakeActionDocker) ContainerRestart(ctx context.Context, containerID string, options container.StopOptions) error {
+	d.restarted = append(d.restarted, containerID)
+	return nil
+}
+
+func TestRunServiceAction(t *testing.T) {
+	agent := &Agent{registration: &schema.RegisterManifestResponse{SubscribeToServices: []string{"web.service"}}}
+	agent.trackContainers([]types.Container{{ID: "web-id"}})
+	docker := &fakeActionDocker{}
+
+	tests := []struct {
+		name    string
+		action  *schema.ServicesStreamResponse
+		docker  sources.DockerI
+		wantErr string
+	}{
+		{"tracked container", &schema.ServicesStreamResponse{ServiceName: "web", ContainerId: "web-id"}, docker, ""},
+		{"untracked container", &schema.ServicesStreamResponse{ServiceName: "db", ContainerId: "db-id"}, docker, "container db-id is not watched"},
+		{"docker not enabled", &schema.ServicesStreamResponse{ServiceName: "web", ContainerId: "web-id"}, nil, "docker is not enabled"},
+		{"systemd not enabled", &schema.ServicesStreamResponse{ServiceName: "web.service"}, docker, "systemd is not enabled"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.action.ServiceAction = schema.ServiceAction_SERVICE_ACTION_RESTART
+			err := agent.runServiceAction(t.Context(), tt.action, tt.docker, nil)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("got error %v", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("got error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+	if !slices.Equal(docker.restarted, []string{"web-id"}) {
+		t.Errorf("restarted %v, want only the tracked container", docker.restarted)
+	}
+
+	// a container that is gone from the latest listing can no longer be acted on
+	agent.trackContainers(nil)
+	if err := agent.runServiceAction(t.Context(), &schema.ServicesStreamResponse{ContainerId: "web-id"}, docker, nil); err == nil {
+		t.Error("ran an action on a container that is no longer listed")
+	}
+}
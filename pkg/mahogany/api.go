@@ -0,0 +1,653 @@
+package mahogany
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	types "github.com/docker/docker/api/types"
+	container "github.com/docker/docker/api/types/container"
+	errdefs "github.com/docker/docker/errdefs"
+	stdcopy "github.com/docker/docker/pkg/stdcopy"
+	github "github.com/google/go-github/v67/github"
+	db "github.com/mpoegel/mahogany/internal/db"
+	sources "github.com/mpoegel/mahogany/pkg/mahogany/sources"
+	views "github.com/mpoegel/mahogany/pkg/mahogany/views"
+	vpn "github.com/mpoegel/mahogany/pkg/vpn"
+)
+
+const (
+	apiPrefix = "/api/v1"
+	// request bodies are small json documents
+	maxAPIBody = 1 << 20
+)
+
+// APIError is the body of every failed API request
+type APIError struct {
+	Error APIErrorDetail `json:"error"`
+}
+
+type APIErrorDetail struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+// APIResult is the body of API requests that take an action rather than return a resource
+type APIResult struct {
+	OK      bool   `json:"ok"`
+	Message string `json:"message,omitempty"`
+}
+
+type APIPackage struct {
+	ID         int64  `json:"id"`
+	Name       string `json:"name"`
+	InstallCmd string `json:"installCmd"`
+	UpdateCmd  string `json:"updateCmd"`
+	RemoveCmd  string `json:"removeCmd,omitempty"`
+}
+
+type APIDevice struct {
+	Device       *vpn.Device                  `json:"device"`
+	Assets       []views.DeviceAsset          `json:"assets"`
+	History      []views.DeviceAssetEvent     `json:"history"`
+	Services     []views.TrackedServiceView   `json:"services"`
+	Missing      []string                     `json:"missingServices"`
+	Metrics      *views.HostMetricsView       `json:"metrics,omitempty"`
+	Certificates []views.AgentCertificateView `json:"certificates"`
+}
+
+// APINewToken is an API token along with its secret, which is only returned when the token is created
+type APINewToken struct {
+	views.APITokenView
+	Token string `json:"token"`
+}
+
+// APIImagePush is where in the registry to push an image, the tag defaults to latest
+type APIImagePush struct {
+	Repository string `json:"repository"`
+	Tag        string `json:"tag"`
+}
+
+// APIVolume is the body of requests that create a volume, the driver defaults to local
+type APIVolume struct {
+	Name   string            `json:"name"`
+	Driver string            `json:"driver"`
+	Labels map[string]string `json:"labels"`
+}
+
+// APINetwork is the body of requests that create a network, the driver defaults to bridge and docker picks the
+// subnet if it is empty
+type APINetwork struct {
+	Name     string            `json:"name"`
+	Driver   string            `json:"driver"`
+	Subnet   string            `json:"subnet"`
+	Internal bool              `json:"internal"`
+	Labels   map[string]string `json:"labels"`
+}
+
+type APIStack struct {
+	views.StackSummary
+	Compose    string            `json:"compose"`
+	Containers []types.Container `json:"containers"`
+}
+
+// APIStackCompose is the body of requests that save, plan or deploy a stack. Plan and deploy use the stored compose
+// file when it is empty.
+type APIStackCompose struct {
+	Compose string `json:"compose"`
+}
+
+type APISettings struct {
+	Settings        map[string]string `json:"settings"`
+	WatchedServices []string          `json:"watchedServices"`
+}
+
+// apiStatusError pins the status of an error that the API returns
+type apiStatusError struct {
+	status int
+	err    error
+}
+
+func (e *apiStatusError) Error() string { return e.err.Error() }
+func (e *apiStatusError) Unwrap() error { return e.err }
+
+func badRequest(err error) error {
+	return &apiStatusError{status: http.StatusBadRequest, err: err}
+}
+
+func notFound(err error) error {
+	return &apiStatusError{status: http.StatusNotFound, err: err}
+}
+
+// apiStatus picks the status code of an error from the sources behind the views
+func apiStatus(err error) int {
+	var statusErr *apiStatusError
+	var numErr *strconv.NumError
+	var githubErr *github.ErrorResponse
+	switch {
+	case errors.As(err, &statusErr):
+		return statusErr.status
+	case errdefs.IsNotFound(err), errors.Is(err, sql.ErrNoRows), errors.Is(err, sources.ErrCertificateNotFound),
+		errors.Is(err, views.ErrAPITokenNotFound), errors.Is(err, sources.ErrUnknownPackage),
+		errors.Is(err, views.ErrStackNotFound):
+		return http.StatusNotFound
+	case errors.As(err, &githubErr) && githubErr.Response != nil && githubErr.Response.StatusCode == http.StatusNotFound:
+		return http.StatusNotFound
+	case errdefs.IsForbidden(err):
+		return http.StatusForbidden
+	case errdefs.IsConflict(err), errors.Is(err, views.ErrNoRollback):
+		return http.StatusConflict
+	case errors.As(err, &numErr), errors.Is(err, views.ErrUnknownServiceAction), errors.Is(err, views.ErrEmptyServiceName),
+		errors.Is(err, views.ErrInvalidStack), errors.Is(err, views.ErrImagePinned),
+		errors.Is(err, views.ErrInvalidImageReference), errors.Is(err, views.ErrNoRegistry), errdefs.IsInvalidParameter(err):
+		return http.StatusBadRequest
+	case errors.Is(err, sources.ErrAgentNotConnected):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, sources.ErrActionPending):
+		return http.StatusAccepted
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
+}
+
+func isAPIRequest(r *http.Request) bool {
+	return r.URL.Path == apiPrefix || strings.HasPrefix(r.URL.Path, apiPrefix+"/")
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		slog.Error("failed to write api response", "err", err)
+	}
+}
+
+func writeAPIError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, APIError{Error: APIErrorDetail{Status: status, Message: message}})
+}
+
+// newAPIHandler is the JSON counterpart of newHandler
+func (s *Server) newAPIHandler(apiFunc func(r *http.Request) (any, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := apiFunc(r)
+		if err != nil {
+			status := apiStatus(err)
+			if status >= http.StatusInternalServerError {
+				slog.Error("api request failed", "method", r.Method, "path", r.URL.Path, "err", err)
+			}
+			writeAPIError(w, status, err.Error())
+			return
+		}
+		if body == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		status := http.StatusOK
+		if r.Method == http.MethodPost && !isActionResult(body) {
+			status = http.StatusCreated
+		}
+		writeJSON(w, status, body)
+	}
+}
+
+func isActionResult(body any) bool {
+	switch body.(type) {
+	case *APIResult, *views.StackPlanView, *views.ContainerUpdateView:
+		return true
+	}
+	return false
+}
+
+// decodeJSON reads the request body into the value, rejecting unknown fields
+func decodeJSON(r *http.Request, v any) error {
+	decoder := json.NewDecoder(io.LimitReader(r.Body, maxAPIBody))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return badRequest(fmt.Errorf("invalid request body: %w", err))
+	}
+	return nil
+}
+
+// actionResult turns the outcome of a view action into an API result
+func actionResult(view *views.ActionResponseView) (any, error) {
+	if !view.IsSuccess {
+		if view.Err != nil {
+			return nil, view.Err
+		}
+		return nil, errors.New(view.Toast)
+	}
+	return &APIResult{OK: true, Message: view.Toast}, nil
+}
+
+func newAPIPackage(pkg db.Package) APIPackage {
+	return APIPackage{
+		ID:         pkg.ID,
+		Name:       pkg.Name,
+		InstallCmd: pkg.InstallCmd,
+		UpdateCmd:  pkg.UpdateCmd,
+		RemoveCmd:  pkg.RemoveCmd.String,
+	}
+}
+
+// apiStackCompose returns the compose file from the request body, or the stored one if the body has none
+func (s *Server) apiStackCompose(r *http.Request) (string, error) {
+	var body APIStackCompose
+	if r.ContentLength != 0 {
+		if err := decodeJSON(r, &body); err != nil {
+			return "", err
+		}
+	}
+	if len(body.Compose) > 0 {
+		return body.Compose, nil
+	}
+	return s.view.StoredCompose(r.Context(), r.PathValue("name"))
+}
+
+// registerAPI adds the JSON API to the mux. Routes require the same roles as their counterparts in the web UI.
+func (s *Server) registerAPI(mux *http.ServeMux) {
+	mux.HandleFunc("GET "+apiPrefix+"/containers", s.require(RoleViewer, s.newAPIHandler(func(r *http.Request) (any, error) {
+		return s.view.ListContainers(r.Context())
+	})))
+	mux.HandleFunc("GET "+apiPrefix+"/containers/{containerID}", s.require(RoleViewer, s.newAPIHandler(func(r *http.Request) (any, error) {
+		view := s.view.GetContainer(r.Context(), r.PathValue("containerID"))
+		if !view.IsSuccess {
+			return nil, view.Err
+		}
+		return view.ContainerInfo, nil
+	})))
+	mux.HandleFunc("GET "+apiPrefix+"/containers/{containerID}/logs", s.require(RoleViewer, s.HandleAPIContainerLogs))
+	mux.HandleFunc("GET "+apiPrefix+"/containers/{containerID}/stats", s.require(RoleViewer, s.newAPIHandler(func(r *http.Request) (any, error) {
+		return s.view.GetContainerStats(r.Context(), r.PathValue("containerID"))
+	})))
+	mux.HandleFunc("POST "+apiPrefix+"/containers/{containerID}/start", s.require(RoleOperator, s.newAPIHandler(func(r *http.Request) (any, error) {
+		view := s.view.StartContainer(r.Context(), r.PathValue("containerID"))
+		return &APIResult{OK: true}, view.Err
+	})))
+	mux.HandleFunc("POST "+apiPrefix+"/containers/{containerID}/stop", s.require(RoleOperator, s.newAPIHandler(func(r *http.Request) (any, error) {
+		view := s.view.StopContainer(r.Context(), r.PathValue("containerID"))
+		return &APIResult{OK: true}, view.Err
+	})))
+	mux.HandleFunc("POST "+apiPrefix+"/containers/{containerID}/restart", s.require(RoleOperator, s.newAPIHandler(func(r *http.Request) (any, error) {
+		view := s.view.RestartContainer(r.Context(), r.PathValue("containerID"))
+		return &APIResult{OK: true}, view.Err
+	})))
+	mux.HandleFunc("POST "+apiPrefix+"/containers/{containerID}/update", s.require(RoleAdmin, noWriteDeadline(s.newAPIHandler(func(r *http.Request) (any, error) {
+		view := s.view.UpdateContainer(context.WithoutCancel(r.Context()), r.PathValue("containerID"))
+		return view, view.Err
+	}))))
+	mux.HandleFunc("POST "+apiPrefix+"/containers/{containerID}/rollback", s.require(RoleAdmin, s.newAPIHandler(func(r *http.Request) (any, error) {
+		view := s.view.RollbackContainer(r.Context(), r.PathValue("containerID"))
+		return &APIResult{OK: true}, view.Err
+	})))
+	mux.HandleFunc("DELETE "+apiPrefix+"/containers/{containerID}", s.require(RoleAdmin, s.newAPIHandler(func(r *http.Request) (any, error) {
+		view := s.view.RemoveContainer(r.Context(), r.PathValue("containerID"))
+		return nil, view.Err
+	})))
+
+	mux.HandleFunc("GET "+apiPrefix+"/images", s.require(RoleViewer, s.newAPIHandler(func(r *http.Request) (any, error) {
+		return s.view.ListImages(r.Context())
+	})))
+	mux.HandleFunc("DELETE "+apiPrefix+"/images/{imageID}", s.require(RoleAdmin, s.newAPIHandler(func(r *http.Request) (any, error) {
+		view := s.view.RemoveImage(r.Context(), r.PathValue("imageID"), r.URL.Query().Get("force") == "true")
+		return nil, view.Err
+	})))
+	mux.HandleFunc("POST "+apiPrefix+"/images/prune", s.require(RoleAdmin, s.newAPIHandler(func(r *http.Request) (any, error) {
+		return actionResult(s.view.PruneImages(r.Context(), r.URL.Query().Get("all") == "true"))
+	})))
+	mux.HandleFunc("POST "+apiPrefix+"/images/{imageID}/push", s.require(RoleAdmin, noWriteDeadline(s.newAPIHandler(func(r *http.Request) (any, error) {
+		var body APIImagePush
+		if err := decodeJSON(r, &body); err != nil {
+			return nil, err
+		}
+		return actionResult(s.view.PushImage(context.WithoutCancel(r.Context()), r.PathValue("imageID"), body.Repository, body.Tag))
+	}))))
+
+	mux.HandleFunc("GET "+apiPrefix+"/volumes", s.require(RoleViewer, s.newAPIHandler(func(r *http.Request) (any, error) {
+		return s.view.ListVolumes(r.Context())
+	})))
+	mux.HandleFunc("POST "+apiPrefix+"/volumes", s.require(RoleAdmin, s.newAPIHandler(func(r *http.Request) (any, error) {
+		var body APIVolume
+		if err := decodeJSON(r, &body); err != nil {
+			return nil, err
+		}
+		view := s.view.CreateVolume(r.Context(), body.Name, body.Driver, body.Labels)
+		return view.Volume, view.Err
+	})))
+	mux.HandleFunc("POST "+apiPrefix+"/volumes/prune", s.require(RoleAdmin, s.newAPIHandler(func(r *http.Request) (any, error) {
+		return actionResult(s.view.PruneVolumes(r.Context(), r.URL.Query().Get("all") == "true"))
+	})))
+	mux.HandleFunc("DELETE "+apiPrefix+"/volumes/{name}", s.require(RoleAdmin, s.newAPIHandler(func(r *http.Request) (any, error) {
+		view := s.view.RemoveVolume(r.Context(), r.PathValue("name"))
+		return nil, view.Err
+	})))
+
+	mux.HandleFunc("GET "+apiPrefix+"/networks", s.require(RoleViewer, s.newAPIHandler(func(r *http.Request) (any, error) {
+		return s.view.ListNetworks(r.Context())
+	})))
+	mux.HandleFunc("POST "+apiPrefix+"/networks", s.require(RoleAdmin, s.newAPIHandler(func(r *http.Request) (any, error) {
+		var body APINetwork
+		if err := decodeJSON(r, &body); err != nil {
+			return nil, err
+		}
+		view := s.view.CreateNetwork(r.Context(), body.Name, body.Driver, body.Subnet, body.Internal, body.Labels)
+		return view.Network, view.Err
+	})))
+	mux.HandleFunc("POST "+apiPrefix+"/networks/prune", s.require(RoleAdmin, s.newAPIHandler(func(r *http.Request) (any, error) {
+		return actionResult(s.view.PruneNetworks(r.Context()))
+	})))
+	mux.HandleFunc("DELETE "+apiPrefix+"/networks/{networkID}", s.require(RoleAdmin, s.newAPIHandler(func(r *http.Request) (any, error) {
+		view := s.view.RemoveNetwork(r.Context(), r.PathValue("networkID"))
+		return nil, view.Err
+	})))
+
+	mux.HandleFunc("GET "+apiPrefix+"/stacks", s.require(RoleViewer, s.newAPIHandler(func(r *http.Request) (any, error) {
+		view := s.view.GetStacks(r.Context())
+		return view.Stacks, view.Err
+	})))
+	mux.HandleFunc("GET "+apiPrefix+"/stacks/{name}", s.require(RoleViewer, s.newAPIHandler(func(r *http.Request) (any, error) {
+		view := s.view.GetStack(r.Context(), r.PathValue("name"))
+		if view.Err != nil {
+			return nil, view.Err
+		}
+		stack := &APIStack{StackSummary: view.Stack, Compose: view.Compose, Containers: view.Containers}
+		if stack.Containers == nil {
+			stack.Containers = []types.Container{}
+		}
+		return stack, nil
+	})))
+	mux.HandleFunc("PUT "+apiPrefix+"/stacks/{name}", s.require(RoleAdmin, s.newAPIHandler(func(r *http.Request) (any, error) {
+		var body APIStackCompose
+		if err := decodeJSON(r, &body); err != nil {
+			return nil, err
+		}
+		return actionResult(s.view.SaveStack(r.Context(), r.PathValue("name"), body.Compose))
+	})))
+	mux.HandleFunc("DELETE "+apiPrefix+"/stacks/{name}", s.require(RoleAdmin, s.newAPIHandler(func(r *http.Request) (any, error) {
+		_, err := actionResult(s.view.DeleteStack(r.Context(), r.PathValue("name")))
+		return nil, err
+	})))
+	mux.HandleFunc("POST "+apiPrefix+"/stacks/{name}/plan", s.require(RoleAdmin, s.newAPIHandler(func(r *http.Request) (any, error) {
+		compose, err := s.apiStackCompose(r)
+		if err != nil {
+			return nil, err
+		}
+		view := s.view.PlanStack(r.Context(), r.PathValue("name"), compose)
+		return view, view.Err
+	})))
+	mux.HandleFunc("POST "+apiPrefix+"/stacks/{name}/deploy", s.require(RoleAdmin, noWriteDeadline(s.newAPIHandler(func(r *http.Request) (any, error) {
+		compose, err := s.apiStackCompose(r)
+		if err != nil {
+			return nil, err
+		}
+		view := s.view.DeployStack(context.WithoutCancel(r.Context()), r.PathValue("name"), compose)
+		return view, view.Err
+	}))))
+	mux.HandleFunc("POST "+apiPrefix+"/stacks/{name}/down", s.require(RoleAdmin, noWriteDeadline(s.newAPIHandler(func(r *http.Request) (any, error) {
+		view := s.view.DownStack(context.WithoutCancel(r.Context()), r.PathValue("name"))
+		return view, view.Err
+	}))))
+
+	mux.HandleFunc("GET "+apiPrefix+"/registry/repositories", s.require(RoleViewer, s.newAPIHandler(func(r *http.Request) (any, error) {
+		return s.view.GetRegistryCatalog(r.Context())
+	})))
+	mux.HandleFunc("GET "+apiPrefix+"/registry/repositories/{repository}/tags", s.require(RoleViewer, s.newAPIHandler(func(r *http.Request) (any, error) {
+		return s.view.GetRegistryTags(r.Context(), r.PathValue("repository"))
+	})))
+	mux.HandleFunc("GET "+apiPrefix+"/registry/repositories/{repository}/tags/{tag}", s.require(RoleViewer, s.newAPIHandler(func(r *http.Request) (any, error) {
+		return s.view.GetRegistryManifest(r.Context(), r.PathValue("repository"), r.PathValue("tag"))
+	})))
+	mux.HandleFunc("DELETE "+apiPrefix+"/registry/repositories/{repository}/tags/{tag}", s.require(RoleAdmin, s.newAPIHandler(func(r *http.Request) (any, error) {
+		_, err := actionResult(s.view.DeleteRegistryImage(r.Context(), r.PathValue("repository"), r.PathValue("tag")))
+		return nil, err
+	})))
+
+	mux.HandleFunc("GET "+apiPrefix+"/devices", s.require(RoleViewer, s.newAPIHandler(func(r *http.Request) (any, error) {
+		view := s.view.GetDevices(r.Context())
+		if view.Err != nil {
+			return nil, view.Err
+		}
+		return view.Devices, nil
+	})))
+	mux.HandleFunc("GET "+apiPrefix+"/devices/{deviceID}", s.require(RoleViewer, s.newAPIHandler(func(r *http.Request) (any, error) {
+		view := s.view.GetDevice(r.Context(), r.PathValue("deviceID"))
+		if view.Err != nil {
+			return nil, view.Err
+		}
+		return &APIDevice{
+			Device:       view.Device,
+			Assets:       view.Assets,
+			History:      view.History,
+			Services:     view.Services,
+			Missing:      view.Missing,
+			Metrics:      view.Metrics,
+			Certificates: view.Certificates,
+		}, nil
+	})))
+	mux.HandleFunc("POST "+apiPrefix+"/devices/{deviceID}/packages/{name}/rollback", s.require(RoleOperator, s.newAPIHandler(func(r *http.Request) (any, error) {
+		return actionResult(s.view.RollbackPackage(r.Context(), r.PathValue("deviceID"), r.PathValue("name")))
+	})))
+
+	mux.HandleFunc("POST "+apiPrefix+"/releases", s.require(RoleOperator, s.newAPIHandler(func(r *http.Request) (any, error) {
+		var req struct {
+			Package string `json:"package"`
+			Tag     string `json:"tag"`
+		}
+		if err := decodeJSON(r, &req); err != nil {
+			return nil, err
+		}
+		if len(req.Package) == 0 {
+			return nil, badRequest(errors.New("package is required"))
+		}
+		return actionResult(s.view.PushRelease(r.Context(), req.Package, req.Tag))
+	})))
+
+	mux.HandleFunc("GET "+apiPrefix+"/services", s.require(RoleViewer, s.newAPIHandler(func(r *http.Request) (any, error) {
+		view := s.view.GetServices(r.Context())
+		if view.Err != nil {
+			return nil, view.Err
+		}
+		return view.Hosts, nil
+	})))
+	mux.HandleFunc("POST "+apiPrefix+"/services/{serviceID}/{action}", s.require(RoleOperator, s.newAPIHandler(func(r *http.Request) (any, error) {
+		return actionResult(s.view.DeviceServiceAction(r.Context(), r.PathValue("serviceID"), r.PathValue("action")))
+	})))
+
+	mux.HandleFunc("GET "+apiPrefix+"/incidents", s.require(RoleViewer, s.newAPIHandler(func(r *http.Request) (any, error) {
+		return s.view.ListIncidents(r.Context())
+	})))
+	mux.HandleFunc("POST "+apiPrefix+"/incidents/{incidentID}/resolve", s.require(RoleOperator, s.newAPIHandler(func(r *http.Request) (any, error) {
+		return actionResult(s.view.ResolveIncident(r.Context(), r.PathValue("incidentID")))
+	})))
+
+	mux.HandleFunc("GET "+apiPrefix+"/packages", s.require(RoleViewer, s.newAPIHandler(func(r *http.Request) (any, error) {
+		view := s.view.GetPackages(r.Context())
+		if view.Err != nil {
+			return nil, view.Err
+		}
+		packages := make([]APIPackage, len(view.Packages))
+		for i, pkg := range view.Packages {
+			packages[i] = newAPIPackage(pkg)
+		}
+		return packages, nil
+	})))
+	mux.HandleFunc("POST "+apiPrefix+"/packages", s.require(RoleAdmin, s.newAPIHandler(func(r *http.Request) (any, error) {
+		var req APIPackage
+		if err := decodeJSON(r, &req); err != nil {
+			return nil, err
+		}
+		view := s.view.AddPackage(r.Context(), db.AddPackageParams{
+			Name:       req.Name,
+			InstallCmd: req.InstallCmd,
+			UpdateCmd:  req.UpdateCmd,
+			RemoveCmd:  sql.NullString{String: req.RemoveCmd, Valid: len(req.RemoveCmd) > 0},
+		})
+		if view.Err != nil {
+			return nil, view.Err
+		}
+		if len(view.Toast) > 0 {
+			return nil, badRequest(errors.New(view.Toast))
+		}
+		return newAPIPackage(view.Packages[len(view.Packages)-1]), nil
+	})))
+	mux.HandleFunc("DELETE "+apiPrefix+"/packages/{ID}", s.require(RoleAdmin, s.newAPIHandler(func(r *http.Request) (any, error) {
+		view := s.view.DeletePackage(r.Context(), r.PathValue("ID"))
+		if !view.IsSuccess {
+			return nil, badRequest(errors.New(view.Toast))
+		}
+		return nil, nil
+	})))
+
+	mux.HandleFunc("GET "+apiPrefix+"/settings", s.require(RoleAdmin, s.newAPIHandler(func(r *http.Request) (any, error) {
+		settings, err := s.view.ListSettings(r.Context())
+		if err != nil {
+			return nil, err
+		}
+		view := s.view.GetSettings(r.Context())
+		watched := make([]string, len(view.WatchedServices))
+		for i, svc := range view.WatchedServices {
+			watched[i] = svc.Service
+		}
+		return &APISettings{Settings: settings, WatchedServices: watched}, nil
+	})))
+	mux.HandleFunc("PUT "+apiPrefix+"/settings/{name}", s.require(RoleAdmin, s.newAPIHandler(func(r *http.Request) (any, error) {
+		name := r.PathValue("name")
+		settings, err := s.view.ListSettings(r.Context())
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := settings[name]; !ok {
+			return nil, notFound(fmt.Errorf("unknown setting %s", name))
+		}
+		var req struct {
+			Value string `json:"value"`
+		}
+		if err = decodeJSON(r, &req); err != nil {
+			return nil, err
+		}
+		if err = s.view.PostSettings(r.Context(), db.UpdateSettingParams{Name: name, Value: req.Value}); err != nil {
+			return nil, badRequest(err)
+		}
+		return &APIResult{OK: true}, nil
+	})))
+	mux.HandleFunc("POST "+apiPrefix+"/settings/watched-services", s.require(RoleAdmin, s.newAPIHandler(func(r *http.Request) (any, error) {
+		var req struct {
+			Name string `json:"name"`
+		}
+		if err := decodeJSON(r, &req); err != nil {
+			return nil, err
+		}
+		view := s.view.PostSettingsWatchedService(r.Context(), req.Name)
+		if view.Err != nil {
+			return nil, view.Err
+		}
+		return &req, nil
+	})))
+	mux.HandleFunc("DELETE "+apiPrefix+"/settings/watched-services/{name}", s.require(RoleAdmin, s.newAPIHandler(func(r *http.Request) (any, error) {
+		return nil, s.view.DeleteWatchedService(r.Context(), r.PathValue("name")).Err
+	})))
+
+	mux.HandleFunc("POST "+apiPrefix+"/settings/notifications/{channel}/test", s.require(RoleAdmin, s.newAPIHandler(func(r *http.Request) (any, error) {
+		return actionResult(s.view.TestNotification(r.Context(), r.PathValue("channel")))
+	})))
+
+	mux.HandleFunc("GET "+apiPrefix+"/tokens", s.require(RoleViewer, s.newAPIHandler(func(r *http.Request) (any, error) {
+		return s.view.ListAPITokens(r.Context(), tokenOwner(r.Context()))
+	})))
+	mux.HandleFunc("POST "+apiPrefix+"/tokens", s.require(RoleViewer, s.newAPIHandler(func(r *http.Request) (any, error) {
+		var req struct {
+			Name          string `json:"name"`
+			Scope         string `json:"scope"`
+			ExpiresInDays int    `json:"expiresInDays"`
+		}
+		if err := decodeJSON(r, &req); err != nil {
+			return nil, err
+		}
+		view := s.createAPIToken(r.Context(), req.Name, req.Scope, req.ExpiresInDays)
+		if view.Err != nil {
+			return nil, badRequest(view.Err)
+		}
+		return &APINewToken{APITokenView: view.APIToken, Token: view.Secret}, nil
+	})))
+	mux.HandleFunc("DELETE "+apiPrefix+"/tokens/{tokenID}", s.require(RoleViewer, s.newAPIHandler(func(r *http.Request) (any, error) {
+		_, err := actionResult(s.view.RevokeAPIToken(r.Context(), r.PathValue("tokenID"), tokenOwner(r.Context())))
+		return nil, err
+	})))
+
+	mux.HandleFunc("POST "+apiPrefix+"/watchtower/update", s.require(RoleOperator, s.newAPIHandler(func(r *http.Request) (any, error) {
+		return actionResult(s.view.WatchtowerUpdate(r.Context()))
+	})))
+
+	mux.HandleFunc(apiPrefix+"/", s.require(RoleViewer, func(w http.ResponseWriter, r *http.Request) {
+		writeAPIError(w, http.StatusNotFound, fmt.Sprintf("no route for %s %s", r.Method, r.URL.Path))
+	}))
+}
+
+// HandleOpenAPI serves the OpenAPI document that describes the JSON API
+func (s *Server) HandleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	http.ServeFile(w, r, path.Join(s.config.StaticDir, "openapi.json"))
+}
+
+// HandleAPIContainerLogs writes the logs of the container as plain text, and keeps writing new lines if follow is set
+func (s *Server) HandleAPIContainerLogs(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	follow, _ := strconv.ParseBool(query.Get("follow"))
+	timestamps, _ := strconv.ParseBool(query.Get("timestamps"))
+	opts := container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     follow,
+		Timestamps: timestamps,
+		Tail:       query.Get("tail"),
+	}
+	logs, tty, err := s.view.OpenContainerLogs(r.Context(), r.PathValue("containerID"), opts)
+	if err != nil {
+		writeAPIError(w, apiStatus(err), err.Error())
+		return
+	}
+	defer logs.Close()
+
+	rc := http.NewResponseController(w)
+	if follow {
+		// following outlives the server's write timeout
+		if err = rc.SetWriteDeadline(time.Time{}); err != nil {
+			slog.Warn("cannot clear write deadline for log stream", "err", err)
+		}
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	out := &flushWriter{w: w, rc: rc}
+	if tty {
+		_, err = io.Copy(out, logs)
+	} else {
+		_, err = stdcopy.StdCopy(out, out, logs)
+	}
+	if err != nil && r.Context().Err() == nil {
+		slog.Warn("container log stream ended", "container", r.PathValue("containerID"), "err", err)
+	}
+}
+
+// flushWriter sends every write to the client right away
+type flushWriter struct {
+	w  io.Writer
+	rc *http.ResponseController
+}
+
+func (f *flushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	if err != nil {
+		return n, err
+	}
+	return n, f.rc.Flush()
+}
@@ -0,0 +1,440 @@
+package mahogany
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	db "github.com/mpoegel/mahogany/internal/db"
+	sources "github.com/mpoegel/mahogany/pkg/mahogany/sources"
+	views "github.com/mpoegel/mahogany/pkg/mahogany/views"
+	bcrypt "golang.org/x/crypto/bcrypt"
+)
+
+const (
+	sessionCookie = "mahogany_session"
+	// the csrf cookie is readable by scripts so that htmx can echo it back in the csrf header
+	csrfCookie = "mahogany_csrf"
+	csrfHeader = "X-CSRF-Token"
+	csrfField  = "csrf_token"
+
+	minPasswordLength = 8
+)
+
+var (
+	ErrInvalidLogin     = errors.New("invalid username or password")
+	ErrPasswordTooShort = errors.New("password must be at least 8 characters")
+)
+
+// dummyPasswordHash is compared against when a login names an unknown user
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte(rand.Text()), bcrypt.DefaultCost)
+	return hash
+})
+
+// Role grants a user everything the roles below it can do
+type Role int
+
+const (
+	// RoleViewer can see containers, logs, the registry and the fleet
+	RoleViewer Role = iota
+	// RoleOperator can also start, stop and restart containers and services, and trigger updates
+	RoleOperator
+	// RoleAdmin can also delete containers and images, change settings and manage packages
+	RoleAdmin
+)
+
+var roleNames = map[Role]string{
+	RoleViewer:   "viewer",
+	RoleOperator: "operator",
+	RoleAdmin:    "admin",
+}
+
+func (r Role) String() string {
+	return roleNames[r]
+}
+
+func ParseRole(name string) (Role, error) {
+	for role, roleName := range roleNames {
+		if roleName == name {
+			return role, nil
+		}
+	}
+	return RoleViewer, fmt.Errorf("unknown role %q, expected viewer, operator or admin", name)
+}
+
+// User is the authenticated user of a request
+type User struct {
+	ID       int64
+	Username string
+	Role     Role
+	// Token names the API token the request was made with, if it did not come from a session
+	Token string
+
+	csrfToken string
+}
+
+// actor is who the audit log records for the user's actions
+func (u *User) actor() string {
+	if len(u.Token) > 0 {
+		return fmt.Sprintf("%s (token %s)", u.Username, u.Token)
+	}
+	return u.Username
+}
+
+type userContextKey struct{}
+
+// UserFromContext returns the user that was authenticated for the request, if any
+func UserFromContext(ctx context.Context) (*User, bool) {
+	user, ok := ctx.Value(userContextKey{}).(*User)
+	return user, ok
+}
+
+func HashPassword(password string) (string, error) {
+	if len(password) < minPasswordLength {
+		return "", ErrPasswordTooShort
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// AddUser creates a user with a hashed password
+func AddUser(ctx context.Context, query *db.Queries, username, password string, role Role) error {
+	if len(username) == 0 {
+		return errors.New("username is required")
+	}
+	hash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+	_, err = query.AddUser(ctx, db.AddUserParams{
+		Username:     username,
+		PasswordHash: hash,
+		Role:         role.String(),
+		CreatedAt:    time.Now().Unix(),
+	})
+	return err
+}
+
+// hashToken hashes session tokens before they are stored so that a leaked database does not leak live sessions
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// login checks the credentials and starts a new session, returning the session and csrf tokens
+func (s *Server) login(ctx context.Context, username, password string) (string, string, error) {
+	user, err := s.query.GetUserByUsername(ctx, username)
+	if errors.Is(err, sql.ErrNoRows) {
+		// compare anyway so that unknown usernames take as long as wrong passwords
+		bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(password))
+		return "", "", ErrInvalidLogin
+	} else if err != nil {
+		return "", "", err
+	}
+	if err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return "", "", ErrInvalidLogin
+	}
+
+	now := time.Now()
+	if err = s.query.DeleteExpiredSessions(ctx, now.Unix()); err != nil {
+		slog.Warn("cannot delete expired sessions", "err", err)
+	}
+	token := rand.Text()
+	csrfToken := rand.Text()
+	err = s.query.AddSession(ctx, db.AddSessionParams{
+		TokenHash: hashToken(token),
+		UserID:    user.ID,
+		CsrfToken: csrfToken,
+		CreatedAt: now.Unix(),
+		ExpiresAt: now.Add(s.config.SessionTTL).Unix(),
+	})
+	if err != nil {
+		return "", "", err
+	}
+	return token, csrfToken, nil
+}
+
+// authenticate returns the user of the session cookie on the request
+func (s *Server) authenticate(r *http.Request) (*User, error) {
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return nil, err
+	}
+	session, err := s.query.GetSession(r.Context(), hashToken(cookie.Value))
+	if err != nil {
+		return nil, err
+	}
+	if time.Now().Unix() > session.ExpiresAt {
+		return nil, errors.New("session expired")
+	}
+	role, err := ParseRole(session.Role)
+	if err != nil {
+		return nil, err
+	}
+	return &User{
+		ID:        session.UserID,
+		Username:  session.Username,
+		Role:      role,
+		csrfToken: session.CsrfToken,
+	}, nil
+}
+
+// authenticateToken returns the user of the bearer token on the request. The user gets the scope of the token, or
+// their own role if it has since been lowered.
+func (s *Server) authenticateToken(r *http.Request, bearer string) (*User, error) {
+	token, err := s.query.GetApiToken(r.Context(), views.HashAPIToken(bearer))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().Unix()
+	if token.RevokedAt.Valid {
+		return nil, errors.New("api token revoked")
+	}
+	if token.ExpiresAt.Valid && now > token.ExpiresAt.Int64 {
+		return nil, errors.New("api token expired")
+	}
+	role, err := ParseRole(token.Role)
+	if err != nil {
+		return nil, err
+	}
+	scope, err := ParseRole(token.Scope)
+	if err != nil {
+		return nil, err
+	}
+	err = s.query.UpdateApiTokenLastUsed(r.Context(), db.UpdateApiTokenLastUsedParams{
+		LastUsedAt: sql.NullInt64{Int64: now, Valid: true},
+		ID:         token.ID,
+	})
+	if err != nil {
+		slog.Warn("cannot update api token last used", "name", token.Name, "err", err)
+	}
+	return &User{
+		ID:       token.UserID,
+		Username: token.Username,
+		Role:     min(role, scope),
+		Token:    token.Name,
+	}, nil
+}
+
+// createAPIToken issues a token for the user of the request. The scope cannot be above the user's own role, and an
+// expiry of zero days never expires.
+func (s *Server) createAPIToken(ctx context.Context, name, scopeName string, expiresInDays int) *views.NewAPITokenView {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return &views.NewAPITokenView{Err: errors.New("authentication required")}
+	}
+	scope, err := ParseRole(scopeName)
+	if err == nil && scope > user.Role {
+		err = fmt.Errorf("%s cannot create %s tokens", user.Role, scope)
+	}
+	if err != nil {
+		return &views.NewAPITokenView{Err: err}
+	}
+	ttl := time.Duration(expiresInDays) * 24 * time.Hour
+	return s.view.CreateAPIToken(ctx, user.ID, user.Username, name, scope.String(), ttl)
+}
+
+// tokenOwner is the user whose API tokens the user of the request can list and revoke. Admins manage the tokens of
+// every user, which is an owner of zero.
+func tokenOwner(ctx context.Context) int64 {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		// no token has this owner
+		return -1
+	}
+	if user.Role == RoleAdmin {
+		return 0
+	}
+	return user.ID
+}
+
+// checkCSRF requires requests that change state to echo the csrf token of their session. Token requests do not have
+// a session, and browsers do not send tokens on their own, so they need no csrf token.
+func checkCSRF(r *http.Request, user *User) bool {
+	if len(user.Token) > 0 {
+		return true
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	token := r.Header.Get(csrfHeader)
+	if len(token) == 0 {
+		token = r.PostFormValue(csrfField)
+	}
+	return len(token) > 0 && subtle.ConstantTimeCompare([]byte(token), []byte(user.csrfToken)) == 1
+}
+
+// requireAuth only lets requests with a valid session through. Browsers are sent to the login page, and htmx
+// requests are told to redirect there since htmx will not follow a redirect with a full page load.
+func (s *Server) requireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			user, err := s.authenticateToken(r, strings.TrimSpace(bearer))
+			if err != nil {
+				slog.Warn("rejected request with invalid api token", "method", r.Method, "path", r.URL.Path, "err", err)
+				if isAPIRequest(r) {
+					writeAPIError(w, http.StatusUnauthorized, "invalid api token")
+				} else {
+					http.Error(w, "invalid api token", http.StatusUnauthorized)
+				}
+				return
+			}
+			ctx := context.WithValue(r.Context(), userContextKey{}, user)
+			next.ServeHTTP(w, r.WithContext(sources.WithActor(ctx, user.actor())))
+			return
+		}
+
+		user, err := s.authenticate(r)
+		if err != nil && isAPIRequest(r) {
+			writeAPIError(w, http.StatusUnauthorized, "authentication required")
+			return
+		} else if err != nil {
+			loginURL := "/login?next=" + url.QueryEscape(r.URL.RequestURI())
+			if r.Header.Get("HX-Request") == "true" {
+				// return to the page that made the request rather than the fragment it asked for
+				if current, err := url.Parse(r.Header.Get("HX-Current-URL")); err == nil {
+					loginURL = "/login?next=" + url.QueryEscape(current.RequestURI())
+				}
+				w.Header().Set("HX-Redirect", loginURL)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			http.Redirect(w, r, loginURL, http.StatusSeeOther)
+			return
+		}
+		if !checkCSRF(r, user) {
+			slog.Warn("rejected request with invalid csrf token", "user", user.Username, "method", r.Method, "path", r.URL.Path)
+			if isAPIRequest(r) {
+				writeAPIError(w, http.StatusForbidden, "invalid csrf token")
+			} else {
+				http.Error(w, "invalid csrf token", http.StatusForbidden)
+			}
+			return
+		}
+		ctx := context.WithValue(r.Context(), userContextKey{}, user)
+		next.ServeHTTP(w, r.WithContext(sources.WithActor(ctx, user.actor())))
+	})
+}
+
+// require only lets users with at least the given role through. The templates hide what a user cannot do, but this
+// is what enforces it.
+func (s *Server) require(role Role, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := UserFromContext(r.Context())
+		if !ok || user.Role < role {
+			username := ""
+			if ok {
+				username = user.Username
+			}
+			slog.Warn("forbidden request", "user", username, "method", r.Method, "path", r.URL.Path, "required", role)
+			if isAPIRequest(r) {
+				writeAPIError(w, http.StatusForbidden, fmt.Sprintf("%s role required", role))
+			} else {
+				http.Error(w, fmt.Sprintf("%s role required", role), http.StatusForbidden)
+			}
+			return
+		}
+		next(w, r)
+	}
+}
+
+// canFunc backs the "can" template function, which reports whether the user of the request has a role
+func canFunc(ctx context.Context) func(string) bool {
+	return func(roleName string) bool {
+		user, ok := UserFromContext(ctx)
+		if !ok {
+			return false
+		}
+		role, err := ParseRole(roleName)
+		if err != nil {
+			slog.Error("template checks unknown role", "role", roleName)
+			return false
+		}
+		return user.Role >= role
+	}
+}
+
+func (s *Server) setSessionCookies(w http.ResponseWriter, token, csrfToken string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   s.config.SecureCookies,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookie,
+		Value:    csrfToken,
+		Path:     "/",
+		MaxAge:   maxAge,
+		Secure:   s.config.SecureCookies,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+type LoginView struct {
+	Next string
+	Err  error
+}
+
+func (v *LoginView) Name() string         { return "LoginView" }
+func (v *LoginView) Headers() http.Header { return http.Header{} }
+
+func (s *Server) HandleLoginPage(r *http.Request) Viewer {
+	return &LoginView{Next: safeRedirect(r.URL.Query().Get("next"))}
+}
+
+func (s *Server) HandleLogin(w http.ResponseWriter, r *http.Request) {
+	next := safeRedirect(r.PostFormValue("next"))
+	token, csrfToken, err := s.login(r.Context(), r.PostFormValue("username"), r.PostFormValue("password"))
+	if err != nil {
+		if !errors.Is(err, ErrInvalidLogin) {
+			slog.Error("login failed", "err", err)
+		}
+		slog.Warn("failed login attempt", "username", r.PostFormValue("username"), "remote", r.RemoteAddr)
+		s.newHandler(func(r *http.Request) Viewer {
+			return &LoginView{Next: next, Err: ErrInvalidLogin}
+		})(w, r)
+		return
+	}
+	slog.Info("user logged in", "username", r.PostFormValue("username"))
+	s.setSessionCookies(w, token, csrfToken, int(s.config.SessionTTL.Seconds()))
+	http.Redirect(w, r, next, http.StatusSeeOther)
+}
+
+func (s *Server) HandleLogout(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		if err = s.query.DeleteSession(r.Context(), hashToken(cookie.Value)); err != nil {
+			slog.Warn("cannot delete session", "err", err)
+		}
+	}
+	s.setSessionCookies(w, "", "", -1)
+	w.Header().Set("HX-Redirect", "/login")
+	w.WriteHeader(http.StatusOK)
+}
+
+// safeRedirect only allows redirects to local paths after login
+func safeRedirect(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
@@ -0,0 +1,587 @@
+package mahogany
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	db "github.com/mpoegel/mahogany/internal/db"
+	dbtest "github.com/mpoegel/mahogany/internal/db/dbtest"
+	views "github.com/mpoegel/mahogany/pkg/mahogany/views"
+)
+
+const testPassword = "correct horse"
+
+// newAuthServer creates a server with a user of each role, named after the role
+func newAuthServer(t *testing.T, sessionTTL time.Duration) *Server {
+	t.Helper()
+	query := db.New(dbtest.New(t))
+	for _, role := range []Role{RoleViewer, RoleOperator, RoleAdmin} {
+		if err := AddUser(t.Context(), query, role.String(), testPassword, role); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &Server{config: Config{SessionTTL: sessionTTL}, query: query, ctx: t.Context()}
+}
+
+// okHandler records the user that a request was let through with
+func okHandler(user **User) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*user, _ = UserFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func TestLogin(t *testing.T) {
+	s := newAuthServer(t, time.Hour)
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+		wantErr  error
+	}{
+		{"valid", "operator", testPassword, nil},
+		{"wrong password", "operator", "incorrect horse", ErrInvalidLogin},
+		{"unknown user", "nobody", testPassword, ErrInvalidLogin},
+		{"empty password", "operator", "", ErrInvalidLogin},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, csrfToken, err := s.login(t.Context(), tt.username, tt.password)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.AddCookie(&http.Cookie{Name: sessionCookie, Value: token})
+			user, err := s.authenticate(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if user.Username != tt.username || user.Role != RoleOperator || user.csrfToken != csrfToken {
+				t.Errorf("got user %+v", user)
+			}
+		})
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	s := newAuthServer(t, time.Hour)
+	token, _, err := s.login(t.Context(), "viewer", testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired := newAuthServer(t, -time.Minute)
+	expiredToken, _, err := expired.login(t.Context(), "viewer", testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		server *Server
+		cookie *http.Cookie
+		ok     bool
+	}{
+		{"session", s, &http.Cookie{Name: sessionCookie, Value: token}, true},
+		{"no cookie", s, nil, false},
+		{"unknown session", s, &http.Cookie{Name: sessionCookie, Value: "guess"}, false},
+		// only the hash of the token is stored, so the stored value does not work as a session
+		{"stored hash", s, &http.Cookie{Name: sessionCookie, Value: hashToken(token)}, false},
+		{"expired", expired, &http.Cookie{Name: sessionCookie, Value: expiredToken}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.cookie != nil {
+				req.AddCookie(tt.cookie)
+			}
+			if _, err := tt.server.authenticate(req); (err == nil) != tt.ok {
+				t.Errorf("got error %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestCheckCSRF(t *testing.T) {
+	session := &User{Username: "admin", Role: RoleAdmin, csrfToken: "csrf"}
+	token := &User{Username: "admin", Role: RoleAdmin, Token: "ci"}
+
+	tests := []struct {
+		name   string
+		method string
+		user   *User
+		header string
+		field  string
+		want   bool
+	}{
+		{"get", http.MethodGet, session, "", "", true},
+		{"head", http.MethodHead, session, "", "", true},
+		{"post with header", http.MethodPost, session, "csrf", "", true},
+		{"post with field", http.MethodPost, session, "", "csrf", true},
+		{"post without token", http.MethodPost, session, "", "", false},
+		{"post with wrong header", http.MethodPost, session, "guess", "csrf", false},
+		{"post with wrong field", http.MethodPost, session, "", "guess", false},
+		{"delete without token", http.MethodDelete, session, "", "", false},
+		{"empty session token", http.MethodPost, &User{Username: "admin"}, "", "", false},
+		{"api token", http.MethodPost, token, "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			if len(tt.field) > 0 {
+				form.Set(csrfField, tt.field)
+			}
+			req := httptest.NewRequest(tt.method, "/settings", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if len(tt.header) > 0 {
+				req.Header.Set(csrfHeader, tt.header)
+			}
+			if got := checkCSRF(req, tt.user); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequireAuth(t *testing.T) {
+	s := newAuthServer(t, time.Hour)
+	token, csrfToken, err := s.login(t.Context(), "admin", testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		session      string
+		headers      map[string]string
+		want         int
+		wantLocation string
+	}{
+		{"page with session", http.MethodGet, "/containers", token, nil, http.StatusOK, ""},
+		{"post with csrf", http.MethodPost, "/settings", token, map[string]string{csrfHeader: csrfToken}, http.StatusOK, ""},
+		{"post without csrf", http.MethodPost, "/settings", token, nil, http.StatusForbidden, ""},
+		{"api post without csrf", http.MethodPost, "/api/v1/watchtower/update", token, nil, http.StatusForbidden, ""},
+		{"page without session", http.MethodGet, "/container/abc?tab=logs", "", nil, http.StatusSeeOther, "/login?next=%2Fcontainer%2Fabc%3Ftab%3Dlogs"},
+		{"invalid session", http.MethodGet, "/containers", "guess", nil, http.StatusSeeOther, "/login?next=%2Fcontainers"},
+		{"htmx without session", http.MethodGet, "/containers/list", "", map[string]string{"HX-Request": "true", "HX-Current-URL": "http://mahogany/containers"}, http.StatusUnauthorized, "/login?next=%2Fcontainers"},
+		{"api without session", http.MethodGet, "/api/v1/containers", "", nil, http.StatusUnauthorized, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var user *User
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if len(tt.session) > 0 {
+				req.AddCookie(&http.Cookie{Name: sessionCookie, Value: tt.session})
+			}
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+			w := httptest.NewRecorder()
+			s.requireAuth(okHandler(&user)).ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Fatalf("got status %d, want %d", w.Code, tt.want)
+			}
+			location := w.Header().Get("Location")
+			if tt.headers["HX-Request"] == "true" {
+				// htmx does not follow redirects with a full page load, so it is told where to go instead
+				location = w.Header().Get("HX-Redirect")
+			}
+			if location != tt.wantLocation {
+				t.Errorf("got location %q, want %q", location, tt.wantLocation)
+			}
+			if (user != nil) != (tt.want == http.StatusOK) {
+				t.Errorf("got user %+v with status %d", user, w.Code)
+			}
+		})
+	}
+}
+
+func TestHandleLogout(t *testing.T) {
+	s := newAuthServer(t, time.Hour)
+	token, _, err := s.login(t.Context(), "viewer", testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/logout", nil)
+	req.AddCookie(&http.Cookie{Name: sessionCookie, Value: token})
+	s.HandleLogout(httptest.NewRecorder(), req)
+
+	if _, err = s.authenticate(req); err == nil {
+		t.Error("session is still valid after logout")
+	}
+}
+
+func TestSafeRedirect(t *testing.T) {
+	tests := []struct {
+		next string
+		want string
+	}{
+		{"/containers", "/containers"},
+		{"/container/abc?tab=logs", "/container/abc?tab=logs"},
+		{"", "/"},
+		{"containers", "/"},
+		{"https://evil.example", "/"},
+		{"//evil.example", "/"},
+		{"/\\evil.example", "/"},
+		{"javascript:alert(1)", "/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.next, func(t *testing.T) {
+			if got := safeRedirect(tt.next); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequire(t *testing.T) {
+	s := &Server{}
+	tests := []struct {
+		name     string
+		user     *User
+		required Role
+		path     string
+		want     int
+	}{
+		{"viewer reads", &User{Username: "viewer", Role: RoleViewer}, RoleViewer, "/containers", http.StatusOK},
+		{"viewer operates", &User{Username: "viewer", Role: RoleViewer}, RoleOperator, "/container/abc/restart", http.StatusForbidden},
+		{"viewer administers", &User{Username: "viewer", Role: RoleViewer}, RoleAdmin, "/settings", http.StatusForbidden},
+		{"operator operates", &User{Username: "operator", Role: RoleOperator}, RoleOperator, "/container/abc/restart", http.StatusOK},
+		{"operator administers", &User{Username: "operator", Role: RoleOperator}, RoleAdmin, "/container/abc/delete", http.StatusForbidden},
+		{"admin administers", &User{Username: "admin", Role: RoleAdmin}, RoleAdmin, "/settings", http.StatusOK},
+		{"admin reads", &User{Username: "admin", Role: RoleAdmin}, RoleViewer, "/containers", http.StatusOK},
+		{"scoped token", &User{Username: "admin", Role: RoleViewer, Token: "ci"}, RoleOperator, "/api/v1/watchtower/update", http.StatusForbidden},
+		{"no user", nil, RoleViewer, "/containers", http.StatusForbidden},
+		{"api without user", nil, RoleViewer, "/api/v1/containers", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var user *User
+			req := httptest.NewRequest(http.MethodPost, tt.path, nil)
+			if tt.user != nil {
+				req = req.WithContext(context.WithValue(req.Context(), userContextKey{}, tt.user))
+			}
+			w := httptest.NewRecorder()
+			s.require(tt.required, okHandler(&user).ServeHTTP)(w, req)
+			if w.Code != tt.want {
+				t.Fatalf("got status %d, want %d", w.Code, tt.want)
+			}
+			if tt.want == http.StatusForbidden && strings.HasPrefix(tt.path, apiPrefix) {
+				if contentType := w.Header().Get("Content-Type"); contentType != "application/json" {
+					t.Errorf("got content type %q for an api request", contentType)
+				}
+			}
+		})
+	}
+}
+
+func TestCanFunc(t *testing.T) {
+	operator := context.WithValue(t.Context(), userContextKey{}, &User{Username: "operator", Role: RoleOperator})
+	tests := []struct {
+		name string
+		ctx  context.Context
+		role string
+		want bool
+	}{
+		{"lower role", operator, "viewer", true},
+		{"same role", operator, "operator", true},
+		{"higher role", operator, "admin", false},
+		{"unknown role", operator, "root", false},
+		{"no user", t.Context(), "viewer", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := canFunc(tt.ctx)(tt.role); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRole(t *testing.T) {
+	for _, role := range []Role{RoleViewer, RoleOperator, RoleAdmin} {
+		if got, err := ParseRole(role.String()); err != nil || got != role {
+			t.Errorf("got %v, %v for %s", got, err, role)
+		}
+	}
+	if _, err := ParseRole("Admin"); err == nil {
+		t.Error("parsed a role in the wrong case")
+	}
+}
+
+// newTestServer creates a server with every route, which only fails once a handler reaches docker
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	dir := t.TempDir()
+	topologyFile := filepath.Join(dir, "topology.toml")
+	if err := os.WriteFile(topologyFile, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewServer(t.Context(), Config{
+		DbFile:       filepath.Join(dir, "mahogany.db"),
+		StaticDir:    "../../static",
+		Timeout:      time.Second,
+		DockerHost:   "unix://" + filepath.Join(dir, "docker.sock"),
+		TopologyFile: topologyFile,
+		SessionTTL:   time.Hour,
+		CADir:        filepath.Join(dir, "ca"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// testSession is a logged in user of a test server
+type testSession struct {
+	token     string
+	csrfToken string
+}
+
+// loginAs adds a user with the role, named after it, and logs them in
+func loginAs(t *testing.T, s *Server, role Role) testSession {
+	t.Helper()
+	if err := AddUser(t.Context(), s.query, role.String(), testPassword, role); err != nil {
+		t.Fatal(err)
+	}
+	token, csrfToken, err := s.login(t.Context(), role.String(), testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testSession{token: token, csrfToken: csrfToken}
+}
+
+// do sends the request through every handler of the server as the user of the session
+func (session testSession) do(s *Server, req *http.Request) *httptest.ResponseRecorder {
+	req.AddCookie(&http.Cookie{Name: sessionCookie, Value: session.token})
+	req.Header.Set(csrfHeader, session.csrfToken)
+	w := httptest.NewRecorder()
+	s.httpServer.Handler.ServeHTTP(w, req)
+	return w
+}
+
+func TestRouteRoles(t *testing.T) {
+	s := newTestServer(t)
+	sessions := map[Role]testSession{
+		RoleViewer:   loginAs(t, s, RoleViewer),
+		RoleOperator: loginAs(t, s, RoleOperator),
+	}
+
+	// each route is requested by the role just below the one it requires, which must be turned away before the
+	// handler runs
+	tests := []struct {
+		method string
+		path   string
+		role   Role
+	}{
+		{http.MethodPost, "/container/abc/restart", RoleOperator},
+		{http.MethodDelete, "/container/abc/delete", RoleAdmin},
+		{http.MethodPost, "/container/abc/update", RoleAdmin},
+		{http.MethodGet, "/container/abc/exec", RoleAdmin},
+		{http.MethodPost, "/container/new", RoleAdmin},
+		{http.MethodPost, "/stack/web/deploy", RoleAdmin},
+		{http.MethodPost, "/images/prune", RoleAdmin},
+		{http.MethodPost, "/watchtower/update", RoleOperator},
+		{http.MethodGet, "/settings", RoleAdmin},
+		{http.MethodPost, "/settings", RoleAdmin},
+		{http.MethodPost, "/device/enroll", RoleAdmin},
+		{http.MethodPost, "/device/service/1/restart", RoleOperator},
+		{http.MethodPost, "/incident/1/resolve", RoleOperator},
+		{http.MethodGet, "/audit", RoleAdmin},
+		{http.MethodDelete, "/package/1", RoleAdmin},
+		{http.MethodPost, apiPrefix + "/containers/abc/restart", RoleOperator},
+		{http.MethodDelete, apiPrefix + "/containers/abc", RoleAdmin},
+		{http.MethodPut, apiPrefix + "/settings/RegistryAddr", RoleAdmin},
+		{http.MethodPost, apiPrefix + "/releases", RoleOperator},
+		{http.MethodPost, apiPrefix + "/watchtower/update", RoleOperator},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			w := sessions[tt.role-1].do(s, httptest.NewRequest(tt.method, tt.path, nil))
+			if w.Code != http.StatusForbidden {
+				t.Errorf("got status %d for %s, want %d", w.Code, tt.role-1, http.StatusForbidden)
+			}
+		})
+	}
+}
+
+func TestAuthenticateToken(t *testing.T) {
+	s := newAuthServer(t, time.Hour)
+	now := time.Now()
+	addToken := func(username, scope string, expiresAt, revokedAt int64) string {
+		t.Helper()
+		user, err := s.query.GetUserByUsername(t.Context(), username)
+		if err != nil {
+			t.Fatal(err)
+		}
+		secret := views.APITokenPrefix + rand.Text()
+		id, err := s.query.AddApiToken(t.Context(), db.AddApiTokenParams{
+			Name:      username + "-" + scope,
+			TokenHash: views.HashAPIToken(secret),
+			UserID:    user.ID,
+			Scope:     scope,
+			CreatedAt: now.Unix(),
+			ExpiresAt: sql.NullInt64{Int64: expiresAt, Valid: expiresAt != 0},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if revokedAt != 0 {
+			_, err = s.query.RevokeApiToken(t.Context(), db.RevokeApiTokenParams{RevokedAt: sql.NullInt64{Int64: revokedAt, Valid: true}, ID: id})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		return secret
+	}
+
+	tests := []struct {
+		name     string
+		bearer   string
+		wantErr  bool
+		wantRole Role
+	}{
+		{"full scope", addToken("admin", "admin", 0, 0), false, RoleAdmin},
+		{"narrow scope", addToken("admin", "viewer", 0, 0), false, RoleViewer},
+		// the scope was allowed when the token was created, but the user's role has since been lowered
+		{"scope above role", addToken("operator", "admin", 0, 0), false, RoleOperator},
+		{"expires later", addToken("operator", "operator", now.Add(time.Hour).Unix(), 0), false, RoleOperator},
+		{"expired", addToken("admin", "admin", now.Add(-time.Hour).Unix(), 0), true, RoleViewer},
+		{"revoked", addToken("admin", "admin", 0, now.Unix()), true, RoleViewer},
+		{"unknown", views.APITokenPrefix + "guess", true, RoleViewer},
+		{"stored hash", views.HashAPIToken(addToken("admin", "admin", 0, 0)), true, RoleViewer},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := s.authenticateToken(httptest.NewRequest(http.MethodGet, "/api/v1/containers", nil), tt.bearer)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if user.Role != tt.wantRole || len(user.Token) == 0 {
+				t.Errorf("got user %+v, want role %s", user, tt.wantRole)
+			}
+		})
+	}
+
+	tokens, err := s.query.ListApiTokens(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, token := range tokens {
+		if token.Name == "admin-viewer" && !token.LastUsedAt.Valid {
+			t.Error("last use of the token was not recorded")
+		}
+	}
+}
+
+func TestAPITokenRoutes(t *testing.T) {
+	s := newTestServer(t)
+	sessions := map[Role]testSession{
+		RoleViewer:   loginAs(t, s, RoleViewer),
+		RoleOperator: loginAs(t, s, RoleOperator),
+		RoleAdmin:    loginAs(t, s, RoleAdmin),
+	}
+	create := func(role Role, scope string, want int) APINewToken {
+		t.Helper()
+		body := fmt.Sprintf(`{"name": "%s", "scope": "%s", "expiresInDays": 30}`, role, scope)
+		w := sessions[role].do(s, httptest.NewRequest(http.MethodPost, apiPrefix+"/tokens", strings.NewReader(body)))
+		if w.Code != want {
+			t.Fatalf("%s creating a %s token: got status %d, want %d: %s", role, scope, w.Code, want, w.Body)
+		}
+		var token APINewToken
+		if want == http.StatusCreated {
+			if err := json.Unmarshal(w.Body.Bytes(), &token); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return token
+	}
+	list := func(role Role) []string {
+		t.Helper()
+		w := sessions[role].do(s, httptest.NewRequest(http.MethodGet, apiPrefix+"/tokens", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s listing tokens: got status %d: %s", role, w.Code, w.Body)
+		}
+		var tokens []views.APITokenView
+		if err := json.Unmarshal(w.Body.Bytes(), &tokens); err != nil {
+			t.Fatal(err)
+		}
+		names := []string{}
+		for _, token := range tokens {
+			names = append(names, token.Owner)
+		}
+		slices.Sort(names)
+		return names
+	}
+	revoke := func(role Role, token APINewToken, want int) {
+		t.Helper()
+		w := sessions[role].do(s, httptest.NewRequest(http.MethodDelete, fmt.Sprintf("%s/tokens/%d", apiPrefix, token.ID), nil))
+		if w.Code != want {
+			t.Errorf("%s revoking a token of %s: got status %d, want %d", role, token.Owner, w.Code, want)
+		}
+	}
+	bearer := func(token APINewToken) int {
+		req := httptest.NewRequest(http.MethodGet, apiPrefix+"/tokens", nil)
+		req.Header.Set("Authorization", "Bearer "+token.Token)
+		w := httptest.NewRecorder()
+		s.httpServer.Handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// everyone can create tokens for themselves, up to their own role
+	create(RoleViewer, "operator", http.StatusBadRequest)
+	viewerToken := create(RoleViewer, "viewer", http.StatusCreated)
+	create(RoleOperator, "admin", http.StatusBadRequest)
+	operatorToken := create(RoleOperator, "operator", http.StatusCreated)
+	adminToken := create(RoleAdmin, "admin", http.StatusCreated)
+
+	if got := list(RoleViewer); !slices.Equal(got, []string{"viewer"}) {
+		t.Errorf("viewer lists tokens of %v", got)
+	}
+	if got := list(RoleOperator); !slices.Equal(got, []string{"operator"}) {
+		t.Errorf("operator lists tokens of %v", got)
+	}
+	if got := list(RoleAdmin); !slices.Equal(got, []string{"admin", "operator", "viewer"}) {
+		t.Errorf("admin lists tokens of %v", got)
+	}
+
+	w := sessions[RoleViewer].do(s, httptest.NewRequest(http.MethodGet, "/tokens", nil))
+	if page := w.Body.String(); w.Code != http.StatusOK || !strings.Contains(page, `value="viewer"`) || strings.Contains(page, `value="operator"`) {
+		t.Errorf("viewer got status %d for the tokens page, or was offered a scope above their role", w.Code)
+	}
+
+	// the tokens of other users look like they do not exist
+	revoke(RoleOperator, adminToken, http.StatusNotFound)
+	revoke(RoleViewer, operatorToken, http.StatusNotFound)
+	if code := bearer(adminToken); code != http.StatusOK {
+		t.Errorf("admin token got status %d after another user tried to revoke it", code)
+	}
+	revoke(RoleOperator, operatorToken, http.StatusNoContent)
+	revoke(RoleAdmin, viewerToken, http.StatusNoContent)
+	for _, token := range []APINewToken{operatorToken, viewerToken} {
+		if code := bearer(token); code != http.StatusUnauthorized {
+			t.Errorf("revoked token of %s got status %d", token.Owner, code)
+		}
+	}
+}
@@ -17,6 +17,10 @@ type Config struct {
 	DockerHost    string
 	DockerVersion string
 	TopologyFile  string
+	SessionTTL    time.Duration
+	SecureCookies bool
+	CADir         string
+	ServerNames   []string
 }
 
 func LoadConfig() Config {
@@ -28,14 +32,29 @@ func LoadConfig() Config {
 		DockerHost:    loadStrEnv("DOCKER_HOST", "localhost"),
 		DockerVersion: loadStrEnv("DOCKER_VERSION", "3"),
 		TopologyFile:  loadStrEnv("TOPOLOGY", "topology.toml"),
+		SessionTTL:    time.Duration(loadIntEnv("SESSION_TTL_HOURS", 24)) * time.Hour,
+		SecureCookies: loadBoolEnv("SECURE_COOKIES", false),
+		CADir:         loadStrEnv("CA_DIR", "ca"),
+		ServerNames:   loadListEnv("SERVER_NAMES", defaultServerNames()),
 	}
 }
 
+// defaultServerNames are the names agents can use to reach the update server when SERVER_NAMES is not set
+func defaultServerNames() []string {
+	names := []string{"localhost"}
+	if hostname, err := os.Hostname(); err == nil && hostname != "localhost" {
+		names = append(names, hostname)
+	}
+	return names
+}
+
 type AgentConfig struct {
 	ServerAddr        string
 	HostName          string
 	DownloadDir       string
 	TelemetryEndpoint string
+	TopologyFile      string
+	CertDir           string
 }
 
 func LoadAgentConfig() AgentConfig {
@@ -43,6 +62,8 @@ func LoadAgentConfig() AgentConfig {
 		ServerAddr:        loadStrEnv("SERVER_ADDR", "localhost:9091"),
 		DownloadDir:       loadStrEnv("DOWNLOAD_DIR", "/tmp"),
 		TelemetryEndpoint: loadStrEnv("TELEMETRY_ENDPOINT", "localhost:4317"),
+		TopologyFile:      loadStrEnv("TOPOLOGY", ""),
+		CertDir:           loadStrEnv("CERT_DIR", "/etc/mahogany"),
 	}
 	hostname, err := os.ReadFile("/etc/hostname")
 	if err == nil {
@@ -57,6 +78,8 @@ type AppData struct {
 	Packages        []db.Package `json:"packages"`
 	Settings        []db.Setting `json:"settings"`
 	WatchedServices []string     `json:"watched_services"`
+	// the audit log is exported for safekeeping but not imported, since it records what happened on this server
+	AuditEvents []db.AuditEvent `json:"audit_events,omitempty"`
 }
 
 func loadStrEnv(name, defaultVal string) string {
@@ -78,3 +101,29 @@ func loadIntEnv(name string, defaultVal int) int {
 	}
 	return val
 }
+
+func loadBoolEnv(name string, defaultVal bool) bool {
+	valStr, ok := os.LookupEnv(name)
+	if !ok {
+		return defaultVal
+	}
+	val, err := strconv.ParseBool(valStr)
+	if err != nil {
+		return defaultVal
+	}
+	return val
+}
+
+func loadListEnv(name string, defaultVal []string) []string {
+	valStr, ok := os.LookupEnv(name)
+	if !ok || len(valStr) == 0 {
+		return defaultVal
+	}
+	vals := []string{}
+	for _, val := range strings.Split(valStr, ",") {
+		if val = strings.TrimSpace(val); len(val) > 0 {
+			vals = append(vals, val)
+		}
+	}
+	return vals
+}
@@ -0,0 +1,165 @@
+package mahogany
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path"
+	"strings"
+
+	sources "github.com/mpoegel/mahogany/pkg/mahogany/sources"
+	schema "github.com/mpoegel/mahogany/pkg/schema"
+	grpc "google.golang.org/grpc"
+	credentials "google.golang.org/grpc/credentials"
+)
+
+const (
+	agentCACertFile = "ca.pem"
+	agentCertFile   = "agent.pem"
+	agentKeyFile    = "agent-key.pem"
+)
+
+var ErrNotEnrolled = errors.New("agent is not enrolled, run mahogany enroll with a join token from the devices page")
+
+// Enroll trades a join token for a client certificate and stores it in the cert dir. Until then the agent does not
+// trust the server, so the server is checked against the CA fingerprint in the token instead.
+func Enroll(ctx context.Context, config AgentConfig, token string) error {
+	_, fingerprint, ok := strings.Cut(token, ".")
+	if !ok || len(fingerprint) == 0 {
+		return errors.New("malformed join token")
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	csrDER, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: config.HostName},
+		DNSNames: []string{config.HostName},
+	}, key)
+	if err != nil {
+		return err
+	}
+
+	creds := credentials.NewTLS(&tls.Config{
+		// the chain is verified against the pinned CA below instead
+		InsecureSkipVerify:    true,
+		VerifyPeerCertificate: verifyPinnedCA(fingerprint),
+		MinVersion:            tls.VersionTLS13,
+	})
+	conn, err := grpc.NewClient(config.ServerAddr, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	resp, err := schema.NewUpdateServiceClient(conn).Enroll(ctx, &schema.EnrollRequest{
+		Hostname: config.HostName,
+		Token:    token,
+		Csr:      pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrDER}),
+	})
+	if err != nil {
+		return err
+	}
+
+	caCert, err := sources.ParseCertificatePEM(resp.CaCertificate)
+	if err != nil {
+		return err
+	}
+	if sources.CertificateFingerprint(caCert) != fingerprint {
+		return errors.New("server sent a CA that does not match the join token")
+	}
+	if _, err = sources.ParseCertificatePEM(resp.Certificate); err != nil {
+		return err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(config.CertDir, 0700); err != nil {
+		return err
+	}
+	if err = os.WriteFile(path.Join(config.CertDir, agentKeyFile), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		return err
+	}
+	if err = os.WriteFile(path.Join(config.CertDir, agentCertFile), resp.Certificate, 0644); err != nil {
+		return err
+	}
+	if err = os.WriteFile(path.Join(config.CertDir, agentCACertFile), resp.CaCertificate, 0644); err != nil {
+		return err
+	}
+	slog.Info("agent enrolled", "hostname", config.HostName, "dir", config.CertDir)
+	return nil
+}
+
+// verifyPinnedCA accepts the server if its chain includes the CA with the fingerprint and the CA signed the server's
+// certificate for server use
+func verifyPinnedCA(fingerprint string) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		certs := make([]*x509.Certificate, len(rawCerts))
+		for i, raw := range rawCerts {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return err
+			}
+			certs[i] = cert
+		}
+		if len(certs) == 0 {
+			return errors.New("server sent no certificate")
+		}
+		roots := x509.NewCertPool()
+		for _, cert := range certs[1:] {
+			if subtle.ConstantTimeCompare([]byte(sources.CertificateFingerprint(cert)), []byte(fingerprint)) == 1 {
+				roots.AddCert(cert)
+			}
+		}
+		_, err := certs[0].Verify(x509.VerifyOptions{
+			Roots:     roots,
+			KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		})
+		if err != nil {
+			return fmt.Errorf("server is not signed by the CA in the join token: %w", err)
+		}
+		return nil
+	}
+}
+
+// agentCredentials loads the client certificate from enrollment for mutual TLS with the update server
+func agentCredentials(config AgentConfig) (credentials.TransportCredentials, error) {
+	cert, err := tls.LoadX509KeyPair(path.Join(config.CertDir, agentCertFile), path.Join(config.CertDir, agentKeyFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotEnrolled
+	} else if err != nil {
+		return nil, err
+	}
+	caPEM, err := os.ReadFile(path.Join(config.CertDir, agentCACertFile))
+	if err != nil {
+		return nil, err
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(caPEM) {
+		return nil, errors.New("invalid CA certificate")
+	}
+	serverName, _, err := net.SplitHostPort(config.ServerAddr)
+	if err != nil {
+		serverName = config.ServerAddr
+	}
+	return credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      roots,
+		ServerName:   serverName,
+		MinVersion:   tls.VersionTLS13,
+	}), nil
+}
@@ -0,0 +1,105 @@
+package mahogany
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	websocket "golang.org/x/net/websocket"
+)
+
+// size of the chunks of terminal output sent to the browser
+const execBufferSize = 32 << 10
+
+// terminalMessage is sent by the terminal in the browser, either what was typed or the new size of the terminal
+type terminalMessage struct {
+	Type string `json:"type"`
+	Data string `json:"data"`
+	Cols uint   `json:"cols"`
+	Rows uint   `json:"rows"`
+}
+
+// HandleContainerExec attaches a terminal in the browser to a shell in the container over a websocket. Output is
+// sent as binary messages, and the browser sends terminalMessages as text.
+func (s *Server) HandleContainerExec(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	cols, _ := strconv.ParseUint(query.Get("cols"), 10, 16)
+	rows, _ := strconv.ParseUint(query.Get("rows"), 10, 16)
+	server := websocket.Server{
+		Handshake: checkWebsocketOrigin,
+		Handler: func(ws *websocket.Conn) {
+			defer ws.Close()
+			// the terminal stays open for as long as it is used, not for the timeouts of the http server
+			if err := ws.SetDeadline(time.Time{}); err != nil {
+				slog.Warn("cannot clear websocket deadline", "err", err)
+			}
+			ctx := ws.Request().Context()
+			containerID := r.PathValue("containerID")
+			session, err := s.view.OpenExec(ctx, containerID, query.Get("cmd"), uint(cols), uint(rows))
+			if err != nil {
+				slog.Error("failed to exec in container", "id", containerID, "err", err)
+				websocket.Message.Send(ws, []byte(fmt.Sprintf("Error: %v\r\n", err)))
+				return
+			}
+			slog.Info("opened terminal", "container", containerID, "exec", session.ID, "cmd", session.Cmd)
+
+			// closing the session when the browser goes away ends the output loop below
+			go func() {
+				defer session.Close()
+				for {
+					var msg terminalMessage
+					if err := websocket.JSON.Receive(ws, &msg); err != nil {
+						return
+					}
+					switch msg.Type {
+					case "input":
+						if _, err := session.Write([]byte(msg.Data)); err != nil {
+							return
+						}
+					case "resize":
+						if err := session.Resize(ctx, msg.Cols, msg.Rows); err != nil {
+							slog.Warn("cannot resize terminal", "exec", session.ID, "err", err)
+						}
+					}
+				}
+			}()
+
+			buf := make([]byte, execBufferSize)
+			for {
+				n, err := session.Read(buf)
+				if n > 0 {
+					if sendErr := websocket.Message.Send(ws, buf[:n]); sendErr != nil {
+						break
+					}
+				}
+				if err != nil {
+					break
+				}
+			}
+			session.Close()
+			if code, err := session.ExitCode(context.WithoutCancel(ctx)); err == nil {
+				websocket.Message.Send(ws, []byte(fmt.Sprintf("\r\n[exited with code %d]\r\n", code)))
+			}
+			slog.Info("closed terminal", "container", containerID, "exec", session.ID)
+		},
+	}
+	server.ServeHTTP(w, r)
+}
+
+// checkWebsocketOrigin only accepts websockets opened by pages of mahogany itself. Browsers send the session cookie
+// with websockets from any site and the csrf token cannot be sent with them, so this is what keeps other sites from
+// opening a terminal.
+func checkWebsocketOrigin(config *websocket.Config, r *http.Request) error {
+	origin, err := websocket.Origin(config, r)
+	if err != nil {
+		return err
+	}
+	if origin == nil || origin.Host != r.Host {
+		return fmt.Errorf("websocket origin %v does not match host %s", origin, r.Host)
+	}
+	config.Origin = origin
+	return nil
+}
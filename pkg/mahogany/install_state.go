@@ -0,0 +1,140 @@
+package mahogany
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"strings"
+
+	schema "github.com/mpoegel/mahogany/pkg/schema"
+	protojson "google.golang.org/protobuf/encoding/protojson"
+)
+
+const (
+	installStateFile = "installed.json"
+	// stagingDirPrefix names the directories that releases are downloaded to until they install
+	stagingDirPrefix = ".staging-"
+)
+
+// installState records the last known-good releases of a package so that a failed or unwanted install can be
+// rolled back to the artifacts that are still kept under the download directory
+type installState struct {
+	dir string
+
+	Current  *schema.Release
+	Previous *schema.Release
+}
+
+type rawInstallState struct {
+	Current  json.RawMessage `json:"current,omitempty"`
+	Previous json.RawMessage `json:"previous,omitempty"`
+}
+
+func loadInstallState(dir string) (*installState, error) {
+	state := &installState{dir: dir}
+	content, err := os.ReadFile(path.Join(dir, installStateFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return state, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	raw := rawInstallState{}
+	if err = json.Unmarshal(content, &raw); err != nil {
+		return nil, err
+	}
+	if len(raw.Current) > 0 {
+		state.Current = &schema.Release{}
+		if err = protojson.Unmarshal(raw.Current, state.Current); err != nil {
+			return nil, err
+		}
+	}
+	if len(raw.Previous) > 0 {
+		state.Previous = &schema.Release{}
+		if err = protojson.Unmarshal(raw.Previous, state.Previous); err != nil {
+			return nil, err
+		}
+	}
+	return state, nil
+}
+
+func (s *installState) save() error {
+	raw := rawInstallState{}
+	var err error
+	if s.Current != nil {
+		if raw.Current, err = protojson.Marshal(s.Current); err != nil {
+			return err
+		}
+	}
+	if s.Previous != nil {
+		if raw.Previous, err = protojson.Marshal(s.Previous); err != nil {
+			return err
+		}
+	}
+	content, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	tmpFile := path.Join(s.dir, installStateFile+".tmp")
+	if err = os.WriteFile(tmpFile, content, 0600); err != nil {
+		return err
+	}
+	if err = os.Rename(tmpFile, path.Join(s.dir, installStateFile)); err != nil {
+		return err
+	}
+	s.prune()
+	return nil
+}
+
+// promote marks the release as the current known-good install
+func (s *installState) promote(release *schema.Release) error {
+	if s.Current != nil && s.Current.Version != release.Version {
+		s.Previous = s.Current
+	}
+	s.Current = release
+	return s.save()
+}
+
+// revert makes the previous release current again after a rollback
+func (s *installState) revert() error {
+	s.Current = s.Previous
+	s.Previous = nil
+	return s.save()
+}
+
+// prune removes downloaded artifacts of every version that is no longer current or previous, and anything left
+// staged by an install that was interrupted
+func (s *installState) prune() {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		slog.Warn("could not list download directory", "dir", s.dir, "err", err)
+		return
+	}
+	keep := map[string]bool{}
+	if s.Current != nil {
+		keep[versionDir(s.Current.Version)] = true
+	}
+	if s.Previous != nil {
+		keep[versionDir(s.Previous.Version)] = true
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || keep[entry.Name()] {
+			continue
+		}
+		if err := os.RemoveAll(path.Join(s.dir, entry.Name())); err != nil {
+			slog.Warn("could not remove old release", "dir", entry.Name(), "err", err)
+		}
+	}
+}
+
+// versionDir converts a release version into a safe directory name
+func versionDir(version string) string {
+	version = strings.NewReplacer("/", "_", "\\", "_", "..", "_").Replace(version)
+	if len(version) == 0 {
+		return "_"
+	}
+	return version
+}
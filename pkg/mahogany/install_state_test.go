@@ -0,0 +1,336 @@
+package mahogany
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	schema "github.com/mpoegel/mahogany/pkg/schema"
+	grpc "google.golang.org/grpc"
+)
+
+func testRelease(version string) *schema.Release {
+	return &schema.Release{Name: "tool", Version: version, InstallCommand: "true"}
+}
+
+// releaseVersions returns the versions of the current and previous release of the state, empty if there is none
+func releaseVersions(state *installState) [2]string {
+	versions := [2]string{}
+	if state.Current != nil {
+		versions[0] = state.Current.Version
+	}
+	if state.Previous != nil {
+		versions[1] = state.Previous.Version
+	}
+	return versions
+}
+
+func listDirs(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func TestInstallStatePromote(t *testing.T) {
+	dir := t.TempDir()
+	state, err := loadInstallState(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Current != nil || state.Previous != nil {
+		t.Fatalf("new state has releases: %v", releaseVersions(state))
+	}
+
+	for _, step := range []struct {
+		version string
+		want    [2]string
+	}{
+		{"v1.0.0", [2]string{"v1.0.0", ""}},
+		{"v1.1.0", [2]string{"v1.1.0", "v1.0.0"}},
+		// installing the current version again keeps the previous one to roll back to
+		{"v1.1.0", [2]string{"v1.1.0", "v1.0.0"}},
+		{"v1.2.0", [2]string{"v1.2.0", "v1.1.0"}},
+	} {
+		if err = state.promote(testRelease(step.version)); err != nil {
+			t.Fatal(err)
+		}
+		if got := releaseVersions(state); got != step.want {
+			t.Errorf("promote %s: got %v, want %v", step.version, got, step.want)
+		}
+		loaded, err := loadInstallState(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := releaseVersions(loaded); got != step.want {
+			t.Errorf("promote %s: loaded %v, want %v", step.version, got, step.want)
+		}
+	}
+
+	loaded, err := loadInstallState(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Current.InstallCommand != "true" || loaded.Current.Name != "tool" {
+		t.Errorf("release was not saved in full: %v", loaded.Current)
+	}
+}
+
+func TestInstallStateRevert(t *testing.T) {
+	dir := t.TempDir()
+	state := &installState{dir: dir}
+	for _, version := range []string{"v1.0.0", "v1.1.0"} {
+		if err := os.Mkdir(filepath.Join(dir, version), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := state.promote(testRelease(version)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := state.revert(); err != nil {
+		t.Fatal(err)
+	}
+	want := [2]string{"v1.0.0", ""}
+	if got := releaseVersions(state); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	loaded, err := loadInstallState(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := releaseVersions(loaded); got != want {
+		t.Errorf("loaded %v, want %v", got, want)
+	}
+	// the reverted release is no longer kept
+	if got := listDirs(t, dir); !slices.Equal(got, []string{installStateFile, "v1.0.0"}) {
+		t.Errorf("got %v left in the download directory", got)
+	}
+}
+
+func TestInstallStatePrune(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"v1.0.0", "v1.1.0", "v1.2.0", "v1.3.0"} {
+		if err := os.Mkdir(filepath.Join(dir, name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name, "tool.deb"), []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	state := &installState{dir: dir, Current: testRelease("v1.1.0")}
+	if err := state.promote(testRelease("v1.2.0")); err != nil {
+		t.Fatal(err)
+	}
+	// only the current and previous releases are kept, along with anything that is not a release directory
+	want := []string{installStateFile, "notes.txt", "v1.1.0", "v1.2.0"}
+	if got := listDirs(t, dir); !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if content, err := os.ReadFile(filepath.Join(dir, "v1.1.0", "tool.deb")); err != nil || string(content) != "v1.1.0" {
+		t.Errorf("kept release lost its artifacts: %q, %v", content, err)
+	}
+}
+
+func TestVersionDir(t *testing.T) {
+	for version, want := range map[string]string{
+		"v1.2.0":        "v1.2.0",
+		"release/1.2":   "release_1.2",
+		"../../etc":     "____etc",
+		`..\windows`:    `__windows`,
+		"":              "_",
+		"v1.2.0 (beta)": "v1.2.0 (beta)",
+	} {
+		if got := versionDir(version); got != want {
+			t.Errorf("versionDir(%q) = %q, want %q", version, got, want)
+		}
+	}
+}
+
+// fakeReportClient keeps the installation reports that the agent sends
+type fakeReportClient struct {
+	schema.UpdateServiceClient
+	reports []*schema.ReportInstallationRequest
+}
+
+func (c *fakeReportClient) ReportInstallation(ctx context.Context, in *schema.ReportInstallationRequest, opts ...grpc.CallOption) (*schema.ReportInstallationResponse, error) {
+	c.reports = append(c.reports, in)
+	return &schema.ReportInstallationResponse{}, nil
+}
+
+func TestAgentRollsBackFailedInstall(t *testing.T) {
+	assets := map[string]string{"/v1/tool.txt": "tool v1", "/v2/tool.txt": "tool v2"}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(assets[r.URL.Path]))
+	}))
+	defer srv.Close()
+
+	workDir := t.TempDir()
+	installed := filepath.Join(workDir, "installed")
+	// installs copy the asset into place, and a failing check after the copy fails the install
+	release := func(version, check string) *schema.Release {
+		content := assets["/"+version+"/tool.txt"]
+		sum := sha256.Sum256([]byte(content))
+		return &schema.Release{
+			Name:           "tool",
+			Version:        version,
+			InstallCommand: "cp {{.Asset}} installed && " + check,
+			WorkingDir:     workDir,
+			Assets: []*schema.Asset{{
+				Name:      "tool.txt",
+				SourceUrl: srv.URL + "/" + version + "/tool.txt",
+				Sha256:    hex.EncodeToString(sum[:]),
+			}},
+		}
+	}
+	client := &fakeReportClient{}
+	agent := &Agent{
+		config:   AgentConfig{HostName: "host", DownloadDir: t.TempDir()},
+		topology: &schema.Topology{},
+		client:   client,
+	}
+	packageDir := filepath.Join(agent.config.DownloadDir, "tool")
+	install := func(rel *schema.Release, action schema.ReleaseAction) {
+		agent.handleRelease(t.Context(), &schema.ReleaseStreamResponse{Release: rel, Action: action})
+	}
+	checkInstalled := func(want string, versions [2]string) {
+		t.Helper()
+		if content, err := os.ReadFile(installed); err != nil || string(content) != want {
+			t.Errorf("installed %q, %v, want %q", content, err, want)
+		}
+		state, err := loadInstallState(packageDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := releaseVersions(state); got != versions {
+			t.Errorf("got releases %v, want %v", got, versions)
+		}
+	}
+
+	install(release("v1", "true"), schema.ReleaseAction_RELEASE_ACTION_INSTALL)
+	checkInstalled("tool v1", [2]string{"v1", ""})
+
+	// v2 installs but fails its check, so v1 is installed again from its kept artifacts
+	install(release("v2", "false"), schema.ReleaseAction_RELEASE_ACTION_INSTALL)
+	checkInstalled("tool v1", [2]string{"v1", ""})
+
+	install(release("v2", "true"), schema.ReleaseAction_RELEASE_ACTION_INSTALL)
+	checkInstalled("tool v2", [2]string{"v2", "v1"})
+
+	install(release("v2", "true"), schema.ReleaseAction_RELEASE_ACTION_ROLLBACK)
+	checkInstalled("tool v1", [2]string{"v1", ""})
+
+	// there is nothing left to roll back to
+	install(release("v1", "true"), schema.ReleaseAction_RELEASE_ACTION_ROLLBACK)
+	checkInstalled("tool v1", [2]string{"v1", ""})
+
+	type outcome struct {
+		version   string
+		action    schema.ReleaseAction
+		installed bool
+	}
+	want := []outcome{
+		{"v1", schema.ReleaseAction_RELEASE_ACTION_INSTALL, true},
+		{"v2", schema.ReleaseAction_RELEASE_ACTION_INSTALL, false},
+		{"v1", schema.ReleaseAction_RELEASE_ACTION_ROLLBACK, true},
+		{"v2", schema.ReleaseAction_RELEASE_ACTION_INSTALL, true},
+		{"v1", schema.ReleaseAction_RELEASE_ACTION_ROLLBACK, true},
+		{"v1", schema.ReleaseAction_RELEASE_ACTION_ROLLBACK, false},
+	}
+	got := []outcome{}
+	for _, report := range client.reports {
+		ok := len(report.Results) > 0
+		for _, result := range report.Results {
+			ok = ok && result.IsInstalled
+		}
+		got = append(got, outcome{report.Version, report.Action, ok})
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got reports %v, want %v", got, want)
+	}
+}
+
+func TestAgentKeepsArtifactsOfFailedReinstall(t *testing.T) {
+	content := "tool v1"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(content))
+	}))
+	defer srv.Close()
+
+	workDir := t.TempDir()
+	installed := filepath.Join(workDir, "installed")
+	release := func(check string) *schema.Release {
+		sum := sha256.Sum256([]byte(content))
+		return &schema.Release{
+			Name:           "tool",
+			Version:        "v1",
+			InstallCommand: "cp {{.Asset}} installed && " + check,
+			WorkingDir:     workDir,
+			Assets: []*schema.Asset{{
+				Name:      "tool.txt",
+				SourceUrl: srv.URL + "/tool.txt",
+				Sha256:    hex.EncodeToString(sum[:]),
+			}},
+		}
+	}
+	client := &fakeReportClient{}
+	agent := &Agent{
+		config:   AgentConfig{HostName: "host", DownloadDir: t.TempDir()},
+		topology: &schema.Topology{},
+		client:   client,
+	}
+	packageDir := filepath.Join(agent.config.DownloadDir, "tool")
+	kept := filepath.Join(packageDir, "v1", "tool.txt")
+	checkInstalled := func(want string) {
+		t.Helper()
+		for _, filename := range []string{installed, kept} {
+			if got, err := os.ReadFile(filename); err != nil || string(got) != want {
+				t.Errorf("got %s %q, %v, want %q", filename, got, err, want)
+			}
+		}
+		if got := listDirs(t, packageDir); !slices.Equal(got, []string{installStateFile, "v1"}) {
+			t.Errorf("got %v left in the download directory", got)
+		}
+	}
+
+	agent.handleRelease(t.Context(), &schema.ReleaseStreamResponse{Release: release("true"), Action: schema.ReleaseAction_RELEASE_ACTION_INSTALL})
+	checkInstalled("tool v1")
+
+	// the same version is published again and fails to install, which rolls back to the artifact it replaced
+	content = "tool v1 rebuilt"
+	agent.handleRelease(t.Context(), &schema.ReleaseStreamResponse{Release: release("false"), Action: schema.ReleaseAction_RELEASE_ACTION_INSTALL})
+	checkInstalled("tool v1")
+	if n := len(client.reports); n != 3 || client.reports[2].Action != schema.ReleaseAction_RELEASE_ACTION_ROLLBACK || !client.reports[2].Results[0].IsInstalled {
+		t.Errorf("the failed install was not rolled back: %v", client.reports)
+	}
+
+	// a kept artifact that changed on disk is not installed again
+	if err := os.WriteFile(kept, []byte("tampered"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	state, err := loadInstallState(packageDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, ok := agent.reinstallRelease(t.Context(), state.Current, packageDir)
+	if ok || len(report.Results) != 1 || !strings.Contains(report.Results[0].Error, "checksum mismatch") {
+		t.Errorf("reinstalled a tampered artifact: %v", report)
+	}
+}
@@ -5,14 +5,17 @@ import (
 	"context"
 	"log/slog"
 	"os"
+	"sync"
 	"time"
 	"unicode"
 
 	"golang.org/x/sys/unix"
 
+	schema "github.com/mpoegel/mahogany/pkg/schema"
 	otel "go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	metric "go.opentelemetry.io/otel/metric"
+	proto "google.golang.org/protobuf/proto"
 )
 
 type Metrics struct {
@@ -23,6 +26,9 @@ type Metrics struct {
 	diskGauge metric.Float64Gauge
 
 	attributes metric.MeasurementOption
+
+	mu     sync.Mutex
+	latest *schema.HostMetrics
 }
 
 func NewMetrics(interval time.Duration, attributes map[string]string) (*Metrics, error) {
@@ -74,35 +80,59 @@ func (metrics *Metrics) Collect(ctx context.Context) {
 	for {
 		select {
 		case <-t.C:
+			// a reading that failed is left unset rather than reported as 0
+			latest := &schema.HostMetrics{}
 			cpuStat, err := metrics.cpuUsage()
 			if err != nil {
 				slog.Warn("failed to collect cpu stat", "err", err)
 			} else if lastCpuStat != nil {
-				cpuPercentUtil := float64(cpuStat.TotalBusy()-lastCpuStat.TotalBusy()) * 100.0 /
-					float64(cpuStat.TotalBusy()-lastCpuStat.TotalBusy()+cpuStat.Idle-lastCpuStat.Idle)
-				metrics.cpuGauge.Record(ctx, cpuPercentUtil, metrics.attributes)
+				busy := cpuStat.TotalBusy() - lastCpuStat.TotalBusy()
+				// the counters only move once a clock tick has passed
+				if total := busy + cpuStat.Idle - lastCpuStat.Idle; total > 0 {
+					cpuPercentUtil := float64(busy) * 100.0 / float64(total)
+					metrics.cpuGauge.Record(ctx, cpuPercentUtil, metrics.attributes)
+					latest.CpuUsage = &cpuPercentUtil
+				}
 			}
 			lastCpuStat = cpuStat
 
 			memStat, err := metrics.memUsage()
 			if err != nil {
 				slog.Warn("failed to collect memory stat", "err", err)
-			} else {
-				metrics.memGauge.Record(ctx, float64(memStat.MemTotal-memStat.MemAvailable)/float64(memStat.MemTotal)*100.0, metrics.attributes)
+			} else if memStat.MemTotal > 0 {
+				memUsage := float64(memStat.MemTotal-memStat.MemAvailable) / float64(memStat.MemTotal) * 100.0
+				metrics.memGauge.Record(ctx, memUsage, metrics.attributes)
+				latest.MemUsage = &memUsage
 			}
 
 			diskStat, err := metrics.diskUsage()
 			if err != nil {
 				slog.Warn("failed to collect disk stat", "err", err)
-			} else {
-				metrics.diskGauge.Record(ctx, float64(diskStat.BlocksTotal-diskStat.BlocksAvailable)/float64(diskStat.BlocksTotal)*100.0, metrics.attributes)
+			} else if diskStat.BlocksTotal > 0 {
+				diskUsage := float64(diskStat.BlocksTotal-diskStat.BlocksAvailable) / float64(diskStat.BlocksTotal) * 100.0
+				metrics.diskGauge.Record(ctx, diskUsage, metrics.attributes)
+				latest.DiskUsage = &diskUsage
 			}
+
+			metrics.mu.Lock()
+			metrics.latest = latest
+			metrics.mu.Unlock()
 		case <-ctx.Done():
 			return
 		}
 	}
 }
 
+// Latest returns the most recent readings, or nil if nothing has been collected yet
+func (metrics *Metrics) Latest() *schema.HostMetrics {
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	if metrics.latest == nil {
+		return nil
+	}
+	return proto.Clone(metrics.latest).(*schema.HostMetrics)
+}
+
 type CpuStat struct {
 	Num    int
 	User   uint64
@@ -0,0 +1,166 @@
+package mahogany
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"text/template"
+	"text/template/parse"
+	"time"
+)
+
+const (
+	defaultCommandTimeout = 5 * time.Minute
+	defaultMaxOutput      = 64 * 1024
+)
+
+// CommandVars are the values available to templated install commands, e.g. {{.Asset}} or {{.Version}}. They are
+// quoted for the shell wherever they are printed, so a path with spaces or a hostile release name stays one word.
+type CommandVars struct {
+	Name        string
+	Version     string
+	Asset       string
+	DownloadDir string
+}
+
+// CommandRunner runs install commands through the shell so that quoting, pipes and variables behave as written
+type CommandRunner struct {
+	Timeout   time.Duration
+	Dir       string
+	Env       map[string]string
+	MaxOutput int
+}
+
+func NewCommandRunner(timeout time.Duration, dir string, env map[string]string) *CommandRunner {
+	if timeout <= 0 {
+		timeout = defaultCommandTimeout
+	}
+	return &CommandRunner{
+		Timeout:   timeout,
+		Dir:       dir,
+		Env:       env,
+		MaxOutput: defaultMaxOutput,
+	}
+}
+
+// Render expands the command template. The legacy {} placeholder is replaced with the asset path, and the output of
+// every action is quoted for the shell while comparisons such as {{if eq .Version "v1"}} see the plain values.
+func (r *CommandRunner) Render(rawCmd string, vars CommandVars) (string, error) {
+	rawCmd = strings.ReplaceAll(rawCmd, "{}", "{{.Asset}}")
+	tmpl, err := template.New("command").Option("missingkey=error").Funcs(template.FuncMap{
+		"quote": quoteValue,
+	}).Parse(rawCmd)
+	if err != nil {
+		return "", err
+	}
+	quoteActions(tmpl.Tree.Root)
+	out := &strings.Builder{}
+	if err = tmpl.Execute(out, vars); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// quoteActions pipes every action that prints a value through quote, unless it already ends with it
+func quoteActions(list *parse.ListNode) {
+	if list == nil {
+		return
+	}
+	for _, node := range list.Nodes {
+		switch node := node.(type) {
+		case *parse.ActionNode:
+			pipe := node.Pipe
+			if len(pipe.Decl) > 0 || len(pipe.Cmds) == 0 {
+				continue
+			}
+			last := pipe.Cmds[len(pipe.Cmds)-1]
+			if ident, ok := last.Args[0].(*parse.IdentifierNode); ok && ident.Ident == "quote" {
+				continue
+			}
+			pipe.Cmds = append(pipe.Cmds, &parse.CommandNode{
+				NodeType: parse.NodeCommand,
+				Pos:      pipe.Pos,
+				Args:     []parse.Node{parse.NewIdentifier("quote").SetPos(pipe.Pos)},
+			})
+		case *parse.IfNode:
+			quoteActions(node.List)
+			quoteActions(node.ElseList)
+		case *parse.RangeNode:
+			quoteActions(node.List)
+			quoteActions(node.ElseList)
+		case *parse.WithNode:
+			quoteActions(node.List)
+			quoteActions(node.ElseList)
+		}
+	}
+}
+
+// Run executes the command with sh -c and returns its combined, size-limited output. On timeout the whole process
+// group is killed so that children spawned by the shell do not outlive the install.
+func (r *CommandRunner) Run(ctx context.Context, command string, vars CommandVars) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.Timeout)
+	defer cancel()
+
+	out := &limitedBuffer{limit: r.MaxOutput}
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", command)
+	cmd.Dir = r.Dir
+	cmd.Env = os.Environ()
+	for key, val := range r.Env {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", key, val))
+	}
+	cmd.Env = append(cmd.Env,
+		"MAHOGANY_PACKAGE="+vars.Name,
+		"MAHOGANY_VERSION="+vars.Version,
+		"MAHOGANY_ASSET="+vars.Asset,
+		"MAHOGANY_DOWNLOAD_DIR="+vars.DownloadDir,
+	)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = 5 * time.Second
+
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("command timed out after %s", r.Timeout)
+	}
+	return out.String(), err
+}
+
+// limitedBuffer keeps the first limit bytes written to it and discards the rest
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if remaining := b.limit - b.buf.Len(); remaining < len(p) {
+		b.truncated = true
+		b.buf.Write(p[:max(remaining, 0)])
+	} else {
+		b.buf.Write(p)
+	}
+	return len(p), nil
+}
+
+func (b *limitedBuffer) String() string {
+	if b.truncated {
+		return b.buf.String() + "\n[output truncated]"
+	}
+	return b.buf.String()
+}
+
+func quoteValue(v any) string {
+	return shellQuote(fmt.Sprint(v))
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
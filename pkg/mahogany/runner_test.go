@@ -0,0 +1,112 @@
+package mahogany
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCommandRunnerRender(t *testing.T) {
+	vars := CommandVars{
+		Name:        "mahogany",
+		Version:     "v1.2.0; rm -rf /",
+		Asset:       "/tmp/my downloads/mahogany's.deb",
+		DownloadDir: "/tmp/my downloads",
+	}
+	tests := []struct {
+		name    string
+		cmd     string
+		want    string
+		wantErr bool
+	}{
+		{"asset", "dpkg -i {{.Asset}}", `dpkg -i '/tmp/my downloads/mahogany'\''s.deb'`, false},
+		{"quoted asset", "dpkg -i {{quote .Asset}}", `dpkg -i '/tmp/my downloads/mahogany'\''s.deb'`, false},
+		{"version", "echo {{.Name}} {{.Version}}", `echo 'mahogany' 'v1.2.0; rm -rf /'`, false},
+		{"quoted literal", `echo {{quote "a b"}}`, `echo 'a b'`, false},
+		{"condition", "{{if .Version}}echo yes{{end}}", "echo yes", false},
+		{"legacy placeholder", "dpkg -i {}", `dpkg -i '/tmp/my downloads/mahogany'\''s.deb'`, false},
+		{"piped to quote", "echo {{.Name | quote}}", `echo 'mahogany'`, false},
+		{"comparison", `{{if eq .Version "v1.2.0; rm -rf /"}}echo {{.Name}}{{else}}false{{end}}`, `echo 'mahogany'`, false},
+		{"printf", `echo {{printf "%s-%s" .Name .DownloadDir}}`, `echo 'mahogany-/tmp/my downloads'`, false},
+		{"variable", "{{$v := .Version}}echo {{$v}}", `echo 'v1.2.0; rm -rf /'`, false},
+		{"brace group", "{ tar xzf {{.Asset}} && make install; } > install.log", `{ tar xzf '/tmp/my downloads/mahogany'\''s.deb' && make install; } > install.log`, false},
+		{"unknown field", "echo {{.Checksum}}", "", true},
+		{"bad template", "echo {{.Asset", "", true},
+	}
+	runner := NewCommandRunner(0, "", nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := runner.Render(tt.cmd, vars)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommandRunnerRun(t *testing.T) {
+	dir := t.TempDir()
+	vars := CommandVars{Name: "mahogany", Version: "v1.2.0", Asset: filepath.Join(dir, "my asset.txt"), DownloadDir: dir}
+	if err := os.WriteFile(vars.Asset, []byte("hello\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runner := NewCommandRunner(5*time.Second, dir, map[string]string{"GREETING": "hi there"})
+	cmd, err := runner.Render(`cat {{.Asset}} | tr a-z A-Z; echo "$GREETING" "$MAHOGANY_VERSION"; pwd; echo oops >&2`, vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := runner.Run(t.Context(), cmd, vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "HELLO\nhi there v1.2.0\n" + dir + "\noops\n"; out != want {
+		t.Errorf("got output %q, want %q", out, want)
+	}
+
+	_, err = runner.Run(t.Context(), "exit 3", vars)
+	if err == nil || !strings.Contains(err.Error(), "exit status 3") {
+		t.Errorf("got error %v, want exit status 3", err)
+	}
+}
+
+func TestCommandRunnerMaxOutput(t *testing.T) {
+	runner := NewCommandRunner(5*time.Second, "", nil)
+	runner.MaxOutput = 10
+	out, err := runner.Run(t.Context(), "echo 0123456789abcdef", CommandVars{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "0123456789\n[output truncated]"; out != want {
+		t.Errorf("got output %q, want %q", out, want)
+	}
+}
+
+func TestCommandRunnerTimeout(t *testing.T) {
+	dir := t.TempDir()
+	leaked := filepath.Join(dir, "leaked")
+	runner := NewCommandRunner(200*time.Millisecond, dir, nil)
+
+	// the background child holds the output open and would outlive the shell if only the shell were killed
+	start := time.Now()
+	out, err := runner.Run(context.Background(), "echo started; (sleep 1; touch leaked) & sleep 10", CommandVars{})
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("got error %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("run took %s after the timeout", elapsed)
+	}
+	if out != "started\n" {
+		t.Errorf("got output %q", out)
+	}
+
+	time.Sleep(1500 * time.Millisecond)
+	if _, err = os.Stat(leaked); err == nil {
+		t.Error("child of the shell outlived the timeout")
+	}
+}
@@ -2,15 +2,19 @@ package mahogany
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"html/template"
+	"io"
 	"log/slog"
 	"net/http"
 	"path"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	db "github.com/mpoegel/mahogany/internal/db"
@@ -23,23 +27,40 @@ type Server struct {
 	view         *views.ViewFinder
 	httpServer   *http.Server
 	updateServer *sources.UpdateServer
+	bus          *sources.EventBus
+	query        *db.Queries
 	ctx          context.Context
+	cancel       context.CancelFunc
+	// the goroutines that publish on the bus, which are waited on before it stops
+	producers sync.WaitGroup
 }
 
 func NewServer(ctx context.Context, config Config) (*Server, error) {
+	// routes on the public mux are reachable without a session, everything else requires one
+	public := http.NewServeMux()
 	mux := http.NewServeMux()
 
 	dbConn, err := sql.Open("sqlite", config.DbFile)
 	if err != nil {
 		return nil, err
 	}
+	if err = db.Migrate(ctx, dbConn); err != nil {
+		return nil, fmt.Errorf("cannot migrate database: %w", err)
+	}
+
+	ca, err := sources.LoadCertificateAuthority(config.CADir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load certificate authority: %w", err)
+	}
 
-	updateServer, err := sources.NewUpdateServer(config.TopologyFile, config.Port+1, dbConn)
+	notifier := sources.NewNotifier(dbConn)
+	bus := sources.NewEventBus()
+	updateServer, err := sources.NewUpdateServer(config.TopologyFile, config.Port+1, config.Timeout, dbConn, ca, config.ServerNames, notifier, bus)
 	if err != nil {
 		return nil, err
 	}
 
-	viewFinder, err := views.NewViewFinder(config.DockerHost, config.DockerVersion, dbConn, updateServer)
+	viewFinder, err := views.NewViewFinder(config.DockerHost, config.DockerVersion, dbConn, updateServer, notifier, bus)
 	if err != nil {
 		return nil, err
 	}
@@ -51,73 +72,237 @@ func NewServer(ctx context.Context, config Config) (*Server, error) {
 			Addr:         fmt.Sprintf("0.0.0.0:%d", config.Port),
 			ReadTimeout:  config.Timeout,
 			WriteTimeout: config.Timeout,
-			Handler:      mux,
+			Handler:      public,
 		},
 		updateServer: updateServer,
-		ctx:          ctx,
+		bus:          bus,
+		query:        db.New(dbConn),
 	}
+	s.ctx, s.cancel = context.WithCancel(ctx)
 
-	mux.HandleFunc("GET /{$}", s.newHandler(func(r *http.Request) Viewer {
+	mux.HandleFunc("GET /{$}", s.require(RoleViewer, s.newHandler(func(r *http.Request) Viewer {
 		return s.view.GetIndex(r.Context())
-	}))
-	mux.HandleFunc("GET /container/{containerID}", s.newHandler(func(r *http.Request) Viewer {
+	})))
+	mux.HandleFunc("GET /events", s.require(RoleViewer, s.HandleEvents))
+	mux.HandleFunc("GET /status", s.require(RoleViewer, s.newHandler(func(r *http.Request) Viewer {
+		return s.view.GetStatus(r.Context())
+	})))
+	mux.HandleFunc("GET /containers/list", s.require(RoleViewer, s.newHandler(func(r *http.Request) Viewer {
+		return s.view.GetContainerList(r.Context())
+	})))
+	mux.HandleFunc("GET /container/{containerID}", s.require(RoleViewer, s.newHandler(func(r *http.Request) Viewer {
 		return s.view.GetContainer(r.Context(), r.PathValue("containerID")).WithName("ContainerView")
-	}))
-	mux.HandleFunc("GET /container/{containerID}/inspect", s.newHandler(func(r *http.Request) Viewer {
+	})))
+	mux.HandleFunc("GET /container/{containerID}/inspect", s.require(RoleViewer, s.newHandler(func(r *http.Request) Viewer {
 		return s.view.GetContainer(r.Context(), r.PathValue("containerID")).WithName("container")
-	}))
-	mux.HandleFunc("POST /container/{containerID}/start", s.newHandler(func(r *http.Request) Viewer {
+	})))
+	mux.HandleFunc("POST /container/{containerID}/start", s.require(RoleOperator, s.newHandler(func(r *http.Request) Viewer {
 		return s.view.StartContainer(r.Context(), r.PathValue("containerID"))
-	}))
-	mux.HandleFunc("POST /container/{containerID}/stop", s.newHandler(func(r *http.Request) Viewer {
+	})))
+	mux.HandleFunc("POST /container/{containerID}/stop", s.require(RoleOperator, s.newHandler(func(r *http.Request) Viewer {
 		return s.view.StopContainer(r.Context(), r.PathValue("containerID"))
-	}))
-	mux.HandleFunc("POST /container/{containerID}/restart", s.newHandler(func(r *http.Request) Viewer {
+	})))
+	mux.HandleFunc("POST /container/{containerID}/restart", s.require(RoleOperator, s.newHandler(func(r *http.Request) Viewer {
 		return s.view.RestartContainer(r.Context(), r.PathValue("containerID"))
-	}))
-	mux.HandleFunc("DELETE /container/{containerID}/delete", s.newHandler(func(r *http.Request) Viewer {
+	})))
+	mux.HandleFunc("DELETE /container/{containerID}/delete", s.require(RoleAdmin, s.newHandler(func(r *http.Request) Viewer {
 		return s.view.RemoveContainer(r.Context(), r.PathValue("containerID"))
-	}))
-	mux.HandleFunc("GET /container/{containerID}/logs", s.newHandler(func(r *http.Request) Viewer {
+	})))
+	mux.HandleFunc("POST /container/{containerID}/update", s.require(RoleAdmin, noWriteDeadline(s.newHandler(func(r *http.Request) Viewer {
+		return s.view.UpdateContainer(context.WithoutCancel(r.Context()), r.PathValue("containerID"))
+	}))))
+	mux.HandleFunc("POST /container/{containerID}/rollback", s.require(RoleAdmin, s.newHandler(func(r *http.Request) Viewer {
+		return s.view.RollbackContainer(r.Context(), r.PathValue("containerID"))
+	})))
+	mux.HandleFunc("GET /container/{containerID}/logs", s.require(RoleViewer, s.newHandler(func(r *http.Request) Viewer {
 		return s.view.GetContainer(r.Context(), r.PathValue("containerID")).WithName("container-logs")
-	}))
-	mux.HandleFunc("GET /container/{containerID}/logs/stream", s.HandleContainerLogsStream)
-	mux.HandleFunc("GET /registry", s.newHandler(func(r *http.Request) Viewer {
+	})))
+	mux.HandleFunc("GET /container/{containerID}/logs/stream", s.require(RoleViewer, s.HandleContainerLogsStream))
+	mux.HandleFunc("GET /container/{containerID}/stats/stream", s.require(RoleViewer, s.HandleContainerStatsStream))
+	mux.HandleFunc("GET /containers/stats/stream", s.require(RoleViewer, s.HandleContainerStatsStream))
+	mux.HandleFunc("GET /container/{containerID}/terminal", s.require(RoleAdmin, s.newHandler(func(r *http.Request) Viewer {
+		return s.view.GetContainer(r.Context(), r.PathValue("containerID")).WithName("container-terminal")
+	})))
+	mux.HandleFunc("GET /container/{containerID}/exec", s.require(RoleAdmin, s.HandleContainerExec))
+	mux.HandleFunc("GET /container/new", s.require(RoleAdmin, s.newHandler(func(r *http.Request) Viewer {
+		return s.view.GetNewContainer(r.Context())
+	})))
+	mux.HandleFunc("POST /container/new", s.require(RoleAdmin, s.newHandler(func(r *http.Request) Viewer {
+		spec, err := views.ParseContainerSpec(r.FormValue("image"), r.FormValue("name"), r.FormValue("ports"),
+			r.FormValue("env"), r.FormValue("volumes"), r.FormValue("network"), r.FormValue("restartPolicy"),
+			r.FormValue("labels"))
+		if err != nil {
+			return &views.DeployView{Spec: spec, Err: err}
+		}
+		return s.view.PrepareDeploy(r.Context(), spec)
+	})))
+	mux.HandleFunc("GET /deploy/{deployID}/stream", s.require(RoleAdmin, s.HandleDeployStream))
+	mux.HandleFunc("GET /stacks", s.require(RoleViewer, s.newHandler(func(r *http.Request) Viewer {
+		return s.view.GetStacks(r.Context())
+	})))
+	mux.HandleFunc("POST /stacks", s.require(RoleAdmin, s.newHandler(func(r *http.Request) Viewer {
+		return s.view.SaveStack(r.Context(), strings.TrimSpace(r.FormValue("name")), composeFromRequest(r))
+	})))
+	mux.HandleFunc("GET /stack/{name}", s.require(RoleViewer, s.newHandler(func(r *http.Request) Viewer {
+		return s.view.GetStack(r.Context(), r.PathValue("name"))
+	})))
+	mux.HandleFunc("POST /stack/{name}", s.require(RoleAdmin, s.newHandler(func(r *http.Request) Viewer {
+		return s.view.SaveStack(r.Context(), r.PathValue("name"), composeFromRequest(r))
+	})))
+	mux.HandleFunc("DELETE /stack/{name}", s.require(RoleAdmin, s.newHandler(func(r *http.Request) Viewer {
+		return s.view.DeleteStack(r.Context(), r.PathValue("name"))
+	})))
+	mux.HandleFunc("POST /stack/{name}/plan", s.require(RoleAdmin, s.newHandler(func(r *http.Request) Viewer {
+		return s.view.PlanStack(r.Context(), r.PathValue("name"), composeFromRequest(r))
+	})))
+	mux.HandleFunc("POST /stack/{name}/deploy", s.require(RoleAdmin, noWriteDeadline(s.newHandler(func(r *http.Request) Viewer {
+		return s.view.DeployStack(context.WithoutCancel(r.Context()), r.PathValue("name"), composeFromRequest(r))
+	}))))
+	mux.HandleFunc("POST /stack/{name}/down", s.require(RoleAdmin, noWriteDeadline(s.newHandler(func(r *http.Request) Viewer {
+		return s.view.DownStack(context.WithoutCancel(r.Context()), r.PathValue("name"))
+	}))))
+	mux.HandleFunc("GET /images", s.require(RoleViewer, s.newHandler(func(r *http.Request) Viewer {
+		return s.view.GetImages(r.Context())
+	})))
+	mux.HandleFunc("GET /images/list", s.require(RoleViewer, s.newHandler(func(r *http.Request) Viewer {
+		return s.view.GetImageList(r.Context())
+	})))
+	mux.HandleFunc("POST /images/prune", s.require(RoleAdmin, s.newHandler(func(r *http.Request) Viewer {
+		return s.view.PruneImages(r.Context(), r.FormValue("all") == "true")
+	})))
+	mux.HandleFunc("DELETE /image/{imageID}", s.require(RoleAdmin, s.newHandler(func(r *http.Request) Viewer {
+		return s.view.RemoveImage(r.Context(), r.PathValue("imageID"), false)
+	})))
+	mux.HandleFunc("POST /image/{imageID}/push", s.require(RoleAdmin, noWriteDeadline(s.newHandler(func(r *http.Request) Viewer {
+		return s.view.PushImage(context.WithoutCancel(r.Context()), r.PathValue("imageID"), r.FormValue("repository"), r.FormValue("tag"))
+	}))))
+	mux.HandleFunc("GET /volumes", s.require(RoleViewer, s.newHandler(func(r *http.Request) Viewer {
+		return s.view.GetVolumes(r.Context())
+	})))
+	mux.HandleFunc("GET /volumes/list", s.require(RoleViewer, s.newHandler(func(r *http.Request) Viewer {
+		return s.view.GetVolumeList(r.Context())
+	})))
+	mux.HandleFunc("POST /volumes", s.require(RoleAdmin, s.newHandler(func(r *http.Request) Viewer {
+		labels, err := views.ParseLabels(r.FormValue("labels"))
+		if err != nil {
+			return &views.ActionResponseView{Toast: err.Error(), Err: err}
+		}
+		return s.view.CreateVolume(r.Context(), r.FormValue("name"), r.FormValue("driver"), labels)
+	})))
+	mux.HandleFunc("POST /volumes/prune", s.require(RoleAdmin, s.newHandler(func(r *http.Request) Viewer {
+		return s.view.PruneVolumes(r.Context(), r.FormValue("all") == "true")
+	})))
+	mux.HandleFunc("DELETE /volume/{name}", s.require(RoleAdmin, s.newHandler(func(r *http.Request) Viewer {
+		return s.view.RemoveVolume(r.Context(), r.PathValue("name"))
+	})))
+	mux.HandleFunc("GET /networks", s.require(RoleViewer, s.newHandler(func(r *http.Request) Viewer {
+		return s.view.GetNetworks(r.Context())
+	})))
+	mux.HandleFunc("GET /networks/list", s.require(RoleViewer, s.newHandler(func(r *http.Request) Viewer {
+		return s.view.GetNetworkList(r.Context())
+	})))
+	mux.HandleFunc("POST /networks", s.require(RoleAdmin, s.newHandler(func(r *http.Request) Viewer {
+		labels, err := views.ParseLabels(r.FormValue("labels"))
+		if err != nil {
+			return &views.ActionResponseView{Toast: err.Error(), Err: err}
+		}
+		return s.view.CreateNetwork(r.Context(), r.FormValue("name"), r.FormValue("driver"), r.FormValue("subnet"),
+			r.FormValue("internal") == "true", labels)
+	})))
+	mux.HandleFunc("POST /networks/prune", s.require(RoleAdmin, s.newHandler(func(r *http.Request) Viewer {
+		return s.view.PruneNetworks(r.Context())
+	})))
+	mux.HandleFunc("DELETE /network/{networkID}", s.require(RoleAdmin, s.newHandler(func(r *http.Request) Viewer {
+		return s.view.RemoveNetwork(r.Context(), r.PathValue("networkID"))
+	})))
+	mux.HandleFunc("GET /registry", s.require(RoleViewer, s.newHandler(func(r *http.Request) Viewer {
 		return s.view.GetRegistry(r.Context())
-	}))
-	mux.HandleFunc("DELETE /registry/image/{repository}/{digest}", s.newHandler(func(r *http.Request) Viewer {
+	})))
+	mux.HandleFunc("DELETE /registry/image/{repository}/{digest}", s.require(RoleAdmin, s.newHandler(func(r *http.Request) Viewer {
 		return s.view.DeleteRegistryImage(r.Context(), r.PathValue("repository"), r.PathValue("digest"))
-	}))
-	mux.HandleFunc("GET /watchtower", s.newHandler(func(r *http.Request) Viewer {
+	})))
+	mux.HandleFunc("GET /watchtower", s.require(RoleViewer, s.newHandler(func(r *http.Request) Viewer {
 		return s.view.GetWatchtower(r.Context())
-	}))
-	mux.HandleFunc("POST /watchtower/update", s.newHandler(func(r *http.Request) Viewer {
+	})))
+	mux.HandleFunc("POST /watchtower/update", s.require(RoleOperator, s.newHandler(func(r *http.Request) Viewer {
 		return s.view.WatchtowerUpdate(r.Context())
-	}))
-	mux.HandleFunc("POST /github/webhook", s.HandleGithubWebHook)
-	mux.HandleFunc("GET /control-plane", s.newHandler(func(r *http.Request) Viewer {
+	})))
+	mux.HandleFunc("GET /control-plane", s.require(RoleViewer, s.newHandler(func(r *http.Request) Viewer {
 		return s.view.GetControlPlane(r.Context())
-	}))
-	mux.HandleFunc("GET /settings", s.newHandler(func(r *http.Request) Viewer {
+	})))
+	mux.HandleFunc("GET /settings", s.require(RoleAdmin, s.newHandler(func(r *http.Request) Viewer {
 		return s.view.GetSettings(r.Context())
-	}))
-	mux.HandleFunc("POST /settings", s.HandlePostSettings)
-	mux.HandleFunc("POST /settings/service", s.newHandler(func(r *http.Request) Viewer {
+	})))
+	mux.HandleFunc("POST /settings", s.require(RoleAdmin, s.HandlePostSettings))
+	mux.HandleFunc("POST /settings/service", s.require(RoleAdmin, s.newHandler(func(r *http.Request) Viewer {
 		return s.view.PostSettingsWatchedService(r.Context(), r.FormValue("watchedService"))
-	}))
-	mux.HandleFunc("DELETE /settings/service/{name}", s.newHandler(func(r *http.Request) Viewer {
+	})))
+	mux.HandleFunc("DELETE /settings/service/{name}", s.require(RoleAdmin, s.newHandler(func(r *http.Request) Viewer {
 		return s.view.DeleteWatchedService(r.Context(), r.PathValue("name"))
-	}))
-	mux.HandleFunc("GET /devices", s.newHandler(func(r *http.Request) Viewer {
+	})))
+	mux.HandleFunc("POST /settings/notifications/{channel}/test", s.require(RoleAdmin, s.newHandler(func(r *http.Request) Viewer {
+		return s.view.TestNotification(r.Context(), r.PathValue("channel"))
+	})))
+	mux.HandleFunc("GET /tokens", s.require(RoleViewer, s.newHandler(func(r *http.Request) Viewer {
+		return s.view.GetAPITokens(r.Context(), tokenOwner(r.Context()))
+	})))
+	mux.HandleFunc("POST /tokens", s.require(RoleViewer, s.newHandler(func(r *http.Request) Viewer {
+		expiresInDays, err := strconv.Atoi(r.PostFormValue("expiresInDays"))
+		if err != nil {
+			return &views.NewAPITokenView{Err: fmt.Errorf("invalid expiry: %w", err)}
+		}
+		return s.createAPIToken(r.Context(), r.PostFormValue("name"), r.PostFormValue("scope"), expiresInDays)
+	})))
+	mux.HandleFunc("POST /token/{tokenID}/revoke", s.require(RoleViewer, s.newHandler(func(r *http.Request) Viewer {
+		return s.view.RevokeAPIToken(r.Context(), r.PathValue("tokenID"), tokenOwner(r.Context()))
+	})))
+	mux.HandleFunc("GET /devices", s.require(RoleViewer, s.newHandler(func(r *http.Request) Viewer {
 		return s.view.GetDevices(r.Context())
-	}))
-	mux.HandleFunc("GET /device/{deviceID}", s.newHandler(func(r *http.Request) Viewer {
+	})))
+	mux.HandleFunc("GET /devices/list", s.require(RoleViewer, s.newHandler(func(r *http.Request) Viewer {
+		return s.view.GetDeviceList(r.Context())
+	})))
+	mux.HandleFunc("GET /device/{deviceID}", s.require(RoleViewer, s.newHandler(func(r *http.Request) Viewer {
 		return s.view.GetDevice(r.Context(), r.PathValue("deviceID"))
-	}))
-	mux.HandleFunc("GET /packages", s.newHandler(func(r *http.Request) Viewer {
+	})))
+	mux.HandleFunc("POST /device/{deviceID}/package/{name}/rollback", s.require(RoleOperator, s.newHandler(func(r *http.Request) Viewer {
+		return s.view.RollbackPackage(r.Context(), r.PathValue("deviceID"), r.PathValue("name"))
+	})))
+	mux.HandleFunc("POST /device/service/{serviceID}/{action}", s.require(RoleOperator, s.newHandler(func(r *http.Request) Viewer {
+		return s.view.DeviceServiceAction(r.Context(), r.PathValue("serviceID"), r.PathValue("action"))
+	})))
+	mux.HandleFunc("POST /device/enroll", s.require(RoleAdmin, s.newHandler(func(r *http.Request) Viewer {
+		return s.view.CreateJoinToken(r.Context(), r.PostFormValue("hostname"))
+	})))
+	mux.HandleFunc("POST /device/certificate/{serial}/revoke", s.require(RoleAdmin, s.newHandler(func(r *http.Request) Viewer {
+		return s.view.RevokeCertificate(r.Context(), r.PathValue("serial"))
+	})))
+	mux.HandleFunc("GET /services", s.require(RoleViewer, s.newHandler(func(r *http.Request) Viewer {
+		return s.view.GetServices(r.Context())
+	})))
+	mux.HandleFunc("GET /incidents", s.require(RoleViewer, s.newHandler(func(r *http.Request) Viewer {
+		return s.view.GetIncidents(r.Context())
+	})))
+	mux.HandleFunc("GET /incidents/list", s.require(RoleViewer, s.newHandler(func(r *http.Request) Viewer {
+		return s.view.GetIncidentList(r.Context())
+	})))
+	mux.HandleFunc("POST /incident/{incidentID}/resolve", s.require(RoleOperator, s.newHandler(func(r *http.Request) Viewer {
+		return s.view.ResolveIncident(r.Context(), r.PathValue("incidentID"))
+	})))
+	mux.HandleFunc("GET /audit", s.require(RoleAdmin, s.newHandler(func(r *http.Request) Viewer {
+		query := r.URL.Query()
+		page, _ := strconv.Atoi(query.Get("page"))
+		return s.view.GetAudit(r.Context(), views.AuditFilter{
+			Actor:      query.Get("actor"),
+			Action:     query.Get("action"),
+			Target:     query.Get("target"),
+			FailedOnly: query.Get("failed") == "on",
+		}, page)
+	})))
+	mux.HandleFunc("GET /packages", s.require(RoleViewer, s.newHandler(func(r *http.Request) Viewer {
 		return s.view.GetPackages(r.Context()).WithName("PackagesView")
-	}))
-	mux.HandleFunc("POST /package", s.newHandler(func(r *http.Request) Viewer {
+	})))
+	mux.HandleFunc("POST /package", s.require(RoleAdmin, s.newHandler(func(r *http.Request) Viewer {
 		return s.view.AddPackage(r.Context(), db.AddPackageParams{
 			Name:       r.FormValue("Name"),
 			InstallCmd: r.FormValue("InstallCmd"),
@@ -127,13 +312,27 @@ func NewServer(ctx context.Context, config Config) (*Server, error) {
 				Valid:  len(r.FormValue("RemoveCmd")) > 0,
 			},
 		}).WithName("packages-content")
-	}))
+	})))
 	// TODO edit package
 	// mux.HandleFunc("POST /package/{ID...}", s.HandlePostPackage)
-	mux.HandleFunc("DELETE /package/{ID}", s.newHandler(func(r *http.Request) Viewer {
+	mux.HandleFunc("DELETE /package/{ID}", s.require(RoleAdmin, s.newHandler(func(r *http.Request) Viewer {
 		return s.view.DeletePackage(r.Context(), r.PathValue("ID")).WithName("packages-content")
-	}))
-	mux.Handle("GET /static/", http.StripPrefix("/static", http.FileServer(http.Dir(config.StaticDir))))
+	})))
+	mux.HandleFunc("POST /logout", s.require(RoleViewer, s.HandleLogout))
+	s.registerAPI(mux)
+
+	public.HandleFunc("GET /login", s.newHandler(s.HandleLoginPage))
+	public.HandleFunc("POST /login", s.HandleLogin)
+	public.HandleFunc("POST /github/webhook", s.HandleGithubWebHook)
+	public.HandleFunc("GET "+apiPrefix+"/openapi.json", s.HandleOpenAPI)
+	public.Handle("GET /static/", http.StripPrefix("/static", http.FileServer(http.Dir(config.StaticDir))))
+	public.Handle("/", s.requireAuth(mux))
+
+	if numUsers, err := s.query.CountUsers(ctx); err != nil {
+		slog.Warn("cannot count users", "err", err)
+	} else if numUsers == 0 {
+		slog.Warn("no users exist, create one with `mahogany user add` to log in")
+	}
 
 	slog.Info("loaded mux", "routes", mux)
 	return s, nil
@@ -147,6 +346,16 @@ func (s *Server) Start() error {
 			c <- err
 		}
 	}()
+	s.bus.Start()
+	s.producers.Add(2)
+	go func() {
+		defer s.producers.Done()
+		s.view.MonitorIncidents(s.ctx)
+	}()
+	go func() {
+		defer s.producers.Done()
+		s.view.FollowDockerEvents(s.ctx)
+	}()
 	go func() {
 		slog.Info("starting server", "addr", s.httpServer.Addr)
 		if err := s.httpServer.ListenAndServe(); err != http.ErrServerClosed {
@@ -166,8 +375,12 @@ func (s *Server) Start() error {
 func (s *Server) Stop() {
 	ctx, cancel := context.WithTimeout(context.Background(), s.config.Timeout)
 	defer cancel()
-	s.updateServer.Stop()
+	// ends the event streams, which would otherwise keep the http server from shutting down, and the producers
+	s.cancel()
 	s.httpServer.Shutdown(ctx)
+	s.updateServer.Stop()
+	s.producers.Wait()
+	s.bus.Stop()
 }
 
 func (s *Server) HandleContainerLogsStream(w http.ResponseWriter, r *http.Request) {
@@ -189,16 +402,238 @@ func (s *Server) HandleContainerLogsStream(w http.ResponseWriter, r *http.Reques
 	}
 }
 
+// minimum time between pull progress events, docker reports progress far more often than is worth rendering
+const deployProgressInterval = 250 * time.Millisecond
+
+// HandleDeployStream starts a prepared deployment and streams the progress of the image pull until the container
+// has started
+func (s *Server) HandleDeployStream(w http.ResponseWriter, r *http.Request) {
+	events, err := s.newEventWriter(w)
+	if err != nil {
+		slog.Error("failed to load templates", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	var lastProgress *views.PullProgress
+	lastSent := time.Time{}
+	onProgress := func(progress *views.PullProgress) {
+		lastProgress = progress
+		if time.Since(lastSent) >= deployProgressInterval {
+			events.send("progress", "pull-progress", progress)
+			lastSent = time.Now()
+		}
+	}
+	// keep going if the page is closed, a half finished deployment is worse than one nobody watched
+	containerID, err := s.view.Deploy(context.WithoutCancel(r.Context()), r.PathValue("deployID"), onProgress)
+	if lastProgress != nil {
+		events.send("progress", "pull-progress", lastProgress)
+	}
+	events.send("done", "deploy-done", struct {
+		ContainerID string
+		Err         error
+	}{containerID, err})
+}
+
+// HandleContainerStatsStream streams the resources the container of the path uses, or every running container if
+// the path has none. The index page tells containers apart by the event name, which is stats-<short id>.
+func (s *Server) HandleContainerStatsStream(w http.ResponseWriter, r *http.Request) {
+	events, err := s.newEventWriter(w)
+	if err != nil {
+		slog.Error("failed to load templates", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	containerID := r.PathValue("containerID")
+	var stats <-chan views.ContainerStats
+	if len(containerID) > 0 {
+		stats, err = s.view.WatchContainerStats(r.Context(), containerID)
+	} else {
+		stats, err = s.view.WatchContainerStats(r.Context())
+	}
+	if err != nil {
+		slog.Error("failed to watch container stats", "err", err)
+		return
+	}
+	for sample := range stats {
+		if len(containerID) > 0 {
+			events.send("stats", "container-stats", sample)
+		} else {
+			events.send("stats-"+sample.ShortID(), "container-stats-summary", sample)
+		}
+	}
+	// the browser would reconnect right away if the stream ended, e.g. because nothing is running
+	<-r.Context().Done()
+}
+
+// HandleEvents streams the events on the bus to the page, named by their kind, so that it can reload whatever shows
+// what changed
+func (s *Server) HandleEvents(w http.ResponseWriter, r *http.Request) {
+	events, err := s.newEventWriter(w)
+	if err != nil {
+		slog.Error("failed to load templates", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	busC := s.bus.Subscribe()
+	if busC == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	defer s.bus.Unsubscribe(busC)
+	for {
+		select {
+		case event, ok := <-busC:
+			if !ok {
+				return
+			}
+			events.send(event.Kind, "bus-event", event)
+		case <-r.Context().Done():
+			return
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// eventWriter sends server-sent events rendered from templates
+type eventWriter struct {
+	w     http.ResponseWriter
+	rc    *http.ResponseController
+	plate *template.Template
+}
+
+// newEventWriter starts a stream of server-sent events. Streams last longer than the write timeout, so it is cleared.
+func (s *Server) newEventWriter(w http.ResponseWriter) (*eventWriter, error) {
+	plate, err := loadTemplates(s.config.StaticDir)
+	if err != nil {
+		return nil, err
+	}
+	w.Header().Set("content-type", "text/event-stream")
+	w.Header().Set("cache-control", "no-cache")
+	w.Header().Set("connection", "keep-alive")
+	rc := http.NewResponseController(w)
+	if err = rc.SetWriteDeadline(time.Time{}); err != nil {
+		slog.Warn("cannot clear write deadline", "err", err)
+	}
+	return &eventWriter{w: w, rc: rc, plate: plate}, nil
+}
+
+func (e *eventWriter) send(event, name string, data any) {
+	var buf bytes.Buffer
+	if err := e.plate.ExecuteTemplate(&buf, name, data); err != nil {
+		slog.Error("failed to execute template", "err", err, "name", name)
+		return
+	}
+	fmt.Fprintf(e.w, "event: %s\n", event)
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		fmt.Fprintf(e.w, "data: %s\n", line)
+	}
+	fmt.Fprint(e.w, "\n")
+	e.rc.Flush()
+}
+
+// noWriteDeadline lets handlers that wait on docker, e.g. to pull images, take longer than the write timeout
+func noWriteDeadline(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+			slog.Warn("cannot clear write deadline", "err", err)
+		}
+		next(w, r)
+	}
+}
+
+// maximum size of an uploaded compose file
+const maxComposeFile = 1 << 20
+
+// composeFromRequest returns the uploaded compose file, or the compose field of the form if nothing was uploaded
+func composeFromRequest(r *http.Request) string {
+	file, _, err := r.FormFile("composeFile")
+	if err != nil {
+		return r.FormValue("compose")
+	}
+	defer file.Close()
+	compose, err := io.ReadAll(io.LimitReader(file, maxComposeFile))
+	if err != nil || len(compose) == 0 {
+		return r.FormValue("compose")
+	}
+	return string(compose)
+}
+
+// maximum size of a github webhook payload
+const maxGithubPayload = 25 << 20
+
 func (s *Server) HandleGithubWebHook(w http.ResponseWriter, r *http.Request) {
+	eventType := r.Header.Get(sources.GithubEventHeader)
+	deliveryID := r.Header.Get(sources.GithubDeliveryHeader)
+
+	payload, err := io.ReadAll(io.LimitReader(r.Body, maxGithubPayload))
+	if err != nil {
+		slog.Error("failed to read github webhook", "err", err, "delivery", deliveryID)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	secret, err := s.query.GetSetting(r.Context(), "GithubWebhookSecret")
+	if err != nil || len(secret.Value) == 0 {
+		slog.Error("github webhook secret is not configured", "err", err)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	if err = sources.VerifyGithubSignature([]byte(secret.Value), r.Header.Get(sources.GithubSignatureHeader), payload); err != nil {
+		slog.Warn("rejected github webhook", "err", err, "delivery", deliveryID)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch eventType {
+	case "ping":
+		slog.Info("received github ping", "delivery", deliveryID)
+		w.WriteHeader(http.StatusOK)
+		return
+	case "release":
+	default:
+		slog.Info("ignoring github webhook", "event", eventType, "delivery", deliveryID)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
 	var event sources.GithubReleaseEvent
-	decoder := json.NewDecoder(r.Body)
-	if err := decoder.Decode(&event); err != nil {
-		slog.Error("failed to decode github webhook", "err", err)
+	if err = json.Unmarshal(payload, &event); err != nil {
+		slog.Error("failed to decode github webhook", "err", err, "delivery", deliveryID)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if event.GetAction() != "published" {
+		slog.Info("ignoring github release", "action", event.GetAction(), "delivery", deliveryID)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if len(deliveryID) == 0 {
+		slog.Warn("github webhook missing delivery id")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	added, err := s.query.AddGithubDelivery(r.Context(), db.AddGithubDeliveryParams{
+		DeliveryID: deliveryID,
+		Event:      eventType,
+		ReceivedAt: time.Now().Unix(),
+	})
+	if err != nil {
+		slog.Error("failed to record github delivery", "err", err, "delivery", deliveryID)
+		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-	// TODO check github signature
-	slog.Info("received github webhook", "name", *event.Repo.Name)
-	// s.updateServer.PropagateGithubRelease(&event)
+	if added == 0 {
+		slog.Info("ignoring duplicate github delivery", "delivery", deliveryID)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	slog.Info("received github webhook", "name", event.GetRepo().GetName(), "delivery", deliveryID)
+	go s.updateServer.PropagateGithubRelease(sources.WithActor(s.ctx, "github"), &event)
+	w.WriteHeader(http.StatusAccepted)
 }
 
 func (s *Server) HandlePostSettings(w http.ResponseWriter, r *http.Request) {
@@ -211,16 +646,17 @@ func (s *Server) HandlePostSettings(w http.ResponseWriter, r *http.Request) {
 	params := db.UpdateSettingParams{
 		Name: r.URL.Query().Get("name"),
 	}
-	params.Value = r.FormValue(params.Name)
 	result := "saved"
 	if err = r.ParseForm(); err != nil {
 		result = "error"
 	}
+	// the channels of a notification route are checkboxes, which post a value for each one that is checked
+	params.Value = strings.Join(r.Form[params.Name], ",")
 	if err = s.view.PostSettings(r.Context(), params); err != nil {
-		slog.Warn("failed to save settings update", "err", err, "setting", params)
+		slog.Warn("failed to save settings update", "err", err, "setting", params.Name)
 		result = err.Error()
 	}
-	slog.Info("posted settings", "setting", params)
+	slog.Info("posted settings", "setting", params.Name)
 	if err = plate.ExecuteTemplate(w, "settings-toast", result); err != nil {
 		slog.Error("failed to execute settings-toast template", "err", err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -240,6 +676,7 @@ func (s *Server) newHandler(viewFunc func(r *http.Request) Viewer) http.HandlerF
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
+		plate.Funcs(template.FuncMap{"can": canFunc(r.Context())})
 		view := viewFunc(r)
 		if view == nil {
 			slog.Error("view finder did not return a view", "method", r.Method, "path", r.URL.Path)
@@ -284,6 +721,8 @@ func loadTemplates(baseDir string) (plate *template.Template, err error) {
 			return fmt.Sprintf("%s ago", sinceThen)
 		},
 		"trimPrefix": strings.TrimPrefix,
+		// replaced per request with a check against the role of the user
+		"can": func(string) bool { return false },
 	})
 	plate, err = plate.ParseGlob(path.Join(baseDir, "views/*.html"))
 	if err != nil {
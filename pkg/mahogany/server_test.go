@@ -0,0 +1,123 @@
+package mahogany
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	db "github.com/mpoegel/mahogany/internal/db"
+	dbtest "github.com/mpoegel/mahogany/internal/db/dbtest"
+	sources "github.com/mpoegel/mahogany/pkg/mahogany/sources"
+)
+
+const testWebhookSecret = "It's a Secret to Everybody"
+
+func newWebhookServer(t *testing.T, secret string) *Server {
+	t.Helper()
+	dbConn := dbtest.New(t)
+	query := db.New(dbConn)
+	if err := query.UpdateSetting(context.Background(), db.UpdateSettingParams{Name: "GithubWebhookSecret", Value: secret}); err != nil {
+		t.Fatal(err)
+	}
+
+	topologyFile := filepath.Join(t.TempDir(), "topology.toml")
+	if err := os.WriteFile(topologyFile, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	bus := sources.NewEventBus()
+	updateServer, err := sources.NewUpdateServer(topologyFile, 0, time.Second, dbConn, nil, nil, sources.NewNotifier(dbConn), bus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Server{
+		updateServer: updateServer,
+		bus:          bus,
+		query:        query,
+		ctx:          t.Context(),
+	}
+}
+
+func signWebhook(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestHandleGithubWebHook(t *testing.T) {
+	published, err := os.ReadFile("testdata/webhooks/release_published.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := bytes.Replace(published, []byte(`"action": "published"`), []byte(`"action": "created"`), 1)
+
+	tests := []struct {
+		name      string
+		secret    string
+		event     string
+		delivery  string
+		payload   []byte
+		signature string
+		want      int
+	}{
+		{"published", testWebhookSecret, "release", "1", published, signWebhook(testWebhookSecret, published), http.StatusAccepted},
+		{"missing secret", "", "release", "1", published, signWebhook(testWebhookSecret, published), http.StatusServiceUnavailable},
+		{"missing signature", testWebhookSecret, "release", "1", published, "", http.StatusUnauthorized},
+		{"wrong secret", testWebhookSecret, "release", "1", published, signWebhook("guess", published), http.StatusUnauthorized},
+		{"malformed signature", testWebhookSecret, "release", "1", published, "sha1=abc", http.StatusUnauthorized},
+		{"tampered payload", testWebhookSecret, "release", "1", append(published, ' '), signWebhook(testWebhookSecret, published), http.StatusUnauthorized},
+		{"ping", testWebhookSecret, "ping", "1", []byte(`{"zen":"Keep it logically awesome."}`), signWebhook(testWebhookSecret, []byte(`{"zen":"Keep it logically awesome."}`)), http.StatusOK},
+		{"other event", testWebhookSecret, "push", "1", published, signWebhook(testWebhookSecret, published), http.StatusNoContent},
+		{"not published", testWebhookSecret, "release", "1", created, signWebhook(testWebhookSecret, created), http.StatusNoContent},
+		{"missing delivery", testWebhookSecret, "release", "", published, signWebhook(testWebhookSecret, published), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newWebhookServer(t, tt.secret)
+			req := newWebhookRequest(tt.event, tt.delivery, tt.signature, tt.payload)
+			w := httptest.NewRecorder()
+			s.HandleGithubWebHook(w, req)
+			if w.Code != tt.want {
+				t.Errorf("got status %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleGithubWebHookDuplicate(t *testing.T) {
+	payload, err := os.ReadFile("testdata/webhooks/release_published.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newWebhookServer(t, testWebhookSecret)
+	signature := signWebhook(testWebhookSecret, payload)
+
+	for i, want := range []int{http.StatusAccepted, http.StatusOK} {
+		w := httptest.NewRecorder()
+		s.HandleGithubWebHook(w, newWebhookRequest("release", "72d3162e-cc78-11e3-81ab-4c9367dc0958", signature, payload))
+		if w.Code != want {
+			t.Errorf("delivery %d: got status %d, want %d", i, w.Code, want)
+		}
+	}
+}
+
+func newWebhookRequest(event, delivery, signature string, payload []byte) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/github/webhook", strings.NewReader(string(payload)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(sources.GithubEventHeader, event)
+	if len(delivery) > 0 {
+		req.Header.Set(sources.GithubDeliveryHeader, delivery)
+	}
+	if len(signature) > 0 {
+		req.Header.Set(sources.GithubSignatureHeader, signature)
+	}
+	return req
+}
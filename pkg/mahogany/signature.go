@@ -0,0 +1,88 @@
+package mahogany
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	schema "github.com/mpoegel/mahogany/pkg/schema"
+	blake2b "golang.org/x/crypto/blake2b"
+)
+
+// a minisign signature is the algorithm, the ID of the key and the ed25519 signature of either the file (Ed) or its
+// BLAKE2b-512 digest (ED). The trusted comment is signed along with the signature.
+// Docs: https://jedisct1.github.io/minisign/#signature-format
+const (
+	minisignUntrustedPrefix = "untrusted comment: "
+	minisignTrustedPrefix   = "trusted comment: "
+	minisignLegacy          = "Ed"
+	minisignPrehashed       = "ED"
+	minisignKeyIDSize       = 8
+)
+
+var ErrUntrustedSignature = errors.New("signature does not match any trusted key")
+
+// verifySignature checks the signature of the content against the trusted keys. The signature is a minisign
+// signature file, or else a base64 ed25519 signature of the whole content.
+func verifySignature(keys []schema.PublicKey, content, signature []byte) error {
+	if bytes.HasPrefix(signature, []byte(minisignUntrustedPrefix)) {
+		return verifyMinisign(keys, content, signature)
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+	if err != nil {
+		return fmt.Errorf("invalid signature: %w", err)
+	}
+	for _, key := range keys {
+		if ed25519.Verify(key.Key, content, sig) {
+			return nil
+		}
+	}
+	return ErrUntrustedSignature
+}
+
+func verifyMinisign(keys []schema.PublicKey, content, signature []byte) error {
+	lines := strings.Split(strings.ReplaceAll(strings.TrimSpace(string(signature)), "\r\n", "\n"), "\n")
+	if len(lines) != 4 {
+		return errors.New("invalid minisign signature: expected 4 lines")
+	}
+	rawSig, err := base64.StdEncoding.DecodeString(lines[1])
+	if err != nil || len(rawSig) != 2+minisignKeyIDSize+ed25519.SignatureSize {
+		return errors.New("invalid minisign signature")
+	}
+	algorithm, keyID, sig := string(rawSig[:2]), rawSig[2:2+minisignKeyIDSize], rawSig[2+minisignKeyIDSize:]
+	trustedComment, ok := strings.CutPrefix(lines[2], minisignTrustedPrefix)
+	if !ok {
+		return errors.New("invalid minisign signature: missing trusted comment")
+	}
+	globalSig, err := base64.StdEncoding.DecodeString(lines[3])
+	if err != nil || len(globalSig) != ed25519.SignatureSize {
+		return errors.New("invalid minisign signature: invalid trusted comment signature")
+	}
+
+	message := content
+	switch algorithm {
+	case minisignLegacy:
+	case minisignPrehashed:
+		digest := blake2b.Sum512(content)
+		message = digest[:]
+	default:
+		return fmt.Errorf("unsupported minisign algorithm %q", algorithm)
+	}
+
+	for _, key := range keys {
+		if key.ID != nil && !bytes.Equal(key.ID, keyID) {
+			continue
+		}
+		if !ed25519.Verify(key.Key, message, sig) {
+			continue
+		}
+		if !ed25519.Verify(key.Key, append(bytes.Clone(sig), trustedComment...), globalSig) {
+			return errors.New("invalid minisign signature: trusted comment was altered")
+		}
+		return nil
+	}
+	return ErrUntrustedSignature
+}
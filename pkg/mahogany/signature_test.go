@@ -0,0 +1,163 @@
+package mahogany
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	schema "github.com/mpoegel/mahogany/pkg/schema"
+	blake2b "golang.org/x/crypto/blake2b"
+)
+
+// minisignKey is a signing key along with its public key as minisign -G prints it
+type minisignKey struct {
+	id      []byte
+	private ed25519.PrivateKey
+	public  string
+}
+
+func newMinisignKey(t *testing.T, id string) minisignKey {
+	t.Helper()
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := append([]byte(minisignLegacy+id), public...)
+	return minisignKey{id: []byte(id), private: private, public: base64.StdEncoding.EncodeToString(raw)}
+}
+
+// sign returns the minisign signature file of the content
+func (k minisignKey) sign(algorithm string, content []byte, trustedComment string) []byte {
+	message := content
+	if algorithm == minisignPrehashed {
+		digest := blake2b.Sum512(content)
+		message = digest[:]
+	}
+	sig := ed25519.Sign(k.private, message)
+	globalSig := ed25519.Sign(k.private, append(append([]byte{}, sig...), trustedComment...))
+	raw := append(append([]byte(algorithm), k.id...), sig...)
+	return fmt.Appendf(nil, "untrusted comment: signature from minisign secret key\n%s\ntrusted comment: %s\n%s\n",
+		base64.StdEncoding.EncodeToString(raw), trustedComment, base64.StdEncoding.EncodeToString(globalSig))
+}
+
+// readPackageKeys reads the public keys of a package from a topology file
+func readPackageKeys(t *testing.T, keys ...string) []schema.PublicKey {
+	t.Helper()
+	topologyFile := filepath.Join(t.TempDir(), "topology.toml")
+	topology := fmt.Sprintf(`
+[[baseline]]
+id = "tool"
+public_keys = ["%s"]
+github_package = { name = "mpoegel/tool", asset_regex = "tool.*" }
+`, strings.Join(keys, `", "`))
+	if err := os.WriteFile(topologyFile, []byte(topology), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	topo, err := schema.ReadTopology(topologyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return topo.FindPackage("host", "tool").Keys
+}
+
+func TestVerifySignature(t *testing.T) {
+	content := []byte("tool v1.2.0")
+	key := newMinisignKey(t, "12345678")
+	other := newMinisignKey(t, "87654321")
+	sameID := newMinisignKey(t, "12345678")
+	bareKey := base64.StdEncoding.EncodeToString(key.private.Public().(ed25519.PublicKey))
+
+	tampered := key.sign(minisignPrehashed, content, "timestamp:1700000000\tfile:tool.deb")
+	tampered = []byte(strings.Replace(string(tampered), "file:tool.deb", "file:other.deb", 1))
+
+	tests := []struct {
+		name      string
+		keys      []string
+		content   []byte
+		signature []byte
+		wantErr   string
+	}{
+		{"prehashed", []string{key.public}, content, key.sign(minisignPrehashed, content, "file:tool.deb"), ""},
+		{"legacy", []string{key.public}, content, key.sign(minisignLegacy, content, "file:tool.deb"), ""},
+		{"second key", []string{other.public, key.public}, content, key.sign(minisignPrehashed, content, "file:tool.deb"), ""},
+		{"bare key", []string{bareKey}, content, key.sign(minisignPrehashed, content, "file:tool.deb"), ""},
+		{"bare signature", []string{bareKey}, content, []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(key.private, content)) + "\n"), ""},
+		{"other key", []string{other.public}, content, key.sign(minisignPrehashed, content, "file:tool.deb"), ErrUntrustedSignature.Error()},
+		{"same key ID", []string{sameID.public}, content, key.sign(minisignPrehashed, content, "file:tool.deb"), ErrUntrustedSignature.Error()},
+		{"altered content", []string{key.public}, []byte("tool v6.6.6"), key.sign(minisignPrehashed, content, "file:tool.deb"), ErrUntrustedSignature.Error()},
+		{"altered trusted comment", []string{key.public}, content, tampered, "trusted comment was altered"},
+		{"unknown algorithm", []string{key.public}, content, key.sign("Xx", content, "file:tool.deb"), "unsupported minisign algorithm"},
+		{"truncated", []string{key.public}, content, []byte("untrusted comment: signature\nRWQ=\n"), "expected 4 lines"},
+		{"not base64", []string{bareKey}, content, []byte("not a signature"), "invalid signature"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifySignature(readPackageKeys(t, tt.keys...), tt.content, tt.signature)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("got error %v", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("got error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyAsset(t *testing.T) {
+	content := []byte("tool v1.2.0")
+	filename := filepath.Join(t.TempDir(), "tool.deb")
+	if err := os.WriteFile(filename, content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256(content)
+	sum := hex.EncodeToString(digest[:])
+	wrongDigest := sha256.Sum256([]byte("tool v6.6.6"))
+	key := newMinisignKey(t, "12345678")
+	signature := key.sign(minisignPrehashed, content, "file:tool.deb")
+	release := testRelease("v1.2.0")
+
+	withKeys := &Agent{config: AgentConfig{HostName: "host"}, topology: &schema.Topology{
+		Baseline: []schema.Package{{ID: "tool", Keys: readPackageKeys(t, key.public)}},
+	}}
+	withoutKeys := &Agent{config: AgentConfig{HostName: "host"}, topology: &schema.Topology{}}
+
+	tests := []struct {
+		name    string
+		agent   *Agent
+		asset   *schema.Asset
+		digest  []byte
+		wantErr string
+	}{
+		{"signed", withKeys, &schema.Asset{Name: "tool.deb", Sha256: sum, Signature: signature}, digest[:], ""},
+		{"not signed", withKeys, &schema.Asset{Name: "tool.deb", Sha256: sum}, digest[:], "asset is not signed"},
+		{"signed by another key", withKeys, &schema.Asset{Name: "tool.deb", Sha256: sum, Signature: newMinisignKey(t, "87654321").sign(minisignPrehashed, content, "")}, digest[:], ErrUntrustedSignature.Error()},
+		// without keys in the topology of the agent the signature is not checked, which is logged
+		{"no keys", withoutKeys, &schema.Asset{Name: "tool.deb", Sha256: sum, Signature: signature}, digest[:], ""},
+		{"no checksum", withoutKeys, &schema.Asset{Name: "tool.deb"}, digest[:], "asset has no checksum"},
+		{"checksum mismatch", withKeys, &schema.Asset{Name: "tool.deb", Sha256: sum, Signature: signature}, wrongDigest[:], "checksum mismatch"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.agent.verifyAsset(release, tt.asset, filename, tt.digest)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("got error %v", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("got error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+	if !errors.Is(verifySignature(nil, content, signature), ErrUntrustedSignature) {
+		t.Error("signature verified without any keys")
+	}
+}
@@ -0,0 +1,73 @@
+package sources
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	db "github.com/mpoegel/mahogany/internal/db"
+)
+
+// SystemActor is recorded for actions that were not started by a user
+const SystemActor = "system"
+
+type actorContextKey struct{}
+
+// WithActor names who is responsible for the actions taken with the context
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorContextKey{}, actor)
+}
+
+func ActorFromContext(ctx context.Context) string {
+	actor, ok := ctx.Value(actorContextKey{}).(string)
+	if !ok || len(actor) == 0 {
+		return SystemActor
+	}
+	return actor
+}
+
+// Auditor keeps a record of every action that changes something, who took it and how it went. Actions that worked
+// are also published on the bus, since they are what most pages show.
+type Auditor struct {
+	query *db.Queries
+	bus   *EventBus
+}
+
+func NewAuditor(dbConn *sql.DB, bus *EventBus) *Auditor {
+	return &Auditor{
+		query: db.New(dbConn),
+		bus:   bus,
+	}
+}
+
+// Record stores the outcome of an action by the actor of the context. The params are stored as JSON. Failing to
+// store the event is logged rather than returned so that auditing never gets in the way of the action itself.
+func (a *Auditor) Record(ctx context.Context, action, target string, params any, err error) {
+	args := db.AddAuditEventParams{
+		CreatedAt: time.Now().Unix(),
+		Actor:     ActorFromContext(ctx),
+		Action:    action,
+		Target:    target,
+		Params:    "{}",
+		IsSuccess: err == nil,
+	}
+	if params != nil {
+		if encoded, jsonErr := json.Marshal(params); jsonErr == nil {
+			args.Params = string(encoded)
+		} else {
+			slog.Warn("cannot encode audit params", "action", action, "err", jsonErr)
+		}
+	}
+	if err != nil {
+		args.Error = sql.NullString{String: err.Error(), Valid: true}
+	}
+	// the action may have been cancelled with its request, but it should still be recorded
+	if dbErr := a.query.AddAuditEvent(context.WithoutCancel(ctx), args); dbErr != nil {
+		slog.Error("cannot record audit event", "action", action, "target", target, "actor", args.Actor, "err", dbErr)
+	}
+	if err == nil {
+		a.bus.Publish(BusAction, target, action)
+	}
+}
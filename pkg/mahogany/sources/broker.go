@@ -1,83 +1,78 @@
 package sources
 
+import "sync"
+
+// Broker fans out every message broadcast to all subscribers. Subscribers that fall behind miss messages rather than
+// holding up the others. It is safe to use from any goroutine, and broadcasts before Start or after Stop are dropped.
 type Broker[T any] struct {
-	stopC      chan struct{}
-	broadcastC chan T
-	subC       chan chan T
-	unsubC     chan chan T
-	isStopped  bool
-	count      int
+	mu        sync.Mutex
+	subs      map[chan T]bool
+	isStopped bool
 }
 
 func NewBroker[T any]() *Broker[T] {
 	b := &Broker[T]{
-		stopC:      make(chan struct{}),
-		broadcastC: make(chan T, 1),
-		subC:       make(chan chan T, 1),
-		unsubC:     make(chan chan T, 1),
-		isStopped:  true,
+		subs:      map[chan T]bool{},
+		isStopped: true,
 	}
 	return b
 }
 
 func (b *Broker[T]) Start() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
 	b.isStopped = false
-	subs := map[chan T]bool{}
-	for {
-		select {
-		case <-b.stopC:
-			for c := range subs {
-				close(c)
-			}
-			return
-		case newC := <-b.subC:
-			subs[newC] = true
-			b.count++
-		case oldC := <-b.unsubC:
-			delete(subs, oldC)
-			close(oldC)
-			b.count--
-		case msg := <-b.broadcastC:
-			for subbedC := range subs {
-				// non-blocking broadcast
-				select {
-				case subbedC <- msg:
-				default:
-				}
-			}
-		}
-	}
 }
 
+// Stop closes the channel of every subscriber
 func (b *Broker[T]) Stop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
 	b.isStopped = true
-	close(b.stopC)
-	close(b.broadcastC)
-	close(b.subC)
-	close(b.unsubC)
+	for c := range b.subs {
+		close(c)
+	}
+	clear(b.subs)
 }
 
+// Subscribe returns a channel of the messages broadcast from now on, or nil if the broker is stopped
 func (b *Broker[T]) Subscribe() chan T {
-	if !b.isStopped {
-		newC := make(chan T, 5)
-		b.subC <- newC
-		return newC
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.isStopped {
+		return nil
 	}
-	return nil
+	newC := make(chan T, 5)
+	b.subs[newC] = true
+	return newC
 }
 
 func (b *Broker[T]) Unsubscribe(oldC chan T) {
-	if !b.isStopped {
-		b.unsubC <- oldC
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.subs[oldC] {
+		delete(b.subs, oldC)
+		close(oldC)
 	}
 }
 
 func (b *Broker[T]) Broadcast(msg T) {
-	if !b.isStopped {
-		b.broadcastC <- msg
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.isStopped {
+		return
+	}
+	for subbedC := range b.subs {
+		// non-blocking broadcast
+		select {
+		case subbedC <- msg:
+		default:
+		}
 	}
 }
 
 func (b *Broker[T]) Count() int {
-	return b.count
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs)
 }
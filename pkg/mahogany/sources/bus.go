@@ -0,0 +1,87 @@
+package sources
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	events "github.com/docker/docker/api/types/events"
+	filters "github.com/docker/docker/api/types/filters"
+)
+
+// the kinds of event on the bus, which are also the names of the events that pages listen for
+const (
+	// a container was created, started, stopped, removed or changed health
+	BusContainer = "container"
+	// an agent connected or disconnected
+	BusAgent = "agent"
+	// an agent reported its services and host metrics
+	BusReport = "report"
+	// an incident was opened, occurred again or was resolved
+	BusIncident = "incident"
+	// a user or the system took an action that was recorded in the audit log
+	BusAction = "action"
+)
+
+// BusEvent says that something changed, so that whatever shows it can load it again
+type BusEvent struct {
+	Kind   string    `json:"kind"`
+	Target string    `json:"target"`
+	Action string    `json:"action"`
+	Time   time.Time `json:"time"`
+}
+
+// EventBus broadcasts what changes across mahogany to everyone subscribed, e.g. the pages open in browsers. Events
+// are dropped for subscribers that fall behind, and nothing is published until the bus is started.
+type EventBus struct {
+	*Broker[BusEvent]
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{Broker: NewBroker[BusEvent]()}
+}
+
+func (b *EventBus) Publish(kind, target, action string) {
+	b.Broadcast(BusEvent{Kind: kind, Target: target, Action: action, Time: time.Now()})
+}
+
+// FollowDocker publishes the changes to containers from the docker events until the context is done, and follows
+// them again if docker goes away
+func (b *EventBus) FollowDocker(ctx context.Context, docker DockerI) {
+	for ctx.Err() == nil {
+		err := b.followDocker(ctx, docker)
+		if ctx.Err() != nil {
+			return
+		}
+		slog.Warn("docker events stream ended", "err", err)
+		select {
+		case <-ctx.Done():
+		case <-time.After(eventsRetryDelay):
+		}
+	}
+}
+
+func (b *EventBus) followDocker(ctx context.Context, docker DockerI) error {
+	opts := events.ListOptions{
+		Filters: filters.NewArgs(
+			filters.Arg("type", string(events.ContainerEventType)),
+			filters.Arg("event", string(events.ActionCreate)),
+			filters.Arg("event", string(events.ActionStart)),
+			filters.Arg("event", string(events.ActionDie)),
+			filters.Arg("event", string(events.ActionPause)),
+			filters.Arg("event", string(events.ActionUnPause)),
+			filters.Arg("event", string(events.ActionRename)),
+			filters.Arg("event", string(events.ActionDestroy)),
+			filters.Arg("event", string(events.ActionHealthStatus)),
+		),
+	}
+	msgs, errs := docker.Events(ctx, opts)
+	for {
+		select {
+		case msg := <-msgs:
+			b.Publish(BusContainer, msg.Actor.ID, string(msg.Action))
+		case err := <-errs:
+			return err
+		}
+	}
+}
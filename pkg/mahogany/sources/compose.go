@@ -0,0 +1,253 @@
+package sources
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// labels that docker compose puts on what it creates, so that stacks deployed by either tool are recognized by both
+const (
+	ComposeProjectLabel = "com.docker.compose.project"
+	ComposeServiceLabel = "com.docker.compose.service"
+	ComposeNumberLabel  = "com.docker.compose.container-number"
+	ComposeOneoffLabel  = "com.docker.compose.oneoff"
+	ComposeNetworkLabel = "com.docker.compose.network"
+	ComposeVolumeLabel  = "com.docker.compose.volume"
+)
+
+// StackConfigHashLabel is the hash of the service a container was deployed from. It is not compose's own config-hash
+// label since the hashes differ, and each tool would recreate every container the other one deployed.
+const StackConfigHashLabel = "mahogany.config-hash"
+
+var composeProjectName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// the service keys that mahogany deploys, anything else in a service is reported as ignored
+var composeServiceKeys = []string{
+	"image", "container_name", "command", "entrypoint", "environment", "ports", "volumes", "networks", "depends_on",
+	"restart", "labels", "user", "working_dir", "hostname",
+}
+
+// ComposeFile is the part of the compose specification that mahogany can deploy
+type ComposeFile struct {
+	Services map[string]*ComposeService  `yaml:"services" json:"services"`
+	Networks map[string]*ComposeResource `yaml:"networks" json:"networks,omitempty"`
+	Volumes  map[string]*ComposeResource `yaml:"volumes" json:"volumes,omitempty"`
+}
+
+type ComposeService struct {
+	Image         string         `yaml:"image" json:"image"`
+	ContainerName string         `yaml:"container_name" json:"containerName,omitempty"`
+	Command       ComposeCommand `yaml:"command" json:"command,omitempty"`
+	Entrypoint    ComposeCommand `yaml:"entrypoint" json:"entrypoint,omitempty"`
+	Environment   ComposeMapping `yaml:"environment" json:"environment,omitempty"`
+	Ports         []string       `yaml:"ports" json:"ports,omitempty"`
+	Volumes       []string       `yaml:"volumes" json:"volumes,omitempty"`
+	Networks      ComposeNames   `yaml:"networks" json:"networks,omitempty"`
+	DependsOn     ComposeNames   `yaml:"depends_on" json:"dependsOn,omitempty"`
+	Restart       string         `yaml:"restart" json:"restart,omitempty"`
+	Labels        ComposeMapping `yaml:"labels" json:"labels,omitempty"`
+	User          string         `yaml:"user" json:"user,omitempty"`
+	WorkingDir    string         `yaml:"working_dir" json:"workingDir,omitempty"`
+	Hostname      string         `yaml:"hostname" json:"hostname,omitempty"`
+}
+
+// ComposeResource is a network or volume of a compose file
+type ComposeResource struct {
+	// Name overrides the name docker gives the resource, which is otherwise prefixed with the project
+	Name     string         `yaml:"name" json:"name,omitempty"`
+	Driver   string         `yaml:"driver" json:"driver,omitempty"`
+	External bool           `yaml:"external" json:"external,omitempty"`
+	Labels   ComposeMapping `yaml:"labels" json:"labels,omitempty"`
+}
+
+// ComposeCommand is a command given either as a list or as a string, which is split on whitespace. Use the list
+// form for arguments with spaces.
+type ComposeCommand []string
+
+func (c *ComposeCommand) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*c = strings.Fields(node.Value)
+		return nil
+	}
+	var list []string
+	if err := node.Decode(&list); err != nil {
+		return err
+	}
+	*c = list
+	return nil
+}
+
+// ComposeMapping is a mapping given either as a map or as a list of KEY=VALUE
+type ComposeMapping map[string]string
+
+func (m *ComposeMapping) UnmarshalYAML(node *yaml.Node) error {
+	mapping := ComposeMapping{}
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if value.Kind != yaml.ScalarNode {
+				return fmt.Errorf("line %d: value of %q must be a string", value.Line, key.Value)
+			}
+			if value.Tag == "!!null" {
+				mapping[key.Value] = ""
+			} else {
+				mapping[key.Value] = value.Value
+			}
+		}
+	case yaml.SequenceNode:
+		var list []string
+		if err := node.Decode(&list); err != nil {
+			return err
+		}
+		for _, item := range list {
+			key, value, _ := strings.Cut(item, "=")
+			mapping[key] = value
+		}
+	default:
+		return fmt.Errorf("line %d: expected a map or a list of KEY=VALUE", node.Line)
+	}
+	*m = mapping
+	return nil
+}
+
+// List returns the mapping as sorted KEY=VALUE pairs
+func (m ComposeMapping) List() []string {
+	list := make([]string, 0, len(m))
+	for _, key := range slices.Sorted(maps.Keys(m)) {
+		list = append(list, key+"="+m[key])
+	}
+	return list
+}
+
+// ComposeNames is a list of names given either as a list or as the keys of a map, like networks and depends_on
+type ComposeNames []string
+
+func (n *ComposeNames) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.MappingNode:
+		names := []string{}
+		for i := 0; i < len(node.Content); i += 2 {
+			names = append(names, node.Content[i].Value)
+		}
+		slices.Sort(names)
+		*n = names
+	case yaml.SequenceNode:
+		var list []string
+		if err := node.Decode(&list); err != nil {
+			return err
+		}
+		*n = list
+	default:
+		return fmt.Errorf("line %d: expected a list or a map", node.Line)
+	}
+	return nil
+}
+
+// ValidateComposeProject checks that the name is allowed as a compose project name
+func ValidateComposeProject(name string) error {
+	if !composeProjectName.MatchString(name) {
+		return fmt.Errorf("name %q must be lowercase letters, digits, dashes and underscores", name)
+	}
+	return nil
+}
+
+// ParseCompose reads and checks a compose file. The warnings list the keys that mahogany does not deploy.
+func ParseCompose(data []byte) (*ComposeFile, []string, error) {
+	var file ComposeFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, nil, err
+	}
+	if len(file.Services) == 0 {
+		return nil, nil, errors.New("compose file has no services")
+	}
+	if file.Networks == nil {
+		file.Networks = map[string]*ComposeResource{}
+	}
+	if file.Volumes == nil {
+		file.Volumes = map[string]*ComposeResource{}
+	}
+	for _, resources := range []map[string]*ComposeResource{file.Networks, file.Volumes} {
+		for key, resource := range resources {
+			if resource == nil {
+				resources[key] = &ComposeResource{}
+			}
+		}
+	}
+
+	var raw struct {
+		Services map[string]map[string]yaml.Node `yaml:"services"`
+	}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, nil, err
+	}
+	warnings := []string{}
+	for _, name := range slices.Sorted(maps.Keys(raw.Services)) {
+		for _, key := range slices.Sorted(maps.Keys(raw.Services[name])) {
+			if key == "build" {
+				return nil, nil, fmt.Errorf("service %q: build is not supported, push the image to a registry", name)
+			}
+			if !slices.Contains(composeServiceKeys, key) {
+				warnings = append(warnings, fmt.Sprintf("service %q: %s is ignored", name, key))
+			}
+		}
+	}
+
+	for name, service := range file.Services {
+		if service == nil || len(service.Image) == 0 {
+			return nil, nil, fmt.Errorf("service %q has no image", name)
+		}
+		for _, dep := range service.DependsOn {
+			if _, ok := file.Services[dep]; !ok {
+				return nil, nil, fmt.Errorf("service %q depends on unknown service %q", name, dep)
+			}
+		}
+		for _, net := range service.Networks {
+			if _, ok := file.Networks[net]; !ok && net != "default" {
+				return nil, nil, fmt.Errorf("service %q uses network %q, which is not declared under networks", name, net)
+			}
+		}
+	}
+	if _, err := file.ServiceOrder(); err != nil {
+		return nil, nil, err
+	}
+	return &file, warnings, nil
+}
+
+// ServiceOrder returns the services so that every service comes after the services it depends on
+func (f *ComposeFile) ServiceOrder() ([]string, error) {
+	order := make([]string, 0, len(f.Services))
+	done := map[string]bool{}
+	visiting := map[string]bool{}
+	var visit func(name string) error
+	visit = func(name string) error {
+		if done[name] {
+			return nil
+		}
+		if visiting[name] {
+			return fmt.Errorf("services depend on each other in a cycle through %q", name)
+		}
+		visiting[name] = true
+		for _, dep := range f.Services[name].DependsOn {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		visiting[name] = false
+		done[name] = true
+		order = append(order, name)
+		return nil
+	}
+	for _, name := range slices.Sorted(maps.Keys(f.Services)) {
+		if err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
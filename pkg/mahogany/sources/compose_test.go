@@ -0,0 +1,183 @@
+package sources
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCompose(t *testing.T) {
+	tests := []struct {
+		name     string
+		compose  string
+		check    func(t *testing.T, file *ComposeFile)
+		warnings []string
+		wantErr  string
+	}{
+		{
+			name: "services",
+			compose: `
+services:
+  web:
+    image: nginx:1.27
+    command: nginx -g "daemon off;"
+    ports: ["8080:80", "127.0.0.1:8443:443/tcp"]
+    environment:
+      TZ: UTC
+      EMPTY:
+    volumes: [data:/var/lib/data, /srv/config:/config:ro]
+    networks: [front]
+    depends_on:
+      db:
+        condition: service_healthy
+    deploy:
+      replicas: 2
+  db:
+    image: postgres:16
+    entrypoint: [docker-entrypoint.sh, "--flag with spaces"]
+    environment: [POSTGRES_DB=app, POSTGRES_PASSWORD=a=b]
+    labels: [tier=data]
+    healthcheck:
+      test: pg_isready
+networks:
+  front:
+volumes:
+  data:
+    name: shared-data
+`,
+			check: func(t *testing.T, file *ComposeFile) {
+				web, db := file.Services["web"], file.Services["db"]
+				wantWeb := &ComposeService{
+					Image:       "nginx:1.27",
+					Command:     ComposeCommand{"nginx", "-g", `"daemon`, `off;"`},
+					Environment: ComposeMapping{"TZ": "UTC", "EMPTY": ""},
+					Ports:       []string{"8080:80", "127.0.0.1:8443:443/tcp"},
+					Volumes:     []string{"data:/var/lib/data", "/srv/config:/config:ro"},
+					Networks:    ComposeNames{"front"},
+					DependsOn:   ComposeNames{"db"},
+				}
+				if !reflect.DeepEqual(web, wantWeb) {
+					t.Errorf("got web %+v\nwant %+v", web, wantWeb)
+				}
+				wantDB := &ComposeService{
+					Image:       "postgres:16",
+					Entrypoint:  ComposeCommand{"docker-entrypoint.sh", "--flag with spaces"},
+					Environment: ComposeMapping{"POSTGRES_DB": "app", "POSTGRES_PASSWORD": "a=b"},
+					Labels:      ComposeMapping{"tier": "data"},
+				}
+				if !reflect.DeepEqual(db, wantDB) {
+					t.Errorf("got db %+v\nwant %+v", db, wantDB)
+				}
+				if file.Networks["front"] == nil {
+					t.Error("network without settings is nil")
+				}
+				if got := file.Volumes["data"].Name; got != "shared-data" {
+					t.Errorf("got volume name %q", got)
+				}
+			},
+			warnings: []string{`service "db": healthcheck is ignored`, `service "web": deploy is ignored`},
+		},
+		{
+			name:    "no services",
+			compose: "networks:\n  front:\n",
+			wantErr: "compose file has no services",
+		},
+		{
+			name:    "no image",
+			compose: "services:\n  web:\n    ports: [\"80:80\"]\n",
+			wantErr: `service "web" has no image`,
+		},
+		{
+			name:    "build",
+			compose: "services:\n  web:\n    build: .\n",
+			wantErr: "build is not supported",
+		},
+		{
+			name:    "unknown dependency",
+			compose: "services:\n  web:\n    image: nginx\n    depends_on: [cache]\n",
+			wantErr: `service "web" depends on unknown service "cache"`,
+		},
+		{
+			name:    "undeclared network",
+			compose: "services:\n  web:\n    image: nginx\n    networks: [front]\n",
+			wantErr: `uses network "front", which is not declared`,
+		},
+		{
+			name:    "default network",
+			compose: "services:\n  web:\n    image: nginx\n    networks: [default]\n",
+		},
+		{
+			name:    "dependency cycle",
+			compose: "services:\n  a:\n    image: x\n    depends_on: [b]\n  b:\n    image: x\n    depends_on: [c]\n  c:\n    image: x\n    depends_on: [a]\n",
+			wantErr: "cycle",
+		},
+		{
+			name:    "depends on itself",
+			compose: "services:\n  a:\n    image: x\n    depends_on: [a]\n",
+			wantErr: "cycle",
+		},
+		{
+			name:    "environment with nested value",
+			compose: "services:\n  web:\n    image: nginx\n    environment:\n      TZ: [UTC]\n",
+			wantErr: `value of "TZ" must be a string`,
+		},
+		{
+			name:    "not yaml",
+			compose: "services: [",
+			wantErr: "yaml",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, warnings, err := ParseCompose([]byte(tt.compose))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.warnings == nil {
+				tt.warnings = []string{}
+			}
+			if !reflect.DeepEqual(warnings, tt.warnings) {
+				t.Errorf("got warnings %q, want %q", warnings, tt.warnings)
+			}
+			if tt.check != nil {
+				tt.check(t, file)
+			}
+		})
+	}
+}
+
+func TestServiceOrder(t *testing.T) {
+	tests := []struct {
+		name     string
+		services map[string][]string
+		want     []string
+		wantErr  bool
+	}{
+		{"independent", map[string][]string{"c": nil, "a": nil, "b": nil}, []string{"a", "b", "c"}, false},
+		{"chain", map[string][]string{"a": {"b"}, "b": {"c"}, "c": nil}, []string{"c", "b", "a"}, false},
+		{"diamond", map[string][]string{"app": {"db", "cache"}, "db": {"volume"}, "cache": {"volume"}, "volume": nil}, []string{"volume", "db", "cache", "app"}, false},
+		{"cycle", map[string][]string{"a": {"b"}, "b": {"a"}, "c": nil}, nil, true},
+		{"self", map[string][]string{"a": {"a"}}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := &ComposeFile{Services: map[string]*ComposeService{}}
+			for name, deps := range tt.services {
+				file.Services[name] = &ComposeService{Image: "x", DependsOn: deps}
+			}
+			got, err := file.ServiceOrder()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got order %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
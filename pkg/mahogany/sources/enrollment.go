@@ -0,0 +1,243 @@
+package sources
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	db "github.com/mpoegel/mahogany/internal/db"
+	schema "github.com/mpoegel/mahogany/pkg/schema"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	credentials "google.golang.org/grpc/credentials"
+	peer "google.golang.org/grpc/peer"
+	status "google.golang.org/grpc/status"
+)
+
+// JoinTokenTTL is how long an agent has to use its join token
+const JoinTokenTTL = 24 * time.Hour
+
+var (
+	ErrCertificateNotFound = errors.New("certificate not found or already revoked")
+	errCertificateRevoked  = status.Error(codes.PermissionDenied, "client certificate has been revoked")
+)
+
+type agentHostnameKey struct{}
+
+// CreateJoinToken returns a one-time token that lets the agent on the host enroll. The token carries the fingerprint
+// of the CA so that the agent can trust the server before it has a copy of the CA certificate.
+func (s *UpdateServer) CreateJoinToken(ctx context.Context, hostname string) (string, error) {
+	if len(hostname) == 0 {
+		return "", errors.New("hostname is required")
+	}
+	secret := rand.Text()
+	now := time.Now()
+	err := s.query.AddJoinToken(ctx, db.AddJoinTokenParams{
+		TokenHash: hashJoinToken(secret),
+		Hostname:  hostname,
+		CreatedAt: now.Unix(),
+		ExpiresAt: now.Add(JoinTokenTTL).Unix(),
+	})
+	s.audit.Record(ctx, "agent.join_token.create", hostname, nil, err)
+	if err != nil {
+		return "", err
+	}
+	slog.Info("created join token", "hostname", hostname)
+	return secret + "." + s.ca.Fingerprint(), nil
+}
+
+// RevokeCertificate stops the certificate from being accepted and drops any streams that are using it
+func (s *UpdateServer) RevokeCertificate(ctx context.Context, serial string) error {
+	err := s.revokeCertificate(ctx, serial)
+	s.audit.Record(ctx, "agent.certificate.revoke", serial, nil, err)
+	return err
+}
+
+func (s *UpdateServer) revokeCertificate(ctx context.Context, serial string) error {
+	rows, err := s.query.RevokeAgentCertificate(ctx, db.RevokeAgentCertificateParams{
+		RevokedAt: sql.NullInt64{Int64: time.Now().Unix(), Valid: true},
+		Serial:    serial,
+	})
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrCertificateNotFound
+	}
+
+	s.streamsMu.Lock()
+	for _, cancel := range s.streams[serial] {
+		cancel(errCertificateRevoked)
+	}
+	delete(s.streams, serial)
+	s.streamsMu.Unlock()
+	slog.Info("revoked agent certificate", "serial", serial)
+	return nil
+}
+
+func (s *UpdateServer) Enroll(ctx context.Context, req *schema.EnrollRequest) (*schema.EnrollResponse, error) {
+	ctx = WithActor(ctx, "agent:"+req.Hostname)
+	resp, serial, err := s.enroll(ctx, req)
+	s.audit.Record(ctx, "agent.enroll", req.Hostname, map[string]string{"serial": serial}, err)
+	return resp, err
+}
+
+func (s *UpdateServer) enroll(ctx context.Context, req *schema.EnrollRequest) (*schema.EnrollResponse, string, error) {
+	secret, _, _ := strings.Cut(req.Token, ".")
+	now := time.Now().Unix()
+	hostname, err := s.query.UseJoinToken(ctx, db.UseJoinTokenParams{
+		UsedAt:    sql.NullInt64{Int64: now, Valid: true},
+		TokenHash: hashJoinToken(secret),
+		ExpiresAt: now,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		slog.Warn("enrollment with invalid join token", "hostname", req.Hostname)
+		return nil, "", status.Error(codes.PermissionDenied, "invalid or expired join token")
+	} else if err != nil {
+		return nil, "", err
+	}
+	if hostname != req.Hostname {
+		slog.Warn("enrollment with join token for another host", "hostname", req.Hostname, "token_hostname", hostname)
+		return nil, "", status.Error(codes.PermissionDenied, "join token was issued for another host")
+	}
+
+	certPEM, cert, err := s.ca.SignAgentCSR(req.Csr, hostname)
+	if err != nil {
+		return nil, "", status.Error(codes.InvalidArgument, err.Error())
+	}
+	err = s.query.AddAgentCertificate(ctx, db.AddAgentCertificateParams{
+		Serial:    CertificateSerial(cert),
+		Hostname:  hostname,
+		IssuedAt:  cert.NotBefore.Unix(),
+		ExpiresAt: cert.NotAfter.Unix(),
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	serial := CertificateSerial(cert)
+	slog.Info("enrolled agent", "hostname", hostname, "serial", serial)
+	return &schema.EnrollResponse{
+		Certificate:   certPEM,
+		CaCertificate: s.ca.CertPEM(),
+	}, serial, nil
+}
+
+// authorize checks the client certificate of the call. Every call but Enroll needs a certificate that was issued by
+// the CA and has not been revoked. The hostname of the certificate is added to the context.
+func (s *UpdateServer) authorize(ctx context.Context, method string) (context.Context, string, error) {
+	if method == schema.UpdateService_Enroll_FullMethodName {
+		return ctx, "", nil
+	}
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil, "", status.Error(codes.Unauthenticated, "client certificate required")
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 || len(tlsInfo.State.VerifiedChains[0]) == 0 {
+		return nil, "", status.Error(codes.Unauthenticated, "client certificate required")
+	}
+	cert := tlsInfo.State.VerifiedChains[0][0]
+	serial := CertificateSerial(cert)
+	record, err := s.query.GetAgentCertificate(ctx, serial)
+	if errors.Is(err, sql.ErrNoRows) {
+		slog.Warn("call with unknown client certificate", "serial", serial, "method", method)
+		return nil, "", status.Error(codes.PermissionDenied, "unknown client certificate")
+	} else if err != nil {
+		return nil, "", err
+	}
+	if record.RevokedAt.Valid {
+		slog.Warn("call with revoked client certificate", "serial", serial, "hostname", record.Hostname, "method", method)
+		return nil, "", errCertificateRevoked
+	}
+	if record.Hostname != cert.Subject.CommonName {
+		return nil, "", status.Error(codes.PermissionDenied, "client certificate does not match its record")
+	}
+	return context.WithValue(ctx, agentHostnameKey{}, record.Hostname), serial, nil
+}
+
+func (s *UpdateServer) authorizeUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, _, err := s.authorize(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (s *UpdateServer) authorizeStream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, serial, err := s.authorize(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	// streams are long lived, so they are ended when their certificate is revoked. Returning ends the underlying
+	// stream, which unblocks a handler waiting to receive.
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	id := s.trackStream(serial, cancel)
+	defer s.untrackStream(serial, id)
+	errC := make(chan error, 1)
+	go func() {
+		errC <- handler(srv, &authorizedStream{ServerStream: ss, ctx: ctx})
+	}()
+	select {
+	case err = <-errC:
+		return err
+	case <-ctx.Done():
+		if errors.Is(context.Cause(ctx), errCertificateRevoked) {
+			return errCertificateRevoked
+		}
+		return <-errC
+	}
+}
+
+func (s *UpdateServer) trackStream(serial string, cancel context.CancelCauseFunc) int64 {
+	s.streamsMu.Lock()
+	defer s.streamsMu.Unlock()
+	s.nextStreamID++
+	if s.streams[serial] == nil {
+		s.streams[serial] = map[int64]context.CancelCauseFunc{}
+	}
+	s.streams[serial][s.nextStreamID] = cancel
+	return s.nextStreamID
+}
+
+func (s *UpdateServer) untrackStream(serial string, id int64) {
+	s.streamsMu.Lock()
+	defer s.streamsMu.Unlock()
+	delete(s.streams[serial], id)
+	if len(s.streams[serial]) == 0 {
+		delete(s.streams, serial)
+	}
+}
+
+// checkHostname makes sure that an agent only speaks for the host its certificate was issued to
+func checkHostname(ctx context.Context, hostname string) error {
+	certHostname, ok := ctx.Value(agentHostnameKey{}).(string)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "client certificate required")
+	}
+	if certHostname != hostname {
+		slog.Warn("agent hostname does not match its certificate", "hostname", hostname, "certificate", certHostname)
+		return status.Errorf(codes.PermissionDenied, "certificate was issued to %s, not %s", certHostname, hostname)
+	}
+	return nil
+}
+
+type authorizedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authorizedStream) Context() context.Context {
+	return s.ctx
+}
+
+func hashJoinToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
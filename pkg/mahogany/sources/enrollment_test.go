@@ -0,0 +1,258 @@
+package sources
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	db "github.com/mpoegel/mahogany/internal/db"
+	schema "github.com/mpoegel/mahogany/pkg/schema"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	credentials "google.golang.org/grpc/credentials"
+	peer "google.golang.org/grpc/peer"
+	status "google.golang.org/grpc/status"
+)
+
+// newTestEnrollServer creates an update server with its own certificate authority
+func newTestEnrollServer(t *testing.T) *UpdateServer {
+	t.Helper()
+	s, _ := newTestUpdateServer(t, "")
+	ca, err := LoadCertificateAuthority(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.ca = ca
+	return s
+}
+
+func newCSR(t *testing.T, hostname string) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{Subject: pkix.Name{CommonName: hostname}}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der})
+}
+
+// enrollAgent enrolls the host and returns the certificate it was issued
+func enrollAgent(t *testing.T, s *UpdateServer, hostname string) *x509.Certificate {
+	t.Helper()
+	token, err := s.CreateJoinToken(t.Context(), hostname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := s.Enroll(t.Context(), &schema.EnrollRequest{Hostname: hostname, Token: token, Csr: newCSR(t, hostname)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := ParseCertificatePEM(resp.Certificate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// withClientCertificate is the context of a call over mutual TLS with the verified client certificate
+func withClientCertificate(ctx context.Context, cert *x509.Certificate) context.Context {
+	return peer.NewContext(ctx, &peer.Peer{AuthInfo: credentials.TLSInfo{
+		State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}},
+	}})
+}
+
+func TestEnroll(t *testing.T) {
+	s := newTestEnrollServer(t)
+	used, err := s.CreateJoinToken(t.Context(), "host")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.Enroll(t.Context(), &schema.EnrollRequest{Hostname: "host", Token: used, Csr: newCSR(t, "host")}); err != nil {
+		t.Fatal(err)
+	}
+	err = s.query.AddJoinToken(t.Context(), db.AddJoinTokenParams{
+		TokenHash: hashJoinToken("expired"),
+		Hostname:  "host",
+		CreatedAt: time.Now().Add(-2 * JoinTokenTTL).Unix(),
+		ExpiresAt: time.Now().Add(-JoinTokenTTL).Unix(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	newToken := func(hostname string) string {
+		token, err := s.CreateJoinToken(t.Context(), hostname)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	tests := []struct {
+		name     string
+		hostname string
+		token    string
+		csr      []byte
+		wantCode codes.Code
+	}{
+		{"valid", "host", newToken("host"), newCSR(t, "host"), codes.OK},
+		{"token without fingerprint", "host", strings.Split(newToken("host"), ".")[0], newCSR(t, "host"), codes.OK},
+		{"used token", "host", used, newCSR(t, "host"), codes.PermissionDenied},
+		{"expired token", "host", "expired", newCSR(t, "host"), codes.PermissionDenied},
+		{"unknown token", "host", "guess", newCSR(t, "host"), codes.PermissionDenied},
+		{"no token", "host", "", newCSR(t, "host"), codes.PermissionDenied},
+		{"token for another host", "other", newToken("host"), newCSR(t, "other"), codes.PermissionDenied},
+		{"invalid csr", "host", newToken("host"), []byte("not a csr"), codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := s.Enroll(t.Context(), &schema.EnrollRequest{Hostname: tt.hostname, Token: tt.token, Csr: tt.csr})
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("got %v, want code %v", err, tt.wantCode)
+			}
+			if err != nil {
+				return
+			}
+			cert, err := ParseCertificatePEM(resp.Certificate)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cert.Subject.CommonName != tt.hostname {
+				t.Errorf("got certificate for %s, want %s", cert.Subject.CommonName, tt.hostname)
+			}
+			if _, err = cert.Verify(x509.VerifyOptions{Roots: s.ca.CertPool(), KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}); err != nil {
+				t.Errorf("certificate is not trusted by the CA: %v", err)
+			}
+		})
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	s := newTestEnrollServer(t)
+	enrolled := enrollAgent(t, s, "host")
+	revoked := enrollAgent(t, s, "host")
+	if err := s.RevokeCertificate(t.Context(), CertificateSerial(revoked)); err != nil {
+		t.Fatal(err)
+	}
+	// issued by the CA but never recorded
+	_, unknown, err := s.ca.SignAgentCSR(newCSR(t, "host"), "host")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// recorded for another host than it names
+	_, mismatched, err := s.ca.SignAgentCSR(newCSR(t, "host"), "host")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.query.AddAgentCertificate(t.Context(), db.AddAgentCertificateParams{
+		Serial:    CertificateSerial(mismatched),
+		Hostname:  "other",
+		IssuedAt:  mismatched.NotBefore.Unix(),
+		ExpiresAt: mismatched.NotAfter.Unix(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		ctx          context.Context
+		method       string
+		wantCode     codes.Code
+		wantHostname string
+	}{
+		{"enrolled", withClientCertificate(t.Context(), enrolled), schema.UpdateService_ReportInstallation_FullMethodName, codes.OK, "host"},
+		{"enroll without certificate", t.Context(), schema.UpdateService_Enroll_FullMethodName, codes.OK, ""},
+		{"no peer", t.Context(), schema.UpdateService_ReportInstallation_FullMethodName, codes.Unauthenticated, ""},
+		{"no tls", peer.NewContext(t.Context(), &peer.Peer{}), schema.UpdateService_ReportInstallation_FullMethodName, codes.Unauthenticated, ""},
+		{"no verified chain", peer.NewContext(t.Context(), &peer.Peer{AuthInfo: credentials.TLSInfo{}}), schema.UpdateService_ReportInstallation_FullMethodName, codes.Unauthenticated, ""},
+		{"revoked", withClientCertificate(t.Context(), revoked), schema.UpdateService_ReportInstallation_FullMethodName, codes.PermissionDenied, ""},
+		{"unknown", withClientCertificate(t.Context(), unknown), schema.UpdateService_ReportInstallation_FullMethodName, codes.PermissionDenied, ""},
+		{"record for another host", withClientCertificate(t.Context(), mismatched), schema.UpdateService_ReportInstallation_FullMethodName, codes.PermissionDenied, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, _, err := s.authorize(tt.ctx, tt.method)
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("got %v, want code %v", err, tt.wantCode)
+			}
+			if err != nil {
+				return
+			}
+			hostname, _ := ctx.Value(agentHostnameKey{}).(string)
+			if hostname != tt.wantHostname {
+				t.Errorf("got hostname %q, want %q", hostname, tt.wantHostname)
+			}
+		})
+	}
+}
+
+func TestCheckHostname(t *testing.T) {
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		hostname string
+		wantCode codes.Code
+	}{
+		{"same host", context.WithValue(t.Context(), agentHostnameKey{}, "host"), "host", codes.OK},
+		{"another host", context.WithValue(t.Context(), agentHostnameKey{}, "host"), "other", codes.PermissionDenied},
+		{"empty hostname", context.WithValue(t.Context(), agentHostnameKey{}, "host"), "", codes.PermissionDenied},
+		{"not authorized", t.Context(), "host", codes.Unauthenticated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := status.Code(checkHostname(tt.ctx, tt.hostname)); code != tt.wantCode {
+				t.Errorf("got code %v, want %v", code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestRevokeCertificateEndsStreams(t *testing.T) {
+	s := newTestEnrollServer(t)
+	cert := enrollAgent(t, s, "host")
+	serial := CertificateSerial(cert)
+
+	started := make(chan struct{})
+	errC := make(chan error, 1)
+	go func() {
+		stream := &fakeServicesStream{ctx: withClientCertificate(t.Context(), cert)}
+		info := &grpc.StreamServerInfo{FullMethod: schema.UpdateService_ServicesStream_FullMethodName}
+		errC <- s.authorizeStream(nil, stream, info, func(srv any, ss grpc.ServerStream) error {
+			close(started)
+			<-ss.Context().Done()
+			return nil
+		})
+	}()
+	<-started
+
+	if err := s.RevokeCertificate(t.Context(), serial); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-errC:
+		if !errors.Is(err, errCertificateRevoked) {
+			t.Errorf("got error %v, want %v", err, errCertificateRevoked)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("stream was not ended by the revocation")
+	}
+
+	if err := s.RevokeCertificate(t.Context(), serial); !errors.Is(err, ErrCertificateNotFound) {
+		t.Errorf("got error %v revoking twice, want %v", err, ErrCertificateNotFound)
+	}
+	if err := s.RevokeCertificate(t.Context(), "unknown"); !errors.Is(err, ErrCertificateNotFound) {
+		t.Errorf("got error %v revoking an unknown certificate, want %v", err, ErrCertificateNotFound)
+	}
+}
@@ -1,9 +1,117 @@
 package sources
 
 import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
 	github "github.com/google/go-github/v67/github"
 )
 
+const (
+	GithubEventHeader     = "X-GitHub-Event"
+	GithubDeliveryHeader  = "X-GitHub-Delivery"
+	GithubSignatureHeader = "X-Hub-Signature-256"
+)
+
+// suffixes of release assets that carry integrity data rather than installable artifacts. Signatures are minisign
+// signature files, or a base64 ed25519 signature of the whole asset.
+const (
+	githubChecksumsSuffix = "checksums.txt"
+	githubMinisignSuffix  = ".minisig"
+	githubSignatureSuffix = ".sig"
+)
+
+// largest checksum or signature file that will be downloaded
+const maxGithubIntegrityFile = 1 << 20
+
+var (
+	ErrMissingSignature = errors.New("missing webhook signature")
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+)
+
 type GithubReleaseEvent struct {
 	github.ReleaseEvent
 }
+
+// VerifyGithubSignature checks the X-Hub-Signature-256 header against the HMAC-SHA256 of the payload
+// Docs: https://docs.github.com/en/webhooks/using-webhooks/validating-webhook-deliveries
+func VerifyGithubSignature(secret []byte, signature string, payload []byte) error {
+	if len(signature) == 0 {
+		return ErrMissingSignature
+	}
+	rawSig, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok {
+		return ErrInvalidSignature
+	}
+	sig, err := hex.DecodeString(rawSig)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// fetchGithubChecksums downloads a sha256sum-style checksums file and maps each file name to its digest
+func fetchGithubChecksums(ctx context.Context, url string) (map[string]string, error) {
+	body, err := fetchGithubAsset(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	checksums := map[string]string{}
+	scanner := bufio.NewScanner(strings.NewReader(string(body)))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		digest, err := hex.DecodeString(fields[0])
+		if err != nil || len(digest) != sha256.Size {
+			return nil, fmt.Errorf("invalid checksum for %s", fields[1])
+		}
+		// sha256sum marks binary mode with a leading asterisk
+		checksums[strings.TrimPrefix(fields[1], "*")] = strings.ToLower(fields[0])
+	}
+	return checksums, scanner.Err()
+}
+
+// githubSignedAsset returns the name of the asset that a signature file signs, if it is one
+func githubSignedAsset(name string) (string, bool) {
+	if signed, ok := strings.CutSuffix(name, githubMinisignSuffix); ok {
+		return signed, true
+	}
+	return strings.CutSuffix(name, githubSignatureSuffix)
+}
+
+// isGithubIntegrityFile reports whether the release asset is a checksums or signature file rather than an artifact
+func isGithubIntegrityFile(name string) bool {
+	_, isSignature := githubSignedAsset(name)
+	return isSignature || strings.HasSuffix(name, githubChecksumsSuffix)
+}
+
+func fetchGithubAsset(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxGithubIntegrityFile))
+}
@@ -0,0 +1,103 @@
+package sources
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	github "github.com/google/go-github/v67/github"
+	dbtest "github.com/mpoegel/mahogany/internal/db/dbtest"
+)
+
+// roundTripFunc serves requests without a network
+type roundTripFunc func(*http.Request) *http.Response
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req), nil
+}
+
+// serveGithubAssets answers downloads of release assets with the given bodies for the rest of the test
+func serveGithubAssets(t *testing.T, bodies map[string]string) {
+	t.Helper()
+	transport := http.DefaultClient.Transport
+	t.Cleanup(func() { http.DefaultClient.Transport = transport })
+	http.DefaultClient.Transport = roundTripFunc(func(req *http.Request) *http.Response {
+		body, ok := bodies[req.URL.String()]
+		if !ok {
+			return &http.Response{StatusCode: http.StatusNotFound, Status: "404 Not Found", Body: io.NopCloser(strings.NewReader(""))}
+		}
+		return &http.Response{StatusCode: http.StatusOK, Status: "200 OK", Body: io.NopCloser(strings.NewReader(body))}
+	})
+}
+
+func TestPropagateGithubReleaseAssets(t *testing.T) {
+	const download = "https://github.com/mpoegel/tool/releases/download/v1.2.0/"
+	minisig := "untrusted comment: signature from minisign secret key\nRUQ=\ntrusted comment: file:tool_linux.deb\nAAA=\n"
+	serveGithubAssets(t, map[string]string{
+		download + "tool_1.2.0_checksums.txt": "0a1b2c3d0a1b2c3d0a1b2c3d0a1b2c3d0a1b2c3d0a1b2c3d0a1b2c3d0a1b2c3d  tool_linux.deb\n",
+		download + "tool_linux.deb.minisig":   minisig,
+		download + "tool_darwin.tar.gz.sig":   "c2lnbmF0dXJl\n",
+		download + "tool_linux.deb":           "not downloaded by the server",
+		download + "tool_darwin.tar.gz":       "not downloaded by the server",
+		download + "tool_darwin.tar.gz.sbom":  "not downloaded by the server",
+	})
+
+	// the asset regex is loose enough to match the checksums and signatures too
+	topologyFile := filepath.Join(t.TempDir(), "topology.toml")
+	topology := `
+[[baseline]]
+id = "tool"
+install_command = "dpkg -i {}"
+github_package = { name = "mpoegel/tool", asset_regex = "^tool_" }
+`
+	if err := os.WriteFile(topologyFile, []byte(topology), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dbConn := dbtest.New(t)
+	s, err := NewUpdateServer(topologyFile, 0, time.Second, dbConn, nil, nil, NewNotifier(dbConn), NewEventBus())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.releaseBroker.Start()
+	defer s.releaseBroker.Stop()
+	releases := s.releaseBroker.Subscribe()
+
+	event := &GithubReleaseEvent{}
+	event.Repo = &github.Repository{Name: github.String("tool"), FullName: github.String("mpoegel/tool")}
+	event.Release = &github.RepositoryRelease{Name: github.String("v1.2.0")}
+	for _, name := range []string{
+		"tool_1.2.0_checksums.txt", "tool_linux.deb", "tool_linux.deb.minisig", "tool_darwin.tar.gz",
+		"tool_darwin.tar.gz.sig", "tool_darwin.tar.gz.sbom",
+	} {
+		event.Release.Assets = append(event.Release.Assets, &github.ReleaseAsset{
+			Name:               github.String(name),
+			BrowserDownloadURL: github.String(download + name),
+		})
+	}
+	if err = s.PropagateGithubRelease(t.Context(), event); err != nil {
+		t.Fatal(err)
+	}
+
+	notice := <-releases
+	got := map[string]string{}
+	for _, asset := range notice.release.Assets {
+		got[asset.Name] = asset.Sha256 + "/" + string(asset.Signature)
+	}
+	want := map[string]string{
+		"tool_linux.deb":          "0a1b2c3d0a1b2c3d0a1b2c3d0a1b2c3d0a1b2c3d0a1b2c3d0a1b2c3d0a1b2c3d/" + minisig,
+		"tool_darwin.tar.gz":      "/c2lnbmF0dXJl\n",
+		"tool_darwin.tar.gz.sbom": "/",
+	}
+	if len(got) != len(want) {
+		t.Errorf("got assets %v, want %v", got, want)
+	}
+	for name, wantAsset := range want {
+		if got[name] != wantAsset {
+			t.Errorf("got asset %s %q, want %q", name, got[name], wantAsset)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package sources
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	events "github.com/docker/docker/api/types/events"
+	filters "github.com/docker/docker/api/types/filters"
+	db "github.com/mpoegel/mahogany/internal/db"
+)
+
+// the kinds of incident recorded for containers
+const (
+	IncidentDied      = "died"
+	IncidentOOM       = "oom"
+	IncidentUnhealthy = "unhealthy"
+	IncidentCrashLoop = "crash-loop"
+)
+
+const (
+	// a container that dies this many times within crashLoopWindow is crash looping
+	crashLoopDeaths = 3
+	crashLoopWindow = 10 * time.Minute
+	// a container that dies this soon after it was stopped or killed on purpose, or ran out of memory, has not
+	// crashed again
+	deathGrace = 1 * time.Minute
+	// time between attempts to follow the docker events again after the stream ended
+	eventsRetryDelay = 5 * time.Second
+)
+
+// IncidentMonitor follows the docker events for containers that die, run out of memory, turn unhealthy or crash
+// loop, and records each as an incident. Later occurrences are added to the incident until it is resolved.
+type IncidentMonitor struct {
+	docker   DockerI
+	query    *db.Queries
+	notifier *Notifier
+	bus      *EventBus
+
+	// only used by the goroutine running the monitor
+	deaths    map[string][]time.Time
+	stopped   map[string]time.Time
+	oomKilled map[string]time.Time
+}
+
+func NewIncidentMonitor(docker DockerI, dbConn *sql.DB, notifier *Notifier, bus *EventBus) *IncidentMonitor {
+	return &IncidentMonitor{
+		docker:    docker,
+		query:     db.New(dbConn),
+		notifier:  notifier,
+		bus:       bus,
+		deaths:    map[string][]time.Time{},
+		stopped:   map[string]time.Time{},
+		oomKilled: map[string]time.Time{},
+	}
+}
+
+// Run follows the docker events until the context is done, and follows them again if docker goes away
+func (m *IncidentMonitor) Run(ctx context.Context) {
+	for ctx.Err() == nil {
+		err := m.follow(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		slog.Warn("docker events stream ended", "err", err)
+		select {
+		case <-ctx.Done():
+		case <-time.After(eventsRetryDelay):
+		}
+	}
+}
+
+func (m *IncidentMonitor) follow(ctx context.Context) error {
+	opts := events.ListOptions{
+		Filters: filters.NewArgs(
+			filters.Arg("type", string(events.ContainerEventType)),
+			filters.Arg("event", string(events.ActionDie)),
+			filters.Arg("event", string(events.ActionOOM)),
+			filters.Arg("event", string(events.ActionKill)),
+			filters.Arg("event", string(events.ActionStop)),
+			filters.Arg("event", string(events.ActionHealthStatus)),
+		),
+	}
+	msgs, errs := m.docker.Events(ctx, opts)
+	for {
+		select {
+		case msg := <-msgs:
+			m.handle(ctx, msg)
+		case err := <-errs:
+			return err
+		}
+	}
+}
+
+func (m *IncidentMonitor) handle(ctx context.Context, msg events.Message) {
+	at := time.Now()
+	if msg.TimeNano > 0 {
+		at = time.Unix(0, msg.TimeNano)
+	}
+	containerID := msg.Actor.ID
+	switch msg.Action {
+	case events.ActionKill, events.ActionStop:
+		m.stopped[containerID] = at
+	case events.ActionOOM:
+		m.oomKilled[containerID] = at
+		m.record(ctx, IncidentOOM, msg, "ran out of memory", at)
+	case events.ActionDie:
+		m.died(ctx, msg, at)
+	case events.ActionHealthStatusUnhealthy:
+		m.record(ctx, IncidentUnhealthy, msg, "healthcheck failed", at)
+	case events.ActionHealthStatusHealthy:
+		m.resolve(ctx, msg.Actor.Attributes["name"], IncidentUnhealthy, at)
+	}
+}
+
+func (m *IncidentMonitor) died(ctx context.Context, msg events.Message, at time.Time) {
+	containerID := msg.Actor.ID
+	stoppedAt, stopped := m.stopped[containerID]
+	oomAt, oomKilled := m.oomKilled[containerID]
+	delete(m.stopped, containerID)
+	delete(m.oomKilled, containerID)
+	if stopped && at.Sub(stoppedAt) < deathGrace {
+		return
+	}
+
+	deaths := []time.Time{}
+	for _, died := range m.deaths[containerID] {
+		if at.Sub(died) < crashLoopWindow {
+			deaths = append(deaths, died)
+		}
+	}
+	deaths = append(deaths, at)
+	m.deaths[containerID] = deaths
+	// deaths are only kept while they can still add up to a crash loop, containers come and go
+	for other, died := range m.deaths {
+		if at.Sub(died[len(died)-1]) >= crashLoopWindow {
+			delete(m.deaths, other)
+		}
+	}
+
+	exitCode := msg.Actor.Attributes["exitCode"]
+	switch {
+	case len(deaths) >= crashLoopDeaths:
+		message := fmt.Sprintf("died %d times in %s, last exit code %s", len(deaths), crashLoopWindow, exitCode)
+		m.record(ctx, IncidentCrashLoop, msg, message, at)
+	case oomKilled && at.Sub(oomAt) < deathGrace:
+	case exitCode != "0":
+		m.record(ctx, IncidentDied, msg, fmt.Sprintf("exited with code %s", exitCode), at)
+	}
+}
+
+func (m *IncidentMonitor) record(ctx context.Context, kind string, msg events.Message, message string, at time.Time) {
+	incident, err := m.query.RecordIncident(ctx, db.RecordIncidentParams{
+		Kind:          kind,
+		ContainerID:   msg.Actor.ID,
+		ContainerName: msg.Actor.Attributes["name"],
+		Image:         msg.Actor.Attributes["image"],
+		Message:       message,
+		SeenAt:        at.Unix(),
+	})
+	if err != nil {
+		slog.Error("cannot record incident", "kind", kind, "container", msg.Actor.Attributes["name"], "err", err)
+		return
+	}
+	slog.Warn("container incident", "kind", kind, "container", incident.ContainerName, "message", message,
+		"occurrences", incident.Occurrences)
+	m.bus.Publish(BusIncident, incident.ContainerName, kind)
+	// later occurrences are added to the open incident, which has been notified already
+	if incident.Occurrences == 1 {
+		m.notifier.Notify(ctx, Notification{
+			Event:   EventIncident,
+			Title:   fmt.Sprintf("%s: %s", incident.ContainerName, incident.Kind),
+			Message: fmt.Sprintf("Container %s (%s) %s.", incident.ContainerName, incident.Image, message),
+			Time:    at,
+			Key:     fmt.Sprintf("incident/%d", incident.ID),
+		})
+	}
+}
+
+func (m *IncidentMonitor) resolve(ctx context.Context, containerName, kind string, at time.Time) {
+	resolved, err := m.query.ResolveContainerIncidents(ctx, db.ResolveContainerIncidentsParams{
+		ResolvedAt:    sql.NullInt64{Int64: at.Unix(), Valid: true},
+		ResolvedBy:    sql.NullString{String: SystemActor, Valid: true},
+		ContainerName: containerName,
+		Kind:          kind,
+	})
+	if err != nil {
+		slog.Error("cannot resolve incident", "kind", kind, "container", containerName, "err", err)
+	} else if resolved > 0 {
+		slog.Info("container incident resolved", "kind", kind, "container", containerName)
+		m.bus.Publish(BusIncident, containerName, "resolve")
+	}
+}
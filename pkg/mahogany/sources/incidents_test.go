@@ -0,0 +1,221 @@
+package sources
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"slices"
+	"testing"
+	"time"
+
+	events "github.com/docker/docker/api/types/events"
+	db "github.com/mpoegel/mahogany/internal/db"
+	dbtest "github.com/mpoegel/mahogany/internal/db/dbtest"
+)
+
+// fakeEventsDocker streams the events that the test sends
+type fakeEventsDocker struct {
+	DockerI
+	msgs chan events.Message
+	errs chan error
+}
+
+func (d *fakeEventsDocker) Events(ctx context.Context, options events.ListOptions) (<-chan events.Message, <-chan error) {
+	return d.msgs, d.errs
+}
+
+var incidentStart = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+// containerEvent is an event of the container that happened the given time after incidentStart
+func containerEvent(name string, action events.Action, after time.Duration, exitCode string) events.Message {
+	msg := events.Message{
+		Type:   events.ContainerEventType,
+		Action: action,
+		Actor: events.Actor{
+			ID:         name + "-id",
+			Attributes: map[string]string{"name": name, "image": name + ":latest"},
+		},
+		TimeNano: incidentStart.Add(after).UnixNano(),
+	}
+	if len(exitCode) > 0 {
+		msg.Actor.Attributes["exitCode"] = exitCode
+	}
+	return msg
+}
+
+// runIncidentMonitor runs the monitor over the events and returns every incident it recorded as
+// name/kind/occurrences, followed by /resolved once it is resolved
+func runIncidentMonitor(t *testing.T, msgs []events.Message) []string {
+	t.Helper()
+	dbConn := dbtest.New(t)
+	docker := &fakeEventsDocker{msgs: make(chan events.Message), errs: make(chan error)}
+	m := NewIncidentMonitor(docker, dbConn, NewNotifier(dbConn), NewEventBus())
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		m.Run(ctx)
+	}()
+	// the stream is unbuffered, so each event is handled once the one after it is taken
+	for _, msg := range append(msgs, containerEvent("sentinel", events.ActionStart, 0, "")) {
+		docker.msgs <- msg
+	}
+	cancel()
+	docker.errs <- io.EOF
+	<-done
+
+	incidents, err := db.New(dbConn).ListIncidents(t.Context(), sql.NullInt64{Int64: 0, Valid: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, incident := range incidents {
+		summary := fmt.Sprintf("%s/%s/%d", incident.ContainerName, incident.Kind, incident.Occurrences)
+		if incident.ResolvedAt.Valid {
+			summary += "/resolved"
+		}
+		got = append(got, summary)
+	}
+	slices.Sort(got)
+	return got
+}
+
+func TestIncidentMonitor(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []events.Message
+		want   []string
+	}{
+		{
+			name:   "crash",
+			events: []events.Message{containerEvent("web", events.ActionDie, 0, "1")},
+			want:   []string{"web/died/1"},
+		},
+		{
+			name:   "clean exit",
+			events: []events.Message{containerEvent("web", events.ActionDie, 0, "0")},
+			want:   []string{},
+		},
+		{
+			name: "stopped on purpose",
+			events: []events.Message{
+				containerEvent("web", events.ActionStop, 0, ""),
+				containerEvent("web", events.ActionKill, time.Second, ""),
+				containerEvent("web", events.ActionDie, 2*time.Second, "137"),
+			},
+			want: []string{},
+		},
+		{
+			name: "crashed long after it was stopped",
+			events: []events.Message{
+				containerEvent("web", events.ActionStop, 0, ""),
+				containerEvent("web", events.ActionDie, 2*deathGrace, "1"),
+			},
+			want: []string{"web/died/1"},
+		},
+		{
+			name: "out of memory",
+			events: []events.Message{
+				containerEvent("web", events.ActionOOM, 0, ""),
+				containerEvent("web", events.ActionDie, time.Second, "137"),
+			},
+			want: []string{"web/oom/1"},
+		},
+		{
+			name: "crashed long after running out of memory",
+			events: []events.Message{
+				containerEvent("web", events.ActionOOM, 0, ""),
+				containerEvent("web", events.ActionDie, 2*deathGrace, "1"),
+			},
+			want: []string{"web/died/1", "web/oom/1"},
+		},
+		{
+			name: "crash loop",
+			events: []events.Message{
+				containerEvent("web", events.ActionDie, 0, "1"),
+				containerEvent("web", events.ActionDie, 2*time.Minute, "1"),
+				containerEvent("web", events.ActionDie, 4*time.Minute, "2"),
+				containerEvent("web", events.ActionDie, 6*time.Minute, "2"),
+			},
+			want: []string{"web/crash-loop/2", "web/died/2"},
+		},
+		{
+			name: "deaths outside the crash loop window",
+			events: []events.Message{
+				containerEvent("web", events.ActionDie, 0, "1"),
+				containerEvent("web", events.ActionDie, crashLoopWindow*6/10, "1"),
+				containerEvent("web", events.ActionDie, crashLoopWindow*12/10, "1"),
+			},
+			want: []string{"web/died/3"},
+		},
+		{
+			name: "out of memory counts towards a crash loop",
+			events: []events.Message{
+				containerEvent("web", events.ActionOOM, 0, ""),
+				containerEvent("web", events.ActionDie, time.Second, "137"),
+				containerEvent("web", events.ActionOOM, 2*time.Minute, ""),
+				containerEvent("web", events.ActionDie, 2*time.Minute+time.Second, "137"),
+				containerEvent("web", events.ActionOOM, 4*time.Minute, ""),
+				containerEvent("web", events.ActionDie, 4*time.Minute+time.Second, "137"),
+			},
+			want: []string{"web/crash-loop/1", "web/oom/3"},
+		},
+		{
+			name: "stops do not count towards a crash loop",
+			events: []events.Message{
+				containerEvent("web", events.ActionDie, 0, "1"),
+				containerEvent("web", events.ActionKill, time.Minute, ""),
+				containerEvent("web", events.ActionDie, time.Minute+time.Second, "137"),
+				containerEvent("web", events.ActionDie, 2*time.Minute, "1"),
+			},
+			want: []string{"web/died/2"},
+		},
+		{
+			name: "deaths are counted per container",
+			events: []events.Message{
+				containerEvent("web", events.ActionDie, 0, "1"),
+				containerEvent("db", events.ActionDie, time.Minute, "1"),
+				containerEvent("web", events.ActionDie, 2*time.Minute, "1"),
+				containerEvent("db", events.ActionDie, 3*time.Minute, "1"),
+			},
+			want: []string{"db/died/2", "web/died/2"},
+		},
+		{
+			name: "unhealthy",
+			events: []events.Message{
+				containerEvent("web", events.ActionHealthStatusUnhealthy, 0, ""),
+				containerEvent("web", events.ActionHealthStatusUnhealthy, 30*time.Second, ""),
+			},
+			want: []string{"web/unhealthy/2"},
+		},
+		{
+			name: "healthy again",
+			events: []events.Message{
+				containerEvent("web", events.ActionHealthStatusUnhealthy, 0, ""),
+				containerEvent("db", events.ActionHealthStatusUnhealthy, 0, ""),
+				containerEvent("web", events.ActionDie, time.Second, "1"),
+				containerEvent("web", events.ActionHealthStatusHealthy, time.Minute, ""),
+			},
+			// only the unhealthy incident of the container resolves itself
+			want: []string{"db/unhealthy/1", "web/died/1", "web/unhealthy/1/resolved"},
+		},
+		{
+			name: "unhealthy after it resolved",
+			events: []events.Message{
+				containerEvent("web", events.ActionHealthStatusUnhealthy, 0, ""),
+				containerEvent("web", events.ActionHealthStatusHealthy, time.Minute, ""),
+				containerEvent("web", events.ActionHealthStatusUnhealthy, 2*time.Minute, ""),
+			},
+			want: []string{"web/unhealthy/1", "web/unhealthy/1/resolved"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := runIncidentMonitor(t, tt.events); !slices.Equal(got, tt.want) {
+				t.Errorf("got incidents %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
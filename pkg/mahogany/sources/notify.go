@@ -0,0 +1,488 @@
+package sources
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	db "github.com/mpoegel/mahogany/internal/db"
+)
+
+// the events that notifications are sent for
+const (
+	EventIncident        = "incident"
+	EventAgentDisconnect = "agent-disconnect"
+	EventRelease         = "release"
+	EventTest            = "test"
+)
+
+// the channels that notifications can be sent to
+const (
+	ChannelWebhook = "webhook"
+	ChannelNtfy    = "ntfy"
+	ChannelGotify  = "gotify"
+	ChannelEmail   = "email"
+	ChannelSlack   = "slack"
+)
+
+// NotificationChannels lists every channel in the order the settings page shows them
+var NotificationChannels = []string{ChannelWebhook, ChannelNtfy, ChannelGotify, ChannelEmail, ChannelSlack}
+
+// NotificationEvent is an event that can be routed to channels by listing their names, separated by commas, in the
+// setting of the event
+type NotificationEvent struct {
+	Name        string
+	Setting     string
+	Description string
+}
+
+var NotificationEvents = []NotificationEvent{
+	{EventIncident, "NotifyIncident", "A container crashed, ran out of memory, turned unhealthy or is crash looping"},
+	{EventAgentDisconnect, "NotifyAgentDisconnect", "An agent lost its connection to the update server"},
+	{EventRelease, "NotifyRelease", "A release was sent to the agents"},
+}
+
+const (
+	// how long to wait on a channel to take a notification
+	notifyTimeout = 10 * time.Second
+	// a notification with the same key as one sent this recently is dropped
+	notifyDedupWindow = 15 * time.Minute
+	// each channel is sent at most notifyRateLimit notifications per notifyRateWindow, the rest are dropped
+	notifyRateLimit  = 10
+	notifyRateWindow = 1 * time.Minute
+)
+
+var ErrChannelNotConfigured = errors.New("notification channel is not configured")
+
+// Notification is something that happened which someone should be told about
+type Notification struct {
+	Event   string    `json:"event"`
+	Title   string    `json:"title"`
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+	// notifications with the same key are only sent once per dedup window, the title is used if it is empty
+	Key string `json:"-"`
+}
+
+// NotificationChannel delivers notifications to some service that tells people about them
+type NotificationChannel interface {
+	Send(ctx context.Context, note Notification) error
+}
+
+// NewNotificationChannels creates every channel that is configured in the settings. It fails if a channel or route
+// is configured with a value that cannot work, so that such settings are never saved.
+func NewNotificationChannels(settings map[string]string) (map[string]NotificationChannel, error) {
+	client := &http.Client{Timeout: notifyTimeout}
+	channels := map[string]NotificationChannel{}
+	if addr := settings["NotifyWebhookURL"]; addr != "" {
+		if err := checkNotifyURL(addr); err != nil {
+			return nil, err
+		}
+		channels[ChannelWebhook] = &webhookChannel{url: addr, client: client}
+	}
+	if addr := settings["NotifyNtfyURL"]; addr != "" {
+		if err := checkNotifyURL(addr); err != nil {
+			return nil, err
+		}
+		channels[ChannelNtfy] = &ntfyChannel{url: addr, token: settings["NotifyNtfyToken"], client: client}
+	}
+	if addr := settings["NotifyGotifyURL"]; addr != "" {
+		if err := checkNotifyURL(addr); err != nil {
+			return nil, err
+		}
+		channels[ChannelGotify] = &gotifyChannel{
+			url:    strings.TrimSuffix(addr, "/") + "/message",
+			token:  settings["NotifyGotifyToken"],
+			client: client,
+		}
+	}
+	if addr := settings["NotifySlackURL"]; addr != "" {
+		if err := checkNotifyURL(addr); err != nil {
+			return nil, err
+		}
+		channels[ChannelSlack] = &slackChannel{url: addr, client: client}
+	}
+	// email takes several settings, which are saved one at a time, so it is only checked once all are set
+	addr, from, to := settings["NotifySMTPAddr"], settings["NotifySMTPFrom"], settings["NotifySMTPTo"]
+	if addr != "" && from != "" && to != "" {
+		channel, err := newEmailChannel(addr, settings["NotifySMTPUsername"], settings["NotifySMTPPassword"], from, to)
+		if err != nil {
+			return nil, err
+		}
+		channels[ChannelEmail] = channel
+	}
+
+	for _, event := range NotificationEvents {
+		for _, name := range notificationRoute(settings, event.Setting) {
+			if !isNotificationChannel(name) {
+				return nil, fmt.Errorf("unknown notification channel %q for %s", name, event.Name)
+			}
+		}
+	}
+	return channels, nil
+}
+
+func checkNotifyURL(addr string) error {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("notification url %s is not an http(s) url", addr)
+	}
+	return nil
+}
+
+func isNotificationChannel(name string) bool {
+	for _, channel := range NotificationChannels {
+		if channel == name {
+			return true
+		}
+	}
+	return false
+}
+
+// notificationRoute returns the names of the channels in the setting of an event
+func notificationRoute(settings map[string]string, setting string) []string {
+	names := []string{}
+	for _, name := range strings.Split(settings[setting], ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Notifier sends notifications to the channels that their event is routed to in the settings. Repeats of a
+// notification are dropped for a while, as is anything past the rate limit of each channel, so that a flapping
+// container or agent does not flood anyone.
+type Notifier struct {
+	query *db.Queries
+
+	mu sync.Mutex
+	// map of notification key to when it was last sent
+	sent map[string]time.Time
+	// map of channel name to when it was sent each notification in the rate window
+	recent map[string][]time.Time
+}
+
+func NewNotifier(dbConn *sql.DB) *Notifier {
+	return &Notifier{
+		query:  db.New(dbConn),
+		sent:   map[string]time.Time{},
+		recent: map[string][]time.Time{},
+	}
+}
+
+// Notify sends the notification in the background, failures are only logged
+func (n *Notifier) Notify(ctx context.Context, note Notification) {
+	if note.Time.IsZero() {
+		note.Time = time.Now()
+	}
+	if note.Key == "" {
+		note.Key = note.Title
+	}
+	go n.notify(context.WithoutCancel(ctx), note)
+}
+
+func (n *Notifier) notify(ctx context.Context, note Notification) {
+	settings, err := n.settings(ctx)
+	if err != nil {
+		slog.Error("cannot load notification settings", "err", err)
+		return
+	}
+	var route []string
+	for _, event := range NotificationEvents {
+		if event.Name == note.Event {
+			route = notificationRoute(settings, event.Setting)
+		}
+	}
+	if len(route) == 0 || !n.firstSent(note) {
+		return
+	}
+	channels, err := NewNotificationChannels(settings)
+	if err != nil {
+		slog.Error("cannot create notification channels", "err", err)
+		return
+	}
+	for _, name := range route {
+		channel, ok := channels[name]
+		if !ok {
+			slog.Warn("notification routed to a channel that is not configured", "event", note.Event, "channel", name)
+			continue
+		}
+		if !n.allow(name, note.Time) {
+			slog.Warn("notification rate limited", "event", note.Event, "channel", name, "title", note.Title)
+			continue
+		}
+		if err := send(ctx, channel, note); err != nil {
+			slog.Error("failed to send notification", "event", note.Event, "channel", name, "err", err)
+		} else {
+			slog.Info("sent notification", "event", note.Event, "channel", name, "title", note.Title)
+		}
+	}
+}
+
+// Test sends a notification to the channel right away, whatever the routes, and waits for it to be taken
+func (n *Notifier) Test(ctx context.Context, channelName string) error {
+	settings, err := n.settings(ctx)
+	if err != nil {
+		return err
+	}
+	channels, err := NewNotificationChannels(settings)
+	if err != nil {
+		return err
+	}
+	channel, ok := channels[channelName]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrChannelNotConfigured, channelName)
+	}
+	return send(ctx, channel, Notification{
+		Event:   EventTest,
+		Title:   "Test notification from mahogany",
+		Message: fmt.Sprintf("The %s channel is working.", channelName),
+		Time:    time.Now(),
+	})
+}
+
+func (n *Notifier) settings(ctx context.Context) (map[string]string, error) {
+	rows, err := n.query.ListSettings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	settings := make(map[string]string, len(rows))
+	for _, row := range rows {
+		settings[row.Name] = row.Value
+	}
+	return settings, nil
+}
+
+// firstSent records that the notification is sent, unless one with the same key was sent within the dedup window
+func (n *Notifier) firstSent(note Notification) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for key, at := range n.sent {
+		if note.Time.Sub(at) >= notifyDedupWindow {
+			delete(n.sent, key)
+		}
+	}
+	if _, ok := n.sent[note.Key]; ok {
+		return false
+	}
+	n.sent[note.Key] = note.Time
+	return true
+}
+
+// allow records a notification sent to the channel, unless the channel is at its rate limit
+func (n *Notifier) allow(channelName string, at time.Time) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	recent := []time.Time{}
+	for _, sentAt := range n.recent[channelName] {
+		if at.Sub(sentAt) < notifyRateWindow {
+			recent = append(recent, sentAt)
+		}
+	}
+	allowed := len(recent) < notifyRateLimit
+	if allowed {
+		recent = append(recent, at)
+	}
+	n.recent[channelName] = recent
+	return allowed
+}
+
+func send(ctx context.Context, channel NotificationChannel, note Notification) error {
+	ctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+	defer cancel()
+	return channel.Send(ctx, note)
+}
+
+// postNotification posts the body to the url and fails unless the response is a success
+func postNotification(ctx context.Context, client *http.Client, addr, contentType string, body []byte, header http.Header) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, addr, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", contentType)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s responded %s: %s", req.URL.Host, resp.Status, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+// webhookChannel posts the notification as JSON
+type webhookChannel struct {
+	url    string
+	client *http.Client
+}
+
+func (c *webhookChannel) Send(ctx context.Context, note Notification) error {
+	body, err := json.Marshal(note)
+	if err != nil {
+		return err
+	}
+	return postNotification(ctx, c.client, c.url, "application/json", body, nil)
+}
+
+// ntfyChannel publishes the notification to the topic at the url
+type ntfyChannel struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+func (c *ntfyChannel) Send(ctx context.Context, note Notification) error {
+	header := http.Header{}
+	header.Set("Title", mime.QEncoding.Encode("utf-8", note.Title))
+	header.Set("Tags", note.Event)
+	if note.Event == EventIncident || note.Event == EventAgentDisconnect {
+		header.Set("Priority", "high")
+	}
+	if c.token != "" {
+		header.Set("Authorization", "Bearer "+c.token)
+	}
+	return postNotification(ctx, c.client, c.url, "text/plain; charset=utf-8", []byte(note.Message), header)
+}
+
+// gotifyChannel pushes the notification as the application that the token belongs to
+type gotifyChannel struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+func (c *gotifyChannel) Send(ctx context.Context, note Notification) error {
+	priority := 5
+	if note.Event == EventIncident || note.Event == EventAgentDisconnect {
+		priority = 8
+	}
+	body, err := json.Marshal(map[string]any{"title": note.Title, "message": note.Message, "priority": priority})
+	if err != nil {
+		return err
+	}
+	header := http.Header{}
+	header.Set("X-Gotify-Key", c.token)
+	return postNotification(ctx, c.client, c.url, "application/json", body, header)
+}
+
+// slackChannel posts the notification to a Slack incoming webhook, or one of the many services that accept the same
+// payload
+type slackChannel struct {
+	url    string
+	client *http.Client
+}
+
+func (c *slackChannel) Send(ctx context.Context, note Notification) error {
+	body, err := json.Marshal(map[string]string{"text": fmt.Sprintf("*%s*\n%s", note.Title, note.Message)})
+	if err != nil {
+		return err
+	}
+	return postNotification(ctx, c.client, c.url, "application/json", body, nil)
+}
+
+// emailChannel mails the notification through an SMTP server, using TLS whenever the server offers it
+type emailChannel struct {
+	addr     string
+	host     string
+	username string
+	password string
+	from     *mail.Address
+	to       []*mail.Address
+}
+
+func newEmailChannel(addr, username, password, from, to string) (*emailChannel, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("smtp address %s: %w", addr, err)
+	}
+	c := &emailChannel{addr: addr, host: host, username: username, password: password}
+	if c.from, err = mail.ParseAddress(from); err != nil {
+		return nil, fmt.Errorf("smtp from address: %w", err)
+	}
+	if c.to, err = mail.ParseAddressList(to); err != nil {
+		return nil, fmt.Errorf("smtp to addresses: %w", err)
+	}
+	return c, nil
+}
+
+func (c *emailChannel) Send(ctx context.Context, note Notification) error {
+	dialer := net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	client, err := smtp.NewClient(conn, c.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err = client.StartTLS(&tls.Config{ServerName: c.host}); err != nil {
+			return err
+		}
+	}
+	if c.username != "" {
+		if err = client.Auth(smtp.PlainAuth("", c.username, c.password, c.host)); err != nil {
+			return err
+		}
+	}
+	if err = client.Mail(c.from.Address); err != nil {
+		return err
+	}
+	recipients := make([]string, len(c.to))
+	for i, to := range c.to {
+		recipients[i] = to.String()
+		if err = client.Rcpt(to.Address); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	msg := strings.Join([]string{
+		"From: " + c.from.String(),
+		"To: " + strings.Join(recipients, ", "),
+		"Subject: " + mime.QEncoding.Encode("utf-8", note.Title),
+		"Date: " + note.Time.Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=utf-8",
+		"",
+		note.Message,
+	}, "\r\n")
+	if _, err = w.Write([]byte(msg)); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
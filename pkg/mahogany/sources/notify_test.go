@@ -0,0 +1,374 @@
+package sources
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	db "github.com/mpoegel/mahogany/internal/db"
+	dbtest "github.com/mpoegel/mahogany/internal/db/dbtest"
+)
+
+// capturedRequest is a request taken by a notification test server
+type capturedRequest struct {
+	header http.Header
+	body   string
+}
+
+func newCaptureServer(t *testing.T) (*httptest.Server, chan capturedRequest) {
+	t.Helper()
+	reqC := make(chan capturedRequest, notifyRateLimit*2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		reqC <- capturedRequest{header: r.Header.Clone(), body: string(body)}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, reqC
+}
+
+var testNote = Notification{
+	Event:   EventIncident,
+	Title:   "web crashed",
+	Message: "web exited with code 1",
+	Time:    time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+}
+
+func TestNotificationChannelsSend(t *testing.T) {
+	srv, reqC := newCaptureServer(t)
+
+	tests := []struct {
+		name     string
+		settings map[string]string
+		channel  string
+		check    func(t *testing.T, req capturedRequest)
+	}{
+		{
+			name:     "webhook",
+			settings: map[string]string{"NotifyWebhookURL": srv.URL + "/hook"},
+			channel:  ChannelWebhook,
+			check: func(t *testing.T, req capturedRequest) {
+				var got Notification
+				if err := json.Unmarshal([]byte(req.body), &got); err != nil {
+					t.Fatal(err)
+				}
+				if got.Event != testNote.Event || got.Title != testNote.Title || got.Message != testNote.Message {
+					t.Errorf("got notification %+v", got)
+				}
+			},
+		},
+		{
+			name:     "ntfy",
+			settings: map[string]string{"NotifyNtfyURL": srv.URL + "/alerts", "NotifyNtfyToken": "tk_secret"},
+			channel:  ChannelNtfy,
+			check: func(t *testing.T, req capturedRequest) {
+				if req.body != testNote.Message {
+					t.Errorf("got body %q", req.body)
+				}
+				if got := req.header.Get("Title"); got != testNote.Title {
+					t.Errorf("got title %q", got)
+				}
+				if got := req.header.Get("Priority"); got != "high" {
+					t.Errorf("got priority %q", got)
+				}
+				if got := req.header.Get("Authorization"); got != "Bearer tk_secret" {
+					t.Errorf("got authorization %q", got)
+				}
+			},
+		},
+		{
+			name:     "gotify",
+			settings: map[string]string{"NotifyGotifyURL": srv.URL + "/", "NotifyGotifyToken": "app-token"},
+			channel:  ChannelGotify,
+			check: func(t *testing.T, req capturedRequest) {
+				var got struct {
+					Title    string `json:"title"`
+					Message  string `json:"message"`
+					Priority int    `json:"priority"`
+				}
+				if err := json.Unmarshal([]byte(req.body), &got); err != nil {
+					t.Fatal(err)
+				}
+				if got.Title != testNote.Title || got.Message != testNote.Message || got.Priority != 8 {
+					t.Errorf("got message %+v", got)
+				}
+				if got := req.header.Get("X-Gotify-Key"); got != "app-token" {
+					t.Errorf("got key %q", got)
+				}
+			},
+		},
+		{
+			name:     "slack",
+			settings: map[string]string{"NotifySlackURL": srv.URL + "/services/T0/B0/x"},
+			channel:  ChannelSlack,
+			check: func(t *testing.T, req capturedRequest) {
+				var got map[string]string
+				if err := json.Unmarshal([]byte(req.body), &got); err != nil {
+					t.Fatal(err)
+				}
+				if want := "*web crashed*\nweb exited with code 1"; got["text"] != want {
+					t.Errorf("got text %q, want %q", got["text"], want)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			channels, err := NewNotificationChannels(tt.settings)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = send(t.Context(), channels[tt.channel], testNote); err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, <-reqC)
+		})
+	}
+}
+
+func TestNotificationChannelFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+	channels, err := NewNotificationChannels(map[string]string{"NotifyWebhookURL": srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = send(t.Context(), channels[ChannelWebhook], testNote)
+	if err == nil || !strings.Contains(err.Error(), "invalid token") {
+		t.Errorf("got error %v, want the response of the server", err)
+	}
+}
+
+// fakeSMTPServer accepts one mail without TLS or authentication and returns what it was sent
+func fakeSMTPServer(t *testing.T) (string, chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	msgC := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+		reply("220 localhost ESMTP")
+		var envelope []string
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			switch cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); cmd {
+			case "EHLO", "HELO":
+				reply("250-localhost")
+				reply("250 8BITMIME")
+			case "MAIL", "RCPT":
+				envelope = append(envelope, line)
+				reply("250 OK")
+			case "DATA":
+				reply("354 go ahead")
+				var data strings.Builder
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if line == ".\r\n" {
+						break
+					}
+					data.WriteString(line)
+				}
+				msgC <- strings.Join(envelope, "\n") + "\n" + data.String()
+				reply("250 OK")
+			case "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("502 not implemented")
+			}
+		}
+	}()
+	return ln.Addr().String(), msgC
+}
+
+func TestEmailChannelSend(t *testing.T) {
+	addr, msgC := fakeSMTPServer(t)
+	channels, err := NewNotificationChannels(map[string]string{
+		"NotifySMTPAddr": addr,
+		"NotifySMTPFrom": "Mahogany <mahogany@example.com>",
+		"NotifySMTPTo":   "ops@example.com, Pager <pager@example.com>",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = send(t.Context(), channels[ChannelEmail], testNote); err != nil {
+		t.Fatal(err)
+	}
+	msg := <-msgC
+	for _, want := range []string{
+		"MAIL FROM:<mahogany@example.com>",
+		"RCPT TO:<ops@example.com>",
+		"RCPT TO:<pager@example.com>",
+		"From: \"Mahogany\" <mahogany@example.com>\r\n",
+		"To: <ops@example.com>, \"Pager\" <pager@example.com>\r\n",
+		"Subject: web crashed\r\n",
+		"\r\n\r\nweb exited with code 1",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("mail is missing %q:\n%s", want, msg)
+		}
+	}
+}
+
+func TestNewNotificationChannels(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings map[string]string
+		want     []string
+		wantErr  bool
+	}{
+		{"nothing configured", map[string]string{}, nil, false},
+		{"every channel", map[string]string{
+			"NotifyWebhookURL": "https://example.com/hook",
+			"NotifyNtfyURL":    "https://ntfy.sh/alerts",
+			"NotifyGotifyURL":  "http://gotify.lan",
+			"NotifySlackURL":   "https://hooks.slack.com/services/T0/B0/x",
+			"NotifySMTPAddr":   "smtp.example.com:587",
+			"NotifySMTPFrom":   "mahogany@example.com",
+			"NotifySMTPTo":     "ops@example.com",
+		}, []string{ChannelWebhook, ChannelNtfy, ChannelGotify, ChannelEmail, ChannelSlack}, false},
+		{"relative url", map[string]string{"NotifyWebhookURL": "/hook"}, nil, true},
+		{"not http", map[string]string{"NotifySlackURL": "ftp://example.com/hook"}, nil, true},
+		{"bad url", map[string]string{"NotifyNtfyURL": "http://[::1"}, nil, true},
+		{"email partly set", map[string]string{"NotifySMTPAddr": "smtp.example.com:587"}, nil, false},
+		{"smtp address without port", map[string]string{
+			"NotifySMTPAddr": "smtp.example.com",
+			"NotifySMTPFrom": "mahogany@example.com",
+			"NotifySMTPTo":   "ops@example.com",
+		}, nil, true},
+		{"bad from address", map[string]string{
+			"NotifySMTPAddr": "smtp.example.com:587",
+			"NotifySMTPFrom": "mahogany",
+			"NotifySMTPTo":   "ops@example.com",
+		}, nil, true},
+		{"bad to address", map[string]string{
+			"NotifySMTPAddr": "smtp.example.com:587",
+			"NotifySMTPFrom": "mahogany@example.com",
+			"NotifySMTPTo":   "ops@example.com, oncall",
+		}, nil, true},
+		{"route to known channels", map[string]string{"NotifyIncident": "webhook, ntfy,,"}, nil, false},
+		{"route to unknown channel", map[string]string{"NotifyRelease": "webhook,pager"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			channels, err := NewNotificationChannels(tt.settings)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if len(channels) != len(tt.want) {
+				t.Errorf("got %d channels, want %v", len(channels), tt.want)
+			}
+			for _, name := range tt.want {
+				if _, ok := channels[name]; !ok {
+					t.Errorf("missing channel %s", name)
+				}
+			}
+		})
+	}
+}
+
+func TestNotifierFirstSent(t *testing.T) {
+	n := NewNotifier(nil)
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		key   string
+		after time.Duration
+		want  bool
+	}{
+		{"web crashed", 0, true},
+		{"web crashed", time.Minute, false},
+		{"db crashed", time.Minute, true},
+		{"web crashed", notifyDedupWindow - time.Second, false},
+		{"web crashed", notifyDedupWindow, true},
+		{"web crashed", notifyDedupWindow + time.Minute, false},
+		{"db crashed", notifyDedupWindow + time.Minute, true},
+	}
+	for i, tt := range tests {
+		if got := n.firstSent(Notification{Key: tt.key, Time: start.Add(tt.after)}); got != tt.want {
+			t.Errorf("%d: firstSent(%q, +%s) = %v, want %v", i, tt.key, tt.after, got, tt.want)
+		}
+	}
+}
+
+func TestNotifierAllow(t *testing.T) {
+	n := NewNotifier(nil)
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i := range notifyRateLimit {
+		if !n.allow(ChannelWebhook, start.Add(time.Duration(i)*time.Second)) {
+			t.Fatalf("notification %d was rate limited", i)
+		}
+	}
+	if n.allow(ChannelWebhook, start.Add(30*time.Second)) {
+		t.Error("notification past the limit was allowed")
+	}
+	if !n.allow(ChannelSlack, start.Add(30*time.Second)) {
+		t.Error("other channel was rate limited")
+	}
+	// the first notification leaves the window, which makes room for one more
+	if !n.allow(ChannelWebhook, start.Add(notifyRateWindow)) {
+		t.Error("notification after the window was rate limited")
+	}
+	if n.allow(ChannelWebhook, start.Add(notifyRateWindow)) {
+		t.Error("notification past the limit was allowed")
+	}
+}
+
+func TestNotifierRoutesAndDedups(t *testing.T) {
+	srv, reqC := newCaptureServer(t)
+	dbConn := dbtest.New(t)
+	query := db.New(dbConn)
+	for name, value := range map[string]string{
+		"NotifyWebhookURL": srv.URL,
+		"NotifyIncident":   ChannelWebhook,
+	} {
+		if err := query.UpdateSetting(t.Context(), db.UpdateSettingParams{Name: name, Value: value}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// notify sends synchronously, so every request is in the channel once it returns
+	n := NewNotifier(dbConn)
+	for _, note := range []Notification{
+		{Event: EventIncident, Title: "web crashed", Key: "web", Time: testNote.Time},
+		{Event: EventIncident, Title: "web crashed again", Key: "web", Time: testNote.Time.Add(time.Minute)},
+		{Event: EventRelease, Title: "v1.2.0 released", Key: "release", Time: testNote.Time},
+	} {
+		n.notify(t.Context(), note)
+	}
+	close(reqC)
+
+	titles := []string{}
+	for req := range reqC {
+		var got Notification
+		if err := json.Unmarshal([]byte(req.body), &got); err != nil {
+			t.Fatal(err)
+		}
+		titles = append(titles, got.Title)
+	}
+	if len(titles) != 1 || titles[0] != "web crashed" {
+		t.Errorf("sent %v, want only the first incident", titles)
+	}
+}
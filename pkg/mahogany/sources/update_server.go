@@ -2,29 +2,70 @@ package sources
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/tls"
 	"database/sql"
 	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
 	"net"
 	"strings"
+	"sync"
+	"time"
 
+	github "github.com/google/go-github/v67/github"
 	db "github.com/mpoegel/mahogany/internal/db"
 	schema "github.com/mpoegel/mahogany/pkg/schema"
 	grpc "google.golang.org/grpc"
+	credentials "google.golang.org/grpc/credentials"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
 	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
 )
 
 const ALL_HOSTS = "*"
 
+// HostMetricsRetention is how long host metrics reported by agents are kept
+const HostMetricsRetention = 24 * time.Hour
+
+// how often host metrics older than the retention are dropped
+const hostMetricsPruneInterval = 10 * time.Minute
+
 type UpdateServerI interface {
 	GetNumConnections() int
+	RollbackRelease(ctx context.Context, hostname, packageName string) error
+	PushGithubRelease(ctx context.Context, packageName, tag string) error
+	SendServiceAction(ctx context.Context, hostname, serviceName, containerID string, action schema.ServiceAction) error
+	CreateJoinToken(ctx context.Context, hostname string) (string, error)
+	RevokeCertificate(ctx context.Context, serial string) error
+}
+
+var (
+	ErrAgentNotConnected     = errors.New("agent is not connected")
+	ErrUnknownPackage        = errors.New("package is not a github package in the topology")
+	ErrActionPending         = errors.New("agent has not acknowledged the service action yet")
+	ErrActionNotAcknowledged = errors.New("agent never acknowledged the service action")
+)
+
+// how long an agent has to acknowledge a service action it took, e.g. while a container stops gracefully
+const serviceActionAckTimeout = 2 * time.Minute
+
+// releaseNotice is a release action broadcast to the release streams of every host that has the package, or only
+// to the named host if one is set
+type releaseNotice struct {
+	release  *schema.Release
+	action   schema.ReleaseAction
+	hostname string
 }
 
 type UpdateServer struct {
 	schema.UnimplementedUpdateServiceServer
 
-	port int
+	port    int
+	timeout time.Duration
+	ca      *CertificateAuthority
+	// names the agents use to reach the server, which go in its certificate
+	serverNames []string
 
 	topology *schema.Topology
 	// map of github full name to package
@@ -32,14 +73,26 @@ type UpdateServer struct {
 	// map of package name to set of host names
 	packageToHost map[string]map[string]bool
 
-	releaseBroker *Broker[*schema.Release]
-	ln            net.Listener
-	isClosed      bool
-	db            *sql.DB
-	query         *db.Queries
+	releaseBroker *Broker[*releaseNotice]
+	// map of host name to the pending actions for its services stream
+	serviceActions map[string]chan *schema.ServicesStreamResponse
+	// map of action ID to the caller waiting on its result
+	actionResults map[string]chan *schema.ServiceActionResult
+	actionsMu     sync.Mutex
+	// map of certificate serial to the cancel funcs of the streams using it
+	streams      map[string]map[int64]context.CancelCauseFunc
+	nextStreamID int64
+	streamsMu    sync.Mutex
+	audit        *Auditor
+	notifier     *Notifier
+	bus          *EventBus
+	ln           net.Listener
+	isClosed     bool
+	db           *sql.DB
+	query        *db.Queries
 }
 
-func NewUpdateServer(topologyFile string, port int, dbConn *sql.DB) (*UpdateServer, error) {
+func NewUpdateServer(topologyFile string, port int, timeout time.Duration, dbConn *sql.DB, ca *CertificateAuthority, serverNames []string, notifier *Notifier, bus *EventBus) (*UpdateServer, error) {
 	topo, err := schema.ReadTopology(topologyFile)
 	if err != nil {
 		return nil, err
@@ -50,7 +103,16 @@ func NewUpdateServer(topologyFile string, port int, dbConn *sql.DB) (*UpdateServ
 		githubPackages: make(map[string]*schema.Package),
 		packageToHost:  make(map[string]map[string]bool),
 		port:           port,
-		releaseBroker:  NewBroker[*schema.Release](),
+		timeout:        timeout,
+		ca:             ca,
+		serverNames:    serverNames,
+		releaseBroker:  NewBroker[*releaseNotice](),
+		serviceActions: make(map[string]chan *schema.ServicesStreamResponse),
+		actionResults:  make(map[string]chan *schema.ServiceActionResult),
+		streams:        make(map[string]map[int64]context.CancelCauseFunc),
+		audit:          NewAuditor(dbConn, bus),
+		notifier:       notifier,
+		bus:            bus,
 		isClosed:       false,
 		db:             dbConn,
 		query:          db.New(dbConn),
@@ -88,6 +150,18 @@ func NewUpdateServer(topologyFile string, port int, dbConn *sql.DB) (*UpdateServ
 }
 
 func (s *UpdateServer) Start(ctx context.Context) error {
+	serverCert, err := s.ca.ServerCertificate(s.serverNames)
+	if err != nil {
+		return err
+	}
+	creds := credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientCAs:    s.ca.CertPool(),
+		// enrolling agents do not have a certificate yet, every other call is refused without one
+		ClientAuth: tls.VerifyClientCertIfGiven,
+		MinVersion: tls.VersionTLS13,
+	})
+
 	lnConfig := net.ListenConfig{}
 
 	addr := fmt.Sprintf(":%d", s.port)
@@ -96,11 +170,18 @@ func (s *UpdateServer) Start(ctx context.Context) error {
 		return err
 	}
 	s.ln = ln
-	slog.Info("update server listening", "addr", addr)
+	slog.Info("update server listening", "addr", addr, "names", s.serverNames)
 
-	go s.releaseBroker.Start()
+	s.releaseBroker.Start()
+	pruneCtx, stopPruning := context.WithCancel(ctx)
+	defer stopPruning()
+	go s.pruneHostMetrics(pruneCtx)
 
-	grpcServer := grpc.NewServer()
+	grpcServer := grpc.NewServer(
+		grpc.Creds(creds),
+		grpc.UnaryInterceptor(s.authorizeUnary),
+		grpc.StreamInterceptor(s.authorizeStream),
+	)
 	schema.RegisterUpdateServiceServer(grpcServer, s)
 	if err := grpcServer.Serve(ln); err != nil && !s.isClosed {
 		return err
@@ -116,46 +197,157 @@ func (s *UpdateServer) Stop() {
 	s.releaseBroker.Stop()
 }
 
-func (s *UpdateServer) PropagateGithubRelease(event *GithubReleaseEvent) {
-	pack, ok := s.githubPackages[*event.Repo.Name]
+func (s *UpdateServer) PropagateGithubRelease(ctx context.Context, event *GithubReleaseEvent) error {
+	repoName := event.GetRepo().GetName()
+	pack, ok := s.githubPackages[repoName]
 	if !ok {
-		slog.Warn("github package not in topology", "name", *event.Repo.Name)
-		return
+		slog.Warn("github package not in topology", "name", repoName)
+		err := fmt.Errorf("%w: %s", ErrUnknownPackage, repoName)
+		s.audit.Record(ctx, "release.install", repoName, nil, err)
+		return err
 	}
 
 	release := &schema.Release{
-		Name:           *event.Repo.Name,
-		Version:        *event.Release.Name,
-		RepositoryName: *event.Repo.FullName,
+		Name:           repoName,
+		Version:        event.GetRelease().GetName(),
+		RepositoryName: event.GetRepo().GetFullName(),
 		Assets:         make([]*schema.Asset, 0),
 		InstallCommand: pack.InstallCommand,
+		WorkingDir:     pack.WorkingDir,
+		Environment:    pack.Environment,
+	}
+	if pack.Timeout > 0 {
+		release.InstallTimeout = durationpb.New(pack.Timeout)
 	}
 
-	for _, asset := range event.Release.Assets {
-		if pack.GithubPackage.Regex.MatchString(*asset.Name) {
+	sourceMask := fmt.Sprintf("https://github.com/%s/releases", pack.GithubPackage.Name)
+	checksums := map[string]string{}
+	signatures := map[string]string{}
+	for _, asset := range event.GetRelease().Assets {
+		name, url := asset.GetName(), asset.GetBrowserDownloadURL()
+		if !strings.HasPrefix(url, sourceMask) {
+			continue
+		}
+		if strings.HasSuffix(name, githubChecksumsSuffix) {
+			tctx, cancel := context.WithTimeout(ctx, s.timeout)
+			sums, err := fetchGithubChecksums(tctx, url)
+			cancel()
+			if err != nil {
+				slog.Warn("failed to fetch release checksums", "url", url, "err", err)
+				continue
+			}
+			maps.Copy(checksums, sums)
+		} else if signed, ok := githubSignedAsset(name); ok {
+			signatures[signed] = url
+		}
+	}
+
+	for _, asset := range event.GetRelease().Assets {
+		// the checksums and signatures are never installed, even if the asset regex matches them
+		if pack.GithubPackage.Regex.MatchString(asset.GetName()) && !isGithubIntegrityFile(asset.GetName()) {
 			asset := &schema.Asset{
-				Name:      *asset.Name,
-				SourceUrl: *asset.BrowserDownloadURL,
+				Name:      asset.GetName(),
+				SourceUrl: asset.GetBrowserDownloadURL(),
+				Sha256:    checksums[asset.GetName()],
 			}
-			sourceMask := fmt.Sprintf("https://github.com/%s/releases", pack.GithubPackage.Name)
 			if !strings.HasPrefix(asset.SourceUrl, sourceMask) {
 				slog.Warn("asset has suspicious download url", "url", asset.SourceUrl)
 				continue
 			}
+			if len(asset.Sha256) == 0 {
+				slog.Warn("asset has no checksum", "name", asset.Name)
+			}
+			if url, ok := signatures[asset.Name]; ok {
+				tctx, cancel := context.WithTimeout(ctx, s.timeout)
+				sig, err := fetchGithubAsset(tctx, url)
+				cancel()
+				if err != nil {
+					slog.Warn("failed to fetch asset signature", "url", url, "err", err)
+				} else {
+					asset.Signature = sig
+				}
+			}
 			release.Assets = append(release.Assets, asset)
 		}
 	}
+	auditParams := map[string]any{"version": release.Version, "assets": len(release.Assets)}
 	if len(release.Assets) == 0 {
-		slog.Warn("no release assets matched", "name", *event.Repo.Name, "version", *event.Release.Name)
-		return
+		slog.Warn("no release assets matched", "name", repoName, "version", release.Version)
+		err := errors.New("no release assets matched")
+		s.audit.Record(ctx, "release.install", repoName, auditParams, err)
+		return err
+	}
+
+	s.releaseBroker.Broadcast(&releaseNotice{release: release, action: schema.ReleaseAction_RELEASE_ACTION_INSTALL})
+	slog.Info("release broadcasted", "name", repoName, "version", release.Version)
+	s.audit.Record(ctx, "release.install", repoName, auditParams, nil)
+	s.notifier.Notify(ctx, Notification{
+		Event:   EventRelease,
+		Title:   fmt.Sprintf("Released %s %s", repoName, release.Version),
+		Message: fmt.Sprintf("%s %s was sent to the agents with %d assets.", repoName, release.Version, len(release.Assets)),
+	})
+	return nil
+}
+
+// PushGithubRelease installs a release of the package without waiting for a webhook, e.g. to catch up on a missed
+// delivery. The latest release is pushed if no tag is given.
+func (s *UpdateServer) PushGithubRelease(ctx context.Context, packageName, tag string) error {
+	pack, ok := s.githubPackages[packageName]
+	if !ok {
+		err := fmt.Errorf("%w: %s", ErrUnknownPackage, packageName)
+		s.audit.Record(ctx, "release.install", packageName, map[string]string{"tag": tag}, err)
+		return err
+	}
+	owner, repo, _ := strings.Cut(pack.GithubPackage.Name, "/")
+	tctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	client := github.NewClient(nil)
+	var release *github.RepositoryRelease
+	var err error
+	if len(tag) == 0 {
+		release, _, err = client.Repositories.GetLatestRelease(tctx, owner, repo)
+	} else {
+		release, _, err = client.Repositories.GetReleaseByTag(tctx, owner, repo, tag)
 	}
+	if err != nil {
+		s.audit.Record(ctx, "release.install", packageName, map[string]string{"tag": tag}, err)
+		return err
+	}
+	return s.PropagateGithubRelease(ctx, &GithubReleaseEvent{
+		ReleaseEvent: github.ReleaseEvent{
+			Action:  github.String("published"),
+			Release: release,
+			Repo: &github.Repository{
+				Name:     github.String(packageName),
+				FullName: github.String(pack.GithubPackage.Name),
+			},
+		},
+	})
+}
 
-	s.releaseBroker.Broadcast(release)
-	slog.Info("release broadcasted", "name", *event.Repo.Name, "version", *event.Release.Name)
+// RollbackRelease asks the agent on the host to reinstall the package's previous known-good release
+func (s *UpdateServer) RollbackRelease(ctx context.Context, hostname, packageName string) error {
+	pack, ok := s.packageToHost[packageName]
+	if !ok || !(pack[hostname] || pack[ALL_HOSTS]) {
+		err := fmt.Errorf("package %s is not installed on %s", packageName, hostname)
+		s.audit.Record(ctx, "release.rollback", hostname, map[string]string{"package": packageName}, err)
+		return err
+	}
+	s.releaseBroker.Broadcast(&releaseNotice{
+		release:  &schema.Release{Name: packageName},
+		action:   schema.ReleaseAction_RELEASE_ACTION_ROLLBACK,
+		hostname: hostname,
+	})
+	slog.Info("rollback broadcasted", "name", packageName, "hostname", hostname)
+	s.audit.Record(ctx, "release.rollback", hostname, map[string]string{"package": packageName}, nil)
+	return nil
 }
 
 func (s *UpdateServer) RegisterManifest(ctx context.Context, req *schema.RegisterManifestRequest) (*schema.RegisterManifestResponse, error) {
 	slog.Info("got register manifest request", "hostname", req.Hostname)
+	if err := checkHostname(ctx, req.Hostname); err != nil {
+		return nil, err
+	}
 	services, err := s.query.ListWatchedServices(ctx)
 	resp := &schema.RegisterManifestResponse{
 		SubscribeToDocker:  true,
@@ -170,23 +362,40 @@ func (s *UpdateServer) RegisterManifest(ctx context.Context, req *schema.Registe
 }
 
 func (s *UpdateServer) ReleaseStream(req *schema.ReleaseStreamRequest, stream schema.UpdateService_ReleaseStreamServer) error {
+	if err := checkHostname(stream.Context(), req.Hostname); err != nil {
+		return err
+	}
 	c := s.releaseBroker.Subscribe()
 	if c == nil {
 		return errors.New("subscription unavailable")
 	}
 	slog.Info("new release stream")
-	defer s.releaseBroker.Unsubscribe(c)
+	s.bus.Publish(BusAgent, req.Hostname, "connect")
+	defer func() {
+		s.releaseBroker.Unsubscribe(c)
+		s.bus.Publish(BusAgent, req.Hostname, "disconnect")
+	}()
 	for {
-		release := <-c
-		if release == nil {
+		var notice *releaseNotice
+		select {
+		case notice = <-c:
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		}
+		if notice == nil {
 			return nil
 		}
+		release := notice.release
+		if len(notice.hostname) > 0 && notice.hostname != req.Hostname {
+			continue
+		}
 		slog.Info("checking release", "hostname", req.Hostname, "release", release.Name)
 		pack, ok := s.packageToHost[release.Name]
 		if ok && (pack[req.Hostname] || pack[ALL_HOSTS]) {
 			resp := &schema.ReleaseStreamResponse{
 				Release:   release,
 				Timestamp: timestamppb.Now(),
+				Action:    notice.action,
 			}
 			if err := stream.Send(resp); err != nil {
 				slog.Warn("failed to send release stream response", "err", err)
@@ -201,40 +410,275 @@ func (s *UpdateServer) ReleaseStream(req *schema.ReleaseStreamRequest, stream sc
 func (s *UpdateServer) ServicesStream(stream grpc.BidiStreamingServer[schema.ServicesStreamRequest, schema.ServicesStreamResponse]) error {
 	slog.Info("new services stream")
 	deviceID := int64(-1)
+	hostname := ""
+	var actionC chan *schema.ServicesStreamResponse
 	trackedServices := map[string]int64{}
+	defer func() {
+		// the agent has already reconnected if a newer stream took over its service actions
+		if actionC != nil && s.closeServiceActions(hostname, actionC) && !s.isClosed {
+			s.notifier.Notify(stream.Context(), Notification{
+				Event:   EventAgentDisconnect,
+				Title:   fmt.Sprintf("Agent on %s disconnected", hostname),
+				Message: fmt.Sprintf("The services stream of the agent on %s ended.", hostname),
+			})
+		}
+	}()
 	for {
 		msg, err := stream.Recv()
 		if err != nil {
 			slog.Warn("error receiving from services stream", "err", err)
 			return nil
 		}
-		for _, svc := range msg.Services {
-			if deviceID == -1 {
-				deviceID, err = s.getDeviceID(stream.Context(), msg)
-				if err != nil {
-					slog.Warn("services stream from unregistered device", "hostname", msg.Hostname, "err", err)
-					break
-				}
+		if err = checkHostname(stream.Context(), msg.Hostname); err != nil {
+			return err
+		}
+		if len(hostname) == 0 && len(msg.Hostname) > 0 {
+			hostname = msg.Hostname
+			actionC = s.openServiceActions(hostname)
+			go s.sendServiceActions(stream, actionC)
+		}
+		if msg.ActionResult != nil {
+			s.completeServiceAction(msg.ActionResult)
+		}
+		if deviceID == -1 && (len(msg.Services) > 0 || msg.HostMetrics != nil) {
+			deviceID, err = s.getDeviceID(stream.Context(), msg)
+			if err != nil {
+				slog.Warn("services stream from unregistered device", "hostname", msg.Hostname, "err", err)
+				continue
+			}
+		}
+		if msg.HostMetrics != nil {
+			if err = s.addHostMetrics(stream.Context(), deviceID, msg.HostMetrics); err != nil {
+				slog.Warn("cannot store host metrics", "device", deviceID, "err", err)
 			}
+		}
+		for _, svc := range msg.Services {
 			slog.Info("got tracked service report", "svc", svc)
 			serviceID, ok := trackedServices[svc.Name]
 			if !ok {
 				serviceID, err = s.query.GetTrackedServiceID(stream.Context(), db.GetTrackedServiceIDParams{Name: svc.Name, DeviceID: deviceID})
-				if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
 					serviceID, err = s.addTrackedService(stream.Context(), deviceID, svc)
 					if err != nil {
 						slog.Warn("cannot add tracked service", "err", err, "svc", svc)
 						continue
 					}
+					trackedServices[svc.Name] = serviceID
+					continue
+				} else if err != nil {
+					slog.Warn("cannot find tracked service", "device", deviceID, "err", err, "svc", svc)
+					continue
 				}
+				// the service is known from an earlier stream, and its status may have changed since
 				trackedServices[svc.Name] = serviceID
-			} else {
-				if err = s.updateTrackedService(stream.Context(), serviceID, svc); err != nil {
-					slog.Warn("cannot update tracked service", "id", serviceID, "device", deviceID, "err", err, "svc", svc)
-				}
 			}
+			if err = s.updateTrackedService(stream.Context(), serviceID, svc); err != nil {
+				slog.Warn("cannot update tracked service", "id", serviceID, "device", deviceID, "err", err, "svc", svc)
+			}
+		}
+		s.bus.Publish(BusReport, hostname, "services")
+	}
+}
+
+// SendServiceAction asks the agent on the host to start, stop or restart one of its services and waits for the
+// agent to acknowledge the result. If the agent took the action but the context is done before it acknowledged it,
+// ErrActionPending is returned and the result is recorded in the audit log once it arrives.
+func (s *UpdateServer) SendServiceAction(ctx context.Context, hostname, serviceName, containerID string, action schema.ServiceAction) error {
+	target := hostname + "/" + serviceName
+	params := map[string]string{
+		"action":       action.String(),
+		"container_id": containerID,
+	}
+	actionID, resultC, err := s.sendServiceAction(ctx, hostname, serviceName, containerID, action)
+	if err != nil {
+		s.audit.Record(ctx, "service.action", target, params, err)
+		return err
+	}
+
+	select {
+	case result := <-resultC:
+		err = serviceActionErr(result)
+		s.audit.Record(ctx, "service.action", target, params, err)
+		return err
+	case <-ctx.Done():
+	}
+	go func() {
+		var err error
+		select {
+		case result := <-resultC:
+			err = serviceActionErr(result)
+		case <-time.After(serviceActionAckTimeout):
+			s.forgetServiceAction(actionID)
+			err = ErrActionNotAcknowledged
+		}
+		slog.Info("pending service action finished", "hostname", hostname, "service", serviceName, "id", actionID, "err", err)
+		s.audit.Record(context.WithoutCancel(ctx), "service.action", target, params, err)
+	}()
+	return ErrActionPending
+}
+
+func serviceActionErr(result *schema.ServiceActionResult) error {
+	if !result.IsSuccess {
+		return errors.New(result.Error)
+	}
+	return nil
+}
+
+// sendServiceAction hands the action to the services stream of the host and returns the channel its result will be
+// sent on. It fails if the stream does not take the action before the context is done.
+func (s *UpdateServer) sendServiceAction(ctx context.Context, hostname, serviceName, containerID string, action schema.ServiceAction) (string, chan *schema.ServiceActionResult, error) {
+	actionID := rand.Text()
+	resultC := make(chan *schema.ServiceActionResult, 1)
+
+	s.actionsMu.Lock()
+	actionC, ok := s.serviceActions[hostname]
+	if ok {
+		s.actionResults[actionID] = resultC
+	}
+	s.actionsMu.Unlock()
+	if !ok {
+		return "", nil, ErrAgentNotConnected
+	}
+
+	req := &schema.ServicesStreamResponse{
+		ServiceName:   serviceName,
+		ServiceAction: action,
+		ActionId:      actionID,
+		ContainerId:   containerID,
+	}
+	select {
+	case actionC <- req:
+	case <-ctx.Done():
+		s.forgetServiceAction(actionID)
+		return "", nil, fmt.Errorf("%w: %w", ErrAgentNotConnected, ctx.Err())
+	}
+	slog.Info("sent service action", "hostname", hostname, "service", serviceName, "action", action, "id", actionID)
+	return actionID, resultC, nil
+}
+
+func (s *UpdateServer) forgetServiceAction(actionID string) {
+	s.actionsMu.Lock()
+	defer s.actionsMu.Unlock()
+	delete(s.actionResults, actionID)
+}
+
+// openServiceActions registers the newest services stream of the host as the target of its service actions
+func (s *UpdateServer) openServiceActions(hostname string) chan *schema.ServicesStreamResponse {
+	actionC := make(chan *schema.ServicesStreamResponse)
+	s.actionsMu.Lock()
+	defer s.actionsMu.Unlock()
+	s.serviceActions[hostname] = actionC
+	return actionC
+}
+
+// closeServiceActions unregisters the stream of the host, and returns whether it was still the newest one
+func (s *UpdateServer) closeServiceActions(hostname string, actionC chan *schema.ServicesStreamResponse) bool {
+	s.actionsMu.Lock()
+	defer s.actionsMu.Unlock()
+	if s.serviceActions[hostname] != actionC {
+		return false
+	}
+	delete(s.serviceActions, hostname)
+	return true
+}
+
+func (s *UpdateServer) sendServiceActions(stream grpc.BidiStreamingServer[schema.ServicesStreamRequest, schema.ServicesStreamResponse], actionC chan *schema.ServicesStreamResponse) {
+	for {
+		select {
+		case action := <-actionC:
+			if err := stream.Send(action); err != nil {
+				slog.Warn("failed to send service action", "err", err, "action", action)
+				s.completeServiceAction(&schema.ServiceActionResult{ActionId: action.ActionId, Error: err.Error()})
+			}
+		case <-stream.Context().Done():
+			return
+		}
+	}
+}
+
+func (s *UpdateServer) completeServiceAction(result *schema.ServiceActionResult) {
+	s.actionsMu.Lock()
+	resultC, ok := s.actionResults[result.ActionId]
+	delete(s.actionResults, result.ActionId)
+	s.actionsMu.Unlock()
+	slog.Info("service action completed", "id", result.ActionId, "success", result.IsSuccess, "err", result.Error)
+	if !ok {
+		return
+	}
+	select {
+	case resultC <- result:
+	default:
+	}
+}
+
+func (s *UpdateServer) ReportInstallation(ctx context.Context, req *schema.ReportInstallationRequest) (*schema.ReportInstallationResponse, error) {
+	slog.Info("got installation report", "hostname", req.Hostname, "name", req.Name, "version", req.Version)
+	if err := checkHostname(ctx, req.Hostname); err != nil {
+		return nil, err
+	}
+	device, err := s.query.GetDevice(ctx, req.Hostname)
+	if err != nil {
+		slog.Warn("installation report from unregistered device", "hostname", req.Hostname, "err", err)
+		return nil, err
+	}
+	pack, err := s.getPackage(ctx, req.Name)
+	if err != nil {
+		slog.Warn("installation report for unknown package", "name", req.Name, "err", err)
+		return nil, err
+	}
+
+	// the assets of a report are recorded together, since whether the release is installed depends on all of them
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	query := s.query.WithTx(tx)
+	var allErrs error
+	for _, result := range req.Results {
+		args := db.AddAssetParams{
+			DeviceID:    device.ID,
+			PackageID:   pack.ID,
+			Name:        result.Asset.GetName(),
+			SourceUrl:   sql.NullString{String: result.Asset.GetSourceUrl(), Valid: result.Asset.GetSourceUrl() != ""},
+			Version:     sql.NullString{String: req.Version, Valid: true},
+			IsInstalled: result.IsInstalled,
+			Output:      sql.NullString{String: result.Output, Valid: true},
+			DurationMs:  result.Duration.AsDuration().Milliseconds(),
+			InstalledAt: req.Timestamp.GetSeconds(),
+			IsRollback:  req.Action == schema.ReleaseAction_RELEASE_ACTION_ROLLBACK,
+		}
+		if result.Error != "" {
+			args.Output.String = strings.Join([]string{result.Output, result.Error}, "\n")
 		}
+		_, err := query.AddAsset(ctx, args)
+		allErrs = errors.Join(allErrs, err)
 	}
+	if allErrs == nil {
+		allErrs = tx.Commit()
+	}
+	if allErrs != nil {
+		slog.Warn("failed to record installation", "hostname", req.Hostname, "name", req.Name, "err", allErrs)
+		return nil, allErrs
+	}
+	return &schema.ReportInstallationResponse{}, nil
+}
+
+// getPackage finds the package by name, adding it from the topology if it is not yet known
+func (s *UpdateServer) getPackage(ctx context.Context, name string) (db.Package, error) {
+	pack, err := s.query.GetPackageByName(ctx, name)
+	if err == nil || !errors.Is(err, sql.ErrNoRows) {
+		return pack, err
+	}
+	topoPack, ok := s.githubPackages[name]
+	if !ok {
+		return pack, fmt.Errorf("package %s not in topology", name)
+	}
+	return s.query.AddPackage(ctx, db.AddPackageParams{
+		Name:       name,
+		InstallCmd: topoPack.InstallCommand,
+		UpdateCmd:  topoPack.InstallCommand,
+	})
 }
 
 func (s *UpdateServer) getDeviceID(ctx context.Context, msg *schema.ServicesStreamRequest) (int64, error) {
@@ -274,6 +718,8 @@ func (s *UpdateServer) updateTrackedService(ctx context.Context, serviceID int64
 	}
 	switch s := svc.Service.(type) {
 	case *schema.ServiceStatus_DockerService:
+		args.ContainerID = sql.NullString{String: s.DockerService.Id, Valid: true}
+		args.ContainerImage = sql.NullString{String: s.DockerService.Image, Valid: true}
 		args.Status = s.DockerService.Status
 	case *schema.ServiceStatus_SystemdService:
 		args.Status = s.SystemdService.ActiveState
@@ -283,6 +729,33 @@ func (s *UpdateServer) updateTrackedService(ctx context.Context, serviceID int64
 	return err
 }
 
+// addHostMetrics stores the readings, leaving those the agent failed to take unset
+func (s *UpdateServer) addHostMetrics(ctx context.Context, deviceID int64, metrics *schema.HostMetrics) error {
+	return s.query.AddHostMetrics(ctx, db.AddHostMetricsParams{
+		DeviceID:   deviceID,
+		RecordedAt: time.Now().Unix(),
+		CpuUsage:   sql.NullFloat64{Float64: metrics.GetCpuUsage(), Valid: metrics.CpuUsage != nil},
+		MemUsage:   sql.NullFloat64{Float64: metrics.GetMemUsage(), Valid: metrics.MemUsage != nil},
+		DiskUsage:  sql.NullFloat64{Float64: metrics.GetDiskUsage(), Valid: metrics.DiskUsage != nil},
+	})
+}
+
+// pruneHostMetrics drops the host metrics that have aged out of the retention window until the context ends
+func (s *UpdateServer) pruneHostMetrics(ctx context.Context) {
+	ticker := time.NewTicker(hostMetricsPruneInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.query.DeleteHostMetricsBefore(ctx, time.Now().Add(-HostMetricsRetention).Unix()); err != nil {
+				slog.Error("failed to prune host metrics", "err", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
 func (s *UpdateServer) GetNumConnections() int {
 	return s.releaseBroker.Count()
 }
@@ -0,0 +1,203 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	db "github.com/mpoegel/mahogany/internal/db"
+	dbtest "github.com/mpoegel/mahogany/internal/db/dbtest"
+	schema "github.com/mpoegel/mahogany/pkg/schema"
+	grpc "google.golang.org/grpc"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// fakeServicesStream hands the requests to the server one at a time and ends once they run out
+type fakeServicesStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	reqs []*schema.ServicesStreamRequest
+}
+
+func (s *fakeServicesStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *fakeServicesStream) Recv() (*schema.ServicesStreamRequest, error) {
+	if len(s.reqs) == 0 {
+		return nil, io.EOF
+	}
+	req := s.reqs[0]
+	s.reqs = s.reqs[1:]
+	return req, nil
+}
+
+func (s *fakeServicesStream) Send(resp *schema.ServicesStreamResponse) error {
+	return nil
+}
+
+func dockerServiceStatus(name, status string) *schema.ServiceStatus {
+	return &schema.ServiceStatus{
+		Name: name,
+		Service: &schema.ServiceStatus_DockerService{DockerService: &schema.ServiceDocker{
+			Id:     name + "-id",
+			Image:  name + ":latest",
+			Status: status,
+		}},
+	}
+}
+
+// newTestUpdateServer creates an update server with the topology and a device named host
+func newTestUpdateServer(t *testing.T, topology string) (*UpdateServer, int64) {
+	t.Helper()
+	dbConn := dbtest.New(t)
+	device, err := db.New(dbConn).AddDevice(t.Context(), "host")
+	if err != nil {
+		t.Fatal(err)
+	}
+	topologyFile := filepath.Join(t.TempDir(), "topology.toml")
+	if err = os.WriteFile(topologyFile, []byte(topology), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewUpdateServer(topologyFile, 0, time.Second, dbConn, nil, nil, NewNotifier(dbConn), NewEventBus())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, device.ID
+}
+
+func TestServicesStreamUpdatesKnownServices(t *testing.T) {
+	s, deviceID := newTestUpdateServer(t, "")
+	query := s.query
+
+	stream := func(statuses ...string) {
+		t.Helper()
+		ctx, cancel := context.WithCancel(context.WithValue(t.Context(), agentHostnameKey{}, "host"))
+		defer cancel()
+		reqs := []*schema.ServicesStreamRequest{}
+		for _, status := range statuses {
+			reqs = append(reqs, &schema.ServicesStreamRequest{
+				Hostname: "host",
+				Services: []*schema.ServiceStatus{dockerServiceStatus("web", status)},
+			})
+		}
+		if err := s.ServicesStream(&fakeServicesStream{ctx: ctx, reqs: reqs}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkStatus := func(want string) {
+		t.Helper()
+		services, err := query.ListTrackedServicesOnDevice(t.Context(), deviceID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(services) != 1 || services[0].Status != want {
+			t.Errorf("got tracked services %+v, want one that is %s", services, want)
+		}
+	}
+
+	stream("running", "exited")
+	checkStatus("exited")
+
+	// the agent reconnects, and the first report of the service it already tracks updates it
+	stream("running")
+	checkStatus("running")
+}
+
+func TestReportInstallation(t *testing.T) {
+	s, deviceID := newTestUpdateServer(t, `
+[[baseline]]
+id = "tool"
+github_package = { name = "mpoegel/tool", asset_regex = "tool.*" }
+`)
+	ctx := context.WithValue(t.Context(), agentHostnameKey{}, "host")
+	report := func(version string, at int64, action schema.ReleaseAction, installed ...bool) {
+		t.Helper()
+		req := &schema.ReportInstallationRequest{
+			Hostname:  "host",
+			Timestamp: &timestamppb.Timestamp{Seconds: at},
+			Name:      "tool",
+			Version:   version,
+			Action:    action,
+		}
+		for i, ok := range installed {
+			req.Results = append(req.Results, &schema.AssetInstallResult{
+				Asset:       &schema.Asset{Name: fmt.Sprintf("tool-%d.deb", i)},
+				IsInstalled: ok,
+				Duration:    durationpb.New(time.Second),
+			})
+		}
+		if _, err := s.ReportInstallation(ctx, req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkInstalled := func(want string) {
+		t.Helper()
+		installed, err := s.query.ListInstalledAssetsOnDevice(t.Context(), deviceID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := ""
+		for _, release := range installed {
+			got += release.PackageName + "@" + release.Version.String
+		}
+		if got != want {
+			t.Errorf("got installed %q, want %q", got, want)
+		}
+	}
+
+	checkInstalled("")
+	report("v1", 100, schema.ReleaseAction_RELEASE_ACTION_INSTALL, true, true)
+	checkInstalled("tool@v1")
+	// the first asset of v2 installs but the second one fails, so v2 is not installed
+	report("v2", 200, schema.ReleaseAction_RELEASE_ACTION_INSTALL, true, false)
+	checkInstalled("tool@v1")
+	report("v1", 201, schema.ReleaseAction_RELEASE_ACTION_ROLLBACK, true, true)
+	checkInstalled("tool@v1")
+	report("v2", 300, schema.ReleaseAction_RELEASE_ACTION_INSTALL, true, true)
+	checkInstalled("tool@v2")
+
+	if _, err := s.ReportInstallation(context.WithValue(t.Context(), agentHostnameKey{}, "other"), &schema.ReportInstallationRequest{Hostname: "host", Name: "tool"}); err == nil {
+		t.Error("accepted a report for another host")
+	}
+}
+
+func TestServicesStreamStoresHostMetrics(t *testing.T) {
+	s, deviceID := newTestUpdateServer(t, "")
+	cpu, mem := 12.5, 40.0
+	ctx := context.WithValue(t.Context(), agentHostnameKey{}, "host")
+	reqs := []*schema.ServicesStreamRequest{
+		{Hostname: "host", HostMetrics: &schema.HostMetrics{CpuUsage: &cpu, MemUsage: &mem}},
+		// none of the readings could be taken
+		{Hostname: "host", HostMetrics: &schema.HostMetrics{}},
+	}
+	if err := s.ServicesStream(&fakeServicesStream{ctx: ctx, reqs: reqs}); err != nil {
+		t.Fatal(err)
+	}
+
+	metrics, err := s.query.ListHostMetricsOnDevice(t.Context(), db.ListHostMetricsOnDeviceParams{DeviceID: deviceID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metrics) != 2 {
+		t.Fatalf("got %d host metrics, want 2", len(metrics))
+	}
+	if m := metrics[0]; m.CpuUsage.Float64 != cpu || m.MemUsage.Float64 != mem || m.DiskUsage.Valid {
+		t.Errorf("got %+v, want cpu %v, memory %v and no disk reading", m, cpu, mem)
+	}
+	if m := metrics[1]; m.CpuUsage.Valid || m.MemUsage.Valid || m.DiskUsage.Valid {
+		t.Errorf("got %+v, want no readings", m)
+	}
+
+	if err = s.query.DeleteHostMetricsBefore(t.Context(), time.Now().Add(time.Minute).Unix()); err != nil {
+		t.Fatal(err)
+	}
+	if metrics, err = s.query.ListHostMetricsOnDevice(t.Context(), db.ListHostMetricsOnDeviceParams{DeviceID: deviceID}); err != nil || len(metrics) != 0 {
+		t.Errorf("got %v, %v after pruning, want none", metrics, err)
+	}
+}
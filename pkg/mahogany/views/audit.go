@@ -0,0 +1,138 @@
+package views
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	db "github.com/mpoegel/mahogany/internal/db"
+)
+
+const auditPageSize = 50
+
+// AuditFilter narrows the audit log. Empty fields match everything.
+type AuditFilter struct {
+	Actor      string
+	Action     string
+	Target     string
+	FailedOnly bool
+}
+
+type AuditEventView struct {
+	Time      time.Time
+	Actor     string
+	Action    string
+	Target    string
+	Params    string
+	IsSuccess bool
+	Error     string
+}
+
+type AuditView struct {
+	Events    []AuditEventView
+	Filter    AuditFilter
+	Actors    []string
+	Actions   []string
+	Page      int
+	NumPages  int
+	Total     int64
+	IsSuccess bool
+	Err       error
+	Status    *StatusView
+}
+
+func (v *AuditView) Name() string         { return "AuditView" }
+func (v *AuditView) Headers() http.Header { return http.Header{} }
+
+func (v *AuditView) HasPrev() bool   { return v.Page > 1 }
+func (v *AuditView) HasNext() bool   { return v.Page < v.NumPages }
+func (v *AuditView) PrevURL() string { return v.pageURL(v.Page - 1) }
+func (v *AuditView) NextURL() string { return v.pageURL(v.Page + 1) }
+
+// pageURL links to another page of the audit log with the same filter
+func (v *AuditView) pageURL(page int) string {
+	query := url.Values{}
+	query.Set("page", strconv.Itoa(page))
+	if len(v.Filter.Actor) > 0 {
+		query.Set("actor", v.Filter.Actor)
+	}
+	if len(v.Filter.Action) > 0 {
+		query.Set("action", v.Filter.Action)
+	}
+	if len(v.Filter.Target) > 0 {
+		query.Set("target", v.Filter.Target)
+	}
+	if v.Filter.FailedOnly {
+		query.Set("failed", "on")
+	}
+	return "/audit?" + query.Encode()
+}
+
+func newAuditEventView(event db.AuditEvent) AuditEventView {
+	view := AuditEventView{
+		Time:      time.Unix(event.CreatedAt, 0).UTC(),
+		Actor:     event.Actor,
+		Action:    event.Action,
+		Target:    event.Target,
+		IsSuccess: event.IsSuccess,
+		Error:     event.Error.String,
+	}
+	if event.Params != "{}" {
+		view.Params = event.Params
+	}
+	return view
+}
+
+// GetAudit returns one page of the audit log, newest first
+func (v *ViewFinder) GetAudit(ctx context.Context, filter AuditFilter, page int) *AuditView {
+	view := &AuditView{
+		Filter:    filter,
+		Page:      max(page, 1),
+		IsSuccess: true,
+		Status:    v.GetStatus(ctx),
+	}
+	total, err := v.query.CountAuditEvents(ctx, db.CountAuditEventsParams{
+		Actor:      filter.Actor,
+		Action:     filter.Action,
+		Target:     filter.Target,
+		FailedOnly: filter.FailedOnly,
+	})
+	if err != nil {
+		slog.Error("count audit events failed", "err", err)
+		view.IsSuccess = false
+		view.Err = err
+		return view
+	}
+	view.Total = total
+	view.NumPages = max(int((total+auditPageSize-1)/auditPageSize), 1)
+
+	events, err := v.query.ListAuditEvents(ctx, db.ListAuditEventsParams{
+		Actor:      filter.Actor,
+		Action:     filter.Action,
+		Target:     filter.Target,
+		FailedOnly: filter.FailedOnly,
+		Limit:      auditPageSize,
+		Offset:     int64(view.Page-1) * auditPageSize,
+	})
+	if err != nil {
+		slog.Error("list audit events failed", "err", err)
+		view.IsSuccess = false
+		view.Err = err
+		return view
+	}
+	view.Events = make([]AuditEventView, len(events))
+	for i, event := range events {
+		view.Events[i] = newAuditEventView(event)
+	}
+
+	if view.Actors, err = v.query.ListAuditActors(ctx); err != nil {
+		slog.Warn("list audit actors failed", "err", err)
+	}
+	if view.Actions, err = v.query.ListAuditActions(ctx); err != nil {
+		slog.Warn("list audit actions failed", "err", err)
+	}
+	return view
+}
@@ -0,0 +1,92 @@
+package views
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	db "github.com/mpoegel/mahogany/internal/db"
+	sources "github.com/mpoegel/mahogany/pkg/mahogany/sources"
+)
+
+// AgentCertificateView is a client certificate issued to the agent on a device
+type AgentCertificateView struct {
+	Serial    string    `json:"serial"`
+	IssuedAt  time.Time `json:"issuedAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	RevokedAt time.Time `json:"revokedAt,omitzero"`
+	IsRevoked bool      `json:"revoked"`
+	IsExpired bool      `json:"expired"`
+}
+
+func newAgentCertificateView(cert db.AgentCertificate, now time.Time) AgentCertificateView {
+	view := AgentCertificateView{
+		Serial:    cert.Serial,
+		IssuedAt:  time.Unix(cert.IssuedAt, 0).UTC(),
+		ExpiresAt: time.Unix(cert.ExpiresAt, 0).UTC(),
+		IsRevoked: cert.RevokedAt.Valid,
+		IsExpired: now.Unix() > cert.ExpiresAt,
+	}
+	if cert.RevokedAt.Valid {
+		view.RevokedAt = time.Unix(cert.RevokedAt.Int64, 0).UTC()
+	}
+	return view
+}
+
+func (v *ViewFinder) listDeviceCertificates(ctx context.Context, hostname string) []AgentCertificateView {
+	certs, err := v.query.ListAgentCertificatesForHost(ctx, hostname)
+	if err != nil {
+		slog.Error("list agent certificates failed", "hostname", hostname, "err", err)
+		return nil
+	}
+	now := time.Now()
+	views := make([]AgentCertificateView, len(certs))
+	for i, cert := range certs {
+		views[i] = newAgentCertificateView(cert, now)
+	}
+	return views
+}
+
+// JoinTokenView shows a new join token along with how to use it
+type JoinTokenView struct {
+	Hostname  string
+	Token     string
+	ExpiresIn time.Duration
+	Err       error
+}
+
+func (v *JoinTokenView) Name() string         { return "join-token" }
+func (v *JoinTokenView) Headers() http.Header { return http.Header{} }
+
+func (v *ViewFinder) CreateJoinToken(ctx context.Context, hostname string) *JoinTokenView {
+	view := &JoinTokenView{
+		Hostname: strings.TrimSpace(hostname),
+	}
+	token, err := v.updateServer.CreateJoinToken(ctx, view.Hostname)
+	if err != nil {
+		slog.Error("create join token failed", "hostname", view.Hostname, "err", err)
+		view.Err = err
+		return view
+	}
+	view.Token = token
+	view.ExpiresIn = sources.JoinTokenTTL
+	return view
+}
+
+func (v *ViewFinder) RevokeCertificate(ctx context.Context, serial string) *ActionResponseView {
+	view := &ActionResponseView{
+		IsSuccess: false,
+	}
+	if err := v.updateServer.RevokeCertificate(ctx, serial); err != nil {
+		slog.Error("revoke certificate failed", "serial", serial, "err", err)
+		view.Err = err
+		view.Toast = fmt.Sprintf("Revoke failed: %v", err)
+		return view
+	}
+	view.IsSuccess = true
+	view.Toast = "Certificate revoked"
+	return view
+}
@@ -0,0 +1,357 @@
+package views
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	container "github.com/docker/docker/api/types/container"
+	image "github.com/docker/docker/api/types/image"
+	network "github.com/docker/docker/api/types/network"
+	volume "github.com/docker/docker/api/types/volume"
+	jsonmessage "github.com/docker/docker/pkg/jsonmessage"
+	nat "github.com/docker/go-connections/nat"
+)
+
+// pendingDeployTTL is how long a validated deployment waits for its progress stream to start it
+const pendingDeployTTL = 10 * time.Minute
+
+var ErrDeployNotFound = errors.New("deployment not found or already started")
+
+// ContainerSpec is a container to create, in the same terms as docker run
+type ContainerSpec struct {
+	Image string `json:"image"`
+	Name  string `json:"name"`
+	// Ports are published like -p, e.g. 8080:80 or 127.0.0.1:53:53/udp
+	Ports []string `json:"ports"`
+	// Env are KEY=VALUE pairs
+	Env []string `json:"env"`
+	// Volumes are mounted like -v, e.g. data:/var/lib/data or /srv/config:/config:ro
+	Volumes       []string          `json:"volumes"`
+	Network       string            `json:"network"`
+	RestartPolicy string            `json:"restartPolicy"`
+	Labels        map[string]string `json:"labels"`
+}
+
+// dockerConfig checks the spec and turns it into the configs for creating the container
+func (s ContainerSpec) dockerConfig() (*container.Config, *container.HostConfig, *network.NetworkingConfig, error) {
+	if len(s.Image) == 0 {
+		return nil, nil, nil, errors.New("image is required")
+	}
+	exposed, bindings, err := nat.ParsePortSpecs(s.Ports)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	for _, env := range s.Env {
+		if key, _, ok := strings.Cut(env, "="); !ok || len(key) == 0 {
+			return nil, nil, nil, fmt.Errorf("invalid environment variable %q, expected KEY=VALUE", env)
+		}
+	}
+	for _, vol := range s.Volumes {
+		parts := strings.Split(vol, ":")
+		if len(parts) < 2 || len(parts) > 3 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+			return nil, nil, nil, fmt.Errorf("invalid volume %q, expected SOURCE:TARGET[:OPTIONS]", vol)
+		}
+	}
+	restartPolicy := container.RestartPolicy{Name: container.RestartPolicyMode(s.RestartPolicy)}
+	if err = container.ValidateRestartPolicy(restartPolicy); err != nil {
+		return nil, nil, nil, err
+	}
+
+	config := &container.Config{
+		Image:        s.Image,
+		Env:          s.Env,
+		Labels:       s.Labels,
+		ExposedPorts: exposed,
+	}
+	hostConfig := &container.HostConfig{
+		PortBindings:  bindings,
+		Binds:         s.Volumes,
+		RestartPolicy: restartPolicy,
+		NetworkMode:   container.NetworkMode(s.Network),
+	}
+	return config, hostConfig, &network.NetworkingConfig{}, nil
+}
+
+// auditParams is the spec as it is recorded in the audit log, which keeps only the keys of the environment since
+// its values are often secrets
+func (s ContainerSpec) auditParams() ContainerSpec {
+	keys := make([]string, len(s.Env))
+	for i, env := range s.Env {
+		keys[i], _, _ = strings.Cut(env, "=")
+	}
+	s.Env = keys
+	return s
+}
+
+// ParseContainerSpec reads the spec from the new container form, where lists are one entry per line
+func ParseContainerSpec(image, name, ports, env, volumes, network, restartPolicy, labels string) (ContainerSpec, error) {
+	spec := ContainerSpec{
+		Image:         strings.TrimSpace(image),
+		Name:          strings.TrimSpace(name),
+		Ports:         formLines(ports),
+		Env:           formLines(env),
+		Volumes:       formLines(volumes),
+		Network:       strings.TrimSpace(network),
+		RestartPolicy: strings.TrimSpace(restartPolicy),
+	}
+	var err error
+	spec.Labels, err = ParseLabels(labels)
+	return spec, err
+}
+
+// ParseLabels reads labels from a form, given one KEY=VALUE per line
+func ParseLabels(value string) (map[string]string, error) {
+	labels := map[string]string{}
+	for _, label := range formLines(value) {
+		key, value, ok := strings.Cut(label, "=")
+		if !ok || len(key) == 0 {
+			return labels, fmt.Errorf("invalid label %q, expected KEY=VALUE", label)
+		}
+		labels[key] = value
+	}
+	return labels, nil
+}
+
+func formLines(value string) []string {
+	lines := []string{}
+	for _, line := range strings.Split(value, "\n") {
+		if line = strings.TrimSpace(line); len(line) > 0 {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+type NewContainerView struct {
+	Images          []string
+	Networks        []string
+	Volumes         []string
+	RestartPolicies []string
+	Status          *StatusView
+}
+
+func (v *NewContainerView) Name() string         { return "NewContainerView" }
+func (v *NewContainerView) Headers() http.Header { return http.Header{} }
+
+// GetNewContainer lists what the new container form can suggest: the images in the registry and the existing
+// networks and volumes
+func (v *ViewFinder) GetNewContainer(ctx context.Context) *NewContainerView {
+	view := &NewContainerView{
+		Images: v.listRegistryImages(ctx),
+		RestartPolicies: []string{
+			string(container.RestartPolicyUnlessStopped),
+			string(container.RestartPolicyAlways),
+			string(container.RestartPolicyOnFailure),
+			string(container.RestartPolicyDisabled),
+		},
+		Status: v.GetStatus(ctx),
+	}
+	networks, err := v.docker.NetworkList(ctx, network.ListOptions{})
+	if err != nil {
+		slog.Warn("cannot list networks", "err", err)
+	}
+	for _, net := range networks {
+		view.Networks = append(view.Networks, net.Name)
+	}
+	volumes, err := v.docker.VolumeList(ctx, volume.ListOptions{})
+	if err != nil {
+		slog.Warn("cannot list volumes", "err", err)
+	}
+	for _, vol := range volumes.Volumes {
+		view.Volumes = append(view.Volumes, vol.Name)
+	}
+	return view
+}
+
+// listRegistryImages returns every image in the registry as a reference that docker can pull
+func (v *ViewFinder) listRegistryImages(ctx context.Context) []string {
+	catalog, err := v.registry.GetCatalog(ctx)
+	if err != nil {
+		slog.Warn("cannot list registry images", "err", err)
+		return nil
+	}
+	addr := v.getSetting(ctx, v.query, "RegistryAddr")
+	images := []string{}
+	for _, repository := range catalog.Repositories {
+		tags, err := v.registry.GetTags(ctx, repository)
+		if err != nil {
+			slog.Warn("cannot list registry tags", "repository", repository, "err", err)
+			continue
+		}
+		for _, tag := range tags.Tags {
+			images = append(images, fmt.Sprintf("%s/%s:%s", addr, repository, tag))
+		}
+	}
+	return images
+}
+
+// DeployView is a deployment that has been checked and is waiting for its progress stream
+type DeployView struct {
+	ID   string
+	Spec ContainerSpec
+	Err  error
+}
+
+func (v *DeployView) Name() string         { return "container-deploy" }
+func (v *DeployView) Headers() http.Header { return http.Header{} }
+
+type pendingDeploy struct {
+	spec      ContainerSpec
+	createdAt time.Time
+}
+
+// deployments holds deployments between the form being posted and the progress stream starting them
+type deployments struct {
+	pending map[string]pendingDeploy
+	mu      sync.Mutex
+}
+
+func (d *deployments) add(spec ContainerSpec) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := time.Now()
+	for id, deploy := range d.pending {
+		if now.Sub(deploy.createdAt) > pendingDeployTTL {
+			delete(d.pending, id)
+		}
+	}
+	id := rand.Text()
+	d.pending[id] = pendingDeploy{spec: spec, createdAt: now}
+	return id
+}
+
+func (d *deployments) take(id string) (ContainerSpec, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	deploy, ok := d.pending[id]
+	delete(d.pending, id)
+	if !ok || time.Since(deploy.createdAt) > pendingDeployTTL {
+		return ContainerSpec{}, false
+	}
+	return deploy.spec, true
+}
+
+// PrepareDeploy checks the spec so that mistakes are shown before anything is pulled
+func (v *ViewFinder) PrepareDeploy(ctx context.Context, spec ContainerSpec) *DeployView {
+	view := &DeployView{
+		Spec: spec,
+	}
+	if _, _, _, err := spec.dockerConfig(); err != nil {
+		view.Err = err
+		return view
+	}
+	view.ID = v.deployments.add(spec)
+	return view
+}
+
+// PullProgress is how far along the pull of each layer of an image is
+type PullProgress struct {
+	Image  string
+	Status string
+	Layers []LayerProgress
+}
+
+type LayerProgress struct {
+	ID      string
+	Status  string
+	Current int64
+	Total   int64
+}
+
+func (p LayerProgress) Percent() int64 {
+	if p.Total <= 0 {
+		return 0
+	}
+	return p.Current * 100 / p.Total
+}
+
+// Deploy pulls the image of the deployment, then creates and starts the container, returning its ID. The progress
+// of the pull is passed to onProgress as it comes in.
+func (v *ViewFinder) Deploy(ctx context.Context, deployID string, onProgress func(*PullProgress)) (string, error) {
+	spec, ok := v.deployments.take(deployID)
+	if !ok {
+		return "", ErrDeployNotFound
+	}
+	config, hostConfig, networkingConfig, err := spec.dockerConfig()
+	if err != nil {
+		return "", err
+	}
+
+	err = v.pullImage(ctx, spec.Image, onProgress)
+	v.audit.Record(ctx, "image.pull", spec.Image, nil, err)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := v.docker.ContainerCreate(ctx, config, hostConfig, networkingConfig, nil, spec.Name)
+	v.audit.Record(ctx, "container.create", spec.Name, spec.auditParams(), err)
+	if err != nil {
+		return "", err
+	}
+	for _, warning := range resp.Warnings {
+		slog.Warn("container created with warning", "id", resp.ID, "warning", warning)
+	}
+	err = v.docker.ContainerStart(ctx, resp.ID, container.StartOptions{})
+	v.audit.Record(ctx, "container.start", resp.ID, nil, err)
+	if err != nil {
+		return resp.ID, err
+	}
+	slog.Info("deployed container", "id", resp.ID, "name", spec.Name, "image", spec.Image)
+	return resp.ID, nil
+}
+
+func (v *ViewFinder) pullImage(ctx context.Context, ref string, onProgress func(*PullProgress)) error {
+	pull, err := v.docker.ImagePull(ctx, ref, image.PullOptions{})
+	if err != nil {
+		return err
+	}
+	defer pull.Close()
+
+	progress := &PullProgress{Image: ref}
+	layers := map[string]int{}
+	return readJSONMessages(pull, func(msg *jsonmessage.JSONMessage) {
+		if len(msg.ID) == 0 || strings.HasPrefix(msg.Status, "Pulling from") {
+			progress.Status = msg.Status
+		} else {
+			i, ok := layers[msg.ID]
+			if !ok {
+				i = len(progress.Layers)
+				layers[msg.ID] = i
+				progress.Layers = append(progress.Layers, LayerProgress{ID: msg.ID})
+			}
+			layer := &progress.Layers[i]
+			layer.Status = msg.Status
+			if msg.Progress != nil {
+				layer.Current, layer.Total = msg.Progress.Current, msg.Progress.Total
+			}
+		}
+		onProgress(progress)
+	})
+}
+
+// readJSONMessages passes each message of a docker pull or push stream to onMessage until the stream ends or reports
+// an error
+func readJSONMessages(r io.Reader, onMessage func(*jsonmessage.JSONMessage)) error {
+	decoder := json.NewDecoder(r)
+	for {
+		var msg jsonmessage.JSONMessage
+		if err := decoder.Decode(&msg); errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if msg.Error != nil {
+			return msg.Error
+		}
+		onMessage(&msg)
+	}
+}
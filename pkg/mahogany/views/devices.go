@@ -3,24 +3,31 @@ package views
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	db "github.com/mpoegel/mahogany/internal/db"
+	sources "github.com/mpoegel/mahogany/pkg/mahogany/sources"
+	schema "github.com/mpoegel/mahogany/pkg/schema"
 	vpn "github.com/mpoegel/mahogany/pkg/vpn"
 )
 
 type DevicesView struct {
-	Devices   []vpn.Device
-	Policy    *vpn.NetPolicy
-	IsSuccess bool
-	Err       error
-	Status    *StatusView
+	TemplateName string
+	Devices      []vpn.Device
+	Metrics      map[string]*HostMetricsView
+	Policy       *vpn.NetPolicy
+	IsSuccess    bool
+	Err          error
+	Status       *StatusView
 }
 
-func (v *DevicesView) Name() string         { return "DevicesView" }
+func (v *DevicesView) Name() string         { return v.TemplateName }
 func (v *DevicesView) Headers() http.Header { return http.Header{} }
 
 type DeviceView struct {
@@ -28,6 +35,11 @@ type DeviceView struct {
 	SourcePolicy *vpn.NetPolicy
 	DestPolicy   *vpn.NetPolicy
 	Assets       []DeviceAsset
+	History      []DeviceAssetEvent
+	Services     []TrackedServiceView
+	Missing      []string
+	Metrics      *HostMetricsView
+	Certificates []AgentCertificateView
 	AllPackages  []db.Package
 	IsSuccess    bool
 	Err          error
@@ -37,8 +49,20 @@ func (v *DeviceView) Name() string         { return "DeviceView" }
 func (v *DeviceView) Headers() http.Header { return http.Header{} }
 
 type DeviceAsset struct {
-	Name    string
-	Version string
+	Name        string    `json:"name"`
+	Version     string    `json:"version"`
+	InstalledAt time.Time `json:"installedAt"`
+}
+
+type DeviceAssetEvent struct {
+	Package     string        `json:"package"`
+	Asset       string        `json:"asset"`
+	Version     string        `json:"version"`
+	IsInstalled bool          `json:"installed"`
+	IsRollback  bool          `json:"rollback"`
+	Output      string        `json:"output"`
+	Duration    time.Duration `json:"durationNs"`
+	InstalledAt time.Time     `json:"installedAt"`
 }
 
 func (v *ViewFinder) syncDevices(ctx context.Context, devices []vpn.Device) error {
@@ -51,7 +75,7 @@ func (v *ViewFinder) syncDevices(ctx context.Context, devices []vpn.Device) erro
 }
 
 func (v *ViewFinder) GetDevices(ctx context.Context) *DevicesView {
-	view := &DevicesView{}
+	view := &DevicesView{TemplateName: "DevicesView"}
 	devices, err := v.deviceFinder.ListDevices(ctx)
 	if err != nil {
 		slog.Error("list devices failed", "err", err)
@@ -61,6 +85,7 @@ func (v *ViewFinder) GetDevices(ctx context.Context) *DevicesView {
 	}
 	v.syncDevices(ctx, devices)
 	view.Devices = devices
+	view.Metrics = v.listRecentMetrics(ctx)
 	view.Status = v.GetStatus(ctx)
 	policy, err := v.deviceFinder.GetACL(ctx)
 	if err != nil {
@@ -73,6 +98,21 @@ func (v *ViewFinder) GetDevices(ctx context.Context) *DevicesView {
 	return view
 }
 
+// GetDeviceList is the device list without the rest of the page, for reloading it when an agent reports in
+func (v *ViewFinder) GetDeviceList(ctx context.Context) *DevicesView {
+	view := &DevicesView{TemplateName: "device-list"}
+	devices, err := v.deviceFinder.ListDevices(ctx)
+	if err != nil {
+		slog.Error("list devices failed", "err", err)
+		view.Err = err
+		return view
+	}
+	view.Devices = devices
+	view.Metrics = v.listRecentMetrics(ctx)
+	view.IsSuccess = true
+	return view
+}
+
 func (v *ViewFinder) GetDevice(ctx context.Context, deviceID string) *DeviceView {
 	view := &DeviceView{}
 	device, err := v.deviceFinder.GetDevice(ctx, deviceID)
@@ -116,6 +156,11 @@ func (v *ViewFinder) GetDevice(ctx context.Context, deviceID string) *DeviceView
 	view.SourcePolicy = &sourceACL
 	view.DestPolicy = &destACL
 
+	view.Assets, view.History = v.listDeviceAssets(ctx, device.Hostname)
+	view.Services, view.Missing = v.listDeviceServices(ctx, device.Hostname)
+	view.Metrics = v.getDeviceMetrics(ctx, device.Hostname)
+	view.Certificates = v.listDeviceCertificates(ctx, device.Hostname)
+
 	packages, err := v.query.ListPackages(ctx)
 	if err != nil {
 		slog.Error("list packages failed", "err", err)
@@ -125,3 +170,166 @@ func (v *ViewFinder) GetDevice(ctx context.Context, deviceID string) *DeviceView
 
 	return view
 }
+
+func (v *ViewFinder) listDeviceAssets(ctx context.Context, hostname string) ([]DeviceAsset, []DeviceAssetEvent) {
+	device, err := v.query.GetDevice(ctx, hostname)
+	if err != nil {
+		slog.Warn("device not registered", "hostname", hostname, "err", err)
+		return nil, nil
+	}
+	installed, err := v.query.ListInstalledAssetsOnDevice(ctx, device.ID)
+	if err != nil {
+		slog.Error("list installed assets failed", "hostname", hostname, "err", err)
+		return nil, nil
+	}
+	assets := make([]DeviceAsset, len(installed))
+	for i, asset := range installed {
+		assets[i] = DeviceAsset{
+			Name:        asset.PackageName,
+			Version:     asset.Version.String,
+			InstalledAt: time.Unix(asset.InstalledAt, 0).UTC(),
+		}
+	}
+
+	history, err := v.query.ListAssetHistoryOnDevice(ctx, device.ID)
+	if err != nil {
+		slog.Error("list asset history failed", "hostname", hostname, "err", err)
+		return assets, nil
+	}
+	events := make([]DeviceAssetEvent, len(history))
+	for i, event := range history {
+		events[i] = DeviceAssetEvent{
+			Package:     event.PackageName,
+			Asset:       event.Name,
+			Version:     event.Version.String,
+			IsInstalled: event.IsInstalled,
+			IsRollback:  event.IsRollback,
+			Output:      event.Output.String,
+			Duration:    time.Duration(event.DurationMs) * time.Millisecond,
+			InstalledAt: time.Unix(event.InstalledAt, 0).UTC(),
+		}
+	}
+	return assets, events
+}
+
+func (v *ViewFinder) RollbackPackage(ctx context.Context, deviceID, packageName string) *ActionResponseView {
+	view := &ActionResponseView{
+		IsSuccess: false,
+	}
+	device, err := v.deviceFinder.GetDevice(ctx, deviceID)
+	if err != nil {
+		slog.Error("get device failed", "err", err)
+		view.Err = err
+		view.Toast = fmt.Sprintf("Rollback failed: %v", err)
+		return view
+	}
+	if err = v.updateServer.RollbackRelease(ctx, device.Hostname, packageName); err != nil {
+		view.Err = err
+		view.Toast = fmt.Sprintf("Rollback failed: %v", err)
+	} else {
+		view.IsSuccess = true
+		view.Toast = fmt.Sprintf("Rollback of %s requested", packageName)
+	}
+	return view
+}
+
+// PushRelease installs a github release of the package on every host that has it. The latest release is pushed if no
+// tag is given.
+func (v *ViewFinder) PushRelease(ctx context.Context, packageName, tag string) *ActionResponseView {
+	view := &ActionResponseView{
+		IsSuccess: false,
+	}
+	if err := v.updateServer.PushGithubRelease(ctx, packageName, tag); err != nil {
+		slog.Error("push release failed", "package", packageName, "tag", tag, "err", err)
+		view.Err = err
+		view.Toast = fmt.Sprintf("Push failed: %v", err)
+		return view
+	}
+	view.IsSuccess = true
+	view.Toast = fmt.Sprintf("Release of %s pushed", packageName)
+	return view
+}
+
+func (v *ViewFinder) listDeviceServices(ctx context.Context, hostname string) ([]TrackedServiceView, []string) {
+	device, err := v.query.GetDevice(ctx, hostname)
+	if err != nil {
+		return nil, nil
+	}
+	tracked, err := v.query.ListTrackedServicesOnDevice(ctx, device.ID)
+	if err != nil {
+		slog.Error("list tracked services failed", "hostname", hostname, "err", err)
+		return nil, nil
+	}
+	now := time.Now().UTC()
+	services := make([]TrackedServiceView, len(tracked))
+	for i, svc := range tracked {
+		services[i] = newTrackedServiceView(svc, now)
+	}
+	// only hosts running an agent report services, so there is nothing to compare against otherwise
+	if len(services) == 0 {
+		return services, nil
+	}
+	watched, err := v.query.ListWatchedServices(ctx)
+	if err != nil {
+		slog.Warn("cannot list watched services", "err", err)
+		return services, nil
+	}
+	return services, missingServices(watched, services)
+}
+
+var serviceActions = map[string]schema.ServiceAction{
+	"start":   schema.ServiceAction_SERVICE_ACTION_START,
+	"stop":    schema.ServiceAction_SERVICE_ACTION_STOP,
+	"restart": schema.ServiceAction_SERVICE_ACTION_RESTART,
+}
+
+// how long to wait for an agent to acknowledge a service action before reporting it as pending, which is kept short of
+// the write timeout of the http server. The outcome of pending actions is recorded in the audit log.
+const serviceActionTimeout = 2 * time.Second
+
+func (v *ViewFinder) DeviceServiceAction(ctx context.Context, serviceID, actionName string) *ActionResponseView {
+	view := &ActionResponseView{
+		IsSuccess: false,
+	}
+	action, ok := serviceActions[actionName]
+	if !ok {
+		view.Err = fmt.Errorf("%w %s", ErrUnknownServiceAction, actionName)
+		view.Toast = fmt.Sprintf("Unknown action %s", actionName)
+		return view
+	}
+	id, err := strconv.ParseInt(serviceID, 10, 64)
+	if err != nil {
+		view.Err = err
+		view.Toast = err.Error()
+		return view
+	}
+	svc, err := v.query.GetTrackedService(ctx, id)
+	if err != nil {
+		slog.Error("get tracked service failed", "id", id, "err", err)
+		view.Err = err
+		view.Toast = fmt.Sprintf("Unknown service: %v", err)
+		return view
+	}
+	device, err := v.query.GetDeviceByID(ctx, svc.DeviceID)
+	if err != nil {
+		slog.Error("get device failed", "id", svc.DeviceID, "err", err)
+		view.Err = err
+		view.Toast = fmt.Sprintf("Unknown device: %v", err)
+		return view
+	}
+
+	tctx, cancel := context.WithTimeout(ctx, serviceActionTimeout)
+	defer cancel()
+	err = v.updateServer.SendServiceAction(tctx, device.Hostname, svc.Name, svc.ContainerID.String, action)
+	if errors.Is(err, sources.ErrActionPending) {
+		view.Err = err
+		view.Toast = fmt.Sprintf("Sent %s to %s, waiting on %s to acknowledge it", actionName, svc.Name, device.Hostname)
+	} else if err != nil {
+		view.Err = err
+		view.Toast = fmt.Sprintf("Failed to %s %s: %v", actionName, svc.Name, err)
+	} else {
+		view.IsSuccess = true
+		view.Toast = fmt.Sprintf("Completed %s of %s", actionName, svc.Name)
+	}
+	return view
+}
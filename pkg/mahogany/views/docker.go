@@ -5,14 +5,39 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
+	"strings"
 
 	types "github.com/docker/docker/api/types"
 	container "github.com/docker/docker/api/types/container"
 )
 
+// ContainerRef is a container that uses an image, volume or network
+type ContainerRef struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+func newContainerRef(c types.Container) ContainerRef {
+	ref := ContainerRef{ID: c.ID, Name: c.ID[:min(len(c.ID), 12)]}
+	if len(c.Names) > 0 {
+		ref.Name = strings.TrimPrefix(c.Names[0], "/")
+	}
+	return ref
+}
+
+// containerNames lists the names of the containers for messages
+func containerNames(refs []ContainerRef) string {
+	names := make([]string, len(refs))
+	for i, ref := range refs {
+		names[i] = ref.Name
+	}
+	return strings.Join(names, ", ")
+}
+
 type ContainerView struct {
 	TemplateName  string
 	ContainerInfo types.ContainerJSON
+	CanRollback   bool
 	IsSuccess     bool
 	Err           error
 }
@@ -74,6 +99,7 @@ func (v *ViewFinder) GetContainer(ctx context.Context, containerID string) *Cont
 	}
 	return &ContainerView{
 		ContainerInfo: containerInfo,
+		CanRollback:   err == nil && v.hasRollback(ctx, strings.TrimPrefix(containerInfo.Name, "/")),
 		IsSuccess:     err == nil,
 		Err:           err,
 	}
@@ -82,6 +108,7 @@ func (v *ViewFinder) GetContainer(ctx context.Context, containerID string) *Cont
 func (v *ViewFinder) StartContainer(ctx context.Context, containerID string) *ContainerStartView {
 	opts := container.StartOptions{}
 	err := v.docker.ContainerStart(ctx, containerID, opts)
+	v.audit.Record(ctx, "container.start", containerID, nil, err)
 	return &ContainerStartView{
 		ID:        containerID,
 		IsSuccess: err == nil,
@@ -92,6 +119,7 @@ func (v *ViewFinder) StartContainer(ctx context.Context, containerID string) *Co
 func (v *ViewFinder) StopContainer(ctx context.Context, containerID string) *ContainerStopView {
 	opts := container.StopOptions{}
 	err := v.docker.ContainerStop(ctx, containerID, opts)
+	v.audit.Record(ctx, "container.stop", containerID, nil, err)
 	return &ContainerStopView{
 		ID:        containerID,
 		IsSuccess: err == nil,
@@ -102,6 +130,7 @@ func (v *ViewFinder) StopContainer(ctx context.Context, containerID string) *Con
 func (v *ViewFinder) RestartContainer(ctx context.Context, containerID string) *ContainerRestartView {
 	opts := container.StopOptions{}
 	err := v.docker.ContainerRestart(ctx, containerID, opts)
+	v.audit.Record(ctx, "container.restart", containerID, nil, err)
 	return &ContainerRestartView{
 		ID:        containerID,
 		IsSuccess: err == nil,
@@ -112,6 +141,7 @@ func (v *ViewFinder) RestartContainer(ctx context.Context, containerID string) *
 func (v *ViewFinder) RemoveContainer(ctx context.Context, containerID string) *ContainerRemoveView {
 	opts := container.RemoveOptions{}
 	err := v.docker.ContainerRemove(ctx, containerID, opts)
+	v.audit.Record(ctx, "container.remove", containerID, nil, err)
 	return &ContainerRemoveView{
 		ID:        containerID,
 		IsSuccess: err == nil,
@@ -128,3 +158,17 @@ func (v *ViewFinder) GetContainerLogs(ctx context.Context, containerID string) (
 	}
 	return v.docker.ContainerLogs(ctx, containerID, opts)
 }
+
+// OpenContainerLogs returns the logs of the container and whether it has a tty. Without a tty, docker multiplexes
+// stdout and stderr into frames that have to be split with stdcopy.
+func (v *ViewFinder) OpenContainerLogs(ctx context.Context, containerID string, opts container.LogsOptions) (io.ReadCloser, bool, error) {
+	info, err := v.docker.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return nil, false, err
+	}
+	logs, err := v.docker.ContainerLogs(ctx, containerID, opts)
+	if err != nil {
+		return nil, false, err
+	}
+	return logs, info.Config != nil && info.Config.Tty, nil
+}
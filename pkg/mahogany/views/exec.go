@@ -0,0 +1,96 @@
+package views
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	types "github.com/docker/docker/api/types"
+	container "github.com/docker/docker/api/types/container"
+	errdefs "github.com/docker/docker/errdefs"
+	sources "github.com/mpoegel/mahogany/pkg/mahogany/sources"
+)
+
+// the command run in a container's terminal unless another is asked for, since not every image has bash
+const defaultExecCmd = "/bin/sh"
+
+// ExecSession is a command running with a tty in a container. Reads return what the command writes to the tty and
+// writes go to its stdin.
+type ExecSession struct {
+	ID          string
+	ContainerID string
+	Cmd         []string
+
+	conn   types.HijackedResponse
+	docker sources.DockerI
+}
+
+func (s *ExecSession) Read(p []byte) (int, error)  { return s.conn.Reader.Read(p) }
+func (s *ExecSession) Write(p []byte) (int, error) { return s.conn.Conn.Write(p) }
+
+func (s *ExecSession) Close() error {
+	s.conn.Close()
+	return nil
+}
+
+// Resize sets the size of the tty to that of the terminal showing it
+func (s *ExecSession) Resize(ctx context.Context, cols, rows uint) error {
+	if cols == 0 || rows == 0 {
+		return nil
+	}
+	return s.docker.ContainerExecResize(ctx, s.ID, container.ResizeOptions{Height: rows, Width: cols})
+}
+
+// ExitCode returns the exit code of the command once it has finished
+func (s *ExecSession) ExitCode(ctx context.Context) (int, error) {
+	info, err := s.docker.ContainerExecInspect(ctx, s.ID)
+	if err != nil {
+		return 0, err
+	}
+	if info.Running {
+		return 0, errdefs.Conflict(errors.New("command is still running"))
+	}
+	return info.ExitCode, nil
+}
+
+// OpenExec runs the command, or a shell if it is empty, in the container with a tty of the given size and attaches
+// to it. Every command opened is recorded in the audit log, since it can do anything the container can.
+func (v *ViewFinder) OpenExec(ctx context.Context, containerID, cmd string, cols, rows uint) (*ExecSession, error) {
+	session := &ExecSession{
+		ContainerID: containerID,
+		Cmd:         strings.Fields(cmd),
+		docker:      v.docker,
+	}
+	if len(session.Cmd) == 0 {
+		session.Cmd = []string{defaultExecCmd}
+	}
+	err := v.openExec(ctx, session, cols, rows)
+	v.audit.Record(ctx, "container.exec", containerID, map[string]any{"cmd": session.Cmd, "execId": session.ID}, err)
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+func (v *ViewFinder) openExec(ctx context.Context, session *ExecSession, cols, rows uint) error {
+	opts := container.ExecOptions{
+		Tty:          true,
+		AttachStdin:  true,
+		AttachStdout: true,
+		AttachStderr: true,
+		Cmd:          session.Cmd,
+	}
+	if cols > 0 && rows > 0 {
+		opts.ConsoleSize = &[2]uint{rows, cols}
+	}
+	resp, err := v.docker.ContainerExecCreate(ctx, session.ContainerID, opts)
+	if err != nil {
+		return err
+	}
+	session.ID = resp.ID
+	session.conn, err = v.docker.ContainerExecAttach(ctx, resp.ID, container.ExecAttachOptions{
+		Tty:         true,
+		ConsoleSize: opts.ConsoleSize,
+	})
+	return err
+}
@@ -0,0 +1,230 @@
+package views
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	reference "github.com/distribution/reference"
+	container "github.com/docker/docker/api/types/container"
+	filters "github.com/docker/docker/api/types/filters"
+	image "github.com/docker/docker/api/types/image"
+	registry "github.com/docker/docker/api/types/registry"
+	jsonmessage "github.com/docker/docker/pkg/jsonmessage"
+	units "github.com/docker/go-units"
+)
+
+// the event that image actions trigger so that the image list reloads itself
+const imagesChangedEvent = "images-changed"
+
+var (
+	ErrInvalidImageReference = errors.New("invalid image reference")
+	ErrNoRegistry            = errors.New("no registry is configured")
+)
+
+// ImageSummary is an image on the docker host along with the containers that use it
+type ImageSummary struct {
+	ID         string         `json:"id"`
+	Tags       []string       `json:"tags"`
+	Size       int64          `json:"size"`
+	Created    time.Time      `json:"created"`
+	Dangling   bool           `json:"dangling"`
+	Containers []ContainerRef `json:"containers"`
+}
+
+func (i ImageSummary) HumanSize() string { return units.HumanSize(float64(i.Size)) }
+
+// Repository is the first tag of the image without its registry and tag, which it is pushed as by default
+func (i ImageSummary) Repository() string {
+	for _, tag := range i.Tags {
+		if named, err := reference.ParseNormalizedNamed(tag); err == nil {
+			return reference.Path(named)
+		}
+	}
+	return ""
+}
+
+type ImagesView struct {
+	TemplateName string
+	Images       []ImageSummary
+	RegistryAddr string
+	IsSuccess    bool
+	Err          error
+	Status       *StatusView
+}
+
+func (v *ImagesView) Name() string         { return v.TemplateName }
+func (v *ImagesView) Headers() http.Header { return http.Header{} }
+
+// TotalSize is the disk space taken by all images
+func (v *ImagesView) TotalSize() string {
+	var total int64
+	for _, img := range v.Images {
+		total += img.Size
+	}
+	return units.HumanSize(float64(total))
+}
+
+func (v *ViewFinder) GetImages(ctx context.Context) *ImagesView {
+	view := &ImagesView{
+		TemplateName: "ImagesView",
+		RegistryAddr: v.getSetting(ctx, v.query, "RegistryAddr"),
+		Status:       v.GetStatus(ctx),
+	}
+	view.Images, view.Err = v.ListImages(ctx)
+	view.IsSuccess = view.Err == nil
+	if view.Err != nil {
+		slog.Error("failed to list images", "err", view.Err)
+	}
+	return view
+}
+
+// GetImageList is the image list without the rest of the page, for reloading it after an action
+func (v *ViewFinder) GetImageList(ctx context.Context) *ImagesView {
+	view := &ImagesView{
+		TemplateName: "image-list",
+		RegistryAddr: v.getSetting(ctx, v.query, "RegistryAddr"),
+	}
+	view.Images, view.Err = v.ListImages(ctx)
+	view.IsSuccess = view.Err == nil
+	return view
+}
+
+// ListImages returns the images on the docker host, newest first
+func (v *ViewFinder) ListImages(ctx context.Context) ([]ImageSummary, error) {
+	images, err := v.docker.ImageList(ctx, image.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	containers, err := v.docker.ContainerList(ctx, container.ListOptions{All: true})
+	if err != nil {
+		return nil, err
+	}
+	users := map[string][]ContainerRef{}
+	for _, c := range containers {
+		users[c.ImageID] = append(users[c.ImageID], newContainerRef(c))
+	}
+
+	summaries := make([]ImageSummary, 0, len(images))
+	for _, img := range images {
+		tags := slices.DeleteFunc(slices.Clone(img.RepoTags), func(tag string) bool { return tag == "<none>:<none>" })
+		summary := ImageSummary{
+			ID:         img.ID,
+			Tags:       tags,
+			Size:       img.Size,
+			Created:    time.Unix(img.Created, 0).UTC(),
+			Dangling:   len(tags) == 0,
+			Containers: users[img.ID],
+		}
+		if summary.Containers == nil {
+			summary.Containers = []ContainerRef{}
+		}
+		summaries = append(summaries, summary)
+	}
+	slices.SortFunc(summaries, func(a, b ImageSummary) int { return b.Created.Compare(a.Created) })
+	return summaries, nil
+}
+
+func imagesChanged() http.Header {
+	return http.Header{"HX-Trigger": []string{imagesChangedEvent}}
+}
+
+// RemoveImage deletes the image from the docker host. Force also removes an image with several tags, or one that
+// stopped containers use.
+func (v *ViewFinder) RemoveImage(ctx context.Context, imageID string, force bool) *ActionResponseView {
+	view := &ActionResponseView{
+		IsSuccess: false,
+	}
+	_, err := v.docker.ImageRemove(ctx, imageID, image.RemoveOptions{Force: force, PruneChildren: true})
+	v.audit.Record(ctx, "image.remove", imageID, map[string]any{"force": force}, err)
+	if err != nil {
+		view.Err = err
+		view.Toast = fmt.Sprintf("Failed to remove image: %v", err)
+		return view
+	}
+	view.IsSuccess = true
+	view.Toast = fmt.Sprintf("Removed image %s", shortImageID(imageID))
+	view.headers = imagesChanged()
+	return view
+}
+
+func shortImageID(imageID string) string {
+	id := strings.TrimPrefix(imageID, "sha256:")
+	return id[:min(len(id), 12)]
+}
+
+// PruneImages removes the dangling images, or every image that no container uses if all is set
+func (v *ViewFinder) PruneImages(ctx context.Context, all bool) *ActionResponseView {
+	view := &ActionResponseView{
+		IsSuccess: false,
+	}
+	args := filters.NewArgs(filters.Arg("dangling", fmt.Sprint(!all)))
+	report, err := v.docker.ImagesPrune(ctx, args)
+	v.audit.Record(ctx, "image.prune", "images", map[string]any{"all": all, "reclaimed": report.SpaceReclaimed}, err)
+	if err != nil {
+		view.Err = err
+		view.Toast = fmt.Sprintf("Failed to prune images: %v", err)
+		return view
+	}
+	view.IsSuccess = true
+	view.Toast = fmt.Sprintf("Pruned images, reclaimed %s", units.HumanSize(float64(report.SpaceReclaimed)))
+	view.headers = imagesChanged()
+	return view
+}
+
+// PushImage tags the image as repository:tag in the configured registry and pushes it there
+func (v *ViewFinder) PushImage(ctx context.Context, imageID, repository, tag string) *ActionResponseView {
+	view := &ActionResponseView{
+		IsSuccess: false,
+	}
+	target, err := v.pushImage(ctx, imageID, repository, tag)
+	v.audit.Record(ctx, "image.push", imageID, map[string]any{"target": target}, err)
+	if err != nil {
+		view.Err = err
+		view.Toast = fmt.Sprintf("Failed to push image: %v", err)
+		return view
+	}
+	slog.Info("pushed image", "image", imageID, "target", target)
+	view.IsSuccess = true
+	view.Toast = fmt.Sprintf("Pushed %s", target)
+	view.headers = imagesChanged()
+	return view
+}
+
+func (v *ViewFinder) pushImage(ctx context.Context, imageID, repository, tag string) (string, error) {
+	addr := v.getSetting(ctx, v.query, "RegistryAddr")
+	if len(addr) == 0 {
+		return "", ErrNoRegistry
+	}
+	if len(tag) == 0 {
+		tag = "latest"
+	}
+	target := fmt.Sprintf("%s/%s:%s", addr, strings.Trim(repository, "/"), tag)
+	named, err := reference.ParseNormalizedNamed(target)
+	if err != nil {
+		return target, fmt.Errorf("%w %s: %v", ErrInvalidImageReference, target, err)
+	}
+	if _, ok := named.(reference.Tagged); !ok || reference.Domain(named) != addr {
+		return target, fmt.Errorf("%w %s", ErrInvalidImageReference, target)
+	}
+
+	if err = v.docker.ImageTag(ctx, imageID, target); err != nil {
+		return target, err
+	}
+	// the registry needs no credentials, but docker expects the header anyway
+	auth, err := registry.EncodeAuthConfig(registry.AuthConfig{})
+	if err != nil {
+		return target, err
+	}
+	push, err := v.docker.ImagePush(ctx, target, image.PushOptions{RegistryAuth: auth})
+	if err != nil {
+		return target, err
+	}
+	defer push.Close()
+	return target, readJSONMessages(push, func(*jsonmessage.JSONMessage) {})
+}
@@ -0,0 +1,142 @@
+package views
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	errdefs "github.com/docker/docker/errdefs"
+	db "github.com/mpoegel/mahogany/internal/db"
+	sources "github.com/mpoegel/mahogany/pkg/mahogany/sources"
+)
+
+// the event that resolving an incident triggers so that the incident list reloads itself
+const incidentsChangedEvent = "incidents-changed"
+
+// how long resolved incidents are still listed
+const resolvedIncidentsWindow = 7 * 24 * time.Hour
+
+// Incident is something that went wrong with a container, along with how often it happened until it was resolved
+type Incident struct {
+	ID            int64      `json:"id"`
+	Kind          string     `json:"kind"`
+	ContainerID   string     `json:"containerId"`
+	ContainerName string     `json:"containerName"`
+	Image         string     `json:"image"`
+	Message       string     `json:"message"`
+	Occurrences   int64      `json:"occurrences"`
+	StartedAt     time.Time  `json:"startedAt"`
+	LastSeenAt    time.Time  `json:"lastSeenAt"`
+	ResolvedAt    *time.Time `json:"resolvedAt,omitempty"`
+	ResolvedBy    string     `json:"resolvedBy,omitempty"`
+}
+
+func (i Incident) IsOpen() bool { return i.ResolvedAt == nil }
+
+func newIncident(incident db.Incident) Incident {
+	view := Incident{
+		ID:            incident.ID,
+		Kind:          incident.Kind,
+		ContainerID:   incident.ContainerID,
+		ContainerName: incident.ContainerName,
+		Image:         incident.Image,
+		Message:       incident.Message,
+		Occurrences:   incident.Occurrences,
+		StartedAt:     time.Unix(incident.StartedAt, 0).UTC(),
+		LastSeenAt:    time.Unix(incident.LastSeenAt, 0).UTC(),
+		ResolvedBy:    incident.ResolvedBy.String,
+	}
+	if incident.ResolvedAt.Valid {
+		resolvedAt := time.Unix(incident.ResolvedAt.Int64, 0).UTC()
+		view.ResolvedAt = &resolvedAt
+	}
+	return view
+}
+
+type IncidentsView struct {
+	TemplateName string
+	Incidents    []Incident
+	IsSuccess    bool
+	Err          error
+	Status       *StatusView
+}
+
+func (v *IncidentsView) Name() string         { return v.TemplateName }
+func (v *IncidentsView) Headers() http.Header { return http.Header{} }
+
+// MonitorIncidents records incidents from the docker events until the context is done
+func (v *ViewFinder) MonitorIncidents(ctx context.Context) {
+	v.incidents.Run(ctx)
+}
+
+func (v *ViewFinder) GetIncidents(ctx context.Context) *IncidentsView {
+	view := &IncidentsView{
+		TemplateName: "IncidentsView",
+		Status:       v.GetStatus(ctx),
+	}
+	view.Incidents, view.Err = v.ListIncidents(ctx)
+	view.IsSuccess = view.Err == nil
+	if view.Err != nil {
+		slog.Error("failed to list incidents", "err", view.Err)
+	}
+	return view
+}
+
+// GetIncidentList is the incident list without the rest of the page, for reloading it after an action
+func (v *ViewFinder) GetIncidentList(ctx context.Context) *IncidentsView {
+	view := &IncidentsView{
+		TemplateName: "incident-list",
+	}
+	view.Incidents, view.Err = v.ListIncidents(ctx)
+	view.IsSuccess = view.Err == nil
+	return view
+}
+
+// ListIncidents returns the open incidents, then those resolved in the last week, most recent first
+func (v *ViewFinder) ListIncidents(ctx context.Context) ([]Incident, error) {
+	since := time.Now().Add(-resolvedIncidentsWindow).Unix()
+	incidents, err := v.query.ListIncidents(ctx, sql.NullInt64{Int64: since, Valid: true})
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Incident, len(incidents))
+	for i, incident := range incidents {
+		list[i] = newIncident(incident)
+	}
+	return list, nil
+}
+
+// ResolveIncident closes an open incident, so that the next occurrence starts a new one
+func (v *ViewFinder) ResolveIncident(ctx context.Context, incidentID string) *ActionResponseView {
+	view := &ActionResponseView{
+		IsSuccess: false,
+	}
+	id, err := strconv.ParseInt(incidentID, 10, 64)
+	if err == nil {
+		var resolved int64
+		resolved, err = v.query.ResolveIncident(ctx, db.ResolveIncidentParams{
+			ResolvedAt: sql.NullInt64{Int64: time.Now().Unix(), Valid: true},
+			ResolvedBy: sql.NullString{String: sources.ActorFromContext(ctx), Valid: true},
+			ID:         id,
+		})
+		if err == nil && resolved == 0 {
+			err = errdefs.NotFound(errors.New("no open incident with that id"))
+		}
+	}
+	v.audit.Record(ctx, "incident.resolve", incidentID, nil, err)
+	if err != nil {
+		view.Err = err
+		view.Toast = fmt.Sprintf("Failed to resolve incident: %v", err)
+		return view
+	}
+	v.bus.Publish(sources.BusIncident, incidentID, "resolve")
+	view.IsSuccess = true
+	view.Toast = "Resolved incident"
+	view.headers = http.Header{"HX-Trigger": []string{incidentsChangedEvent}}
+	return view
+}
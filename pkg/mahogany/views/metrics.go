@@ -0,0 +1,145 @@
+package views
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	db "github.com/mpoegel/mahogany/internal/db"
+	sources "github.com/mpoegel/mahogany/pkg/mahogany/sources"
+)
+
+const (
+	// window of metrics shown next to each host on the devices page
+	devicesMetricsWindow = 1 * time.Hour
+	// most points drawn in one sparkline, longer series are averaged down to this
+	maxSparklinePoints = 120
+	// sparklines are drawn in a 100x20 view box and scaled by css
+	sparklineWidth  = 100.0
+	sparklineHeight = 20.0
+)
+
+// MetricSeries is a percentage time series ready to be drawn as an SVG polyline
+type MetricSeries struct {
+	Latest float64 `json:"latest"`
+	Peak   float64 `json:"peak"`
+	Points string  `json:"-"`
+}
+
+type HostMetricsView struct {
+	CPU         *MetricSeries `json:"cpu,omitempty"`
+	Memory      *MetricSeries `json:"memory,omitempty"`
+	Disk        *MetricSeries `json:"disk,omitempty"`
+	LastUpdated time.Time     `json:"lastUpdated"`
+}
+
+func newHostMetricsView(metrics []db.HostMetric) *HostMetricsView {
+	if len(metrics) == 0 {
+		return nil
+	}
+	var cpu, mem, disk []sql.NullFloat64
+	for _, m := range metrics {
+		cpu = append(cpu, m.CpuUsage)
+		mem = append(mem, m.MemUsage)
+		disk = append(disk, m.DiskUsage)
+	}
+	return &HostMetricsView{
+		CPU:         newMetricSeries(cpu),
+		Memory:      newMetricSeries(mem),
+		Disk:        newMetricSeries(disk),
+		LastUpdated: time.Unix(metrics[len(metrics)-1].RecordedAt, 0).UTC(),
+	}
+}
+
+// newMetricSeries draws the readings that were taken, or returns nil if there are none
+func newMetricSeries(readings []sql.NullFloat64) *MetricSeries {
+	values := []float64{}
+	for _, reading := range readings {
+		if reading.Valid {
+			values = append(values, reading.Float64)
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	series := &MetricSeries{
+		Latest: values[len(values)-1],
+	}
+	for _, val := range values {
+		series.Peak = max(series.Peak, val)
+	}
+
+	values = downsample(values, maxSparklinePoints)
+	// a single reading is drawn as a flat line across the chart
+	if len(values) == 1 {
+		values = []float64{values[0], values[0]}
+	}
+	points := make([]string, len(values))
+	for i, val := range values {
+		x := float64(i) * sparklineWidth / float64(len(values)-1)
+		y := sparklineHeight - min(max(val, 0), 100)*sparklineHeight/100
+		points[i] = fmt.Sprintf("%.2f,%.2f", x, y)
+	}
+	series.Points = strings.Join(points, " ")
+	return series
+}
+
+// downsample averages consecutive values so that at most n remain
+func downsample(values []float64, n int) []float64 {
+	if len(values) <= n {
+		return values
+	}
+	out := make([]float64, n)
+	for i := range out {
+		start := i * len(values) / n
+		end := (i + 1) * len(values) / n
+		sum := 0.0
+		for _, val := range values[start:end] {
+			sum += val
+		}
+		out[i] = sum / float64(end-start)
+	}
+	return out
+}
+
+func (v *ViewFinder) getDeviceMetrics(ctx context.Context, hostname string) *HostMetricsView {
+	device, err := v.query.GetDevice(ctx, hostname)
+	if err != nil {
+		return nil
+	}
+	metrics, err := v.query.ListHostMetricsOnDevice(ctx, db.ListHostMetricsOnDeviceParams{
+		DeviceID:   device.ID,
+		RecordedAt: time.Now().Add(-sources.HostMetricsRetention).Unix(),
+	})
+	if err != nil {
+		slog.Error("list host metrics failed", "hostname", hostname, "err", err)
+		return nil
+	}
+	return newHostMetricsView(metrics)
+}
+
+// listRecentMetrics returns the recent metrics of every host that reported any, keyed by hostname
+func (v *ViewFinder) listRecentMetrics(ctx context.Context) map[string]*HostMetricsView {
+	rows, err := v.query.ListHostMetricsSince(ctx, time.Now().Add(-devicesMetricsWindow).Unix())
+	if err != nil {
+		slog.Error("list host metrics failed", "err", err)
+		return nil
+	}
+	byHost := map[string][]db.HostMetric{}
+	for _, row := range rows {
+		byHost[row.Hostname] = append(byHost[row.Hostname], db.HostMetric{
+			RecordedAt: row.RecordedAt,
+			CpuUsage:   row.CpuUsage,
+			MemUsage:   row.MemUsage,
+			DiskUsage:  row.DiskUsage,
+		})
+	}
+	views := make(map[string]*HostMetricsView, len(byHost))
+	for hostname, metrics := range byHost {
+		views[hostname] = newHostMetricsView(metrics)
+	}
+	return views
+}
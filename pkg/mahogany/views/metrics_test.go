@@ -0,0 +1,36 @@
+package views
+
+import (
+	"database/sql"
+	"testing"
+
+	db "github.com/mpoegel/mahogany/internal/db"
+)
+
+func TestNewHostMetricsView(t *testing.T) {
+	reading := func(val float64) sql.NullFloat64 {
+		return sql.NullFloat64{Float64: val, Valid: true}
+	}
+	view := newHostMetricsView([]db.HostMetric{
+		{RecordedAt: 100, CpuUsage: reading(80), MemUsage: reading(30)},
+		{RecordedAt: 160, CpuUsage: reading(20)},
+		// a failed reading is not drawn as 0
+		{RecordedAt: 220, MemUsage: reading(50)},
+	})
+
+	if view.CPU == nil || view.CPU.Latest != 20 || view.CPU.Peak != 80 || view.CPU.Points != "0.00,4.00 100.00,16.00" {
+		t.Errorf("got cpu %+v", view.CPU)
+	}
+	if view.Memory == nil || view.Memory.Latest != 50 || view.Memory.Peak != 50 {
+		t.Errorf("got memory %+v", view.Memory)
+	}
+	if view.Disk != nil {
+		t.Errorf("got disk %+v, want none reported", view.Disk)
+	}
+	if got := view.LastUpdated.Unix(); got != 220 {
+		t.Errorf("got last updated %d, want 220", got)
+	}
+	if newHostMetricsView(nil) != nil {
+		t.Error("got a view without metrics")
+	}
+}
@@ -0,0 +1,262 @@
+package views
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+
+	container "github.com/docker/docker/api/types/container"
+	filters "github.com/docker/docker/api/types/filters"
+	network "github.com/docker/docker/api/types/network"
+	errdefs "github.com/docker/docker/errdefs"
+	sources "github.com/mpoegel/mahogany/pkg/mahogany/sources"
+)
+
+// the event that network actions trigger so that the network list reloads itself
+const networksChangedEvent = "networks-changed"
+
+// the networks that docker creates itself and that cannot be removed
+var builtinNetworks = []string{"bridge", "host", "none"}
+
+// NetworkSummary is a network along with the containers attached to it
+type NetworkSummary struct {
+	ID         string            `json:"id"`
+	Name       string            `json:"name"`
+	Driver     string            `json:"driver"`
+	Scope      string            `json:"scope"`
+	Subnets    []string          `json:"subnets"`
+	Internal   bool              `json:"internal"`
+	Builtin    bool              `json:"builtin"`
+	Created    time.Time         `json:"created"`
+	Labels     map[string]string `json:"labels"`
+	Stack      string            `json:"stack,omitempty"`
+	Containers []ContainerRef    `json:"containers"`
+}
+
+func newNetworkSummary(net network.Summary) NetworkSummary {
+	summary := NetworkSummary{
+		ID:         net.ID,
+		Name:       net.Name,
+		Driver:     net.Driver,
+		Scope:      net.Scope,
+		Subnets:    []string{},
+		Internal:   net.Internal,
+		Builtin:    slices.Contains(builtinNetworks, net.Name),
+		Created:    net.Created,
+		Labels:     net.Labels,
+		Stack:      net.Labels[sources.ComposeProjectLabel],
+		Containers: []ContainerRef{},
+	}
+	for _, config := range net.IPAM.Config {
+		if len(config.Subnet) > 0 {
+			summary.Subnets = append(summary.Subnets, config.Subnet)
+		}
+	}
+	return summary
+}
+
+type NetworksView struct {
+	TemplateName string
+	Networks     []NetworkSummary
+	IsSuccess    bool
+	Err          error
+	Status       *StatusView
+}
+
+func (v *NetworksView) Name() string         { return v.TemplateName }
+func (v *NetworksView) Headers() http.Header { return http.Header{} }
+
+// NetworkCreateView is the outcome of creating a network, shown as a toast
+type NetworkCreateView struct {
+	Network   NetworkSummary
+	IsSuccess bool
+	Toast     string
+	Err       error
+
+	headers http.Header
+}
+
+func (v *NetworkCreateView) Name() string         { return "toast" }
+func (v *NetworkCreateView) Headers() http.Header { return v.headers }
+
+func (v *ViewFinder) GetNetworks(ctx context.Context) *NetworksView {
+	view := &NetworksView{
+		TemplateName: "NetworksView",
+		Status:       v.GetStatus(ctx),
+	}
+	view.Networks, view.Err = v.ListNetworks(ctx)
+	view.IsSuccess = view.Err == nil
+	if view.Err != nil {
+		slog.Error("failed to list networks", "err", view.Err)
+	}
+	return view
+}
+
+// GetNetworkList is the network list without the rest of the page, for reloading it after an action
+func (v *ViewFinder) GetNetworkList(ctx context.Context) *NetworksView {
+	view := &NetworksView{
+		TemplateName: "network-list",
+	}
+	view.Networks, view.Err = v.ListNetworks(ctx)
+	view.IsSuccess = view.Err == nil
+	return view
+}
+
+// ListNetworks returns every network by name with the containers attached to it, stopped ones included
+func (v *ViewFinder) ListNetworks(ctx context.Context) ([]NetworkSummary, error) {
+	networks, err := v.docker.NetworkList(ctx, network.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	containers, err := v.docker.ContainerList(ctx, container.ListOptions{All: true})
+	if err != nil {
+		return nil, err
+	}
+	users := map[string][]ContainerRef{}
+	for _, c := range containers {
+		if c.NetworkSettings == nil {
+			continue
+		}
+		for name := range c.NetworkSettings.Networks {
+			users[name] = append(users[name], newContainerRef(c))
+		}
+	}
+
+	summaries := make([]NetworkSummary, 0, len(networks))
+	for _, net := range networks {
+		summary := newNetworkSummary(net)
+		if refs, ok := users[net.Name]; ok {
+			summary.Containers = refs
+		}
+		summaries = append(summaries, summary)
+	}
+	slices.SortFunc(summaries, func(a, b NetworkSummary) int { return strings.Compare(a.Name, b.Name) })
+	return summaries, nil
+}
+
+func networksChanged() http.Header {
+	return http.Header{"HX-Trigger": []string{networksChangedEvent}}
+}
+
+// CreateNetwork creates a network with the bridge driver unless another is given. Docker picks the subnet if none is
+// given.
+func (v *ViewFinder) CreateNetwork(ctx context.Context, name, driver, subnet string, internal bool, labels map[string]string) *NetworkCreateView {
+	view := &NetworkCreateView{
+		IsSuccess: false,
+	}
+	name = strings.TrimSpace(name)
+	opts := network.CreateOptions{
+		Driver:   strings.TrimSpace(driver),
+		Internal: internal,
+		Labels:   labels,
+	}
+	if subnet = strings.TrimSpace(subnet); len(subnet) > 0 {
+		if _, err := netip.ParsePrefix(subnet); err != nil {
+			view.Err = errdefs.InvalidParameter(fmt.Errorf("invalid subnet %q, expected CIDR like 172.30.0.0/24", subnet))
+		}
+		opts.IPAM = &network.IPAM{Config: []network.IPAMConfig{{Subnet: subnet}}}
+	}
+	if len(name) == 0 {
+		view.Err = errdefs.InvalidParameter(errors.New("network name is required"))
+	}
+	var resp network.CreateResponse
+	if view.Err == nil {
+		resp, view.Err = v.docker.NetworkCreate(ctx, name, opts)
+		v.audit.Record(ctx, "network.create", name, opts, view.Err)
+	}
+	if view.Err != nil {
+		view.Toast = fmt.Sprintf("Failed to create network: %v", view.Err)
+		return view
+	}
+	if len(resp.Warning) > 0 {
+		slog.Warn("network created with warning", "name", name, "warning", resp.Warning)
+	}
+	view.IsSuccess = true
+	view.Toast = fmt.Sprintf("Created network %s", name)
+	view.Network = newNetworkSummary(network.Summary{
+		ID:       resp.ID,
+		Name:     name,
+		Driver:   opts.Driver,
+		Internal: internal,
+		Labels:   labels,
+		Created:  time.Now().UTC(),
+	})
+	if opts.IPAM != nil {
+		view.Network.Subnets = []string{subnet}
+	}
+	view.headers = networksChanged()
+	return view
+}
+
+// RemoveNetwork deletes the network unless a container is attached to it. Docker only refuses to for running
+// containers, which would leave stopped containers unable to start, so those are checked too.
+func (v *ViewFinder) RemoveNetwork(ctx context.Context, networkID string) *ActionResponseView {
+	view := &ActionResponseView{
+		IsSuccess: false,
+	}
+	var err error
+	if slices.Contains(builtinNetworks, networkID) {
+		err = errdefs.Forbidden(fmt.Errorf("network %s is built into docker", networkID))
+	}
+	if err == nil {
+		var users []ContainerRef
+		users, err = v.containersUsing(ctx, filters.Arg("network", networkID))
+		if err == nil && len(users) > 0 {
+			err = errdefs.Conflict(fmt.Errorf("network %s is used by %s", networkID, containerNames(users)))
+		}
+	}
+	if err == nil {
+		err = v.docker.NetworkRemove(ctx, networkID)
+	}
+	v.audit.Record(ctx, "network.remove", networkID, nil, err)
+	if err != nil {
+		view.Err = err
+		view.Toast = fmt.Sprintf("Failed to remove network: %v", err)
+		return view
+	}
+	view.IsSuccess = true
+	view.Toast = fmt.Sprintf("Removed network %s", networkID)
+	view.headers = networksChanged()
+	return view
+}
+
+// PruneNetworks removes the local networks that no container is attached to, stopped ones included
+func (v *ViewFinder) PruneNetworks(ctx context.Context) *ActionResponseView {
+	view := &ActionResponseView{
+		IsSuccess: false,
+	}
+	networks, err := v.ListNetworks(ctx)
+	removed := []string{}
+	if err == nil {
+		var errs []error
+		for _, net := range networks {
+			if len(net.Containers) > 0 || net.Builtin || net.Scope != "local" {
+				continue
+			}
+			if err := v.docker.NetworkRemove(ctx, net.ID); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			removed = append(removed, net.Name)
+		}
+		err = errors.Join(errs...)
+	}
+	v.audit.Record(ctx, "network.prune", "networks", map[string]any{"removed": removed}, err)
+	if len(removed) > 0 {
+		view.headers = networksChanged()
+	}
+	if err != nil {
+		view.Err = err
+		view.Toast = fmt.Sprintf("Pruned %d networks, failed to remove others: %v", len(removed), err)
+		return view
+	}
+	view.IsSuccess = true
+	view.Toast = fmt.Sprintf("Pruned %d networks", len(removed))
+	return view
+}
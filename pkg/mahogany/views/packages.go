@@ -64,6 +64,11 @@ func (vf *ViewFinder) AddPackage(ctx context.Context, params db.AddPackageParams
 	}
 
 	pkg, err := vf.query.AddPackage(ctx, params)
+	vf.audit.Record(ctx, "package.add", params.Name, map[string]string{
+		"install_cmd": params.InstallCmd,
+		"update_cmd":  params.UpdateCmd,
+		"remove_cmd":  params.RemoveCmd.String,
+	}, err)
 	if err != nil {
 		slog.Error("failed to add package", "params", params, "err", err)
 		view.IsSuccess = false
@@ -90,7 +95,15 @@ func (vf *ViewFinder) DeletePackage(ctx context.Context, id string) *PackagesVie
 		return view
 	}
 
-	if err = vf.query.DeletePackage(ctx, packageID); err != nil {
+	target := id
+	for _, pkg := range view.Packages {
+		if pkg.ID == packageID {
+			target = pkg.Name
+		}
+	}
+	err = vf.query.DeletePackage(ctx, packageID)
+	vf.audit.Record(ctx, "package.delete", target, map[string]int64{"id": packageID}, err)
+	if err != nil {
 		slog.Error("failed to delete package", "id", packageID, "err", err)
 		view.IsSuccess = false
 		view.Toast = err.Error()
@@ -52,11 +52,26 @@ func (v *ViewFinder) GetRegistry(ctx context.Context) *RegistryView {
 	return view
 }
 
+func (v *ViewFinder) GetRegistryCatalog(ctx context.Context) (*sources.RegistryCatalog, error) {
+	return v.registry.GetCatalog(ctx)
+}
+
+func (v *ViewFinder) GetRegistryTags(ctx context.Context, repository string) (*sources.RegistryTags, error) {
+	return v.registry.GetTags(ctx, repository)
+}
+
+func (v *ViewFinder) GetRegistryManifest(ctx context.Context, repository, tag string) (*sources.RegistryManifest, error) {
+	return v.registry.GetManifest(ctx, repository, tag)
+}
+
 func (v *ViewFinder) DeleteRegistryImage(ctx context.Context, repository, tag string) *ActionResponseView {
 	view := &ActionResponseView{
 		IsSuccess: false,
 	}
-	if err := v.registry.DeleteImage(ctx, repository, tag); err != nil {
+	err := v.registry.DeleteImage(ctx, repository, tag)
+	v.audit.Record(ctx, "registry.image.delete", repository+":"+tag, nil, err)
+	if err != nil {
+		view.Err = err
 		view.Toast = fmt.Sprintf("Failed to delete image: %v", err)
 	} else {
 		view.IsSuccess = true
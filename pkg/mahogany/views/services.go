@@ -0,0 +1,122 @@
+package views
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	db "github.com/mpoegel/mahogany/internal/db"
+)
+
+// agents report their services every 30 seconds, so a service that has not been updated for several intervals is
+// either gone from the host or its agent is no longer connected
+const staleServiceAge = 2 * time.Minute
+
+type ServicesView struct {
+	Hosts     []ServiceHostView
+	IsSuccess bool
+	Err       error
+	Status    *StatusView
+}
+
+func (v *ServicesView) Name() string         { return "ServicesView" }
+func (v *ServicesView) Headers() http.Header { return http.Header{} }
+
+type ServiceHostView struct {
+	Hostname string               `json:"hostname"`
+	DeviceID string               `json:"deviceId,omitempty"`
+	Services []TrackedServiceView `json:"services"`
+	Missing  []string             `json:"missing"`
+}
+
+type TrackedServiceView struct {
+	ID             int64     `json:"id"`
+	Name           string    `json:"name"`
+	Status         string    `json:"status"`
+	ContainerID    string    `json:"containerId,omitempty"`
+	ContainerImage string    `json:"containerImage,omitempty"`
+	LastUpdated    time.Time `json:"lastUpdated"`
+	IsStale        bool      `json:"stale"`
+}
+
+func newTrackedServiceView(svc db.TrackedService, now time.Time) TrackedServiceView {
+	lastUpdated := time.Unix(svc.LastUpdated, 0).UTC()
+	return TrackedServiceView{
+		ID:             svc.ID,
+		Name:           svc.Name,
+		Status:         svc.Status,
+		ContainerID:    svc.ContainerID.String,
+		ContainerImage: svc.ContainerImage.String,
+		LastUpdated:    lastUpdated,
+		IsStale:        now.Sub(lastUpdated) > staleServiceAge,
+	}
+}
+
+// missingServices lists the watched services that the host has never reported. Docker reports container names with
+// a leading slash, so both forms are accepted.
+func missingServices(watched []string, services []TrackedServiceView) []string {
+	missing := []string{}
+	for _, name := range watched {
+		if !slices.ContainsFunc(services, func(svc TrackedServiceView) bool {
+			return strings.TrimPrefix(svc.Name, "/") == name
+		}) {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+func (v *ViewFinder) GetServices(ctx context.Context) *ServicesView {
+	view := &ServicesView{
+		Status: v.GetStatus(ctx),
+	}
+	services, err := v.query.ListTrackedServices(ctx)
+	if err != nil {
+		slog.Error("list tracked services failed", "err", err)
+		view.IsSuccess = false
+		view.Err = err
+		return view
+	}
+	watched, err := v.query.ListWatchedServices(ctx)
+	if err != nil {
+		slog.Warn("cannot list watched services", "err", err)
+	}
+
+	// link hosts to their device pages when the virtual network knows about them
+	deviceIDs := map[string]string{}
+	if devices, err := v.deviceFinder.ListDevices(ctx); err != nil {
+		slog.Warn("list devices failed", "err", err)
+	} else {
+		for _, device := range devices {
+			deviceIDs[device.Hostname] = device.Id
+		}
+	}
+
+	now := time.Now().UTC()
+	for _, svc := range services {
+		if len(view.Hosts) == 0 || view.Hosts[len(view.Hosts)-1].Hostname != svc.Hostname {
+			view.Hosts = append(view.Hosts, ServiceHostView{
+				Hostname: svc.Hostname,
+				DeviceID: deviceIDs[svc.Hostname],
+			})
+		}
+		host := &view.Hosts[len(view.Hosts)-1]
+		host.Services = append(host.Services, newTrackedServiceView(db.TrackedService{
+			ID:             svc.ID,
+			DeviceID:       svc.DeviceID,
+			Name:           svc.Name,
+			Status:         svc.Status,
+			LastUpdated:    svc.LastUpdated,
+			ContainerID:    svc.ContainerID,
+			ContainerImage: svc.ContainerImage,
+		}, now))
+	}
+	for i := range view.Hosts {
+		view.Hosts[i].Missing = missingServices(watched, view.Hosts[i].Services)
+	}
+	view.IsSuccess = true
+	return view
+}
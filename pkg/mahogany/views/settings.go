@@ -2,25 +2,32 @@ package views
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"strings"
 	"time"
 
+	errdefs "github.com/docker/docker/errdefs"
 	db "github.com/mpoegel/mahogany/internal/db"
 	sources "github.com/mpoegel/mahogany/pkg/mahogany/sources"
 	vpn "github.com/mpoegel/mahogany/pkg/vpn"
 )
 
 type SettingsView struct {
-	WatchtowerAddr    string
-	WatchtowerToken   string
-	WatchtowerTimeout string
-	RegistryAddr      string
-	RegistryTimeout   string
-	TailscaleApiKey   string
-	TailnetName       string
-	WatchedServices   []WatchedServiceView
-	Status            *StatusView
+	WatchtowerAddr      string
+	WatchtowerToken     string
+	WatchtowerTimeout   string
+	RegistryAddr        string
+	RegistryTimeout     string
+	TailscaleApiKey     string
+	TailnetName         string
+	GithubWebhookSecret string
+	WatchedServices     []WatchedServiceView
+	Notifications       NotificationsView
+	Status              *StatusView
 }
 
 func (v *SettingsView) Name() string         { return "SettingsView" }
@@ -29,6 +36,7 @@ func (v *SettingsView) Headers() http.Header { return http.Header{} }
 type WatchedServiceView struct {
 	Service string
 	Toast   string
+	Err     error
 
 	tmplName string
 	headers  http.Header
@@ -37,6 +45,33 @@ type WatchedServiceView struct {
 func (v *WatchedServiceView) Name() string         { return v.tmplName }
 func (v *WatchedServiceView) Headers() http.Header { return v.headers }
 
+// NotificationsView has the settings of every notification channel, and the channels each event is routed to
+type NotificationsView struct {
+	Settings map[string]string
+	Channels []string
+	Routes   []NotificationRouteView
+}
+
+type NotificationRouteView struct {
+	sources.NotificationEvent
+	Channels map[string]bool
+}
+
+func newNotificationsView(settings map[string]string) NotificationsView {
+	view := NotificationsView{
+		Settings: settings,
+		Channels: sources.NotificationChannels,
+		Routes:   make([]NotificationRouteView, len(sources.NotificationEvents)),
+	}
+	for i, event := range sources.NotificationEvents {
+		view.Routes[i] = NotificationRouteView{NotificationEvent: event, Channels: map[string]bool{}}
+		for _, channel := range strings.Split(settings[event.Setting], ",") {
+			view.Routes[i].Channels[strings.TrimSpace(channel)] = true
+		}
+	}
+	return view
+}
+
 func (v *ViewFinder) reload(ctx context.Context, query *db.Queries) error {
 	if query == nil {
 		query = v.query
@@ -50,6 +85,15 @@ func (v *ViewFinder) reload(ctx context.Context, query *db.Queries) error {
 		return err
 	}
 
+	// notifications load the settings when they are sent, this only keeps settings that cannot work from being saved
+	settings, err := listSettings(ctx, query)
+	if err != nil {
+		return err
+	}
+	if _, err = sources.NewNotificationChannels(settings); err != nil {
+		return err
+	}
+
 	v.registry = sources.NewRegistry(v.getSetting(ctx, query, "RegistryAddr"), registryTimeout)
 	v.watchtower = sources.NewWatchtower(v.getSetting(ctx, query, "WatchtowerAddr"), v.getSetting(ctx, query, "WatchtowerToken"), watchtowerTimeout)
 	v.deviceFinder = vpn.NewClient(v.getSetting(ctx, query, "TailscaleApiKey"), v.getSetting(ctx, query, "TailnetName"))
@@ -65,16 +109,34 @@ func (v *ViewFinder) getSetting(ctx context.Context, query *db.Queries, name str
 	return row.Value
 }
 
+// ListSettings returns the value of every setting by name
+func (v *ViewFinder) ListSettings(ctx context.Context) (map[string]string, error) {
+	return listSettings(ctx, v.query)
+}
+
+func listSettings(ctx context.Context, query *db.Queries) (map[string]string, error) {
+	settings, err := query.ListSettings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string, len(settings))
+	for _, setting := range settings {
+		values[setting.Name] = setting.Value
+	}
+	return values, nil
+}
+
 func (v *ViewFinder) GetSettings(ctx context.Context) *SettingsView {
 	view := &SettingsView{
-		WatchtowerAddr:    v.getSetting(ctx, v.query, "WatchtowerAddr"),
-		WatchtowerToken:   v.getSetting(ctx, v.query, "WatchtowerToken"),
-		WatchtowerTimeout: v.getSetting(ctx, v.query, "WatchtowerTimeout"),
-		RegistryAddr:      v.getSetting(ctx, v.query, "RegistryAddr"),
-		RegistryTimeout:   v.getSetting(ctx, v.query, "RegistryTimeout"),
-		TailscaleApiKey:   v.getSetting(ctx, v.query, "TailscaleApiKey"),
-		TailnetName:       v.getSetting(ctx, v.query, "TailnetName"),
-		Status:            v.GetStatus(ctx),
+		WatchtowerAddr:      v.getSetting(ctx, v.query, "WatchtowerAddr"),
+		WatchtowerToken:     v.getSetting(ctx, v.query, "WatchtowerToken"),
+		WatchtowerTimeout:   v.getSetting(ctx, v.query, "WatchtowerTimeout"),
+		RegistryAddr:        v.getSetting(ctx, v.query, "RegistryAddr"),
+		RegistryTimeout:     v.getSetting(ctx, v.query, "RegistryTimeout"),
+		TailscaleApiKey:     v.getSetting(ctx, v.query, "TailscaleApiKey"),
+		TailnetName:         v.getSetting(ctx, v.query, "TailnetName"),
+		GithubWebhookSecret: v.getSetting(ctx, v.query, "GithubWebhookSecret"),
+		Status:              v.GetStatus(ctx),
 	}
 	services, err := v.query.ListWatchedServices(ctx)
 	if err != nil {
@@ -85,23 +147,38 @@ func (v *ViewFinder) GetSettings(ctx context.Context) *SettingsView {
 			view.WatchedServices[i].Service = svc
 		}
 	}
+	if settings, err := v.ListSettings(ctx); err != nil {
+		slog.Warn("cannot list settings", "err", err)
+	} else {
+		view.Notifications = newNotificationsView(settings)
+	}
 
 	return view
 }
 
 func (v *ViewFinder) PostSettings(ctx context.Context, params db.UpdateSettingParams) error {
+	err := v.updateSetting(ctx, params)
+	// the value is left out since several settings are secrets
+	v.audit.Record(ctx, "setting.update", params.Name, nil, err)
+	return err
+}
+
+func (v *ViewFinder) updateSetting(ctx context.Context, params db.UpdateSettingParams) error {
 	tx, err := v.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
+	// rolling back after the commit is a no-op
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			slog.Warn("failed to rollback settings transaction", "err", err)
+		}
+	}()
 	query := v.query.WithTx(tx)
-	if err := query.UpdateSetting(ctx, params); err != nil {
+	if err = query.UpdateSetting(ctx, params); err != nil {
 		return err
 	}
 	if err = v.reload(ctx, query); err != nil {
-		if err := tx.Rollback(); err != nil {
-			slog.Warn("failed to rollback settings transaction", "err", err)
-		}
 		return err
 	}
 	return tx.Commit()
@@ -114,13 +191,16 @@ func (v *ViewFinder) PostSettingsWatchedService(ctx context.Context, newService
 		headers:  http.Header{},
 	}
 	if len(newService) == 0 {
-		view.Toast = "service name cannot be empty"
+		view.Err = ErrEmptyServiceName
+		view.Toast = view.Err.Error()
 		view.tmplName = "toast"
 		view.headers["HX-Retarget"] = []string{"#toast"}
 		view.headers["HX-Reswap"] = []string{"outerHTML"}
 	} else {
 		err := v.query.AddWatchedService(ctx, newService)
+		v.audit.Record(ctx, "watched_service.add", newService, nil, err)
 		if err != nil {
+			view.Err = err
 			view.Toast = err.Error()
 			view.tmplName = "toast"
 			view.headers["HX-Retarget"] = []string{"#toast"}
@@ -135,10 +215,33 @@ func (v *ViewFinder) DeleteWatchedService(ctx context.Context, serviceName strin
 		headers: http.Header{},
 	}
 	err := v.query.DeleteWatchedService(ctx, serviceName)
+	v.audit.Record(ctx, "watched_service.delete", serviceName, nil, err)
 	if err != nil {
+		view.Err = err
 		view.Toast = err.Error()
 		view.headers["HX-Retarget"] = []string{"#toast"}
 		view.headers["HX-Reswap"] = []string{"outerHTML"}
 	}
 	return view
 }
+
+// TestNotification sends a test notification to the channel, so that its settings can be checked without waiting for
+// something to go wrong
+func (v *ViewFinder) TestNotification(ctx context.Context, channel string) *ActionResponseView {
+	view := &ActionResponseView{
+		IsSuccess: false,
+	}
+	err := v.notifier.Test(ctx, channel)
+	if errors.Is(err, sources.ErrChannelNotConfigured) {
+		err = errdefs.NotFound(err)
+	}
+	v.audit.Record(ctx, "notification.test", channel, nil, err)
+	if err != nil {
+		view.Err = err
+		view.Toast = fmt.Sprintf("Failed to send test notification: %v", err)
+		return view
+	}
+	view.IsSuccess = true
+	view.Toast = fmt.Sprintf("Sent test notification to %s", channel)
+	return view
+}
@@ -0,0 +1,50 @@
+package views
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	db "github.com/mpoegel/mahogany/internal/db"
+	dbtest "github.com/mpoegel/mahogany/internal/db/dbtest"
+)
+
+func TestUpdateSetting(t *testing.T) {
+	dbConn := dbtest.New(t)
+	// a transaction that is left open holds the only connection and blocks every update after it
+	dbConn.SetMaxOpenConns(1)
+	_, err := dbConn.Exec(`CREATE TRIGGER reject_setting BEFORE UPDATE ON settings WHEN NEW.value = 'rejected'
+BEGIN SELECT RAISE(ABORT, 'setting rejected'); END`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := &ViewFinder{db: dbConn, query: db.New(dbConn)}
+
+	tests := []struct {
+		name    string
+		params  db.UpdateSettingParams
+		wantErr bool
+		want    string
+	}{
+		{"valid", db.UpdateSettingParams{Name: "RegistryTimeout", Value: "5s"}, false, "5s"},
+		{"cannot reload", db.UpdateSettingParams{Name: "RegistryTimeout", Value: "soon"}, true, "5s"},
+		{"cannot update", db.UpdateSettingParams{Name: "RegistryAddr", Value: "rejected"}, true, "localhost:5000"},
+		{"valid after a failed update", db.UpdateSettingParams{Name: "RegistryAddr", Value: "registry:5000"}, false, "registry:5000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(t.Context(), 2*time.Second)
+			defer cancel()
+			if err := v.updateSetting(ctx, tt.params); (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			setting, err := v.query.GetSetting(ctx, tt.params.Name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if setting.Value != tt.want {
+				t.Errorf("got %s=%q, want %q", tt.params.Name, setting.Value, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,881 @@
+package views
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	types "github.com/docker/docker/api/types"
+	container "github.com/docker/docker/api/types/container"
+	filters "github.com/docker/docker/api/types/filters"
+	network "github.com/docker/docker/api/types/network"
+	volume "github.com/docker/docker/api/types/volume"
+	nat "github.com/docker/go-connections/nat"
+	db "github.com/mpoegel/mahogany/internal/db"
+	sources "github.com/mpoegel/mahogany/pkg/mahogany/sources"
+)
+
+var (
+	ErrStackNotFound = errors.New("stack not found")
+	// ErrInvalidStack wraps the mistakes in a stack's name or compose file
+	ErrInvalidStack = errors.New("invalid stack")
+)
+
+// what applying a stack does to each network, volume and container
+const (
+	StackCreate    = "create"
+	StackRecreate  = "recreate"
+	StackStart     = "start"
+	StackRemove    = "remove"
+	StackUnchanged = "unchanged"
+)
+
+// StackSummary is a stack on the stacks page, either stored in mahogany, running in docker, or both
+type StackSummary struct {
+	Name       string    `json:"name"`
+	IsStored   bool      `json:"stored"`
+	Services   []string  `json:"services"`
+	Running    int       `json:"running"`
+	Containers int       `json:"containerCount"`
+	UpdatedAt  time.Time `json:"updatedAt,omitzero"`
+	DeployedAt time.Time `json:"deployedAt,omitzero"`
+}
+
+type StacksView struct {
+	Stacks []StackSummary
+	Status *StatusView
+	Err    error
+}
+
+func (v *StacksView) Name() string         { return "StacksView" }
+func (v *StacksView) Headers() http.Header { return http.Header{} }
+
+type StackView struct {
+	Stack      StackSummary
+	Compose    string
+	Containers []types.Container
+	Status     *StatusView
+	Err        error
+}
+
+func (v *StackView) Name() string         { return "StackView" }
+func (v *StackView) Headers() http.Header { return http.Header{} }
+
+// StackChange is one step of applying a stack
+type StackChange struct {
+	Kind    string   `json:"kind"`
+	Name    string   `json:"name"`
+	Action  string   `json:"action"`
+	Details []string `json:"details,omitempty"`
+	IsDone  bool     `json:"done"`
+	Err     error    `json:"-"`
+
+	// the docker ID of what is being changed, if it exists
+	id       string
+	service  *stackService
+	resource *stackResource
+}
+
+func (c StackChange) MarshalJSON() ([]byte, error) {
+	type change StackChange
+	errMsg := ""
+	if c.Err != nil {
+		errMsg = c.Err.Error()
+	}
+	return json.Marshal(struct {
+		change
+		Error string `json:"error,omitempty"`
+	}{change(c), errMsg})
+}
+
+// StackPlanView is what applying a stack will change or, once applied, what it did
+type StackPlanView struct {
+	Stack     string        `json:"stack"`
+	Changes   []StackChange `json:"changes"`
+	Warnings  []string      `json:"warnings,omitempty"`
+	IsApplied bool          `json:"applied"`
+	Err       error         `json:"-"`
+}
+
+func (v *StackPlanView) Name() string         { return "stack-plan" }
+func (v *StackPlanView) Headers() http.Header { return http.Header{} }
+
+// HasChanges is whether applying the plan would do anything
+func (v *StackPlanView) HasChanges() bool {
+	return slices.ContainsFunc(v.Changes, func(c StackChange) bool { return c.Action != StackUnchanged })
+}
+
+// stackResource is a network or volume of a stack with the name docker knows it by
+type stackResource struct {
+	key      string
+	name     string
+	driver   string
+	external bool
+	labels   map[string]string
+}
+
+// stackService is a service of a stack turned into what docker needs to create its container
+type stackService struct {
+	name          string
+	containerName string
+	config        *container.Config
+	hostConfig    *container.HostConfig
+	networking    *network.NetworkingConfig
+	hash          string
+}
+
+// stackProject is a compose file resolved for a stack, with its services in the order they are started
+type stackProject struct {
+	name     string
+	file     *sources.ComposeFile
+	networks []stackResource
+	volumes  []stackResource
+	services []*stackService
+}
+
+func newStackProject(name string, file *sources.ComposeFile) (*stackProject, error) {
+	project := &stackProject{
+		name: name,
+		file: file,
+	}
+	usesDefault := false
+	for _, service := range file.Services {
+		usesDefault = usesDefault || len(service.Networks) == 0 || slices.Contains(service.Networks, "default")
+	}
+	if _, ok := file.Networks["default"]; usesDefault && !ok {
+		file.Networks["default"] = &sources.ComposeResource{}
+	}
+	for _, key := range slices.Sorted(maps.Keys(file.Networks)) {
+		project.networks = append(project.networks, project.resource(key, file.Networks[key], sources.ComposeNetworkLabel))
+	}
+	for _, key := range slices.Sorted(maps.Keys(file.Volumes)) {
+		project.volumes = append(project.volumes, project.resource(key, file.Volumes[key], sources.ComposeVolumeLabel))
+	}
+
+	order, err := file.ServiceOrder()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range order {
+		service, err := project.service(name, file.Services[name])
+		if err != nil {
+			return nil, fmt.Errorf("service %q: %w", name, err)
+		}
+		project.services = append(project.services, service)
+	}
+	return project, nil
+}
+
+// resource names a network or volume the way docker compose does, prefixed with the project unless it is external
+// or named explicitly
+func (p *stackProject) resource(key string, resource *sources.ComposeResource, keyLabel string) stackResource {
+	r := stackResource{
+		key:      key,
+		name:     resource.Name,
+		driver:   resource.Driver,
+		external: resource.External,
+		labels:   map[string]string{},
+	}
+	if len(r.name) == 0 && r.external {
+		r.name = key
+	} else if len(r.name) == 0 {
+		r.name = p.name + "_" + key
+	}
+	maps.Copy(r.labels, resource.Labels)
+	r.labels[sources.ComposeProjectLabel] = p.name
+	r.labels[keyLabel] = key
+	return r
+}
+
+func (p *stackProject) networkName(key string) string {
+	for _, net := range p.networks {
+		if net.key == key {
+			return net.name
+		}
+	}
+	return key
+}
+
+func (p *stackProject) volumeName(key string) (string, bool) {
+	for _, vol := range p.volumes {
+		if vol.key == key {
+			return vol.name, true
+		}
+	}
+	return "", false
+}
+
+func (p *stackProject) service(name string, service *sources.ComposeService) (*stackService, error) {
+	s := &stackService{
+		name:          name,
+		containerName: service.ContainerName,
+	}
+	if len(s.containerName) == 0 {
+		s.containerName = fmt.Sprintf("%s-%s-1", p.name, name)
+	}
+
+	exposed, bindings, err := nat.ParsePortSpecs(service.Ports)
+	if err != nil {
+		return nil, err
+	}
+	config := &container.Config{
+		Image:        service.Image,
+		Cmd:          []string(service.Command),
+		Entrypoint:   []string(service.Entrypoint),
+		Env:          service.Environment.List(),
+		Labels:       map[string]string{},
+		ExposedPorts: exposed,
+		Hostname:     service.Hostname,
+		User:         service.User,
+		WorkingDir:   service.WorkingDir,
+		Volumes:      map[string]struct{}{},
+	}
+	maps.Copy(config.Labels, service.Labels)
+	config.Labels[sources.ComposeProjectLabel] = p.name
+	config.Labels[sources.ComposeServiceLabel] = name
+	config.Labels[sources.ComposeNumberLabel] = "1"
+	config.Labels[sources.ComposeOneoffLabel] = "False"
+
+	restartPolicy, err := parseRestartPolicy(service.Restart)
+	if err != nil {
+		return nil, err
+	}
+	hostConfig := &container.HostConfig{
+		PortBindings:  bindings,
+		RestartPolicy: restartPolicy,
+	}
+	for _, vol := range service.Volumes {
+		source, target, ok := strings.Cut(vol, ":")
+		switch {
+		case !ok:
+			// an anonymous volume
+			config.Volumes[vol] = struct{}{}
+		case strings.HasPrefix(source, "/"):
+			hostConfig.Binds = append(hostConfig.Binds, vol)
+		case strings.HasPrefix(source, ".") || strings.HasPrefix(source, "~"):
+			return nil, fmt.Errorf("volume %q: relative paths are not supported, use an absolute path", vol)
+		default:
+			volumeName, ok := p.volumeName(source)
+			if !ok {
+				return nil, fmt.Errorf("volume %q is not declared under volumes", source)
+			}
+			hostConfig.Binds = append(hostConfig.Binds, volumeName+":"+target)
+		}
+	}
+
+	networks := service.Networks
+	if len(networks) == 0 {
+		networks = []string{"default"}
+	}
+	networking := &network.NetworkingConfig{
+		EndpointsConfig: map[string]*network.EndpointSettings{},
+	}
+	for _, key := range networks {
+		networking.EndpointsConfig[p.networkName(key)] = &network.EndpointSettings{
+			Aliases: []string{name},
+		}
+	}
+	hostConfig.NetworkMode = container.NetworkMode(p.networkName(networks[0]))
+
+	// the hash covers everything about the container, so a container whose hash label matches needs no change
+	hashed, err := json.Marshal([]any{config, hostConfig, networking})
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(hashed)
+	s.hash = hex.EncodeToString(sum[:])
+	config.Labels[sources.StackConfigHashLabel] = s.hash
+
+	s.config, s.hostConfig, s.networking = config, hostConfig, networking
+	return s, nil
+}
+
+// parseRestartPolicy reads the restart policy of a compose service, e.g. unless-stopped or on-failure:3
+func parseRestartPolicy(restart string) (container.RestartPolicy, error) {
+	name, retries, hasRetries := strings.Cut(restart, ":")
+	policy := container.RestartPolicy{Name: container.RestartPolicyMode(name)}
+	if hasRetries {
+		count, err := strconv.Atoi(retries)
+		if err != nil {
+			return policy, fmt.Errorf("invalid restart policy %q", restart)
+		}
+		policy.MaximumRetryCount = count
+	}
+	return policy, container.ValidateRestartPolicy(policy)
+}
+
+func (v *ViewFinder) listStackContainers(ctx context.Context, name string) ([]types.Container, error) {
+	return v.docker.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", sources.ComposeProjectLabel+"="+name)),
+	})
+}
+
+// GetStacks groups the containers by the compose project they belong to, along with the stacks stored in mahogany
+// that are not running
+func (v *ViewFinder) GetStacks(ctx context.Context) *StacksView {
+	view := &StacksView{
+		Status: v.GetStatus(ctx),
+	}
+	stacks, err := v.listStacks(ctx)
+	if err != nil {
+		slog.Error("failed to list stacks", "err", err)
+		view.Err = err
+	}
+	view.Stacks = stacks
+	return view
+}
+
+func (v *ViewFinder) listStacks(ctx context.Context) ([]StackSummary, error) {
+	summaries := map[string]*StackSummary{}
+	summary := func(name string) *StackSummary {
+		if _, ok := summaries[name]; !ok {
+			summaries[name] = &StackSummary{Name: name, Services: []string{}}
+		}
+		return summaries[name]
+	}
+
+	stored, err := v.query.ListStacks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, stack := range stored {
+		s := summary(stack.Name)
+		s.IsStored = true
+		s.UpdatedAt = time.Unix(stack.UpdatedAt, 0).UTC()
+		if stack.DeployedAt.Valid {
+			s.DeployedAt = time.Unix(stack.DeployedAt.Int64, 0).UTC()
+		}
+	}
+
+	containers, err := v.docker.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", sources.ComposeProjectLabel)),
+	})
+	if err != nil {
+		slog.Warn("cannot list stack containers", "err", err)
+	}
+	for _, ctr := range containers {
+		s := summary(ctr.Labels[sources.ComposeProjectLabel])
+		s.Containers++
+		if ctr.State == "running" {
+			s.Running++
+		}
+		if service := ctr.Labels[sources.ComposeServiceLabel]; !slices.Contains(s.Services, service) {
+			s.Services = append(s.Services, service)
+		}
+	}
+
+	list := make([]StackSummary, 0, len(summaries))
+	for _, name := range slices.Sorted(maps.Keys(summaries)) {
+		slices.Sort(summaries[name].Services)
+		list = append(list, *summaries[name])
+	}
+	return list, nil
+}
+
+func (v *ViewFinder) GetStack(ctx context.Context, name string) *StackView {
+	view := &StackView{
+		Stack:  StackSummary{Name: name, Services: []string{}},
+		Status: v.GetStatus(ctx),
+	}
+	stack, err := v.query.GetStack(ctx, name)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		view.Err = err
+		return view
+	}
+	if err == nil {
+		view.Stack.IsStored = true
+		view.Stack.UpdatedAt = time.Unix(stack.UpdatedAt, 0).UTC()
+		if stack.DeployedAt.Valid {
+			view.Stack.DeployedAt = time.Unix(stack.DeployedAt.Int64, 0).UTC()
+		}
+		view.Compose = stack.Compose
+	}
+
+	view.Containers, err = v.listStackContainers(ctx, name)
+	if err != nil {
+		slog.Warn("cannot list stack containers", "stack", name, "err", err)
+	}
+	if !view.Stack.IsStored && len(view.Containers) == 0 {
+		view.Err = ErrStackNotFound
+		return view
+	}
+	for _, ctr := range view.Containers {
+		view.Stack.Containers++
+		if ctr.State == "running" {
+			view.Stack.Running++
+		}
+		if service := ctr.Labels[sources.ComposeServiceLabel]; !slices.Contains(view.Stack.Services, service) {
+			view.Stack.Services = append(view.Stack.Services, service)
+		}
+	}
+	slices.Sort(view.Stack.Services)
+	return view
+}
+
+// SaveStack stores the compose file of the stack without deploying it
+func (v *ViewFinder) SaveStack(ctx context.Context, name, compose string) *ActionResponseView {
+	view := &ActionResponseView{
+		headers: http.Header{},
+	}
+	err := v.saveStack(ctx, name, compose)
+	v.audit.Record(ctx, "stack.save", name, nil, err)
+	if err != nil {
+		slog.Warn("failed to save stack", "stack", name, "err", err)
+		view.Err = err
+		view.Toast = fmt.Sprintf("Save failed: %v", err)
+		return view
+	}
+	view.IsSuccess = true
+	view.Toast = "Saved"
+	view.headers["HX-Redirect"] = []string{"/stack/" + name}
+	return view
+}
+
+func (v *ViewFinder) saveStack(ctx context.Context, name, compose string) error {
+	if _, _, err := v.loadStackProject(name, compose); err != nil {
+		return err
+	}
+	now := time.Now().Unix()
+	return v.query.SaveStack(ctx, db.SaveStackParams{
+		Name:      name,
+		Compose:   compose,
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+}
+
+// DeleteStack forgets the compose file of the stack. Its containers are left alone, use DownStack to remove them.
+func (v *ViewFinder) DeleteStack(ctx context.Context, name string) *ActionResponseView {
+	view := &ActionResponseView{
+		headers: http.Header{},
+	}
+	rows, err := v.query.DeleteStack(ctx, name)
+	if err == nil && rows == 0 {
+		err = ErrStackNotFound
+	}
+	v.audit.Record(ctx, "stack.delete", name, nil, err)
+	if err != nil {
+		view.Err = err
+		view.Toast = fmt.Sprintf("Delete failed: %v", err)
+		return view
+	}
+	view.IsSuccess = true
+	view.Toast = "Deleted"
+	view.headers["HX-Redirect"] = []string{"/stacks"}
+	return view
+}
+
+// PlanStack shows what deploying the compose file would change, without changing anything
+func (v *ViewFinder) PlanStack(ctx context.Context, name, compose string) *StackPlanView {
+	view := &StackPlanView{
+		Stack: name,
+	}
+	project, warnings, err := v.loadStackProject(name, compose)
+	view.Warnings = warnings
+	if err != nil {
+		view.Err = err
+		return view
+	}
+	view.Changes, view.Err = v.planStack(ctx, project, v.deployedCompose(ctx, name))
+	return view
+}
+
+// DeployStack saves the compose file, then creates, updates and removes whatever it takes for the stack to match it
+func (v *ViewFinder) DeployStack(ctx context.Context, name, compose string) *StackPlanView {
+	view := v.PlanStack(ctx, name, compose)
+	if view.Err == nil {
+		view.Err = v.saveStack(ctx, name, compose)
+	}
+	if view.Err == nil {
+		view.IsApplied = true
+		view.Err = v.applyStack(ctx, view.Changes)
+	}
+	if view.Err == nil {
+		err := v.query.SetStackDeployed(ctx, db.SetStackDeployedParams{
+			DeployedCompose: sql.NullString{String: compose, Valid: true},
+			DeployedAt:      sql.NullInt64{Int64: time.Now().Unix(), Valid: true},
+			Name:            name,
+		})
+		if err != nil {
+			slog.Warn("cannot record stack deployment", "stack", name, "err", err)
+		}
+	}
+	v.audit.Record(ctx, "stack.deploy", name, stackChangeCounts(view.Changes), view.Err)
+	if view.Err != nil {
+		slog.Error("failed to deploy stack", "stack", name, "err", view.Err)
+	} else {
+		slog.Info("deployed stack", "stack", name, "changes", len(view.Changes))
+	}
+	return view
+}
+
+// DownStack removes the containers and networks of the stack. Volumes are kept, like docker compose down does.
+func (v *ViewFinder) DownStack(ctx context.Context, name string) *StackPlanView {
+	view := &StackPlanView{
+		Stack:     name,
+		IsApplied: true,
+	}
+	containers, err := v.listStackContainers(ctx, name)
+	if err != nil {
+		view.Err = err
+		return view
+	}
+	// dependents are removed before the services they depend on. Services that are not in the compose file go
+	// first, and otherwise the order docker lists them in is kept, which is newest first.
+	ranks := v.stackServiceRanks(ctx, name)
+	rank := func(ctr types.Container) int {
+		if rank, ok := ranks[ctr.Labels[sources.ComposeServiceLabel]]; ok {
+			return rank
+		}
+		return len(ranks)
+	}
+	slices.SortStableFunc(containers, func(a, b types.Container) int {
+		return cmp.Compare(rank(b), rank(a))
+	})
+	for _, ctr := range containers {
+		view.Changes = append(view.Changes, StackChange{
+			Kind:   "container",
+			Name:   containerName(ctr),
+			Action: StackRemove,
+			id:     ctr.ID,
+		})
+	}
+	networks, err := v.docker.NetworkList(ctx, network.ListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", sources.ComposeProjectLabel+"="+name)),
+	})
+	if err != nil {
+		view.Err = err
+		return view
+	}
+	for _, net := range networks {
+		view.Changes = append(view.Changes, StackChange{
+			Kind:   "network",
+			Name:   net.Name,
+			Action: StackRemove,
+			id:     net.ID,
+		})
+	}
+
+	view.Err = v.applyStack(ctx, view.Changes)
+	if view.Err == nil {
+		err = v.query.SetStackDeployed(ctx, db.SetStackDeployedParams{Name: name})
+		if err != nil {
+			slog.Warn("cannot record stack removal", "stack", name, "err", err)
+		}
+	}
+	v.audit.Record(ctx, "stack.down", name, stackChangeCounts(view.Changes), view.Err)
+	return view
+}
+
+// stackServiceRanks is the position of each service in the order the stack deploys them, from the compose file it
+// was last deployed with or else the stored one
+func (v *ViewFinder) stackServiceRanks(ctx context.Context, name string) map[string]int {
+	ranks := map[string]int{}
+	file := v.deployedCompose(ctx, name)
+	if file == nil {
+		compose, err := v.StoredCompose(ctx, name)
+		if err != nil {
+			return ranks
+		}
+		if file, _, err = sources.ParseCompose([]byte(compose)); err != nil {
+			return ranks
+		}
+	}
+	order, err := file.ServiceOrder()
+	if err != nil {
+		return ranks
+	}
+	for i, service := range order {
+		ranks[service] = i
+	}
+	return ranks
+}
+
+func (v *ViewFinder) loadStackProject(name, compose string) (*stackProject, []string, error) {
+	if err := sources.ValidateComposeProject(name); err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrInvalidStack, err)
+	}
+	file, warnings, err := sources.ParseCompose([]byte(compose))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrInvalidStack, err)
+	}
+	project, err := newStackProject(name, file)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrInvalidStack, err)
+	}
+	return project, warnings, nil
+}
+
+// StoredCompose returns the compose file stored for the stack
+func (v *ViewFinder) StoredCompose(ctx context.Context, name string) (string, error) {
+	stack, err := v.query.GetStack(ctx, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrStackNotFound
+	}
+	return stack.Compose, err
+}
+
+// deployedCompose is the compose file the stack was last deployed with, if it was deployed by mahogany
+func (v *ViewFinder) deployedCompose(ctx context.Context, name string) *sources.ComposeFile {
+	stack, err := v.query.GetStack(ctx, name)
+	if err != nil || !stack.DeployedCompose.Valid {
+		return nil
+	}
+	file, _, err := sources.ParseCompose([]byte(stack.DeployedCompose.String))
+	if err != nil {
+		return nil
+	}
+	return file
+}
+
+// planStack compares the project to what is running. The changes are in the order they have to be applied.
+func (v *ViewFinder) planStack(ctx context.Context, project *stackProject, deployed *sources.ComposeFile) ([]StackChange, error) {
+	changes := []StackChange{}
+
+	networks, err := v.docker.NetworkList(ctx, network.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	existingNetworks := map[string]string{}
+	for _, net := range networks {
+		existingNetworks[net.Name] = net.ID
+	}
+	for i, net := range project.networks {
+		change := StackChange{Kind: "network", Name: net.name, Action: StackUnchanged, resource: &project.networks[i]}
+		if _, ok := existingNetworks[net.name]; !ok && net.external {
+			return nil, fmt.Errorf("%w: external network %q does not exist", ErrInvalidStack, net.name)
+		} else if !ok {
+			change.Action = StackCreate
+		}
+		changes = append(changes, change)
+	}
+
+	volumes, err := v.docker.VolumeList(ctx, volume.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	existingVolumes := map[string]bool{}
+	for _, vol := range volumes.Volumes {
+		existingVolumes[vol.Name] = true
+	}
+	for i, vol := range project.volumes {
+		change := StackChange{Kind: "volume", Name: vol.name, Action: StackUnchanged, resource: &project.volumes[i]}
+		if !existingVolumes[vol.name] && vol.external {
+			return nil, fmt.Errorf("%w: external volume %q does not exist", ErrInvalidStack, vol.name)
+		} else if !existingVolumes[vol.name] {
+			change.Action = StackCreate
+		}
+		changes = append(changes, change)
+	}
+
+	containers, err := v.listStackContainers(ctx, project.name)
+	if err != nil {
+		return nil, err
+	}
+	existing := map[string]types.Container{}
+	for _, ctr := range containers {
+		service := ctr.Labels[sources.ComposeServiceLabel]
+		if _, ok := project.file.Services[service]; !ok {
+			changes = append(changes, StackChange{
+				Kind:    "container",
+				Name:    containerName(ctr),
+				Action:  StackRemove,
+				Details: []string{fmt.Sprintf("service %q is no longer in the compose file", service)},
+				id:      ctr.ID,
+			})
+		} else if _, ok := existing[service]; ok {
+			changes = append(changes, StackChange{
+				Kind:    "container",
+				Name:    containerName(ctr),
+				Action:  StackRemove,
+				Details: []string{fmt.Sprintf("service %q has more than one container", service)},
+				id:      ctr.ID,
+			})
+		} else {
+			existing[service] = ctr
+		}
+	}
+	for _, service := range project.services {
+		change := StackChange{
+			Kind:    "container",
+			Name:    service.containerName,
+			Action:  StackCreate,
+			service: service,
+		}
+		if ctr, ok := existing[service.name]; ok {
+			change.id = ctr.ID
+			switch {
+			case ctr.Labels[sources.StackConfigHashLabel] != service.hash:
+				change.Action = StackRecreate
+				if deployed != nil && deployed.Services[service.name] != nil {
+					change.Details = diffComposeService(deployed.Services[service.name], project.file.Services[service.name])
+				}
+				if len(change.Details) == 0 {
+					change.Details = []string{"the configuration changed"}
+				}
+			case ctr.State != "running":
+				change.Action = StackStart
+			default:
+				change.Action = StackUnchanged
+			}
+		}
+		changes = append(changes, change)
+	}
+
+	// networks are removed last, once no container of the stack is using them
+	for _, net := range networks {
+		if net.Labels[sources.ComposeProjectLabel] != project.name {
+			continue
+		}
+		if !slices.ContainsFunc(project.networks, func(r stackResource) bool { return r.name == net.Name }) {
+			changes = append(changes, StackChange{Kind: "network", Name: net.Name, Action: StackRemove, id: net.ID})
+		}
+	}
+	return changes, nil
+}
+
+// applyStack makes the changes in order, stopping at the first one that fails
+func (v *ViewFinder) applyStack(ctx context.Context, changes []StackChange) error {
+	for i := range changes {
+		change := &changes[i]
+		if change.Action == StackUnchanged {
+			continue
+		}
+		change.Err = v.applyStackChange(ctx, change)
+		if change.Err != nil {
+			return fmt.Errorf("%s %s %s: %w", change.Action, change.Kind, change.Name, change.Err)
+		}
+		change.IsDone = true
+		slog.Info("applied stack change", "kind", change.Kind, "name", change.Name, "action", change.Action)
+	}
+	return nil
+}
+
+func (v *ViewFinder) applyStackChange(ctx context.Context, change *StackChange) error {
+	switch {
+	case change.Kind == "network" && change.Action == StackCreate:
+		resp, err := v.docker.NetworkCreate(ctx, change.resource.name, network.CreateOptions{
+			Driver: change.resource.driver,
+			Labels: change.resource.labels,
+		})
+		change.id = resp.ID
+		return err
+	case change.Kind == "network" && change.Action == StackRemove:
+		return v.docker.NetworkRemove(ctx, change.id)
+	case change.Kind == "volume" && change.Action == StackCreate:
+		_, err := v.docker.VolumeCreate(ctx, volume.CreateOptions{
+			Name:   change.resource.name,
+			Driver: change.resource.driver,
+			Labels: change.resource.labels,
+		})
+		return err
+	case change.Kind == "container" && change.Action == StackRemove:
+		return v.docker.ContainerRemove(ctx, change.id, container.RemoveOptions{Force: true})
+	case change.Kind == "container" && change.Action == StackStart:
+		return v.docker.ContainerStart(ctx, change.id, container.StartOptions{})
+	case change.Kind == "container" && change.Action == StackRecreate:
+		if err := v.docker.ContainerStop(ctx, change.id, container.StopOptions{}); err != nil {
+			return err
+		}
+		if err := v.docker.ContainerRemove(ctx, change.id, container.RemoveOptions{}); err != nil {
+			return err
+		}
+		return v.createStackContainer(ctx, change)
+	case change.Kind == "container" && change.Action == StackCreate:
+		return v.createStackContainer(ctx, change)
+	}
+	return fmt.Errorf("cannot %s a %s", change.Action, change.Kind)
+}
+
+func (v *ViewFinder) createStackContainer(ctx context.Context, change *StackChange) error {
+	service := change.service
+	// the image may only exist locally, in which case creating the container still works
+	if err := v.pullImage(ctx, service.config.Image, func(*PullProgress) {}); err != nil {
+		slog.Warn("cannot pull stack image", "image", service.config.Image, "err", err)
+	}
+	resp, err := v.docker.ContainerCreate(ctx, service.config, service.hostConfig, service.networking, nil, service.containerName)
+	if err != nil {
+		return err
+	}
+	change.id = resp.ID
+	return v.docker.ContainerStart(ctx, resp.ID, container.StartOptions{})
+}
+
+func stackChangeCounts(changes []StackChange) map[string]int {
+	counts := map[string]int{}
+	for _, change := range changes {
+		if change.Action != StackUnchanged {
+			counts[change.Action]++
+		}
+	}
+	return counts
+}
+
+func containerName(ctr types.Container) string {
+	if len(ctr.Names) == 0 {
+		return ctr.ID
+	}
+	return strings.TrimPrefix(ctr.Names[0], "/")
+}
+
+// diffComposeService describes how a service changed, leaving out the values of environment variables since they
+// often hold secrets
+func diffComposeService(old, new *sources.ComposeService) []string {
+	details := []string{}
+	diffValue := func(field, a, b string) {
+		if a != b {
+			details = append(details, fmt.Sprintf("%s: %q → %q", field, a, b))
+		}
+	}
+	diffList := func(field string, a, b []string) {
+		for _, item := range b {
+			if !slices.Contains(a, item) {
+				details = append(details, fmt.Sprintf("%s: +%s", field, item))
+			}
+		}
+		for _, item := range a {
+			if !slices.Contains(b, item) {
+				details = append(details, fmt.Sprintf("%s: -%s", field, item))
+			}
+		}
+	}
+	diffValue("image", old.Image, new.Image)
+	diffValue("container_name", old.ContainerName, new.ContainerName)
+	diffValue("command", strings.Join(old.Command, " "), strings.Join(new.Command, " "))
+	diffValue("entrypoint", strings.Join(old.Entrypoint, " "), strings.Join(new.Entrypoint, " "))
+	for _, key := range slices.Sorted(maps.Keys(new.Environment)) {
+		if value, ok := old.Environment[key]; !ok {
+			details = append(details, fmt.Sprintf("environment: +%s", key))
+		} else if value != new.Environment[key] {
+			details = append(details, fmt.Sprintf("environment: %s changed", key))
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(old.Environment)) {
+		if _, ok := new.Environment[key]; !ok {
+			details = append(details, fmt.Sprintf("environment: -%s", key))
+		}
+	}
+	diffList("ports", old.Ports, new.Ports)
+	diffList("volumes", old.Volumes, new.Volumes)
+	diffList("networks", old.Networks, new.Networks)
+	diffValue("restart", old.Restart, new.Restart)
+	diffList("labels", old.Labels.List(), new.Labels.List())
+	diffValue("user", old.User, new.User)
+	diffValue("working_dir", old.WorkingDir, new.WorkingDir)
+	diffValue("hostname", old.Hostname, new.Hostname)
+	return details
+}
@@ -0,0 +1,355 @@
+package views
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	types "github.com/docker/docker/api/types"
+	container "github.com/docker/docker/api/types/container"
+	network "github.com/docker/docker/api/types/network"
+	volume "github.com/docker/docker/api/types/volume"
+	nat "github.com/docker/go-connections/nat"
+	db "github.com/mpoegel/mahogany/internal/db"
+	dbtest "github.com/mpoegel/mahogany/internal/db/dbtest"
+	sources "github.com/mpoegel/mahogany/pkg/mahogany/sources"
+)
+
+// fakeStackDocker is the part of docker that planning a stack looks at
+type fakeStackDocker struct {
+	sources.DockerI
+	networks   []network.Summary
+	volumes    []*volume.Volume
+	containers []types.Container
+	// the IDs of the containers and networks that were removed, in order
+	removed []string
+}
+
+func (d *fakeStackDocker) NetworkList(ctx context.Context, options network.ListOptions) ([]network.Summary, error) {
+	return d.networks, nil
+}
+
+func (d *fakeStackDocker) VolumeList(ctx context.Context, options volume.ListOptions) (volume.ListResponse, error) {
+	return volume.ListResponse{Volumes: d.volumes}, nil
+}
+
+func (d *fakeStackDocker) ContainerList(ctx context.Context, options container.ListOptions) ([]types.Container, error) {
+	return d.containers, nil
+}
+
+func (d *fakeStackDocker) ContainerRemove(ctx context.Context, containerID string, options container.RemoveOptions) error {
+	d.removed = append(d.removed, containerID)
+	return nil
+}
+
+func (d *fakeStackDocker) NetworkRemove(ctx context.Context, networkID string) error {
+	d.removed = append(d.removed, networkID)
+	return nil
+}
+
+const testStackCompose = `
+services:
+  web:
+    image: nginx:1.27
+    ports: ["8080:80", "127.0.0.1:8443:443"]
+    environment:
+      TZ: UTC
+      LEVEL: debug
+    volumes: [data:/var/lib/data, /srv/config:/config:ro, /cache]
+    depends_on: [db]
+    restart: on-failure:3
+  db:
+    image: postgres:16
+volumes:
+  data:
+`
+
+func newTestStackProject(t *testing.T, compose string) *stackProject {
+	t.Helper()
+	file, _, err := sources.ParseCompose([]byte(compose))
+	if err != nil {
+		t.Fatal(err)
+	}
+	project, err := newStackProject("app", file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return project
+}
+
+func TestStackProjectService(t *testing.T) {
+	project := newTestStackProject(t, testStackCompose)
+	if len(project.services) != 2 || project.services[0].name != "db" || project.services[1].name != "web" {
+		t.Fatalf("services are not in dependency order: %+v", project.services)
+	}
+	web := project.services[1]
+
+	if web.containerName != "app-web-1" {
+		t.Errorf("got container name %q", web.containerName)
+	}
+	if want := []string{"LEVEL=debug", "TZ=UTC"}; !reflect.DeepEqual(web.config.Env, want) {
+		t.Errorf("got env %q, want %q", web.config.Env, want)
+	}
+	if want := (nat.PortSet{"80/tcp": {}, "443/tcp": {}}); !reflect.DeepEqual(web.config.ExposedPorts, want) {
+		t.Errorf("got exposed ports %v, want %v", web.config.ExposedPorts, want)
+	}
+	wantBindings := nat.PortMap{
+		"80/tcp":  {{HostIP: "", HostPort: "8080"}},
+		"443/tcp": {{HostIP: "127.0.0.1", HostPort: "8443"}},
+	}
+	if !reflect.DeepEqual(web.hostConfig.PortBindings, wantBindings) {
+		t.Errorf("got port bindings %v, want %v", web.hostConfig.PortBindings, wantBindings)
+	}
+	if want := []string{"app_data:/var/lib/data", "/srv/config:/config:ro"}; !reflect.DeepEqual(web.hostConfig.Binds, want) {
+		t.Errorf("got binds %q, want %q", web.hostConfig.Binds, want)
+	}
+	if want := map[string]struct{}{"/cache": {}}; !reflect.DeepEqual(web.config.Volumes, want) {
+		t.Errorf("got anonymous volumes %v, want %v", web.config.Volumes, want)
+	}
+	if want := (container.RestartPolicy{Name: "on-failure", MaximumRetryCount: 3}); web.hostConfig.RestartPolicy != want {
+		t.Errorf("got restart policy %+v, want %+v", web.hostConfig.RestartPolicy, want)
+	}
+	if _, ok := web.networking.EndpointsConfig["app_default"]; !ok || web.hostConfig.NetworkMode != "app_default" {
+		t.Errorf("service is not on the default network: %v", web.networking.EndpointsConfig)
+	}
+	for key, want := range map[string]string{
+		sources.ComposeProjectLabel:  "app",
+		sources.ComposeServiceLabel:  "web",
+		sources.StackConfigHashLabel: web.hash,
+	} {
+		if got := web.config.Labels[key]; got != want {
+			t.Errorf("got label %s=%q, want %q", key, got, want)
+		}
+	}
+
+	again := newTestStackProject(t, testStackCompose)
+	if again.services[1].hash != web.hash {
+		t.Error("hash of the same service changed")
+	}
+	changed := newTestStackProject(t, strings.Replace(testStackCompose, "LEVEL: debug", "LEVEL: info", 1))
+	if changed.services[1].hash == web.hash {
+		t.Error("hash did not change with the environment")
+	}
+}
+
+func TestStackProjectServiceErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		service string
+		wantErr string
+	}{
+		{"bad port", "ports: [\"80:http\"]", "invalid containerPort"},
+		{"relative volume", "volumes: [./config:/config]", "relative paths are not supported"},
+		{"undeclared volume", "volumes: [logs:/var/log]", `volume "logs" is not declared under volumes`},
+		{"bad restart policy", "restart: sometimes", "restart"},
+		{"bad restart count", "restart: on-failure:many", "invalid restart policy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, _, err := sources.ParseCompose([]byte("services:\n  web:\n    image: nginx\n    " + tt.service + "\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = newStackProject("app", file)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("got error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// changeActions lists the changes as kind/name/action
+func changeActions(changes []StackChange) []string {
+	actions := make([]string, len(changes))
+	for i, change := range changes {
+		actions[i] = change.Kind + "/" + change.Name + "/" + change.Action
+	}
+	return actions
+}
+
+func TestPlanStack(t *testing.T) {
+	project := newTestStackProject(t, testStackCompose)
+	db, web := project.services[0], project.services[1]
+	stackLabels := func(service, hash string) map[string]string {
+		return map[string]string{
+			sources.ComposeProjectLabel:  "app",
+			sources.ComposeServiceLabel:  service,
+			sources.StackConfigHashLabel: hash,
+		}
+	}
+	deployed, _, err := sources.ParseCompose([]byte(strings.Replace(testStackCompose, "LEVEL: debug", "LEVEL: info", 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		docker   *fakeStackDocker
+		deployed *sources.ComposeFile
+		want     []string
+		details  map[string][]string
+	}{
+		{
+			name:   "new stack",
+			docker: &fakeStackDocker{},
+			want: []string{
+				"network/app_default/create",
+				"volume/app_data/create",
+				"container/app-db-1/create",
+				"container/app-web-1/create",
+			},
+		},
+		{
+			name: "up to date",
+			docker: &fakeStackDocker{
+				networks: []network.Summary{{ID: "n1", Name: "app_default"}},
+				volumes:  []*volume.Volume{{Name: "app_data"}},
+				containers: []types.Container{
+					{ID: "c1", Names: []string{"/app-db-1"}, State: "running", Labels: stackLabels("db", db.hash)},
+					{ID: "c2", Names: []string{"/app-web-1"}, State: "running", Labels: stackLabels("web", web.hash)},
+				},
+			},
+			want: []string{
+				"network/app_default/unchanged",
+				"volume/app_data/unchanged",
+				"container/app-db-1/unchanged",
+				"container/app-web-1/unchanged",
+			},
+		},
+		{
+			name: "changed",
+			docker: &fakeStackDocker{
+				networks: []network.Summary{
+					{ID: "n1", Name: "app_default", Labels: map[string]string{sources.ComposeProjectLabel: "app"}},
+					{ID: "n2", Name: "app_backend", Labels: map[string]string{sources.ComposeProjectLabel: "app"}},
+					{ID: "n3", Name: "other_backend", Labels: map[string]string{sources.ComposeProjectLabel: "other"}},
+				},
+				volumes: []*volume.Volume{{Name: "app_data"}},
+				containers: []types.Container{
+					{ID: "c1", Names: []string{"/app-db-1"}, State: "exited", Labels: stackLabels("db", db.hash)},
+					{ID: "c2", Names: []string{"/app-web-1"}, State: "running", Labels: stackLabels("web", "stale")},
+					{ID: "c3", Names: []string{"/app-web-2"}, State: "running", Labels: stackLabels("web", "stale")},
+					{ID: "c4", Names: []string{"/app-cache-1"}, State: "running", Labels: stackLabels("cache", "stale")},
+				},
+			},
+			deployed: deployed,
+			want: []string{
+				"network/app_default/unchanged",
+				"volume/app_data/unchanged",
+				"container/app-web-2/remove",
+				"container/app-cache-1/remove",
+				"container/app-db-1/start",
+				"container/app-web-1/recreate",
+				"network/app_backend/remove",
+			},
+			details: map[string][]string{
+				"app-web-1":   {"environment: LEVEL changed"},
+				"app-web-2":   {`service "web" has more than one container`},
+				"app-cache-1": {`service "cache" is no longer in the compose file`},
+			},
+		},
+		{
+			name: "changed without a deployed compose file",
+			docker: &fakeStackDocker{
+				networks: []network.Summary{{ID: "n1", Name: "app_default"}},
+				volumes:  []*volume.Volume{{Name: "app_data"}},
+				containers: []types.Container{
+					{ID: "c1", Names: []string{"/app-db-1"}, State: "running", Labels: stackLabels("db", db.hash)},
+					{ID: "c2", Names: []string{"/app-web-1"}, State: "running", Labels: stackLabels("web", "stale")},
+				},
+			},
+			want: []string{
+				"network/app_default/unchanged",
+				"volume/app_data/unchanged",
+				"container/app-db-1/unchanged",
+				"container/app-web-1/recreate",
+			},
+			details: map[string][]string{"app-web-1": {"the configuration changed"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &ViewFinder{docker: tt.docker}
+			changes, err := v.planStack(t.Context(), project, tt.deployed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := changeActions(changes); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got changes\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+			for _, change := range changes {
+				if want, ok := tt.details[change.Name]; ok && !reflect.DeepEqual(change.Details, want) {
+					t.Errorf("got details of %s %q, want %q", change.Name, change.Details, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPlanStackExternal(t *testing.T) {
+	project := newTestStackProject(t, `
+services:
+  web:
+    image: nginx
+    networks: [proxy]
+networks:
+  proxy:
+    external: true
+`)
+	v := &ViewFinder{docker: &fakeStackDocker{}}
+	_, err := v.planStack(t.Context(), project, nil)
+	if !errors.Is(err, ErrInvalidStack) {
+		t.Errorf("got error %v, want %v", err, ErrInvalidStack)
+	}
+
+	v = &ViewFinder{docker: &fakeStackDocker{networks: []network.Summary{{ID: "n1", Name: "proxy"}}}}
+	changes, err := v.planStack(t.Context(), project, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"network/proxy/unchanged", "container/app-web-1/create"}; !reflect.DeepEqual(changeActions(changes), want) {
+		t.Errorf("got changes %q, want %q", changeActions(changes), want)
+	}
+}
+
+func TestDownStack(t *testing.T) {
+	dbConn := dbtest.New(t)
+	query := db.New(dbConn)
+	compose := `
+services:
+  proxy:
+    image: caddy:2
+    depends_on: [web]
+  web:
+    image: nginx:1.27
+    depends_on: [db]
+  db:
+    image: postgres:16
+`
+	if err := query.SaveStack(t.Context(), db.SaveStackParams{Name: "app", Compose: compose}); err != nil {
+		t.Fatal(err)
+	}
+	stackLabels := func(service string) map[string]string {
+		return map[string]string{sources.ComposeProjectLabel: "app", sources.ComposeServiceLabel: service}
+	}
+	docker := &fakeStackDocker{
+		networks: []network.Summary{{ID: "n1", Name: "app_default"}},
+		containers: []types.Container{
+			{ID: "db", Labels: stackLabels("db")},
+			{ID: "proxy", Labels: stackLabels("proxy")},
+			{ID: "cache", Labels: stackLabels("cache")},
+			{ID: "web", Labels: stackLabels("web")},
+		},
+	}
+	v := &ViewFinder{docker: docker, db: dbConn, query: query, audit: sources.NewAuditor(dbConn, sources.NewEventBus())}
+
+	if view := v.DownStack(t.Context(), "app"); view.Err != nil {
+		t.Fatal(view.Err)
+	}
+	// the service that is no longer in the compose file goes first, then each service before what it depends on
+	if want := []string{"cache", "proxy", "web", "db", "n1"}; !reflect.DeepEqual(docker.removed, want) {
+		t.Errorf("got removed %q, want %q", docker.removed, want)
+	}
+}
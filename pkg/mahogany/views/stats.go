@@ -0,0 +1,162 @@
+package views
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	container "github.com/docker/docker/api/types/container"
+	units "github.com/docker/go-units"
+)
+
+// ContainerStats is a sample of the resources a container uses. IO counters are totals since the container started.
+type ContainerStats struct {
+	ID            string    `json:"id"`
+	ContainerName string    `json:"name"`
+	Read          time.Time `json:"read"`
+	CPUPercent    float64   `json:"cpuPercent"`
+	MemoryUsage   uint64    `json:"memoryUsage"`
+	MemoryLimit   uint64    `json:"memoryLimit"`
+	MemoryPercent float64   `json:"memoryPercent"`
+	NetworkRx     uint64    `json:"networkRx"`
+	NetworkTx     uint64    `json:"networkTx"`
+	BlockRead     uint64    `json:"blockRead"`
+	BlockWrite    uint64    `json:"blockWrite"`
+	PIDs          uint64    `json:"pids"`
+}
+
+func (s ContainerStats) HumanMemory() string {
+	return fmt.Sprintf("%s / %s", units.BytesSize(float64(s.MemoryUsage)), units.BytesSize(float64(s.MemoryLimit)))
+}
+
+func (s ContainerStats) HumanNetwork() string {
+	return fmt.Sprintf("%s / %s", units.HumanSize(float64(s.NetworkRx)), units.HumanSize(float64(s.NetworkTx)))
+}
+
+func (s ContainerStats) HumanBlockIO() string {
+	return fmt.Sprintf("%s / %s", units.HumanSize(float64(s.BlockRead)), units.HumanSize(float64(s.BlockWrite)))
+}
+
+// ShortID is the ID that the index page names the stats events of each container by
+func (s ContainerStats) ShortID() string { return s.ID[:min(len(s.ID), 12)] }
+
+// newContainerStats works out usage the same way as `docker stats` on linux
+func newContainerStats(stats container.StatsResponse) ContainerStats {
+	view := ContainerStats{
+		ID:            stats.ID,
+		ContainerName: strings.TrimPrefix(stats.Name, "/"),
+		Read:          stats.Read,
+		MemoryLimit:   stats.MemoryStats.Limit,
+		PIDs:          stats.PidsStats.Current,
+	}
+
+	cpuDelta := float64(stats.CPUStats.CPUUsage.TotalUsage) - float64(stats.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(stats.CPUStats.SystemUsage) - float64(stats.PreCPUStats.SystemUsage)
+	onlineCPUs := float64(stats.CPUStats.OnlineCPUs)
+	if onlineCPUs == 0 {
+		onlineCPUs = float64(len(stats.CPUStats.CPUUsage.PercpuUsage))
+	}
+	if cpuDelta > 0 && systemDelta > 0 {
+		view.CPUPercent = cpuDelta / systemDelta * onlineCPUs * 100
+	}
+
+	// the page cache counts towards usage but can be reclaimed, cgroup v1 and v2 name it differently
+	view.MemoryUsage = stats.MemoryStats.Usage
+	for _, key := range []string{"total_inactive_file", "inactive_file"} {
+		if cache, ok := stats.MemoryStats.Stats[key]; ok && cache < view.MemoryUsage {
+			view.MemoryUsage -= cache
+			break
+		}
+	}
+	if view.MemoryLimit > 0 {
+		view.MemoryPercent = float64(view.MemoryUsage) / float64(view.MemoryLimit) * 100
+	}
+
+	for _, net := range stats.Networks {
+		view.NetworkRx += net.RxBytes
+		view.NetworkTx += net.TxBytes
+	}
+	for _, entry := range stats.BlkioStats.IoServiceBytesRecursive {
+		switch strings.ToLower(entry.Op) {
+		case "read":
+			view.BlockRead += entry.Value
+		case "write":
+			view.BlockWrite += entry.Value
+		}
+	}
+	return view
+}
+
+// GetContainerStats samples the resources the container uses. Docker waits for a second sample to work out CPU usage,
+// so this takes about a second.
+func (v *ViewFinder) GetContainerStats(ctx context.Context, containerID string) (ContainerStats, error) {
+	resp, err := v.docker.ContainerStats(ctx, containerID, false)
+	if err != nil {
+		return ContainerStats{}, err
+	}
+	defer resp.Body.Close()
+	var stats container.StatsResponse
+	if err = json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return ContainerStats{}, err
+	}
+	return newContainerStats(stats), nil
+}
+
+// WatchContainerStats streams a sample of the resources each container uses about every second, or of every running
+// container if none are given. The channel is closed once the context is done or docker stops every stream.
+func (v *ViewFinder) WatchContainerStats(ctx context.Context, containerIDs ...string) (<-chan ContainerStats, error) {
+	if len(containerIDs) == 0 {
+		containers, err := v.docker.ContainerList(ctx, container.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range containers {
+			containerIDs = append(containerIDs, c.ID)
+		}
+	}
+
+	out := make(chan ContainerStats)
+	var wg sync.WaitGroup
+	for _, containerID := range containerIDs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := v.streamContainerStats(ctx, containerID, out); err != nil && ctx.Err() == nil {
+				slog.Warn("container stats stream ended", "container", containerID, "err", err)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out, nil
+}
+
+func (v *ViewFinder) streamContainerStats(ctx context.Context, containerID string, out chan<- ContainerStats) error {
+	resp, err := v.docker.ContainerStats(ctx, containerID, true)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var stats container.StatsResponse
+		if err := decoder.Decode(&stats); errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		select {
+		case out <- newContainerStats(stats):
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package views
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	db "github.com/mpoegel/mahogany/internal/db"
+)
+
+// APITokenPrefix marks API tokens so that they are easy to tell apart from other secrets, e.g. by secret scanners
+const APITokenPrefix = "mhg_"
+
+var ErrAPITokenNotFound = errors.New("api token not found or already revoked")
+
+// APITokenView is an API token without its secret, which is only shown when the token is created
+type APITokenView struct {
+	ID         int64     `json:"id"`
+	Name       string    `json:"name"`
+	Owner      string    `json:"owner"`
+	Scope      string    `json:"scope"`
+	CreatedAt  time.Time `json:"createdAt"`
+	ExpiresAt  time.Time `json:"expiresAt,omitzero"`
+	LastUsedAt time.Time `json:"lastUsedAt,omitzero"`
+	RevokedAt  time.Time `json:"revokedAt,omitzero"`
+	IsRevoked  bool      `json:"revoked"`
+	IsExpired  bool      `json:"expired"`
+}
+
+func newAPITokenView(token db.ListApiTokensRow, now time.Time) APITokenView {
+	view := APITokenView{
+		ID:        token.ID,
+		Name:      token.Name,
+		Owner:     token.Username,
+		Scope:     token.Scope,
+		CreatedAt: time.Unix(token.CreatedAt, 0).UTC(),
+		IsRevoked: token.RevokedAt.Valid,
+		IsExpired: token.ExpiresAt.Valid && now.Unix() > token.ExpiresAt.Int64,
+	}
+	if token.ExpiresAt.Valid {
+		view.ExpiresAt = time.Unix(token.ExpiresAt.Int64, 0).UTC()
+	}
+	if token.LastUsedAt.Valid {
+		view.LastUsedAt = time.Unix(token.LastUsedAt.Int64, 0).UTC()
+	}
+	if token.RevokedAt.Valid {
+		view.RevokedAt = time.Unix(token.RevokedAt.Int64, 0).UTC()
+	}
+	return view
+}
+
+// NewAPITokenView shows the secret of a token that was just created
+type NewAPITokenView struct {
+	APIToken APITokenView
+	Secret   string
+	Err      error
+}
+
+func (v *NewAPITokenView) Name() string         { return "new-api-token" }
+func (v *NewAPITokenView) Headers() http.Header { return http.Header{} }
+
+// HashAPIToken is how API tokens are stored, so that a leaked database does not leak usable tokens
+func HashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// TokensView is the page where users manage their API tokens
+type TokensView struct {
+	APITokens []APITokenView
+	Status    *StatusView
+	Err       error
+}
+
+func (v *TokensView) Name() string         { return "TokensView" }
+func (v *TokensView) Headers() http.Header { return http.Header{} }
+
+func (v *ViewFinder) GetAPITokens(ctx context.Context, ownerID int64) *TokensView {
+	view := &TokensView{Status: v.GetStatus(ctx)}
+	view.APITokens, view.Err = v.ListAPITokens(ctx, ownerID)
+	return view
+}
+
+// ListAPITokens lists the tokens of the owner, or the tokens of every user if the owner is zero
+func (v *ViewFinder) ListAPITokens(ctx context.Context, ownerID int64) ([]APITokenView, error) {
+	var tokens []db.ListApiTokensRow
+	if ownerID == 0 {
+		var err error
+		if tokens, err = v.query.ListApiTokens(ctx); err != nil {
+			return nil, err
+		}
+	} else {
+		owned, err := v.query.ListApiTokensForUser(ctx, ownerID)
+		if err != nil {
+			return nil, err
+		}
+		for _, token := range owned {
+			tokens = append(tokens, db.ListApiTokensRow(token))
+		}
+	}
+	now := time.Now()
+	views := make([]APITokenView, len(tokens))
+	for i, token := range tokens {
+		views[i] = newAPITokenView(token, now)
+	}
+	return views, nil
+}
+
+// CreateAPIToken issues a token that acts as the user, limited to the scope. A ttl of zero never expires.
+func (v *ViewFinder) CreateAPIToken(ctx context.Context, userID int64, username, name, scope string, ttl time.Duration) *NewAPITokenView {
+	view := &NewAPITokenView{
+		APIToken: APITokenView{
+			Name:  strings.TrimSpace(name),
+			Owner: username,
+			Scope: scope,
+		},
+	}
+	if len(view.APIToken.Name) == 0 {
+		view.Err = errors.New("token name is required")
+		return view
+	}
+	if ttl < 0 {
+		view.Err = errors.New("token expiry cannot be in the past")
+		return view
+	}
+
+	now := time.Now()
+	view.APIToken.CreatedAt = now.UTC().Truncate(time.Second)
+	params := db.AddApiTokenParams{
+		Name:      view.APIToken.Name,
+		UserID:    userID,
+		Scope:     scope,
+		CreatedAt: now.Unix(),
+	}
+	if ttl > 0 {
+		view.APIToken.ExpiresAt = now.Add(ttl).UTC().Truncate(time.Second)
+		params.ExpiresAt = sql.NullInt64{Int64: view.APIToken.ExpiresAt.Unix(), Valid: true}
+	}
+	token := APITokenPrefix + rand.Text()
+	params.TokenHash = HashAPIToken(token)
+
+	id, err := v.query.AddApiToken(ctx, params)
+	v.audit.Record(ctx, "api_token.create", view.APIToken.Name, map[string]string{"scope": scope}, err)
+	if err != nil {
+		slog.Error("create api token failed", "name", view.APIToken.Name, "err", err)
+		view.Err = err
+		return view
+	}
+	slog.Info("created api token", "name", view.APIToken.Name, "owner", username, "scope", scope)
+	view.APIToken.ID = id
+	view.Secret = token
+	return view
+}
+
+// RevokeAPIToken revokes a token of the owner, or of any user if the owner is zero
+func (v *ViewFinder) RevokeAPIToken(ctx context.Context, tokenID string, ownerID int64) *ActionResponseView {
+	view := &ActionResponseView{
+		IsSuccess: false,
+	}
+	err := v.revokeAPIToken(ctx, tokenID, ownerID)
+	v.audit.Record(ctx, "api_token.revoke", tokenID, nil, err)
+	if err != nil {
+		slog.Error("revoke api token failed", "id", tokenID, "err", err)
+		view.Err = err
+		view.Toast = fmt.Sprintf("Revoke failed: %v", err)
+		return view
+	}
+	view.IsSuccess = true
+	view.Toast = "Token revoked"
+	return view
+}
+
+func (v *ViewFinder) revokeAPIToken(ctx context.Context, tokenID string, ownerID int64) error {
+	id, err := strconv.ParseInt(tokenID, 10, 64)
+	if err != nil {
+		return err
+	}
+	revokedAt := sql.NullInt64{Int64: time.Now().Unix(), Valid: true}
+	var rows int64
+	if ownerID == 0 {
+		rows, err = v.query.RevokeApiToken(ctx, db.RevokeApiTokenParams{RevokedAt: revokedAt, ID: id})
+	} else {
+		// a token of another user looks the same as one that does not exist
+		rows, err = v.query.RevokeUserApiToken(ctx, db.RevokeUserApiTokenParams{RevokedAt: revokedAt, ID: id, UserID: ownerID})
+	}
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrAPITokenNotFound
+	}
+	slog.Info("revoked api token", "id", id)
+	return nil
+}
@@ -0,0 +1,329 @@
+package views
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	types "github.com/docker/docker/api/types"
+	container "github.com/docker/docker/api/types/container"
+	network "github.com/docker/docker/api/types/network"
+	errdefs "github.com/docker/docker/errdefs"
+)
+
+// an updated container's previous container is kept stopped under its name with this suffix until the next update
+const rollbackSuffix = "-rollback"
+
+// how long an updated container has to report healthy before the update counts as failed
+const updateHealthTimeout = 2 * time.Minute
+
+// how long an updated container without a healthcheck has to keep running to count as healthy
+const updateStartGrace = 5 * time.Second
+
+var (
+	ErrNoRollback  = errors.New("no previous container to roll back to")
+	ErrImagePinned = errors.New("image is pinned to a digest")
+)
+
+// ContainerUpdateView is the result of updating a container to the latest image of its tag
+type ContainerUpdateView struct {
+	// ID is the container after the update, which is the new container if it was recreated
+	ID            string `json:"id"`
+	ContainerName string `json:"name"`
+	Image         string `json:"image"`
+	PreviousImage string `json:"previousImageId"`
+	CurrentImage  string `json:"currentImageId"`
+	Updated       bool   `json:"updated"`
+	Health        string `json:"health,omitempty"`
+	CanRollback   bool   `json:"canRollback"`
+	IsSuccess     bool   `json:"-"`
+	Err           error  `json:"-"`
+}
+
+func (v *ContainerUpdateView) Name() string         { return "container-update" }
+func (v *ContainerUpdateView) Headers() http.Header { return http.Header{} }
+
+type ContainerRollbackView struct {
+	ID        string
+	IsSuccess bool
+	Err       error
+}
+
+func (v *ContainerRollbackView) Name() string         { return "container-rollback" }
+func (v *ContainerRollbackView) Headers() http.Header { return http.Header{} }
+
+// UpdateContainer pulls the image tag of the container and, if it now points at a different image, recreates the
+// container with the same config. The old container is stopped and renamed so that the update can be rolled back
+// if the new one turns out unhealthy. If the new container cannot be created or started, the old one is put back.
+func (v *ViewFinder) UpdateContainer(ctx context.Context, containerID string) *ContainerUpdateView {
+	view := &ContainerUpdateView{
+		ID: containerID,
+	}
+	view.Err = v.updateContainer(ctx, containerID, view)
+	view.IsSuccess = view.Err == nil
+	details := map[string]any{"image": view.Image, "from": view.PreviousImage, "to": view.CurrentImage}
+	v.audit.Record(ctx, "container.update", containerID, details, view.Err)
+	if view.Err != nil {
+		slog.Error("failed to update container", "id", containerID, "err", view.Err)
+	} else if view.Updated {
+		slog.Info("updated container", "name", view.ContainerName, "image", view.Image, "id", view.ID)
+	}
+	return view
+}
+
+func (v *ViewFinder) updateContainer(ctx context.Context, containerID string, view *ContainerUpdateView) error {
+	old, err := v.docker.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return err
+	}
+	view.ID = old.ID
+	view.ContainerName = strings.TrimPrefix(old.Name, "/")
+	view.Image = old.Config.Image
+	view.PreviousImage = old.Image
+	view.CanRollback = v.hasRollback(ctx, view.ContainerName)
+	if strings.Contains(old.Config.Image, "@") {
+		return fmt.Errorf("%w: %s", ErrImagePinned, old.Config.Image)
+	}
+
+	if err = v.pullImage(ctx, old.Config.Image, func(*PullProgress) {}); err != nil {
+		return err
+	}
+	pulled, _, err := v.docker.ImageInspectWithRaw(ctx, old.Config.Image)
+	if err != nil {
+		return err
+	}
+	view.CurrentImage = pulled.ID
+	if pulled.ID == old.Image {
+		return nil
+	}
+
+	// the old image tells which parts of the config the container inherited rather than were given
+	var imageConfig *container.Config
+	if previous, _, err := v.docker.ImageInspectWithRaw(ctx, old.Image); err == nil {
+		imageConfig = previous.Config
+	} else if errdefs.IsNotFound(err) {
+		slog.Warn("previous image is gone, keeping the whole config", "id", containerID, "image", old.Image)
+	} else {
+		return err
+	}
+
+	wasRunning := old.State != nil && old.State.Running
+	newID, err := v.recreateContainer(ctx, old, imageConfig, view.ContainerName)
+	if err != nil {
+		return err
+	}
+	view.ID = newID
+	view.Updated = true
+	view.CanRollback = true
+	if !wasRunning {
+		return nil
+	}
+	view.Health, err = v.waitHealthy(ctx, view.ID)
+	return err
+}
+
+// recreateContainer replaces the container with a new one made from the same config, keeping the old container
+// stopped under the rollback name. The new container is started if the old one was running.
+func (v *ViewFinder) recreateContainer(ctx context.Context, old types.ContainerJSON, imageConfig *container.Config, name string) (string, error) {
+	if previous, err := v.docker.ContainerInspect(ctx, name+rollbackSuffix); err == nil {
+		if err = v.docker.ContainerRemove(ctx, previous.ID, container.RemoveOptions{Force: true}); err != nil {
+			return "", err
+		}
+	} else if !errdefs.IsNotFound(err) {
+		return "", err
+	}
+
+	wasRunning := old.State != nil && old.State.Running
+	if wasRunning {
+		if err := v.docker.ContainerStop(ctx, old.ID, container.StopOptions{}); err != nil {
+			return "", err
+		}
+	}
+	if err := v.docker.ContainerRename(ctx, old.ID, name+rollbackSuffix); err != nil {
+		return "", errors.Join(err, v.restoreContainer(ctx, old.ID, "", wasRunning))
+	}
+
+	config, hostConfig, networkingConfig := recreateConfig(old, imageConfig)
+	resp, err := v.docker.ContainerCreate(ctx, config, hostConfig, networkingConfig, nil, name)
+	if err == nil && wasRunning {
+		err = v.docker.ContainerStart(ctx, resp.ID, container.StartOptions{})
+	}
+	if err != nil {
+		if len(resp.ID) > 0 {
+			err = errors.Join(err, v.docker.ContainerRemove(ctx, resp.ID, container.RemoveOptions{Force: true}))
+		}
+		return "", errors.Join(err, v.restoreContainer(ctx, old.ID, name, wasRunning))
+	}
+	for _, warning := range resp.Warnings {
+		slog.Warn("container created with warning", "id", resp.ID, "warning", warning)
+	}
+	return resp.ID, nil
+}
+
+// restoreContainer gives the old container back its name, if it was renamed, and starts it again if it was running
+func (v *ViewFinder) restoreContainer(ctx context.Context, containerID, name string, start bool) error {
+	if len(name) > 0 {
+		if err := v.docker.ContainerRename(ctx, containerID, name); err != nil {
+			return err
+		}
+	}
+	if start {
+		return v.docker.ContainerStart(ctx, containerID, container.StartOptions{})
+	}
+	return nil
+}
+
+// recreateConfig returns the configs to create a container just like the inspected one. Whatever the container
+// inherited from the config of its image is left out, so that the new image's defaults take its place. If the image
+// config is nil, the whole config is kept.
+func recreateConfig(old types.ContainerJSON, imageConfig *container.Config) (*container.Config, *container.HostConfig, *network.NetworkingConfig) {
+	config := *old.Config
+	if imageConfig != nil {
+		withoutImageDefaults(&config, imageConfig)
+	}
+	// docker sets the hostname to the short container ID unless one was given
+	if len(old.ID) >= 12 && config.Hostname == old.ID[:12] {
+		config.Hostname = ""
+	}
+	networkingConfig := &network.NetworkingConfig{
+		EndpointsConfig: map[string]*network.EndpointSettings{},
+	}
+	if old.NetworkSettings != nil {
+		for name, endpoint := range old.NetworkSettings.Networks {
+			networkingConfig.EndpointsConfig[name] = &network.EndpointSettings{
+				IPAMConfig: endpoint.IPAMConfig,
+				Links:      endpoint.Links,
+				Aliases: slices.DeleteFunc(slices.Clone(endpoint.Aliases), func(alias string) bool {
+					return strings.HasPrefix(old.ID, alias)
+				}),
+				DriverOpts: endpoint.DriverOpts,
+			}
+		}
+	}
+	return &config, old.HostConfig, networkingConfig
+}
+
+// withoutImageDefaults clears the parts of the config that are the same as in the image, which leaves only what was
+// given when the container was created
+func withoutImageDefaults(config, image *container.Config) {
+	if slices.Equal(config.Entrypoint, image.Entrypoint) {
+		config.Entrypoint = nil
+		// docker only fills in the cmd of the image if the entrypoint is not overridden
+		if slices.Equal(config.Cmd, image.Cmd) {
+			config.Cmd = nil
+		}
+	}
+	config.Env = slices.DeleteFunc(slices.Clone(config.Env), func(env string) bool {
+		return slices.Contains(image.Env, env)
+	})
+	config.Labels = withoutInherited(config.Labels, image.Labels)
+	config.ExposedPorts = withoutInherited(config.ExposedPorts, image.ExposedPorts)
+	config.Volumes = withoutInherited(config.Volumes, image.Volumes)
+	if config.WorkingDir == image.WorkingDir {
+		config.WorkingDir = ""
+	}
+	if config.User == image.User {
+		config.User = ""
+	}
+	if config.StopSignal == image.StopSignal {
+		config.StopSignal = ""
+	}
+	if reflect.DeepEqual(config.Healthcheck, image.Healthcheck) {
+		config.Healthcheck = nil
+	}
+}
+
+// withoutInherited returns the entries of the map that the image does not set to the same value
+func withoutInherited[M ~map[K]V, K, V comparable](m, image M) M {
+	kept := M{}
+	for key, value := range m {
+		if imageValue, ok := image[key]; !ok || imageValue != value {
+			kept[key] = value
+		}
+	}
+	return kept
+}
+
+// waitHealthy waits for the container's healthcheck to pass and returns its last health status. A container without
+// a healthcheck counts as healthy once it has kept running for updateStartGrace.
+func (v *ViewFinder) waitHealthy(ctx context.Context, containerID string) (string, error) {
+	start := time.Now()
+	for {
+		info, err := v.docker.ContainerInspect(ctx, containerID)
+		if err != nil {
+			return "", err
+		}
+		health := types.NoHealthcheck
+		if info.State.Health != nil {
+			health = info.State.Health.Status
+		}
+		switch {
+		case !info.State.Running:
+			return health, fmt.Errorf("container exited with code %d", info.State.ExitCode)
+		case health == types.Healthy:
+			return health, nil
+		case health == types.Unhealthy:
+			return health, errors.New("container is unhealthy")
+		case health == types.NoHealthcheck && time.Since(start) >= updateStartGrace:
+			return health, nil
+		case time.Since(start) >= updateHealthTimeout:
+			return health, fmt.Errorf("container did not become healthy within %s", updateHealthTimeout)
+		}
+		select {
+		case <-ctx.Done():
+			return health, ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func (v *ViewFinder) hasRollback(ctx context.Context, name string) bool {
+	_, err := v.docker.ContainerInspect(ctx, name+rollbackSuffix)
+	return err == nil
+}
+
+// RollbackContainer replaces the container with the one it was updated from, which is started if the container was
+// running
+func (v *ViewFinder) RollbackContainer(ctx context.Context, containerID string) *ContainerRollbackView {
+	view := &ContainerRollbackView{
+		ID: containerID,
+	}
+	view.Err = v.rollbackContainer(ctx, containerID, view)
+	view.IsSuccess = view.Err == nil
+	v.audit.Record(ctx, "container.rollback", containerID, nil, view.Err)
+	if view.Err != nil {
+		slog.Error("failed to roll back container", "id", containerID, "err", view.Err)
+	}
+	return view
+}
+
+func (v *ViewFinder) rollbackContainer(ctx context.Context, containerID string, view *ContainerRollbackView) error {
+	current, err := v.docker.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return err
+	}
+	name := strings.TrimPrefix(current.Name, "/")
+	previous, err := v.docker.ContainerInspect(ctx, name+rollbackSuffix)
+	if errdefs.IsNotFound(err) {
+		return ErrNoRollback
+	} else if err != nil {
+		return err
+	}
+	if err = v.docker.ContainerRemove(ctx, current.ID, container.RemoveOptions{Force: true}); err != nil {
+		return err
+	}
+	if err = v.docker.ContainerRename(ctx, previous.ID, name); err != nil {
+		return err
+	}
+	view.ID = previous.ID
+	if current.State == nil || !current.State.Running {
+		return nil
+	}
+	return v.docker.ContainerStart(ctx, previous.ID, container.StartOptions{})
+}
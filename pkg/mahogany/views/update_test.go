@@ -0,0 +1,121 @@
+package views
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	types "github.com/docker/docker/api/types"
+	container "github.com/docker/docker/api/types/container"
+	nat "github.com/docker/go-connections/nat"
+)
+
+func TestRecreateConfig(t *testing.T) {
+	image := &container.Config{
+		Cmd:          []string{"serve"},
+		Entrypoint:   []string{"/app"},
+		Env:          []string{"PATH=/usr/bin", "APP_VERSION=1.0"},
+		Labels:       map[string]string{"org.opencontainers.image.version": "1.0", "tier": "web"},
+		WorkingDir:   "/srv",
+		ExposedPorts: nat.PortSet{"8080/tcp": {}},
+		User:         "app",
+		Healthcheck:  &container.HealthConfig{Test: []string{"CMD", "/app", "health"}, Interval: 30 * time.Second},
+	}
+
+	tests := []struct {
+		name   string
+		config container.Config
+		image  *container.Config
+		want   container.Config
+	}{
+		{
+			name: "only image defaults",
+			config: container.Config{
+				Image:        "app:latest",
+				Cmd:          image.Cmd,
+				Entrypoint:   image.Entrypoint,
+				Env:          image.Env,
+				Labels:       image.Labels,
+				WorkingDir:   image.WorkingDir,
+				ExposedPorts: image.ExposedPorts,
+				User:         image.User,
+				Healthcheck:  image.Healthcheck,
+			},
+			image: image,
+			want: container.Config{
+				Image:        "app:latest",
+				Env:          []string{},
+				Labels:       map[string]string{},
+				ExposedPorts: nat.PortSet{},
+				Volumes:      map[string]struct{}{},
+			},
+		},
+		{
+			name: "overrides",
+			config: container.Config{
+				Image:        "app:latest",
+				Cmd:          []string{"serve", "--verbose"},
+				Entrypoint:   image.Entrypoint,
+				Env:          []string{"PATH=/usr/bin", "APP_VERSION=1.0", "TOKEN=secret"},
+				Labels:       map[string]string{"org.opencontainers.image.version": "1.0", "tier": "api"},
+				WorkingDir:   "/data",
+				ExposedPorts: nat.PortSet{"8080/tcp": {}, "9090/tcp": {}},
+				User:         image.User,
+				Healthcheck:  &container.HealthConfig{Test: []string{"NONE"}},
+			},
+			image: image,
+			want: container.Config{
+				Image:        "app:latest",
+				Cmd:          []string{"serve", "--verbose"},
+				Env:          []string{"TOKEN=secret"},
+				Labels:       map[string]string{"tier": "api"},
+				WorkingDir:   "/data",
+				ExposedPorts: nat.PortSet{"9090/tcp": {}},
+				Volumes:      map[string]struct{}{},
+				Healthcheck:  &container.HealthConfig{Test: []string{"NONE"}},
+			},
+		},
+		{
+			name: "entrypoint override keeps cmd",
+			config: container.Config{
+				Image:      "app:latest",
+				Cmd:        image.Cmd,
+				Entrypoint: []string{"/bin/sh", "-c"},
+			},
+			image: image,
+			want: container.Config{
+				Image:        "app:latest",
+				Cmd:          image.Cmd,
+				Entrypoint:   []string{"/bin/sh", "-c"},
+				Labels:       map[string]string{},
+				ExposedPorts: nat.PortSet{},
+				Volumes:      map[string]struct{}{},
+			},
+		},
+		{
+			name: "unknown image",
+			config: container.Config{
+				Image: "app:latest",
+				Cmd:   image.Cmd,
+				Env:   image.Env,
+			},
+			want: container.Config{
+				Image: "app:latest",
+				Cmd:   image.Cmd,
+				Env:   image.Env,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old := types.ContainerJSON{
+				ContainerJSONBase: &types.ContainerJSONBase{ID: "0123456789abcdef"},
+				Config:            &tt.config,
+			}
+			got, _, _ := recreateConfig(old, tt.image)
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("got config %+v\nwant %+v", *got, tt.want)
+			}
+		})
+	}
+}
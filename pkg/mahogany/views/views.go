@@ -3,6 +3,7 @@ package views
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"log/slog"
 	"net/http"
 	"time"
@@ -15,24 +16,35 @@ import (
 	_ "modernc.org/sqlite"
 )
 
+var (
+	ErrUnknownServiceAction = errors.New("unknown action")
+	ErrEmptyServiceName     = errors.New("service name cannot be empty")
+)
+
 type StatusView struct {
 	NumAgents           int
 	NumDevices          int64
 	RegistryConnected   bool
 	WatchtowerConnected bool
+	NumIncidents        int64
 }
 
+func (v *StatusView) Name() string         { return "status-bar" }
+func (v *StatusView) Headers() http.Header { return http.Header{} }
+
 type IndexView struct {
-	Status     *StatusView
-	Containers []types.Container
+	TemplateName string
+	Status       *StatusView
+	Containers   []types.Container
 }
 
-func (v *IndexView) Name() string         { return "IndexView" }
+func (v *IndexView) Name() string         { return v.TemplateName }
 func (v *IndexView) Headers() http.Header { return http.Header{} }
 
 type ActionResponseView struct {
 	IsSuccess bool
 	Toast     string
+	Err       error
 
 	headers http.Header
 }
@@ -46,11 +58,16 @@ type ViewFinder struct {
 	watchtower   sources.WatchtowerI
 	updateServer sources.UpdateServerI
 	deviceFinder vpn.VirtualNetworkClient
+	audit        *sources.Auditor
+	incidents    *sources.IncidentMonitor
+	notifier     *sources.Notifier
+	bus          *sources.EventBus
+	deployments  *deployments
 	db           *sql.DB
 	query        *db.Queries
 }
 
-func NewViewFinder(dockerHost, dockerVersion string, dbConn *sql.DB, updateServer sources.UpdateServerI) (*ViewFinder, error) {
+func NewViewFinder(dockerHost, dockerVersion string, dbConn *sql.DB, updateServer sources.UpdateServerI, notifier *sources.Notifier, bus *sources.EventBus) (*ViewFinder, error) {
 	docker, err := sources.NewDocker(dockerHost, dockerVersion)
 	if err != nil {
 		return nil, err
@@ -58,6 +75,11 @@ func NewViewFinder(dockerHost, dockerVersion string, dbConn *sql.DB, updateServe
 
 	vf := &ViewFinder{
 		docker:       docker,
+		audit:        sources.NewAuditor(dbConn, bus),
+		incidents:    sources.NewIncidentMonitor(docker, dbConn, notifier, bus),
+		notifier:     notifier,
+		bus:          bus,
+		deployments:  &deployments{pending: map[string]pendingDeploy{}},
 		db:           dbConn,
 		query:        db.New(dbConn),
 		updateServer: updateServer,
@@ -71,14 +93,19 @@ func NewViewFinder(dockerHost, dockerVersion string, dbConn *sql.DB, updateServe
 	return vf, nil
 }
 
-func (v *ViewFinder) GetIndex(ctx context.Context) *IndexView {
-	view := &IndexView{
-		Status: v.GetStatus(ctx),
-	}
+func (v *ViewFinder) ListContainers(ctx context.Context) ([]types.Container, error) {
 	opts := container.ListOptions{
 		All: true,
 	}
-	containerList, err := v.docker.ContainerList(ctx, opts)
+	return v.docker.ContainerList(ctx, opts)
+}
+
+func (v *ViewFinder) GetIndex(ctx context.Context) *IndexView {
+	view := &IndexView{
+		TemplateName: "IndexView",
+		Status:       v.GetStatus(ctx),
+	}
+	containerList, err := v.ListContainers(ctx)
 	if err != nil {
 		slog.Error("failed to get docker container list", "err", err)
 	} else {
@@ -88,6 +115,25 @@ func (v *ViewFinder) GetIndex(ctx context.Context) *IndexView {
 	return view
 }
 
+// GetContainerList is the container list without the rest of the page, for reloading it when a container changes
+func (v *ViewFinder) GetContainerList(ctx context.Context) *IndexView {
+	view := &IndexView{
+		TemplateName: "container-list",
+	}
+	containerList, err := v.ListContainers(ctx)
+	if err != nil {
+		slog.Error("failed to get docker container list", "err", err)
+	} else {
+		view.Containers = containerList
+	}
+	return view
+}
+
+// FollowDockerEvents publishes the changes to containers on the bus until the context is done
+func (v *ViewFinder) FollowDockerEvents(ctx context.Context) {
+	v.bus.FollowDocker(ctx, v.docker)
+}
+
 func (v *ViewFinder) GetStatus(ctx context.Context) *StatusView {
 	view := &StatusView{
 		NumAgents:           v.updateServer.GetNumConnections(),
@@ -96,5 +142,6 @@ func (v *ViewFinder) GetStatus(ctx context.Context) *StatusView {
 		WatchtowerConnected: v.watchtower.Status(ctx) == nil,
 	}
 	view.NumDevices, _ = v.query.CountDevices(ctx)
+	view.NumIncidents, _ = v.query.CountOpenIncidents(ctx)
 	return view
 }
@@ -0,0 +1,278 @@
+package views
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	types "github.com/docker/docker/api/types"
+	container "github.com/docker/docker/api/types/container"
+	filters "github.com/docker/docker/api/types/filters"
+	mount "github.com/docker/docker/api/types/mount"
+	volume "github.com/docker/docker/api/types/volume"
+	errdefs "github.com/docker/docker/errdefs"
+	units "github.com/docker/go-units"
+	sources "github.com/mpoegel/mahogany/pkg/mahogany/sources"
+)
+
+// the event that volume actions trigger so that the volume list reloads itself
+const volumesChangedEvent = "volumes-changed"
+
+// docker labels anonymous volumes since 23.0, older versions only give them a random name
+const anonymousVolumeLabel = "com.docker.volume.anonymous"
+
+var anonymousVolumeName = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// VolumeSummary is a volume along with the containers that mount it
+type VolumeSummary struct {
+	Name       string            `json:"name"`
+	Driver     string            `json:"driver"`
+	Mountpoint string            `json:"mountpoint"`
+	CreatedAt  string            `json:"createdAt"`
+	Labels     map[string]string `json:"labels"`
+	// Size is the disk usage in bytes, or -1 if docker does not know it
+	Size       int64          `json:"size"`
+	Anonymous  bool           `json:"anonymous"`
+	Stack      string         `json:"stack,omitempty"`
+	Containers []ContainerRef `json:"containers"`
+}
+
+func (s VolumeSummary) HumanSize() string {
+	if s.Size < 0 {
+		return "-"
+	}
+	return units.HumanSize(float64(s.Size))
+}
+
+type VolumesView struct {
+	TemplateName string
+	Volumes      []VolumeSummary
+	IsSuccess    bool
+	Err          error
+	Status       *StatusView
+}
+
+func (v *VolumesView) Name() string         { return v.TemplateName }
+func (v *VolumesView) Headers() http.Header { return http.Header{} }
+
+// VolumeCreateView is the outcome of creating a volume, shown as a toast
+type VolumeCreateView struct {
+	Volume    VolumeSummary
+	IsSuccess bool
+	Toast     string
+	Err       error
+
+	headers http.Header
+}
+
+func (v *VolumeCreateView) Name() string         { return "toast" }
+func (v *VolumeCreateView) Headers() http.Header { return v.headers }
+
+func (v *ViewFinder) GetVolumes(ctx context.Context) *VolumesView {
+	view := &VolumesView{
+		TemplateName: "VolumesView",
+		Status:       v.GetStatus(ctx),
+	}
+	view.Volumes, view.Err = v.ListVolumes(ctx)
+	view.IsSuccess = view.Err == nil
+	if view.Err != nil {
+		slog.Error("failed to list volumes", "err", view.Err)
+	}
+	return view
+}
+
+// GetVolumeList is the volume list without the rest of the page, for reloading it after an action
+func (v *ViewFinder) GetVolumeList(ctx context.Context) *VolumesView {
+	view := &VolumesView{
+		TemplateName: "volume-list",
+	}
+	view.Volumes, view.Err = v.ListVolumes(ctx)
+	view.IsSuccess = view.Err == nil
+	return view
+}
+
+// ListVolumes returns every volume by name with its disk usage and the containers that mount it, stopped ones
+// included
+func (v *ViewFinder) ListVolumes(ctx context.Context) ([]VolumeSummary, error) {
+	volumes, err := v.docker.VolumeList(ctx, volume.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	containers, err := v.docker.ContainerList(ctx, container.ListOptions{All: true})
+	if err != nil {
+		return nil, err
+	}
+	users := map[string][]ContainerRef{}
+	for _, c := range containers {
+		for _, m := range c.Mounts {
+			if m.Type == mount.TypeVolume {
+				users[m.Name] = append(users[m.Name], newContainerRef(c))
+			}
+		}
+	}
+	// sizing volumes walks their files, so the list is still shown if it fails
+	sizes := map[string]int64{}
+	usage, err := v.docker.DiskUsage(ctx, types.DiskUsageOptions{Types: []types.DiskUsageObject{types.VolumeObject}})
+	if err != nil {
+		slog.Warn("cannot get volume disk usage", "err", err)
+	}
+	for _, vol := range usage.Volumes {
+		if vol.UsageData != nil {
+			sizes[vol.Name] = vol.UsageData.Size
+		}
+	}
+
+	summaries := make([]VolumeSummary, 0, len(volumes.Volumes))
+	for _, vol := range volumes.Volumes {
+		summary := VolumeSummary{
+			Name:       vol.Name,
+			Driver:     vol.Driver,
+			Mountpoint: vol.Mountpoint,
+			CreatedAt:  vol.CreatedAt,
+			Labels:     vol.Labels,
+			Size:       -1,
+			Anonymous:  anonymousVolumeName.MatchString(vol.Name),
+			Stack:      vol.Labels[sources.ComposeProjectLabel],
+			Containers: users[vol.Name],
+		}
+		if _, ok := vol.Labels[anonymousVolumeLabel]; ok {
+			summary.Anonymous = true
+		}
+		if size, ok := sizes[vol.Name]; ok {
+			summary.Size = size
+		}
+		if summary.Containers == nil {
+			summary.Containers = []ContainerRef{}
+		}
+		summaries = append(summaries, summary)
+	}
+	slices.SortFunc(summaries, func(a, b VolumeSummary) int { return strings.Compare(a.Name, b.Name) })
+	return summaries, nil
+}
+
+func volumesChanged() http.Header {
+	return http.Header{"HX-Trigger": []string{volumesChangedEvent}}
+}
+
+// CreateVolume creates a named volume with the local driver unless another is given
+func (v *ViewFinder) CreateVolume(ctx context.Context, name, driver string, labels map[string]string) *VolumeCreateView {
+	view := &VolumeCreateView{
+		IsSuccess: false,
+	}
+	opts := volume.CreateOptions{
+		Name:   strings.TrimSpace(name),
+		Driver: strings.TrimSpace(driver),
+		Labels: labels,
+	}
+	if len(opts.Name) == 0 {
+		view.Err = errdefs.InvalidParameter(errors.New("volume name is required"))
+	}
+	var vol volume.Volume
+	if view.Err == nil {
+		vol, view.Err = v.docker.VolumeCreate(ctx, opts)
+		v.audit.Record(ctx, "volume.create", opts.Name, opts, view.Err)
+	}
+	if view.Err != nil {
+		view.Toast = fmt.Sprintf("Failed to create volume: %v", view.Err)
+		return view
+	}
+	view.IsSuccess = true
+	view.Toast = fmt.Sprintf("Created volume %s", vol.Name)
+	view.Volume = VolumeSummary{
+		Name:       vol.Name,
+		Driver:     vol.Driver,
+		Mountpoint: vol.Mountpoint,
+		CreatedAt:  vol.CreatedAt,
+		Labels:     vol.Labels,
+		Size:       -1,
+		Containers: []ContainerRef{},
+	}
+	view.headers = volumesChanged()
+	return view
+}
+
+// containersUsing lists the containers, stopped ones included, that match the filter
+func (v *ViewFinder) containersUsing(ctx context.Context, filter filters.KeyValuePair) ([]ContainerRef, error) {
+	opts := container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filter),
+	}
+	containers, err := v.docker.ContainerList(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	refs := make([]ContainerRef, len(containers))
+	for i, c := range containers {
+		refs[i] = newContainerRef(c)
+	}
+	return refs, nil
+}
+
+// RemoveVolume deletes the volume unless a container mounts it. Docker only refuses to for containers that exist, so
+// stopped containers are checked too.
+func (v *ViewFinder) RemoveVolume(ctx context.Context, name string) *ActionResponseView {
+	view := &ActionResponseView{
+		IsSuccess: false,
+	}
+	users, err := v.containersUsing(ctx, filters.Arg("volume", name))
+	if err == nil && len(users) > 0 {
+		err = errdefs.Conflict(fmt.Errorf("volume %s is used by %s", name, containerNames(users)))
+	}
+	if err == nil {
+		err = v.docker.VolumeRemove(ctx, name, false)
+	}
+	v.audit.Record(ctx, "volume.remove", name, nil, err)
+	if err != nil {
+		view.Err = err
+		view.Toast = fmt.Sprintf("Failed to remove volume: %v", err)
+		return view
+	}
+	view.IsSuccess = true
+	view.Toast = fmt.Sprintf("Removed volume %s", name)
+	view.headers = volumesChanged()
+	return view
+}
+
+// PruneVolumes removes the anonymous volumes that no container mounts. Named volumes usually hold data that outlives
+// their containers, like those of a stack that is down, so they are only removed if all is set.
+func (v *ViewFinder) PruneVolumes(ctx context.Context, all bool) *ActionResponseView {
+	view := &ActionResponseView{
+		IsSuccess: false,
+	}
+	volumes, err := v.ListVolumes(ctx)
+	removed := []string{}
+	var reclaimed int64
+	if err == nil {
+		var errs []error
+		for _, vol := range volumes {
+			if len(vol.Containers) > 0 || (!vol.Anonymous && !all) {
+				continue
+			}
+			// without force, docker still refuses if a container was created since the volumes were listed
+			if err := v.docker.VolumeRemove(ctx, vol.Name, false); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			removed = append(removed, vol.Name)
+			reclaimed += max(vol.Size, 0)
+		}
+		err = errors.Join(errs...)
+	}
+	v.audit.Record(ctx, "volume.prune", "volumes", map[string]any{"all": all, "removed": removed}, err)
+	if len(removed) > 0 {
+		view.headers = volumesChanged()
+	}
+	if err != nil {
+		view.Err = err
+		view.Toast = fmt.Sprintf("Pruned %d volumes, failed to remove others: %v", len(removed), err)
+		return view
+	}
+	view.IsSuccess = true
+	view.Toast = fmt.Sprintf("Pruned %d volumes, reclaimed %s", len(removed), units.HumanSize(float64(reclaimed)))
+	return view
+}
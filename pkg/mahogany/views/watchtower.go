@@ -23,7 +23,10 @@ func (v *ViewFinder) WatchtowerUpdate(ctx context.Context) *ActionResponseView {
 	view := &ActionResponseView{
 		IsSuccess: false,
 	}
-	if err := v.watchtower.Update(ctx); err != nil {
+	err := v.watchtower.Update(ctx)
+	v.audit.Record(ctx, "watchtower.update", "watchtower", nil, err)
+	if err != nil {
+		view.Err = err
 		view.Toast = fmt.Sprintf("Update request failed: %v", err)
 	} else {
 		view.IsSuccess = true
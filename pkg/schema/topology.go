@@ -1,21 +1,39 @@
 package schema
 
 import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
 	"os"
 	"regexp"
+	"time"
 
 	validator "github.com/go-playground/validator/v10"
 	toml "github.com/pelletier/go-toml/v2"
 )
 
+// minisign keys are the signature algorithm, the key ID and the ed25519 key
+const (
+	minisignAlgorithm = "Ed"
+	minisignKeyIDSize = 8
+)
+
 type Topology struct {
 	Baseline     []Package      `toml:"baseline"`
 	HostPackages []HostPackages `toml:"host_packages"`
 }
 
 type Package struct {
-	ID             string `toml:"id" validate:"required"`
-	InstallCommand string `toml:"install_command"`
+	ID             string            `toml:"id" validate:"required"`
+	InstallCommand string            `toml:"install_command"`
+	InstallTimeout string            `toml:"install_timeout"`
+	WorkingDir     string            `toml:"working_dir"`
+	Environment    map[string]string `toml:"environment"`
+	PublicKeys     []string          `toml:"public_keys"`
+
+	// ed25519 keys, at least one of which must have signed each asset if any are declared
+	Keys    []PublicKey   `toml:"-"`
+	Timeout time.Duration `toml:"-"`
 
 	GithubPackage *GithubPackage `toml:"github_package" validate:"required_without=AptPackage DockerPackage LocalPackage"`
 	AptPackage    *AptPackage    `toml:"apt_package" validate:"required_without=GithubPackage DockerPackage LocalPackage"`
@@ -23,6 +41,12 @@ type Package struct {
 	LocalPackage  *LocalPackage  `toml:"local_package" validate:"required_without=GithubPackage AptPackage DockerPackage"`
 }
 
+// PublicKey is a key that release assets are signed with. Keys of minisign have an ID that their signatures name.
+type PublicKey struct {
+	ID  []byte
+	Key ed25519.PublicKey
+}
+
 type HostPackages struct {
 	HostName string    `toml:"hostname" validate:"required"`
 	Packages []Package `toml:"packages" validate:"required"`
@@ -98,6 +122,12 @@ func ReadTopology(filename string) (*Topology, error) {
 				topo.Baseline[i].GithubPackage.Regex = re
 			}
 		}
+		if topo.Baseline[i].Keys, err = parsePublicKeys(pack.PublicKeys); err != nil {
+			return nil, fmt.Errorf("package %s: %w", pack.ID, err)
+		}
+		if topo.Baseline[i].Timeout, err = parseTimeout(pack.InstallTimeout); err != nil {
+			return nil, fmt.Errorf("package %s: %w", pack.ID, err)
+		}
 	}
 	for i, host := range topo.HostPackages {
 		for k, pack := range host.Packages {
@@ -108,8 +138,65 @@ func ReadTopology(filename string) (*Topology, error) {
 					topo.HostPackages[i].Packages[k].GithubPackage.Regex = re
 				}
 			}
+			if topo.HostPackages[i].Packages[k].Keys, err = parsePublicKeys(pack.PublicKeys); err != nil {
+				return nil, fmt.Errorf("package %s on %s: %w", pack.ID, host.HostName, err)
+			}
+			if topo.HostPackages[i].Packages[k].Timeout, err = parseTimeout(pack.InstallTimeout); err != nil {
+				return nil, fmt.Errorf("package %s on %s: %w", pack.ID, host.HostName, err)
+			}
 		}
 	}
 
 	return &topo, nil
 }
+
+// FindPackage looks up a package installed on the host, preferring host packages over the baseline
+func (t *Topology) FindPackage(hostname, id string) *Package {
+	for i, host := range t.HostPackages {
+		if host.HostName != hostname {
+			continue
+		}
+		for k, pack := range host.Packages {
+			if pack.ID == id {
+				return &t.HostPackages[i].Packages[k]
+			}
+		}
+	}
+	for i, pack := range t.Baseline {
+		if pack.ID == id {
+			return &t.Baseline[i]
+		}
+	}
+	return nil
+}
+
+func parseTimeout(rawTimeout string) (time.Duration, error) {
+	if len(rawTimeout) == 0 {
+		return 0, nil
+	}
+	timeout, err := time.ParseDuration(rawTimeout)
+	if err != nil {
+		return 0, fmt.Errorf("invalid install timeout: %w", err)
+	}
+	return timeout, nil
+}
+
+// parsePublicKeys reads base64 keys, either a bare ed25519 key or a minisign key as printed by minisign -G
+func parsePublicKeys(rawKeys []string) ([]PublicKey, error) {
+	keys := make([]PublicKey, 0, len(rawKeys))
+	for _, rawKey := range rawKeys {
+		key, err := base64.StdEncoding.DecodeString(rawKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid public key: %w", err)
+		}
+		switch {
+		case len(key) == ed25519.PublicKeySize:
+			keys = append(keys, PublicKey{Key: ed25519.PublicKey(key)})
+		case len(key) == 2+minisignKeyIDSize+ed25519.PublicKeySize && string(key[:2]) == minisignAlgorithm:
+			keys = append(keys, PublicKey{ID: key[2 : 2+minisignKeyIDSize], Key: ed25519.PublicKey(key[2+minisignKeyIDSize:])})
+		default:
+			return nil, fmt.Errorf("invalid public key size %d", len(key))
+		}
+	}
+	return keys, nil
+}
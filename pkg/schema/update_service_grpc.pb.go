@@ -19,9 +19,11 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	UpdateService_RegisterManifest_FullMethodName = "/sequoia.UpdateService/RegisterManifest"
-	UpdateService_ReleaseStream_FullMethodName    = "/sequoia.UpdateService/ReleaseStream"
-	UpdateService_ServicesStream_FullMethodName   = "/sequoia.UpdateService/ServicesStream"
+	UpdateService_RegisterManifest_FullMethodName   = "/sequoia.UpdateService/RegisterManifest"
+	UpdateService_ReleaseStream_FullMethodName      = "/sequoia.UpdateService/ReleaseStream"
+	UpdateService_ServicesStream_FullMethodName     = "/sequoia.UpdateService/ServicesStream"
+	UpdateService_ReportInstallation_FullMethodName = "/sequoia.UpdateService/ReportInstallation"
+	UpdateService_Enroll_FullMethodName             = "/sequoia.UpdateService/Enroll"
 )
 
 // UpdateServiceClient is the client API for UpdateService service.
@@ -31,6 +33,8 @@ type UpdateServiceClient interface {
 	RegisterManifest(ctx context.Context, in *RegisterManifestRequest, opts ...grpc.CallOption) (*RegisterManifestResponse, error)
 	ReleaseStream(ctx context.Context, in *ReleaseStreamRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ReleaseStreamResponse], error)
 	ServicesStream(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[ServicesStreamRequest, ServicesStreamResponse], error)
+	ReportInstallation(ctx context.Context, in *ReportInstallationRequest, opts ...grpc.CallOption) (*ReportInstallationResponse, error)
+	Enroll(ctx context.Context, in *EnrollRequest, opts ...grpc.CallOption) (*EnrollResponse, error)
 }
 
 type updateServiceClient struct {
@@ -83,6 +87,26 @@ func (c *updateServiceClient) ServicesStream(ctx context.Context, opts ...grpc.C
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type UpdateService_ServicesStreamClient = grpc.BidiStreamingClient[ServicesStreamRequest, ServicesStreamResponse]
 
+func (c *updateServiceClient) ReportInstallation(ctx context.Context, in *ReportInstallationRequest, opts ...grpc.CallOption) (*ReportInstallationResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ReportInstallationResponse)
+	err := c.cc.Invoke(ctx, UpdateService_ReportInstallation_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *updateServiceClient) Enroll(ctx context.Context, in *EnrollRequest, opts ...grpc.CallOption) (*EnrollResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(EnrollResponse)
+	err := c.cc.Invoke(ctx, UpdateService_Enroll_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // UpdateServiceServer is the server API for UpdateService service.
 // All implementations must embed UnimplementedUpdateServiceServer
 // for forward compatibility.
@@ -90,6 +114,8 @@ type UpdateServiceServer interface {
 	RegisterManifest(context.Context, *RegisterManifestRequest) (*RegisterManifestResponse, error)
 	ReleaseStream(*ReleaseStreamRequest, grpc.ServerStreamingServer[ReleaseStreamResponse]) error
 	ServicesStream(grpc.BidiStreamingServer[ServicesStreamRequest, ServicesStreamResponse]) error
+	ReportInstallation(context.Context, *ReportInstallationRequest) (*ReportInstallationResponse, error)
+	Enroll(context.Context, *EnrollRequest) (*EnrollResponse, error)
 	mustEmbedUnimplementedUpdateServiceServer()
 }
 
@@ -109,6 +135,12 @@ func (UnimplementedUpdateServiceServer) ReleaseStream(*ReleaseStreamRequest, grp
 func (UnimplementedUpdateServiceServer) ServicesStream(grpc.BidiStreamingServer[ServicesStreamRequest, ServicesStreamResponse]) error {
 	return status.Errorf(codes.Unimplemented, "method ServicesStream not implemented")
 }
+func (UnimplementedUpdateServiceServer) ReportInstallation(context.Context, *ReportInstallationRequest) (*ReportInstallationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReportInstallation not implemented")
+}
+func (UnimplementedUpdateServiceServer) Enroll(context.Context, *EnrollRequest) (*EnrollResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Enroll not implemented")
+}
 func (UnimplementedUpdateServiceServer) mustEmbedUnimplementedUpdateServiceServer() {}
 func (UnimplementedUpdateServiceServer) testEmbeddedByValue()                       {}
 
@@ -166,6 +198,42 @@ func _UpdateService_ServicesStream_Handler(srv interface{}, stream grpc.ServerSt
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type UpdateService_ServicesStreamServer = grpc.BidiStreamingServer[ServicesStreamRequest, ServicesStreamResponse]
 
+func _UpdateService_ReportInstallation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReportInstallationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UpdateServiceServer).ReportInstallation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UpdateService_ReportInstallation_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UpdateServiceServer).ReportInstallation(ctx, req.(*ReportInstallationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UpdateService_Enroll_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EnrollRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UpdateServiceServer).Enroll(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UpdateService_Enroll_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UpdateServiceServer).Enroll(ctx, req.(*EnrollRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // UpdateService_ServiceDesc is the grpc.ServiceDesc for UpdateService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -177,6 +245,14 @@ var UpdateService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "RegisterManifest",
 			Handler:    _UpdateService_RegisterManifest_Handler,
 		},
+		{
+			MethodName: "ReportInstallation",
+			Handler:    _UpdateService_ReportInstallation_Handler,
+		},
+		{
+			MethodName: "Enroll",
+			Handler:    _UpdateService_Enroll_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{